@@ -0,0 +1,141 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package capture implements a file format for recording the decrypted nodes that a client sends and receives.
+//
+// Captures are newline-delimited JSON: the first line is a Header, and every following line is one node
+// along with the time it was sent or received. Nodes are stored in the binary XML format exactly as they
+// were on the wire (after the noise layer), so decoding them gives the same attribute types as live traffic.
+//
+// To record a capture, set whatsmeow.Client.CaptureWriter. Captures can be fed back into a client with
+// whatsmeow.Client.Replay.
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// FormatVersion is the current version of the capture format.
+const FormatVersion = 1
+
+const formatName = "whatsmeow-capture"
+
+// Errors returned when reading captures.
+var (
+	ErrNotCapture         = errors.New("file is not a whatsmeow capture")
+	ErrUnsupportedVersion = errors.New("unsupported capture format version")
+)
+
+// Direction is the direction of a captured node.
+type Direction string
+
+const (
+	DirectionSend Direction = "send"
+	DirectionRecv Direction = "recv"
+)
+
+// Header is the first line of a capture file.
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// JID is the JID of the device that recorded the capture, if known.
+	JID types.JID `json:"jid"`
+}
+
+// Entry is a single captured node.
+type Entry struct {
+	Time      time.Time `json:"t"`
+	Direction Direction `json:"dir"`
+	// Data is the node encoded in the binary XML format, including the leading flag byte.
+	Data []byte `json:"data"`
+}
+
+// Node decodes the node in the entry.
+func (entry *Entry) Node() (*waBinary.Node, error) {
+	unpacked, err := waBinary.Unpack(entry.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack node: %w", err)
+	}
+	return waBinary.Unmarshal(unpacked)
+}
+
+// Writer writes nodes into a capture. It's safe for concurrent use.
+type Writer struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+}
+
+// NewWriter writes a capture header to the given writer and returns a Writer for appending nodes.
+func NewWriter(w io.Writer, jid types.JID) (*Writer, error) {
+	enc := json.NewEncoder(w)
+	err := enc.Encode(&Header{
+		Format:  formatName,
+		Version: FormatVersion,
+		Created: time.Now(),
+		JID:     jid,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	return &Writer{enc: enc}, nil
+}
+
+// WriteFrame appends an already encoded node to the capture.
+func (cw *Writer) WriteFrame(dir Direction, ts time.Time, data []byte) error {
+	cw.lock.Lock()
+	defer cw.lock.Unlock()
+	return cw.enc.Encode(&Entry{Time: ts, Direction: dir, Data: data})
+}
+
+// WriteNode encodes the given node and appends it to the capture.
+func (cw *Writer) WriteNode(dir Direction, ts time.Time, node waBinary.Node) error {
+	data, err := waBinary.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node: %w", err)
+	}
+	return cw.WriteFrame(dir, ts, data)
+}
+
+// Reader reads nodes from a capture.
+type Reader struct {
+	Header Header
+	dec    *json.Decoder
+}
+
+// NewReader reads the capture header from the given reader and returns a Reader for the nodes.
+func NewReader(r io.Reader) (*Reader, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var header Header
+	err := dec.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	} else if header.Format != formatName {
+		return nil, ErrNotCapture
+	} else if header.Version > FormatVersion || header.Version < 1 {
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, header.Version)
+	}
+	return &Reader{Header: header, dec: dec}, nil
+}
+
+// Next reads the next entry from the capture. It returns io.EOF when there are no more entries.
+func (cr *Reader) Next() (*Entry, error) {
+	var entry Entry
+	err := cr.dec.Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capture_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/capture"
+	"go.mau.fi/whatsmeow/store/memstore"
+	"go.mau.fi/whatsmeow/testserver"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestRecord(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	srv, err := testserver.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer srv.Close()
+	alice := srv.NewAccount("10000000001")
+
+	var buf bytes.Buffer
+	cli := srv.NewClient(nil)
+	cli.CaptureWriter, err = capture.NewWriter(&buf, types.EmptyJID)
+	if err != nil {
+		t.Fatalf("Failed to create capture writer: %v", err)
+	}
+	qrChan, _ := cli.GetQRChannel(ctx)
+	if err = cli.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer cli.Disconnect()
+	jid, err := alice.Pair(ctx, (<-qrChan).Code)
+	if err != nil {
+		t.Fatalf("Failed to pair: %v", err)
+	} else if err = srv.WaitConnected(ctx, jid); err != nil {
+		t.Fatalf("Client didn't reconnect: %v", err)
+	}
+	cli.Disconnect()
+
+	reader, err := capture.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read capture header: %v", err)
+	}
+	seen := make(map[capture.Direction]map[string]bool)
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatalf("Failed to read capture entry: %v", err)
+		}
+		node, err := entry.Node()
+		if err != nil {
+			t.Fatalf("Failed to decode captured node: %v", err)
+		}
+		if seen[entry.Direction] == nil {
+			seen[entry.Direction] = make(map[string]bool)
+		}
+		seen[entry.Direction][node.Tag] = true
+	}
+	if !seen[capture.DirectionRecv]["success"] {
+		t.Errorf("Capture didn't contain received success node")
+	}
+	if !seen[capture.DirectionSend]["iq"] {
+		t.Errorf("Capture didn't contain sent iq nodes")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := capture.NewWriter(&buf, types.EmptyJID)
+	if err != nil {
+		t.Fatalf("Failed to create capture writer: %v", err)
+	}
+	user := types.NewJID("10000000002", types.DefaultUserServer)
+	_ = writer.WriteNode(capture.DirectionSend, time.Now(), waBinary.Node{Tag: "presence"})
+	_ = writer.WriteNode(capture.DirectionRecv, time.Now(), waBinary.Node{
+		Tag: "notification",
+		Attrs: waBinary.Attrs{
+			"id":   "1234",
+			"from": user,
+			"type": "picture",
+			"t":    time.Now().Unix(),
+		},
+		Content: []waBinary.Node{{
+			Tag:   "set",
+			Attrs: waBinary.Attrs{"jid": user, "id": "5678"},
+		}},
+	})
+
+	cli := whatsmeow.NewClient(memstore.New(nil).NewDevice(), nil)
+	var pictures []*events.Picture
+	cli.AddEventHandler(func(evt any) {
+		if pic, ok := evt.(*events.Picture); ok {
+			pictures = append(pictures, pic)
+		}
+	})
+	reader, err := capture.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read capture header: %v", err)
+	}
+	if err = cli.Replay(context.Background(), reader); err != nil {
+		t.Fatalf("Failed to replay capture: %v", err)
+	}
+	if len(pictures) != 1 {
+		t.Fatalf("Expected 1 picture event, got %d", len(pictures))
+	} else if pictures[0].JID != user || pictures[0].PictureID != "5678" {
+		t.Errorf("Unexpected picture event %+v", pictures[0])
+	}
+}
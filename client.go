@@ -9,6 +9,7 @@ package whatsmeow
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime/debug"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,6 +30,7 @@ import (
 
 	"go.mau.fi/whatsmeow/appstate"
 	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/capture"
 	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/proto/waWa6"
 	"go.mau.fi/whatsmeow/proto/waWeb"
@@ -74,15 +77,75 @@ type Client struct {
 	InitialAutoReconnect  bool
 	LastSuccessfulConnect time.Time
 	AutoReconnectErrors   int
+	disconnectCause       atomic.Pointer[ReconnectCause]
+
+	connState            types.ConnectionState
+	connStateLock        sync.Mutex
+	connStateEvents      []*events.ConnectionStateChange
+	connStateDispatching bool
+
 	// AutoReconnectHook is called when auto-reconnection fails. If the function returns false,
 	// the client will not attempt to reconnect. The number of retries can be read from AutoReconnectErrors.
 	AutoReconnectHook func(error) bool
+	// ReconnectPolicy decides how long to wait between automatic reconnection attempts and when to give up.
+	// The default (nil) is LinearReconnectPolicy. Each scheduled attempt emits an events.ReconnectScheduled.
+	ReconnectPolicy ReconnectPolicy
 	// If SynchronousAck is set, acks for messages will only be sent after all event handlers return.
 	SynchronousAck             bool
 	EnableDecryptedEventBuffer bool
-	lastDecryptedBufferClear   time.Time
+	lastDecryptedBufferClear   atomic.Int64
+	// EnableMessageStore makes the client save all sent and received messages, as well as messages from history syncs,
+	// in Store.Messages. When enabled, the store is also the default source of messages for handling retry receipts
+	// (before GetMessageForRetry is called) and for BuildHistorySyncRequestFromStore. Store.Messages is optional,
+	// this has no effect if the device store doesn't have a message store.
+	EnableMessageStore bool
+	// EventWorkers is the maximum number of incoming nodes that are handled in parallel. Nodes are still handled
+	// in order within each chat and for each sender, so Signal sessions and sender keys are always updated in order.
+	// Nodes that aren't tied to a chat (e.g. IQs and stream errors) wait for all previous nodes to be handled first.
+	//
+	// When this is greater than 1, event handlers may be called concurrently and must be thread-safe.
+	// The default (0 or 1) handles all nodes sequentially. Changes take effect on the next connect.
+	EventWorkers int
+
+	// Metrics receives measurements of client internals like reconnects, IQ latency and send timings.
+	// The default is NoopMetrics, and nil is treated the same way.
+	Metrics Metrics
+	// Tracer creates tracing spans for sending and receiving messages.
+	// The default is NoopTracer, and nil is treated the same way.
+	Tracer Tracer
 
 	DisableLoginAutoReconnect bool
+	// EnableNoiseResume makes the client cache the server's static key in the device store and use
+	// the shorter Noise_IK handshake when reconnecting. If the server rejects the resume attempt,
+	// the handshake falls back to the full Noise_XX exchange.
+	//
+	// This is experimental and disabled by default: the resume handshake has only been tested against
+	// the testserver package, not the real WhatsApp servers.
+	EnableNoiseResume bool
+	// SessionLease enables a lease in the device store that prevents other processes from connecting
+	// the same device at the same time. See SessionLeaseConfig for details. The default (nil) disables the lease.
+	// The lease is also disabled if the store doesn't support it (Store.Leases is nil).
+	SessionLease      *SessionLeaseConfig
+	sessionLeaseOwner string
+	stopSessionLease  context.CancelFunc
+	sessionLeaseStore store.SessionLeaseStore
+	// sessionLeaseReleasing is held from stopSessionLeaseRenewal until releaseSessionLease has finished,
+	// so that reconnecting can't acquire the lease before the previous release deletes it.
+	sessionLeaseReleasing sync.Mutex
+
+	// EnableOutbox allows queuing messages with EnqueueMessage, which persists them in Store.Outbox
+	// and sends them in the background. Persisted messages are loaded after the first successful connection.
+	// If the store doesn't support the outbox (Store.Outbox is nil), EnqueueMessage returns ErrOutboxNotSupported.
+	EnableOutbox bool
+	// OutboxRetryPolicy decides how long to wait before retrying failed outbox messages and when to give up
+	// (the attempt number in ReconnectAttempt is the number of failed send attempts for the message).
+	// The default is an ExponentialReconnectPolicy starting at 2 seconds.
+	OutboxRetryPolicy  ReconnectPolicy
+	outboxQueues       map[types.JID]*outboxQueue
+	outboxLock         sync.Mutex
+	outboxLoaded       bool
+	outboxLastQueuedAt time.Time
+	outboxOnline       *exsync.Event
 
 	sendActiveReceipts atomic.Uint32
 
@@ -95,6 +158,11 @@ type Client struct {
 	pendingPhoneRerequests             map[types.MessageID]context.CancelFunc
 	pendingPhoneRerequestsLock         sync.RWMutex
 
+	// MediaRetryTimeout is how long DownloadWithMediaRetry waits for the phone to re-upload media. Defaults to 1 minute.
+	MediaRetryTimeout time.Duration
+	mediaRetryWaiters map[mediaRetryKey]*mediaRetryWaiter
+	mediaRetryLock    sync.Mutex
+
 	appStateProc     *appstate.Processor
 	appStateSyncLock sync.Mutex
 
@@ -105,6 +173,14 @@ type Client struct {
 	uploadPreKeysLock sync.Mutex
 	lastPreKeyUpload  time.Time
 
+	// ParallelDownload enables downloading large attachments as parallel byte ranges from multiple media hosts.
+	// See ParallelDownloadConfig for details. The default (nil) downloads each file with a single request.
+	ParallelDownload *ParallelDownloadConfig
+	// MediaCache can be set to cache downloaded and uploaded files. Download and DownloadMediaWithPath are
+	// served from the cache when possible, and Upload and UploadReader reuse previous uploads of the same file.
+	// Upload also caches the uploaded data for later downloads, but UploadReader doesn't, as it streams the data.
+	// See MediaCache and FSMediaCache for details. The default (nil) disables caching.
+	MediaCache     MediaCache
 	mediaConnCache *MediaConn
 	mediaConnLock  sync.Mutex
 
@@ -115,6 +191,9 @@ type Client struct {
 	handlerQueue      chan *waBinary.Node
 	eventHandlers     []wrappedEventHandler
 	eventHandlersLock sync.RWMutex
+	// subscriptions contains the subscriptions whose event handlers are in eventHandlers,
+	// so that they can be stopped when the handler is removed. Protected by eventHandlersLock.
+	subscriptions map[uint32]*Subscription
 
 	messageRetries     map[string]int
 	messageRetriesLock sync.Mutex
@@ -125,14 +204,26 @@ type Client struct {
 	appStateKeyRequests     map[string]time.Time
 	appStateKeyRequestsLock sync.RWMutex
 
-	messageSendLock sync.Mutex
+	// chatSendLocks serializes sends to the same chat. signalLocks protects Signal sessions and sender keys,
+	// which may also be used by sends to other chats (e.g. sessions with our own devices) and by retries.
+	chatSendLocks keyedMutex
+	signalLocks   keyedMutex
 
 	privacySettingsCache atomic.Value
 
-	groupCache           map[types.JID]*groupMetaCache
+	groupCache           lruCache[types.JID, *groupMetaCache]
 	groupCacheLock       sync.Mutex
-	userDevicesCache     map[types.JID]deviceCache
+	userDevicesCache     lruCache[types.JID, deviceCache]
 	userDevicesCacheLock sync.Mutex
+	// EnableGroupSnapshots makes the client store the info of groups in Store.GroupSnapshots whenever it's fetched,
+	// keep the participant lists updated based on notifications, and fetch the list of joined groups again
+	// after reconnecting. Changes that were missed while offline are emitted as events.GroupMembershipDiff.
+	// This has no effect if the store doesn't support snapshots (Store.GroupSnapshots is nil).
+	EnableGroupSnapshots bool
+	groupSnapshotLock    sync.Mutex
+	// MetadataCache contains size limits, expiry and persistence options for the group member
+	// and device list caches. Use GetMetadataCacheStats to see how effective the caches are.
+	MetadataCache MetadataCacheConfig
 
 	recentMessagesMap  map[recentMessageKey]RecentMessage
 	recentMessagesList [recentMessagesSize]recentMessageKey
@@ -158,6 +249,14 @@ type Client struct {
 	// This should NOT be used for WhatsApp (to change the OS name, update fields in store.BaseClientPayload directly).
 	GetClientPayload func() *waWa6.ClientPayload
 
+	// CertRootKey overrides the public key that the server's noise certificate chain must be signed with.
+	// If nil, WACertPubKey is used. This should only be changed when connecting to a test server.
+	CertRootKey *[32]byte
+
+	// CaptureWriter records every decrypted node sent and received by the client, if set.
+	// The resulting capture can be fed back into a client with Replay.
+	CaptureWriter *capture.Writer
+
 	// Should untrusted identity errors be handled automatically? If true, the stored identity and existing signal
 	// sessions will be removed on untrusted identity errors, and an events.IdentityChange will be dispatched.
 	// If false, decrypting a message from untrusted devices will fail.
@@ -186,6 +285,10 @@ type Client struct {
 	// The library is currently embedded in mautrix-meta (https://github.com/mautrix/meta), but may be separated later.
 	MessengerConfig *MessengerConfig
 	RefreshCAT      func(context.Context) error
+
+	// WebsocketConfig overrides the websocket endpoint that the client connects to.
+	// Fields that are left empty fall back to the WhatsApp (or Messenger, if MessengerConfig is set) defaults.
+	WebsocketConfig *WebsocketConfig
 }
 
 type groupMetaCache struct {
@@ -200,6 +303,28 @@ type MessengerConfig struct {
 	WebsocketURL string
 }
 
+// WebsocketConfig contains per-client websocket endpoint settings.
+//
+// It can be used to point the client at a local test server, a recording proxy or a regional endpoint.
+type WebsocketConfig struct {
+	// URL is the websocket URL to dial, e.g. wss://web.whatsapp.com/ws/chat
+	URL string
+	// Origin is the value of the Origin header sent in the websocket handshake.
+	Origin string
+	// Header contains extra HTTP headers to send in the websocket handshake.
+	// They are applied after the default headers, so they can also be used to override those.
+	Header http.Header
+	// TLSConfig is the TLS configuration to use when dialing the websocket.
+	// It's only applied if the websocket HTTP client uses a *http.Transport.
+	TLSConfig *tls.Config
+
+	// HTTP clients with TLSConfig applied, keyed by the client they were derived from.
+	// They're cached so that reconnecting doesn't throw away the connection pool.
+	tlsClientsLock   sync.Mutex
+	tlsClients       map[*http.Client]*http.Client
+	tlsClientsForCfg *tls.Config
+}
+
 // Size of buffer for the channel that all incoming XML nodes go through.
 // In general it shouldn't go past a few buffered messages, but the channel is big to be safe.
 const handlerQueueSize = 2048
@@ -241,23 +366,25 @@ func NewClient(deviceStore *store.Device, log waLog.Logger) *Client {
 		eventHandlers:      make([]wrappedEventHandler, 0, 1),
 		messageRetries:     make(map[string]int),
 		handlerQueue:       make(chan *waBinary.Node, handlerQueueSize),
+		Metrics:            NoopMetrics{},
+		Tracer:             NoopTracer{},
 		appStateProc:       appstate.NewProcessor(deviceStore, log.Sub("AppState")),
 		socketWait:         make(chan struct{}),
 		expectedDisconnect: exsync.NewEvent(),
+		outboxQueues:       make(map[types.JID]*outboxQueue),
+		outboxOnline:       exsync.NewEvent(),
 
 		incomingRetryRequestCounter: make(map[incomingRetryKey]int),
 
 		historySyncNotifications: make(chan *waE2E.HistorySyncNotification, 32),
 
-		groupCache:       make(map[types.JID]*groupMetaCache),
-		userDevicesCache: make(map[types.JID]deviceCache),
-
 		recentMessagesMap:      make(map[recentMessageKey]RecentMessage, recentMessagesSize),
 		sessionRecreateHistory: make(map[types.JID]time.Time),
 		GetMessageForRetry:     func(requester, to types.JID, id types.MessageID) *waE2E.Message { return nil },
 		appStateKeyRequests:    make(map[string]time.Time),
 
 		pendingPhoneRerequests: make(map[types.MessageID]context.CancelFunc),
+		mediaRetryWaiters:      make(map[mediaRetryKey]*mediaRetryWaiter),
 
 		EnableAutoReconnect: true,
 		AutoTrustIdentity:   true,
@@ -396,6 +523,46 @@ func (cli *Client) SetPreLoginHTTPClient(h *http.Client) {
 	cli.preLoginHTTP = h
 }
 
+func (wc *WebsocketConfig) apply(fs *socket.FrameSocket) {
+	if wc.URL != "" {
+		fs.URL = wc.URL
+	}
+	if wc.Origin != "" {
+		fs.HTTPHeaders.Set("Origin", wc.Origin)
+	}
+	for key, values := range wc.Header {
+		fs.HTTPHeaders[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+	}
+	fs.HTTPClient = wc.getHTTPClient(fs.HTTPClient)
+}
+
+// getHTTPClient returns a copy of the given HTTP client with TLSConfig applied.
+// The copy is only created once per client, so the same transport is reused for all connections.
+func (wc *WebsocketConfig) getHTTPClient(base *http.Client) *http.Client {
+	if wc.TLSConfig == nil || base == nil {
+		return base
+	}
+	transport, ok := base.Transport.(*http.Transport)
+	if !ok {
+		return base
+	}
+	wc.tlsClientsLock.Lock()
+	defer wc.tlsClientsLock.Unlock()
+	if wc.tlsClients == nil || wc.tlsClientsForCfg != wc.TLSConfig {
+		wc.tlsClients = make(map[*http.Client]*http.Client)
+		wc.tlsClientsForCfg = wc.TLSConfig
+	}
+	httpClient, ok := wc.tlsClients[base]
+	if !ok {
+		transport = transport.Clone()
+		transport.TLSClientConfig = wc.TLSConfig.Clone()
+		httpClient = ptr.Clone(base)
+		httpClient.Transport = transport
+		wc.tlsClients[base] = httpClient
+	}
+	return httpClient
+}
+
 func (cli *Client) getSocketWaitChan() <-chan struct{} {
 	cli.socketLock.RLock()
 	ch := cli.socketWait
@@ -453,7 +620,7 @@ func (cli *Client) Connect() error {
 }
 
 func isRetryableConnectError(err error) bool {
-	if exhttp.IsNetworkError(err) {
+	if exhttp.IsNetworkError(err) || errors.Is(err, store.ErrSessionLeaseHeld) {
 		return true
 	}
 
@@ -474,15 +641,20 @@ func (cli *Client) ConnectContext(ctx context.Context) error {
 	}
 
 	cli.socketLock.Lock()
-	defer cli.socketLock.Unlock()
-
 	err := cli.unlockedConnect(ctx)
 	if isRetryableConnectError(err) && cli.InitialAutoReconnect && cli.EnableAutoReconnect {
+		cli.socketLock.Unlock()
 		cli.Log.Errorf("Initial connection failed but reconnecting in background (%v)", err)
 		go cli.dispatchEvent(&events.Disconnected{})
-		go cli.autoReconnect(ctx)
+		go cli.autoReconnectWithCause(ctx, ReconnectCauseInitialConnectFailed)
 		return nil
 	}
+	var leases store.SessionLeaseStore
+	if err != nil && !errors.Is(err, ErrAlreadyConnected) {
+		leases = cli.stopSessionLeaseRenewal()
+	}
+	cli.socketLock.Unlock()
+	cli.releaseSessionLease(leases)
 	return err
 }
 
@@ -503,6 +675,11 @@ func (cli *Client) unlockedConnect(ctx context.Context) error {
 	}
 
 	cli.resetExpectedDisconnect()
+	cli.setConnectionState(types.ConnectionStateConnecting, "connect")
+	if err := cli.acquireSessionLease(ctx); err != nil {
+		cli.setConnectionState(types.ConnectionStateDisconnected, err.Error())
+		return err
+	}
 	client := cli.websocketHTTP
 	if cli.Store.ID == nil {
 		client = cli.preLoginHTTP
@@ -518,13 +695,22 @@ func (cli *Client) unlockedConnect(ctx context.Context) error {
 		//fs.HTTPHeaders.Set("Sec-Fetch-Mode", "websocket")
 		//fs.HTTPHeaders.Set("Sec-Fetch-Site", "cross-site")
 	}
+	if cli.WebsocketConfig != nil {
+		cli.WebsocketConfig.apply(fs)
+	}
 	if err := fs.Connect(ctx); err != nil {
 		fs.Close(0)
+		cli.setConnectionState(types.ConnectionStateDisconnected, err.Error())
 		return err
-	} else if err = cli.doHandshake(ctx, fs, *keys.NewKeyPair()); err != nil {
+	}
+	cli.setConnectionState(types.ConnectionStateHandshaking, "websocket connected")
+	if err := cli.doHandshake(ctx, fs, *keys.NewKeyPair()); err != nil {
 		fs.Close(0)
-		return fmt.Errorf("noise handshake failed: %w", err)
+		err = fmt.Errorf("noise handshake failed: %w", err)
+		cli.setConnectionState(types.ConnectionStateDisconnected, err.Error())
+		return err
 	}
+	cli.setConnectionState(types.ConnectionStateAuthenticating, "handshake complete")
 	go cli.keepAliveLoop(ctx, fs.Context())
 	go cli.handlerQueueLoop(ctx, fs.Context())
 	return nil
@@ -542,10 +728,15 @@ func (cli *Client) onDisconnect(ctx context.Context, ns *socket.NoiseSocket, rem
 	if cli.socket == ns {
 		cli.socket = nil
 		cli.clearResponseWaiters(xmlStreamEndNode)
+		cause := ReconnectCauseDisconnected
+		if storedCause := cli.disconnectCause.Swap(nil); storedCause != nil {
+			cause = *storedCause
+		}
+		cli.setConnectionState(types.ConnectionStateDisconnected, string(cause))
 		if !cli.isExpectedDisconnect() && (cli.forceAutoReconnect.Swap(false) || remote) {
 			cli.Log.Debugf("Emitting Disconnected event")
 			go cli.dispatchEvent(&events.Disconnected{})
-			go cli.autoReconnect(ctx)
+			go cli.autoReconnectWithCause(ctx, cause)
 		} else if remote {
 			cli.Log.Debugf("OnDisconnect() called, but it was expected, so not emitting event")
 		} else {
@@ -571,20 +762,45 @@ func (cli *Client) isExpectedDisconnect() bool {
 }
 
 func (cli *Client) autoReconnect(ctx context.Context) {
+	cli.autoReconnectWithCause(ctx, ReconnectCauseDisconnected)
+}
+
+func (cli *Client) autoReconnectWithCause(ctx context.Context, cause ReconnectCause) {
 	if !cli.EnableAutoReconnect || cli.Store.ID == nil {
 		return
 	}
+	var lastErr error
 	for {
-		autoReconnectDelay := time.Duration(cli.AutoReconnectErrors) * 2 * time.Second
-		cli.Log.Debugf("Automatically reconnecting after %v", autoReconnectDelay)
+		decision := policyOrDefault(cli.ReconnectPolicy).NextAttempt(ReconnectAttempt{
+			Attempt:   cli.AutoReconnectErrors,
+			Cause:     cause,
+			LastError: lastErr,
+		})
+		if decision.Stop {
+			cli.Log.Warnf("Reconnect policy gave up after %d attempts (cause: %s)", cli.AutoReconnectErrors, cause)
+			cli.setConnectionState(types.ConnectionStateDisconnected, "reconnect policy gave up")
+			cli.stopReconnecting()
+			return
+		}
+		cli.Log.Debugf("Automatically reconnecting after %v (cause: %s, circuit open: %t)", decision.Delay, cause, decision.CircuitOpen)
 		cli.AutoReconnectErrors++
-		if cli.expectedDisconnect.WaitTimeoutCtx(ctx, autoReconnectDelay) == nil {
+		cli.setConnectionState(types.ConnectionStateBackingOff, fmt.Sprintf("%s, reconnecting in %v", cause, decision.Delay))
+		go cli.dispatchEvent(&events.ReconnectScheduled{
+			Attempt:     cli.AutoReconnectErrors,
+			Delay:       decision.Delay,
+			Cause:       string(cause),
+			LastError:   lastErr,
+			CircuitOpen: decision.CircuitOpen,
+		})
+		if cli.expectedDisconnect.WaitTimeoutCtx(ctx, decision.Delay) == nil {
 			cli.Log.Debugf("Cancelling automatic reconnect due to expected disconnect")
 			return
 		} else if ctx.Err() != nil {
 			cli.Log.Debugf("Cancelling automatic reconnect due to context cancellation")
+			cli.stopReconnecting()
 			return
 		}
+		cli.getMetrics().Reconnect(cause, cli.AutoReconnectErrors)
 		err := cli.connect(ctx)
 		if errors.Is(err, ErrAlreadyConnected) {
 			cli.Log.Debugf("Connect() said we're already connected after autoreconnect sleep")
@@ -597,14 +813,28 @@ func (cli *Client) autoReconnect(ctx context.Context) {
 			cli.Log.Errorf("Error reconnecting after autoreconnect sleep: %v", err)
 			if cli.AutoReconnectHook != nil && !cli.AutoReconnectHook(err) {
 				cli.Log.Debugf("AutoReconnectHook returned false, not reconnecting")
+				cli.setConnectionState(types.ConnectionStateDisconnected, "AutoReconnectHook returned false")
+				cli.stopReconnecting()
 				return
 			}
+			lastErr = err
 		} else {
 			return
 		}
 	}
 }
 
+// stopReconnecting releases resources that are only needed while the client is connected or reconnecting.
+func (cli *Client) stopReconnecting() {
+	cli.socketLock.Lock()
+	var leases store.SessionLeaseStore
+	if cli.socket == nil {
+		leases = cli.stopSessionLeaseRenewal()
+	}
+	cli.socketLock.Unlock()
+	cli.releaseSessionLease(leases)
+}
+
 // IsConnected checks if the client is connected to the WhatsApp web websocket.
 // Note that this doesn't check if the client is authenticated. See the IsLoggedIn field for that.
 func (cli *Client) IsConnected() bool {
@@ -628,7 +858,10 @@ func (cli *Client) Disconnect() {
 	cli.socketLock.Lock()
 	cli.expectDisconnect()
 	cli.unlockedDisconnect()
+	leases := cli.stopSessionLeaseRenewal()
+	cli.setConnectionState(types.ConnectionStateDisconnected, "manual disconnect")
 	cli.socketLock.Unlock()
+	cli.releaseSessionLease(leases)
 	cli.clearDelayedMessageRequests()
 }
 
@@ -688,6 +921,7 @@ func (cli *Client) Logout(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("error sending logout request: %w", err)
 	}
+	cli.setConnectionState(types.ConnectionStateLoggedOut, "user initiated logout")
 	cli.Disconnect()
 	err = cli.Store.Delete(ctx)
 	if err != nil {
@@ -727,6 +961,8 @@ func (cli *Client) Logout(ctx context.Context) error {
 //	func (mycli *MyClient) myEventHandler(evt interface{}) {
 //		// Handle event and access mycli.WAClient
 //	}
+//
+// See Subscribe and SubscribeChan for a typed alternative that can filter events by chat, sender and message type.
 func (cli *Client) AddEventHandler(handler EventHandler) uint32 {
 	return cli.AddEventHandlerWithSuccessStatus(func(evt any) bool {
 		handler(evt)
@@ -754,9 +990,15 @@ func (cli *Client) AddEventHandlerWithSuccessStatus(handler EventHandlerWithSucc
 //			go mycli.WAClient.RemoveEventHandler(mycli.eventHandlerID)
 //		}
 //	}
+//
+// If the handler belongs to a Subscription, the subscription is stopped as if Unsubscribe was called.
 func (cli *Client) RemoveEventHandler(id uint32) bool {
 	cli.eventHandlersLock.Lock()
 	defer cli.eventHandlersLock.Unlock()
+	if sub, ok := cli.subscriptions[id]; ok {
+		delete(cli.subscriptions, id)
+		sub.Unsubscribe()
+	}
 	for index := range cli.eventHandlers {
 		if cli.eventHandlers[index].id == id {
 			if index == 0 {
@@ -774,11 +1016,17 @@ func (cli *Client) RemoveEventHandler(id uint32) bool {
 	return false
 }
 
-// RemoveEventHandlers removes all event handlers that have been registered with AddEventHandler
+// RemoveEventHandlers removes all event handlers that have been registered with AddEventHandler.
+// Subscriptions are stopped too, which closes the channels returned by SubscribeChan.
 func (cli *Client) RemoveEventHandlers() {
 	cli.eventHandlersLock.Lock()
 	cli.eventHandlers = make([]wrappedEventHandler, 0, 1)
+	subs := cli.subscriptions
+	cli.subscriptions = nil
 	cli.eventHandlersLock.Unlock()
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
 }
 
 func (cli *Client) handleFrame(ctx context.Context, data []byte) {
@@ -795,6 +1043,7 @@ func (cli *Client) handleFrame(ctx context.Context, data []byte) {
 		return
 	}
 	cli.recvLog.Debugf("%s", node.XMLString())
+	cli.captureFrame(capture.DirectionRecv, data)
 	if node.Tag == "xmlstreamend" {
 		if !cli.isExpectedDisconnect() {
 			cli.Log.Warnf("Received stream end frame")
@@ -803,6 +1052,7 @@ func (cli *Client) handleFrame(ctx context.Context, data []byte) {
 	} else if cli.receiveResponse(ctx, node) {
 		// handled
 	} else if _, ok := cli.nodeHandlers[node.Tag]; ok {
+		cli.getMetrics().HandlerQueueDepth(len(cli.handlerQueue))
 		select {
 		case cli.handlerQueue <- node:
 		case <-ctx.Done():
@@ -821,35 +1071,15 @@ func (cli *Client) handleFrame(ctx context.Context, data []byte) {
 }
 
 func (cli *Client) handlerQueueLoop(evtCtx, connCtx context.Context) {
-	ticker := time.NewTicker(30 * time.Second)
-	ticker.Stop()
+	if workers := cli.EventWorkers; workers > 1 {
+		cli.parallelHandlerQueueLoop(evtCtx, connCtx, workers)
+		return
+	}
 	cli.Log.Debugf("Starting handler queue loop")
-Loop:
 	for {
 		select {
 		case node := <-cli.handlerQueue:
-			doneChan := make(chan struct{}, 1)
-			start := time.Now()
-			go func() {
-				cli.nodeHandlers[node.Tag](evtCtx, node)
-				duration := time.Since(start)
-				doneChan <- struct{}{}
-				if duration > 5*time.Second {
-					cli.Log.Warnf("Node handling took %s for %s", duration, node.XMLString())
-				}
-			}()
-			ticker.Reset(30 * time.Second)
-			for i := 0; i < 10; i++ {
-				select {
-				case <-doneChan:
-					ticker.Stop()
-					continue Loop
-				case <-ticker.C:
-					cli.Log.Warnf("Node handling is taking long for %s (started %s ago)", node.XMLString(), time.Since(start))
-				}
-			}
-			cli.Log.Warnf("Continuing handling of %s in background as it's taking too long", node.XMLString())
-			ticker.Stop()
+			cli.handleQueuedNode(evtCtx, node)
 		case <-connCtx.Done():
 			cli.Log.Debugf("Closing handler queue loop")
 			return
@@ -857,6 +1087,30 @@ Loop:
 	}
 }
 
+func (cli *Client) handleQueuedNode(ctx context.Context, node *waBinary.Node) {
+	doneChan := make(chan struct{}, 1)
+	start := time.Now()
+	go func() {
+		cli.nodeHandlers[node.Tag](ctx, node)
+		duration := time.Since(start)
+		doneChan <- struct{}{}
+		if duration > 5*time.Second {
+			cli.Log.Warnf("Node handling took %s for %s", duration, node.XMLString())
+		}
+	}()
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for i := 0; i < 10; i++ {
+		select {
+		case <-doneChan:
+			return
+		case <-ticker.C:
+			cli.Log.Warnf("Node handling is taking long for %s (started %s ago)", node.XMLString(), time.Since(start))
+		}
+	}
+	cli.Log.Warnf("Continuing handling of %s in background as it's taking too long", node.XMLString())
+}
+
 func (cli *Client) sendNodeAndGetData(ctx context.Context, node waBinary.Node) ([]byte, error) {
 	if cli == nil {
 		return nil, ErrClientIsNil
@@ -874,6 +1128,7 @@ func (cli *Client) sendNodeAndGetData(ctx context.Context, node waBinary.Node) (
 	}
 
 	cli.sendLog.Debugf("%s", node.XMLString())
+	cli.captureFrame(capture.DirectionSend, payload)
 	return payload, sock.SendFrame(ctx, payload)
 }
 
@@ -8,6 +8,7 @@ package whatsmeow
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	waBinary "go.mau.fi/whatsmeow/binary"
@@ -32,6 +33,7 @@ func (cli *Client) handleStreamError(ctx context.Context, node *waBinary.Node) {
 		cli.Log.Infof("Got 515 code, reconnecting...")
 		go func() {
 			cli.Disconnect()
+			cli.getMetrics().Reconnect(ReconnectCauseLogin, 1)
 			err := cli.connect(ctx)
 			if err != nil {
 				cli.Log.Errorf("Failed to reconnect after 515 code: %v", err)
@@ -40,6 +42,7 @@ func (cli *Client) handleStreamError(ctx context.Context, node *waBinary.Node) {
 	case code == "401" && conflictType == "device_removed":
 		cli.expectDisconnect()
 		cli.Log.Infof("Got device removed stream error, sending LoggedOut event and deleting session")
+		cli.setConnectionState(types.ConnectionStateLoggedOut, "device removed")
 		go cli.dispatchEvent(&events.LoggedOut{OnConnect: false, Reason: events.ConnectFailureLoggedOut})
 		err := cli.Store.Delete(ctx)
 		if err != nil {
@@ -48,13 +51,16 @@ func (cli *Client) handleStreamError(ctx context.Context, node *waBinary.Node) {
 	case conflictType == "replaced":
 		cli.expectDisconnect()
 		cli.Log.Infof("Got replaced stream error, sending StreamReplaced event")
+		cli.setConnectionState(types.ConnectionStateDisconnected, "stream replaced")
 		go cli.dispatchEvent(&events.StreamReplaced{})
 	case code == "503":
 		// This seems to happen when the server wants to restart or something.
 		// The disconnection will be emitted as an events.Disconnected and then the auto-reconnect will do its thing.
 		cli.Log.Warnf("Got 503 stream error, assuming automatic reconnect will handle it")
+		cli.setDisconnectCause(ReconnectCauseStreamError)
 	case cli.RefreshCAT != nil && (code == events.ConnectFailureCATInvalid.NumberString() || code == events.ConnectFailureCATExpired.NumberString()):
 		cli.Log.Infof("Got %s stream error, refreshing CAT before reconnecting...", code)
+		cli.setDisconnectCause(ReconnectCauseStreamError)
 		cli.socketLock.RLock()
 		defer cli.socketLock.RUnlock()
 		err := cli.RefreshCAT(ctx)
@@ -85,6 +91,11 @@ func (cli *Client) handleIB(ctx context.Context, node *waBinary.Node) {
 				Receipts:       ag.Int("receipt"),
 			})
 		case "offline":
+			cli.setConnectionState(types.ConnectionStateOnline, "offline sync completed")
+			if cli.EnableGroupSnapshots {
+				// Offline notifications have already been applied to the snapshots at this point
+				go cli.reconcileGroupSnapshots(cli.BackgroundEventCtx)
+			}
 			cli.dispatchEvent(&events.OfflineSyncCompleted{
 				Count: ag.Int("count"),
 			})
@@ -104,6 +115,7 @@ func (cli *Client) handleConnectFailure(ctx context.Context, node *waBinary.Node
 	reason := events.ConnectFailureReason(ag.Int("reason"))
 	message := ag.OptionalString("message")
 	willAutoReconnect := true
+	cli.setDisconnectCause(ReconnectCauseConnectFailure)
 	switch {
 	default:
 		// By default, expect a disconnect (i.e. prevent auto-reconnect)
@@ -125,6 +137,7 @@ func (cli *Client) handleConnectFailure(ctx context.Context, node *waBinary.Node
 	}
 	if reason.IsLoggedOut() {
 		cli.Log.Infof("Got %s connect failure, sending LoggedOut event and deleting session", reason)
+		cli.setConnectionState(types.ConnectionStateLoggedOut, fmt.Sprintf("connect failure: %s", reason))
 		go cli.dispatchEvent(&events.LoggedOut{OnConnect: true, Reason: reason})
 		err := cli.Store.Delete(ctx)
 		if err != nil {
@@ -160,6 +173,10 @@ func (cli *Client) handleConnectSuccess(ctx context.Context, node *waBinary.Node
 	cli.LastSuccessfulConnect = time.Now()
 	cli.AutoReconnectErrors = 0
 	cli.isLoggedIn.Store(true)
+	cli.setConnectionState(types.ConnectionStateSyncingOffline, "authenticated")
+	if cli.EnableOutbox {
+		go cli.loadOutbox(ctx)
+	}
 	nodeLID := node.AttrGetter().JID("lid")
 	if !cli.Store.LID.IsEmpty() && !nodeLID.IsEmpty() && cli.Store.LID != nodeLID {
 		// This should probably never happen, but check just in case.
@@ -191,6 +208,7 @@ func (cli *Client) handleConnectSuccess(ctx context.Context, node *waBinary.Node
 				cli.Log.Debugf("Prekey count after upload: %d", sc)
 			}
 		}
+		cli.checkSignedPreKeyRotation(ctx)
 		err := cli.SetPassive(ctx, false)
 		if err != nil {
 			cli.Log.Warnf("Failed to send post-connect passive IQ: %v", err)
@@ -0,0 +1,71 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ConnectionState returns the current state of the connection to WhatsApp.
+//
+// Every change of the state also emits an events.ConnectionStateChange.
+func (cli *Client) ConnectionState() types.ConnectionState {
+	if cli == nil {
+		return types.ConnectionStateDisconnected
+	}
+	cli.connStateLock.Lock()
+	defer cli.connStateLock.Unlock()
+	if cli.connState == "" {
+		return types.ConnectionStateDisconnected
+	}
+	return cli.connState
+}
+
+func (cli *Client) setConnectionState(state types.ConnectionState, reason string) {
+	cli.connStateLock.Lock()
+	defer cli.connStateLock.Unlock()
+	prev := cli.connState
+	if prev == "" {
+		prev = types.ConnectionStateDisconnected
+	}
+	if prev == state {
+		return
+	} else if prev == types.ConnectionStateLoggedOut && (state == types.ConnectionStateDisconnected || state == types.ConnectionStateBackingOff) {
+		// The socket is always closed after a logout, which shouldn't hide the fact that the device was logged out.
+		return
+	}
+	cli.Log.Debugf("Connection state changed from %s to %s (%s)", prev, state, reason)
+	cli.connState = state
+	cli.updateOutboxOnline(state)
+	// State changes often happen while holding socketLock, so the events are dispatched in the background.
+	// A single goroutine drains the queue so that handlers see the transitions in order.
+	cli.connStateEvents = append(cli.connStateEvents, &events.ConnectionStateChange{
+		Previous: prev,
+		State:    state,
+		Reason:   reason,
+	})
+	if !cli.connStateDispatching {
+		cli.connStateDispatching = true
+		go cli.dispatchConnectionStateEvents()
+	}
+}
+
+func (cli *Client) dispatchConnectionStateEvents() {
+	for {
+		cli.connStateLock.Lock()
+		if len(cli.connStateEvents) == 0 {
+			cli.connStateDispatching = false
+			cli.connStateLock.Unlock()
+			return
+		}
+		evt := cli.connStateEvents[0]
+		cli.connStateEvents = cli.connStateEvents[1:]
+		cli.connStateLock.Unlock()
+		cli.dispatchEvent(evt)
+	}
+}
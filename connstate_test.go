@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestConnectionState(t *testing.T) {
+	ctx, _, _, _, cli := newPairedTestClients(t)
+	changes := make(chan *events.ConnectionStateChange, 16)
+	cli.AddEventHandler(func(evt any) {
+		if change, ok := evt.(*events.ConnectionStateChange); ok {
+			changes <- change
+		}
+	})
+	cli.Disconnect()
+	if state := cli.ConnectionState(); state != types.ConnectionStateDisconnected {
+		t.Fatalf("Unexpected state after disconnecting: %s", state)
+	}
+	// Skip events from the initial connection, which may still be in the queue
+	for change := range changes {
+		if change.Reason == "manual disconnect" {
+			break
+		}
+	}
+
+	if err := cli.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	expected := []types.ConnectionState{
+		types.ConnectionStateConnecting,
+		types.ConnectionStateHandshaking,
+		types.ConnectionStateAuthenticating,
+		types.ConnectionStateSyncingOffline,
+		types.ConnectionStateOnline,
+		types.ConnectionStateDisconnected,
+	}
+	prev := types.ConnectionStateDisconnected
+	for i, state := range expected {
+		if state == types.ConnectionStateDisconnected {
+			cli.Disconnect()
+		}
+		select {
+		case change := <-changes:
+			if change.State != state || change.Previous != prev {
+				t.Fatalf("Unexpected transition #%d: %s -> %s (%s), expected %s -> %s", i, change.Previous, change.State, change.Reason, prev, state)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Didn't get transition to %s: %v", state, ctx.Err())
+		}
+		prev = state
+	}
+	if state := cli.ConnectionState(); state != types.ConnectionStateDisconnected {
+		t.Errorf("Unexpected final state: %s", state)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"crypto/aes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ParallelDownloadConfig contains options for downloading large attachments as parallel byte ranges.
+//
+// Parallel downloads are only used for encrypted media with a direct path and a known file length.
+// The byte ranges are spread over the media hosts that haven't failed recently, and ranges that fail
+// are retried on other hosts. If the parallel download fails, the file is downloaded with a single request instead.
+type ParallelDownloadConfig struct {
+	// MinSize is the minimum file size to download in parallel. Defaults to 8 MiB.
+	MinSize int
+	// ChunkSize is the size of each byte range. Defaults to 2 MiB.
+	ChunkSize int
+	// Concurrency is the maximum number of simultaneous requests for one file. Defaults to 4.
+	Concurrency int
+}
+
+func (cli *Client) getParallelDownloadConfig() (minSize, chunkSize, concurrency int) {
+	minSize, chunkSize, concurrency = cli.ParallelDownload.MinSize, cli.ParallelDownload.ChunkSize, cli.ParallelDownload.Concurrency
+	if minSize <= 0 {
+		minSize = 8 * 1024 * 1024
+	}
+	if chunkSize <= 0 {
+		chunkSize = 2 * 1024 * 1024
+	}
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	return
+}
+
+// getParallelDownloadSize returns the size of the encrypted file if it should be downloaded in parallel, or 0 otherwise.
+func (cli *Client) getParallelDownloadSize(mediaKey, encFileHash []byte, fileLength int) int64 {
+	if cli.ParallelDownload == nil || len(mediaKey) == 0 || len(encFileHash) != 32 || fileLength < 0 {
+		return 0
+	}
+	minSize, _, _ := cli.getParallelDownloadConfig()
+	if fileLength < minSize {
+		return 0
+	}
+	// The plaintext is always padded with 1-16 bytes, and the MAC is appended after the ciphertext
+	return int64(fileLength/aes.BlockSize+1)*aes.BlockSize + mediaHMACLength
+}
+
+type byteSliceWriterAt []byte
+
+func (b byteSliceWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(b)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(b[off:], p), nil
+}
+
+type mediaChunk struct {
+	index      int
+	start, end int64
+}
+
+// downloadMediaParallel downloads the given number of bytes of a file as parallel byte ranges and writes them to dst.
+// The data isn't validated in any way, the caller must check the hash after this returns.
+func (cli *Client) downloadMediaParallel(
+	ctx context.Context,
+	mediaConn *MediaConn,
+	directPath string,
+	encFileHash []byte,
+	mmsType string,
+	size int64,
+	dst io.WriterAt,
+) error {
+	_, chunkSize, concurrency := cli.getParallelDownloadConfig()
+	chunkCount := int((size + int64(chunkSize) - 1) / int64(chunkSize))
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	chunks := make(chan mediaChunk)
+	var wg sync.WaitGroup
+	for range min(concurrency, chunkCount) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				err := cli.downloadMediaChunk(ctx, mediaConn, directPath, encFileHash, mmsType, chunk, dst)
+				if err != nil {
+					cancel(err)
+					return
+				}
+			}
+		}()
+	}
+	cli.Log.Debugf("Downloading %d bytes in %d chunks with up to %d parallel requests", size, chunkCount, concurrency)
+Loop:
+	for i := range chunkCount {
+		chunk := mediaChunk{index: i, start: int64(i) * int64(chunkSize)}
+		chunk.end = min(chunk.start+int64(chunkSize), size) - 1
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+			break Loop
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// downloadMediaChunk downloads a single byte range, spreading chunks over the healthy hosts
+// and moving to the next host if a request fails.
+func (cli *Client) downloadMediaChunk(
+	ctx context.Context,
+	mediaConn *MediaConn,
+	directPath string,
+	encFileHash []byte,
+	mmsType string,
+	chunk mediaChunk,
+	dst io.WriterAt,
+) (err error) {
+	for attempt := 0; attempt < len(mediaConn.Hosts)*mediaDownloadRounds; attempt++ {
+		hosts, healthy := mediaConn.SortedHosts()
+		host := hosts[(chunk.index+attempt)%max(healthy, 1)]
+		if attempt >= healthy {
+			// All healthy hosts have already been tried, so try the unhealthy ones too
+			host = hosts[(chunk.index+attempt)%len(hosts)]
+		}
+		err = cli.downloadMediaRange(ctx, getMediaDownloadURL(host.Hostname, directPath, encFileHash, mmsType), chunk.start, chunk.end, dst)
+		if err == nil {
+			mediaConn.recordHostResult(host.Hostname, true)
+			return nil
+		} else if isPermanentMediaDownloadError(err) || ctx.Err() != nil {
+			return err
+		}
+		mediaConn.recordHostResult(host.Hostname, false)
+		cli.Log.Warnf("Failed to download bytes %d-%d from %s: %v", chunk.start, chunk.end, host.Hostname, err)
+	}
+	return fmt.Errorf("failed to download bytes %d-%d: %w", chunk.start, chunk.end, err)
+}
+
+func (cli *Client) downloadMediaRange(ctx context.Context, url string, start, end int64, dst io.WriterAt) error {
+	resp, err := cli.doMediaRangeRequest(ctx, url, start, end)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, end-start+1)
+	n, err := io.ReadFull(resp.Body, buf)
+	_ = resp.Body.Close()
+	cli.getMetrics().MediaTransfer(MediaTransferDownload, int64(n), err)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	_, err = dst.WriteAt(buf, start)
+	return err
+}
@@ -0,0 +1,495 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/retryafter"
+)
+
+// MediaStream is a reader that downloads and decrypts an attachment on the fly. It's returned by [Client.DownloadStream].
+//
+// When the stream is read sequentially from the start, the HMAC and hashes of the file are verified when the end
+// is reached: instead of io.EOF, the final Read call returns ErrInvalidMediaHMAC, ErrInvalidMediaEncSHA256,
+// ErrInvalidMediaSHA256 or ErrFileLengthMismatch if the file was corrupted or tampered with. The data returned
+// by earlier Read calls must not be trusted until Read has returned io.EOF.
+//
+// MediaStream also implements io.Seeker using HTTP range requests, so it can be used with e.g. http.ServeContent.
+// The MAC covers the entire file, so data read after seeking to a position other than the start is not verified.
+//
+// MediaStream is not safe for concurrent use.
+type MediaStream struct {
+	cli      *Client
+	ctx      context.Context
+	url      string
+	attempts int
+
+	encrypted     bool
+	iv            []byte
+	macKey        []byte
+	block         cipher.Block
+	fileLength    int
+	fileEncSHA256 []byte
+	fileSHA256    []byte
+
+	// encSize is the size of the downloaded file including the MAC, plainSize is the size after decrypting
+	// and removing padding, or -1 if it's not known yet.
+	encSize   int64
+	plainSize int64
+
+	// pos is the position returned by Seek, readPos is the position of the first byte in out.
+	pos     int64
+	readPos int64
+	err     error
+	closed  bool
+
+	body       io.ReadCloser
+	bodyOffset int64
+	bodyRead   int64
+	cbc        cipher.BlockMode
+	buf        []byte
+	pending    []byte
+	out        []byte
+	outBuf     []byte
+	skip       int
+
+	verify      bool
+	mac         hash.Hash
+	encHash     hash.Hash
+	plainHash   hash.Hash
+	plainRead   int64
+	receivedMAC []byte
+}
+
+var _ io.ReadSeekCloser = (*MediaStream)(nil)
+
+const (
+	mediaStreamBufferSize = 32 * 1024
+	mediaStreamAttempts   = 5
+)
+
+// DownloadStream downloads the attachment from the given protobuf message as a stream.
+//
+// This is otherwise identical to [Download], but the attachment is decrypted while it's being read instead of
+// being buffered in memory. The first request is made before this function returns, so HTTP errors like
+// ErrMediaDownloadFailedWith404 are returned here, while validation errors are returned at the end of the stream.
+// See [MediaStream] for details. The stream must be closed after use.
+func (cli *Client) DownloadStream(ctx context.Context, msg DownloadableMessage) (*MediaStream, error) {
+	if cli == nil {
+		return nil, ErrClientIsNil
+	}
+	mediaType := GetMediaType(msg)
+	if mediaType == "" {
+		return nil, fmt.Errorf("%w %T", ErrUnknownMediaType, msg)
+	}
+	urlable, ok := msg.(downloadableMessageWithURL)
+	var url string
+	var isWebWhatsappNetURL bool
+	if ok {
+		url = urlable.GetURL()
+		isWebWhatsappNetURL = strings.HasPrefix(url, "https://web.whatsapp.net")
+	}
+	if len(url) > 0 && !isWebWhatsappNetURL {
+		return cli.newMediaStream(ctx, url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256(), mediaStreamAttempts)
+	} else if len(msg.GetDirectPath()) > 0 {
+		return cli.DownloadMediaWithPathStream(ctx, msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType])
+	} else {
+		if isWebWhatsappNetURL {
+			cli.Log.Warnf("Got a media message with a web.whatsapp.net URL (%s) and no direct path", url)
+		}
+		return nil, ErrNoURLPresent
+	}
+}
+
+// DownloadMediaWithPathStream downloads an attachment as a stream by manually specifying the path and encryption details.
+//
+// This is otherwise identical to [DownloadMediaWithPath], but returns a stream like [DownloadStream].
+func (cli *Client) DownloadMediaWithPathStream(
+	ctx context.Context,
+	directPath string,
+	encFileHash, fileHash, mediaKey []byte,
+	fileLength int,
+	mediaType MediaType,
+	mmsType string,
+) (stream *MediaStream, err error) {
+	if !strings.HasPrefix(directPath, "/") {
+		return nil, fmt.Errorf("media download path does not start with slash: %s", directPath)
+	}
+	var mediaConn *MediaConn
+	mediaConn, err = cli.refreshMediaConn(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh media connections: %w", err)
+	}
+	if len(mmsType) == 0 {
+		mmsType = mediaTypeToMMSType[mediaType]
+	}
+	err = cli.downloadFromMediaHosts(ctx, mediaConn, directPath, encFileHash, mmsType, func(mediaURL string) (err error) {
+		// Only make one attempt per host when opening the stream, so that failing hosts are rotated quickly
+		stream, err = cli.newMediaStream(ctx, mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash, 1)
+		return
+	})
+	return
+}
+
+func (cli *Client) newMediaStream(
+	ctx context.Context,
+	url string,
+	mediaKey []byte,
+	appInfo MediaType,
+	fileLength int,
+	fileEncSHA256, fileSHA256 []byte,
+	firstAttempts int,
+) (*MediaStream, error) {
+	ms := &MediaStream{
+		attempts:      firstAttempts,
+		cli:           cli,
+		ctx:           ctx,
+		url:           url,
+		encrypted:     mediaKey != nil || fileEncSHA256 != nil,
+		fileLength:    fileLength,
+		fileEncSHA256: fileEncSHA256,
+		fileSHA256:    fileSHA256,
+		encSize:       -1,
+		plainSize:     -1,
+		encHash:       sha256.New(),
+		plainHash:     sha256.New(),
+	}
+	if ms.encrypted {
+		var cipherKey []byte
+		ms.iv, cipherKey, ms.macKey, _ = getMediaKeys(mediaKey, appInfo)
+		var err error
+		ms.block, err = aes.NewCipher(cipherKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		ms.mac = hmac.New(sha256.New, ms.macKey)
+	}
+	err := ms.open()
+	if err != nil {
+		return nil, err
+	}
+	ms.attempts = mediaStreamAttempts
+	if ms.encrypted {
+		cipherLen := ms.encSize - mediaHMACLength
+		if cipherLen < aes.BlockSize || cipherLen%aes.BlockSize != 0 {
+			_ = ms.Close()
+			return nil, fmt.Errorf("%w: encrypted size %d is not a multiple of block size", ErrTooShortFile, ms.encSize)
+		}
+	} else {
+		ms.plainSize = ms.encSize
+	}
+	return ms, nil
+}
+
+// Read reads decrypted data from the stream. See [MediaStream] for how the file is validated.
+func (ms *MediaStream) Read(p []byte) (n int, err error) {
+	if ms.closed {
+		return 0, os.ErrClosed
+	} else if ms.pos != ms.readPos {
+		ms.closeBody(nil)
+		ms.readPos = ms.pos
+		ms.err = nil
+	}
+	if ms.err != nil {
+		return 0, ms.err
+	}
+	for len(ms.out) == 0 {
+		if ms.body == nil {
+			if err = ms.open(); err != nil {
+				ms.err = err
+				return 0, err
+			}
+		} else if ms.bodyOffset >= ms.encSize {
+			ms.err = ms.finish()
+			return 0, ms.err
+		} else if err = ms.fill(); err != nil {
+			ms.closeBody(err)
+			ms.err = err
+			return 0, err
+		}
+	}
+	n = copy(p, ms.out)
+	ms.out = ms.out[n:]
+	ms.pos += int64(n)
+	ms.readPos = ms.pos
+	return n, nil
+}
+
+// Seek sets the position for the next Read. Seeking relative to the end requires knowing the exact size of the
+// decrypted file, which may require an extra request to fetch the last block of the file. The new range is only
+// requested when Read is called.
+func (ms *MediaStream) Seek(offset int64, whence int) (int64, error) {
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = ms.pos + offset
+	case io.SeekEnd:
+		size, err := ms.Size()
+		if err != nil {
+			return ms.pos, err
+		}
+		newPos = size + offset
+	default:
+		return ms.pos, fmt.Errorf("invalid whence %d", whence)
+	}
+	if newPos < 0 {
+		return ms.pos, fmt.Errorf("can't seek to negative position %d", newPos)
+	}
+	ms.pos = newPos
+	return newPos, nil
+}
+
+// Size returns the size of the decrypted file. If the stream hasn't been read to the end yet, the last block
+// of the file is requested separately to find out how much padding there is.
+func (ms *MediaStream) Size() (int64, error) {
+	if ms.plainSize >= 0 {
+		return ms.plainSize, nil
+	}
+	cipherLen := ms.encSize - mediaHMACLength
+	start := max(cipherLen-2*aes.BlockSize, 0)
+	resp, err := ms.requestRange(start, cipherLen-1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to request last block: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, cipherLen-start))
+	_ = resp.Body.Close()
+	ms.cli.getMetrics().MediaTransfer(MediaTransferDownload, int64(len(data)), err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read last block: %w", err)
+	} else if int64(len(data)) != cipherLen-start {
+		return 0, fmt.Errorf("failed to read last block: %w", io.ErrUnexpectedEOF)
+	}
+	iv := ms.iv
+	if start > 0 {
+		iv, data = data[:aes.BlockSize], data[aes.BlockSize:]
+	}
+	cipher.NewCBCDecrypter(ms.block, iv).CryptBlocks(data, data)
+	padding, err := getMediaPadding(data)
+	if err != nil {
+		return 0, err
+	}
+	ms.plainSize = cipherLen - int64(padding)
+	return ms.plainSize, nil
+}
+
+// Close closes the current HTTP response body, if there is one.
+func (ms *MediaStream) Close() error {
+	ms.closeBody(nil)
+	ms.closed = true
+	return nil
+}
+
+func (ms *MediaStream) open() error {
+	var blockStart int64
+	start := ms.pos
+	if ms.pos > 0 {
+		size, err := ms.Size()
+		if err != nil {
+			return err
+		} else if ms.pos >= size {
+			return io.EOF
+		}
+	}
+	if ms.encrypted {
+		blockStart = ms.pos / aes.BlockSize * aes.BlockSize
+		start = max(blockStart-aes.BlockSize, 0)
+		ms.skip = int(ms.pos - blockStart)
+	}
+	resp, err := ms.requestRange(start, -1)
+	if err != nil {
+		return err
+	}
+	if ms.encSize < 0 {
+		if resp.ContentLength < 0 {
+			_ = resp.Body.Close()
+			return fmt.Errorf("media server didn't return content length")
+		}
+		ms.encSize = resp.ContentLength
+	}
+	ms.body = resp.Body
+	ms.bodyOffset = start
+	ms.bodyRead = 0
+	ms.pending = ms.pending[:0]
+	ms.out = ms.outBuf[:0]
+	ms.receivedMAC = ms.receivedMAC[:0]
+	ms.verify = start == 0 && blockStart == 0
+	if ms.verify {
+		ms.encHash.Reset()
+		ms.plainHash.Reset()
+		ms.plainRead = 0
+		if ms.encrypted {
+			ms.mac.Reset()
+			ms.mac.Write(ms.iv)
+		}
+	}
+	if !ms.encrypted {
+		ms.skip = 0
+		return nil
+	}
+	iv := ms.iv
+	if blockStart > 0 {
+		iv = make([]byte, aes.BlockSize)
+		_, err = io.ReadFull(ms.body, iv)
+		if err != nil {
+			ms.closeBody(err)
+			return fmt.Errorf("failed to read previous block: %w", err)
+		}
+		ms.bodyOffset += aes.BlockSize
+		ms.bodyRead += aes.BlockSize
+	}
+	ms.cbc = cipher.NewCBCDecrypter(ms.block, iv)
+	return nil
+}
+
+func (ms *MediaStream) requestRange(start, end int64) (resp *http.Response, err error) {
+	for retryNum := 0; retryNum < ms.attempts; retryNum++ {
+		resp, err = ms.cli.doMediaRangeRequest(ms.ctx, ms.url, start, end)
+		if err == nil || !shouldRetryMediaDownload(err) || retryNum >= ms.attempts-1 {
+			return
+		}
+		retryDuration := time.Duration(retryNum+1) * time.Second
+		var httpErr DownloadHTTPError
+		if errors.As(err, &httpErr) {
+			retryDuration = retryafter.Parse(httpErr.Response.Header.Get("Retry-After"), retryDuration)
+		}
+		ms.cli.Log.Warnf("Failed to download media due to network error: %v, retrying in %s...", err, retryDuration)
+		select {
+		case <-ms.ctx.Done():
+			return nil, ms.ctx.Err()
+		case <-time.After(retryDuration):
+		}
+	}
+	return
+}
+
+func (ms *MediaStream) fill() error {
+	if ms.buf == nil {
+		ms.buf = make([]byte, mediaStreamBufferSize)
+	}
+	n, err := ms.body.Read(ms.buf[:min(int64(len(ms.buf)), ms.encSize-ms.bodyOffset)])
+	data := ms.buf[:n]
+	ms.bodyRead += int64(n)
+	if ms.verify {
+		ms.encHash.Write(data)
+	}
+	dataLen := ms.encSize
+	if ms.encrypted {
+		dataLen -= mediaHMACLength
+	}
+	if ms.bodyOffset+int64(n) > dataLen {
+		macStart := max(dataLen-ms.bodyOffset, 0)
+		ms.receivedMAC = append(ms.receivedMAC, data[macStart:]...)
+		data = data[:macStart]
+	}
+	ms.bodyOffset += int64(n)
+	ms.out = ms.outBuf[:0]
+	if ms.encrypted {
+		if decryptErr := ms.decrypt(data, ms.bodyOffset >= dataLen); decryptErr != nil {
+			return decryptErr
+		}
+	} else {
+		ms.addPlaintext(data)
+	}
+	ms.outBuf = ms.out[:0]
+	if errors.Is(err, io.EOF) {
+		if ms.bodyOffset < ms.encSize {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}
+	return err
+}
+
+func (ms *MediaStream) decrypt(data []byte, final bool) error {
+	if ms.verify {
+		ms.mac.Write(data)
+	}
+	ms.pending = append(ms.pending, data...)
+	fullBlocks := len(ms.pending) / aes.BlockSize * aes.BlockSize
+	plaintext := ms.pending[:fullBlocks]
+	ms.cbc.CryptBlocks(plaintext, plaintext)
+	if final {
+		if fullBlocks != len(ms.pending) || fullBlocks == 0 {
+			return fmt.Errorf("failed to decrypt file: ciphertext is not a multiple of block size")
+		}
+		padding, err := getMediaPadding(plaintext)
+		if err != nil {
+			return err
+		}
+		plaintext = plaintext[:len(plaintext)-padding]
+		ms.plainSize = ms.encSize - mediaHMACLength - int64(padding)
+	}
+	ms.addPlaintext(plaintext)
+	ms.pending = append(ms.pending[:0], ms.pending[fullBlocks:]...)
+	return nil
+}
+
+func (ms *MediaStream) addPlaintext(data []byte) {
+	if ms.verify {
+		ms.plainHash.Write(data)
+		ms.plainRead += int64(len(data))
+	}
+	if ms.skip > 0 {
+		skip := min(ms.skip, len(data))
+		data = data[skip:]
+		ms.skip -= skip
+	}
+	ms.out = append(ms.out, data...)
+}
+
+func (ms *MediaStream) finish() error {
+	verify := ms.verify
+	ms.closeBody(nil)
+	if !verify {
+		return io.EOF
+	} else if ms.encrypted && !hmac.Equal(ms.mac.Sum(nil)[:mediaHMACLength], ms.receivedMAC) {
+		return ErrInvalidMediaHMAC
+	} else if ms.encrypted && len(ms.fileEncSHA256) == 32 && !hmac.Equal(ms.encHash.Sum(nil), ms.fileEncSHA256) {
+		return ErrInvalidMediaEncSHA256
+	} else if ReturnDownloadWarnings {
+		if ms.fileLength >= 0 && ms.plainRead != int64(ms.fileLength) {
+			return fmt.Errorf("%w: expected %d, got %d", ErrFileLengthMismatch, ms.fileLength, ms.plainRead)
+		} else if len(ms.fileSHA256) == 32 && !hmac.Equal(ms.plainHash.Sum(nil), ms.fileSHA256) {
+			return ErrInvalidMediaSHA256
+		}
+	}
+	return io.EOF
+}
+
+func (ms *MediaStream) closeBody(err error) {
+	if ms.body == nil {
+		return
+	}
+	_ = ms.body.Close()
+	ms.cli.getMetrics().MediaTransfer(MediaTransferDownload, ms.bodyRead, err)
+	ms.body = nil
+	ms.out = nil
+	ms.verify = false
+}
+
+func getMediaPadding(lastBlock []byte) (int, error) {
+	padding := int(lastBlock[len(lastBlock)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(lastBlock) {
+		return 0, fmt.Errorf("failed to decrypt file: invalid padding length %d", padding)
+	}
+	return padding, nil
+}
@@ -10,7 +10,6 @@ import (
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -90,24 +89,30 @@ func (cli *Client) DownloadMediaWithPathToFile(
 	if len(mmsType) == 0 {
 		mmsType = mediaTypeToMMSType[mediaType]
 	}
-	for i, host := range mediaConn.Hosts {
-		// TODO omit hash for unencrypted media?
-		mediaURL := fmt.Sprintf("https://%s%s&hash=%s&mms-type=%s&__wa-mms=", host.Hostname, directPath, base64.URLEncoding.EncodeToString(encFileHash), mmsType)
-		err = cli.downloadAndDecryptToFile(ctx, mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash, file)
-		if err == nil ||
-			errors.Is(err, ErrFileLengthMismatch) ||
-			errors.Is(err, ErrInvalidMediaSHA256) ||
-			errors.Is(err, ErrMediaDownloadFailedWith403) ||
-			errors.Is(err, ErrMediaDownloadFailedWith404) ||
-			errors.Is(err, ErrMediaDownloadFailedWith410) ||
-			errors.Is(err, context.Canceled) {
+	if encSize := cli.getParallelDownloadSize(mediaKey, encFileHash, fileLength); encSize > 0 {
+		var mac []byte
+		err = cli.downloadMediaParallel(ctx, mediaConn, directPath, encFileHash, mmsType, encSize, file)
+		if err == nil {
+			mac, err = finishEncryptedMediaFile(file, encSize, encFileHash)
+		}
+		if err == nil {
+			return decryptMediaFile(file, mac, mediaKey, mediaType, fileLength, encFileHash, fileHash)
+		} else if isPermanentMediaDownloadError(err) {
 			return err
-		} else if i >= len(mediaConn.Hosts)-1 {
-			return fmt.Errorf("failed to download media from last host: %w", err)
 		}
-		cli.Log.Warnf("Failed to download media: %s, trying with next host...", err)
+		cli.Log.Warnf("Failed to download media in parallel: %v, falling back to a single request...", err)
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			return fmt.Errorf("failed to seek to start of file after parallel download: %w", err)
+		}
 	}
-	return err
+	return cli.downloadFromMediaHosts(ctx, mediaConn, directPath, encFileHash, mmsType, func(mediaURL string) error {
+		mac, err := cli.downloadPossiblyEncryptedMediaToFile(ctx, mediaURL, encFileHash, file)
+		if err != nil {
+			return err
+		}
+		return decryptMediaFile(file, mac, mediaKey, mediaType, fileLength, encFileHash, fileHash)
+	})
 }
 
 func (cli *Client) downloadAndDecryptToFile(
@@ -119,14 +124,20 @@ func (cli *Client) downloadAndDecryptToFile(
 	fileEncSHA256, fileSHA256 []byte,
 	file File,
 ) error {
+	mac, err := cli.downloadPossiblyEncryptedMediaWithRetriesToFile(ctx, url, fileEncSHA256, file)
+	if err != nil {
+		return err
+	}
+	return decryptMediaFile(file, mac, mediaKey, appInfo, fileLength, fileEncSHA256, fileSHA256)
+}
+
+func decryptMediaFile(file File, mac, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte) error {
 	iv, cipherKey, macKey, _ := getMediaKeys(mediaKey, appInfo)
 	hasher := sha256.New()
-	if mac, err := cli.downloadPossiblyEncryptedMediaWithRetriesToFile(ctx, url, fileEncSHA256, file); err != nil {
-		return err
-	} else if mediaKey == nil && fileEncSHA256 == nil && mac == nil {
+	if mediaKey == nil && fileEncSHA256 == nil && mac == nil {
 		// Unencrypted media, just return the downloaded data
 		return nil
-	} else if err = validateMediaFile(file, iv, macKey, mac); err != nil {
+	} else if err := validateMediaFile(file, iv, macKey, mac); err != nil {
 		return err
 	} else if _, err = file.Seek(0, io.SeekStart); err != nil {
 		return fmt.Errorf("failed to seek to start of file after validating mac: %w", err)
@@ -148,13 +159,22 @@ func (cli *Client) downloadAndDecryptToFile(
 	return nil
 }
 
+func (cli *Client) downloadPossiblyEncryptedMediaToFile(ctx context.Context, url string, checksum []byte, file File) (mac []byte, err error) {
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek to start of file: %w", err)
+	}
+	if checksum == nil {
+		_, _, err = cli.downloadMediaToFile(ctx, url, file)
+	} else {
+		mac, err = cli.downloadEncryptedMediaToFile(ctx, url, checksum, file)
+	}
+	return
+}
+
 func (cli *Client) downloadPossiblyEncryptedMediaWithRetriesToFile(ctx context.Context, url string, checksum []byte, file File) (mac []byte, err error) {
 	for retryNum := 0; retryNum < 5; retryNum++ {
-		if checksum == nil {
-			_, _, err = cli.downloadMediaToFile(ctx, url, file)
-		} else {
-			mac, err = cli.downloadEncryptedMediaToFile(ctx, url, checksum, file)
-		}
+		mac, err = cli.downloadPossiblyEncryptedMediaToFile(ctx, url, checksum, file)
 		if err == nil || !shouldRetryMediaDownload(err) {
 			return
 		}
@@ -164,10 +184,6 @@ func (cli *Client) downloadPossiblyEncryptedMediaWithRetriesToFile(ctx context.C
 			retryDuration = retryafter.Parse(httpErr.Response.Header.Get("Retry-After"), retryDuration)
 		}
 		cli.Log.Warnf("Failed to download media due to network error: %v, retrying in %s...", err, retryDuration)
-		_, err = file.Seek(0, io.SeekStart)
-		if err != nil {
-			return nil, fmt.Errorf("failed to seek to start of file to retry download: %w", err)
-		}
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -192,6 +208,7 @@ func (cli *Client) downloadMediaToFile(ctx context.Context, url string, file io.
 	}
 	hasher := sha256.New()
 	n, err := io.Copy(file, io.TeeReader(resp.Body, hasher))
+	cli.getMetrics().MediaTransfer(MediaTransferDownload, n, err)
 	return n, hasher.Sum(nil), err
 }
 
@@ -204,8 +221,24 @@ func (cli *Client) downloadEncryptedMediaToFile(ctx context.Context, url string,
 	} else if len(checksum) == 32 && !hmac.Equal(checksum, hash) {
 		return nil, ErrInvalidMediaEncSHA256
 	}
+	return splitMediaFileMAC(file, size)
+}
+
+// finishEncryptedMediaFile checks the hash of a file that was downloaded in parallel and removes the MAC from the end.
+func finishEncryptedMediaFile(file File, size int64, checksum []byte) ([]byte, error) {
+	hasher := sha256.New()
+	_, err := io.Copy(hasher, io.NewSectionReader(file, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	} else if !hmac.Equal(checksum, hasher.Sum(nil)) {
+		return nil, ErrInvalidMediaEncSHA256
+	}
+	return splitMediaFileMAC(file, size)
+}
+
+func splitMediaFileMAC(file File, size int64) ([]byte, error) {
 	mac := make([]byte, mediaHMACLength)
-	_, err = file.ReadAt(mac, size-mediaHMACLength)
+	_, err := file.ReadAt(mac, size-mediaHMACLength)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read MAC from file: %w", err)
 	}
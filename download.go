@@ -211,6 +211,9 @@ func GetMediaType(msg DownloadableMessage) MediaType {
 //	imageData, err := cli.Download(msg.GetImageMessage())
 //
 // You can also use DownloadAny to download the first non-nil sub-message.
+//
+// If Client.MediaCache is set, the file is returned from the cache when possible,
+// and successfully downloaded files are stored in the cache.
 func (cli *Client) Download(ctx context.Context, msg DownloadableMessage) ([]byte, error) {
 	if cli == nil {
 		return nil, ErrClientIsNil
@@ -226,16 +229,18 @@ func (cli *Client) Download(ctx context.Context, msg DownloadableMessage) ([]byt
 		url = urlable.GetURL()
 		isWebWhatsappNetURL = strings.HasPrefix(url, "https://web.whatsapp.net")
 	}
-	if len(url) > 0 && !isWebWhatsappNetURL {
-		return cli.downloadAndDecrypt(ctx, url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256())
-	} else if len(msg.GetDirectPath()) > 0 {
-		return cli.DownloadMediaWithPath(ctx, msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType])
-	} else {
-		if isWebWhatsappNetURL {
-			cli.Log.Warnf("Got a media message with a web.whatsapp.net URL (%s) and no direct path", url)
+	return cli.downloadWithCache(ctx, msg.GetFileSHA256(), msg.GetFileEncSHA256(), func() ([]byte, error) {
+		if len(url) > 0 && !isWebWhatsappNetURL {
+			return cli.downloadAndDecrypt(ctx, url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256())
+		} else if len(msg.GetDirectPath()) > 0 {
+			return cli.downloadMediaWithPath(ctx, msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType])
+		} else {
+			if isWebWhatsappNetURL {
+				cli.Log.Warnf("Got a media message with a web.whatsapp.net URL (%s) and no direct path", url)
+			}
+			return nil, ErrNoURLPresent
 		}
-		return nil, ErrNoURLPresent
-	}
+	})
 }
 
 func (cli *Client) DownloadFB(
@@ -247,6 +252,7 @@ func (cli *Client) DownloadFB(
 }
 
 // DownloadMediaWithPath downloads an attachment by manually specifying the path and encryption details.
+// Like Download, this returns the file from Client.MediaCache if it's cached.
 func (cli *Client) DownloadMediaWithPath(
 	ctx context.Context,
 	directPath string,
@@ -254,6 +260,19 @@ func (cli *Client) DownloadMediaWithPath(
 	fileLength int,
 	mediaType MediaType,
 	mmsType string,
+) ([]byte, error) {
+	return cli.downloadWithCache(ctx, fileHash, encFileHash, func() ([]byte, error) {
+		return cli.downloadMediaWithPath(ctx, directPath, encFileHash, fileHash, mediaKey, fileLength, mediaType, mmsType)
+	})
+}
+
+func (cli *Client) downloadMediaWithPath(
+	ctx context.Context,
+	directPath string,
+	encFileHash, fileHash, mediaKey []byte,
+	fileLength int,
+	mediaType MediaType,
+	mmsType string,
 ) (data []byte, err error) {
 	if !strings.HasPrefix(directPath, "/") {
 		return nil, fmt.Errorf("media download path does not start with slash: %s", directPath)
@@ -266,26 +285,102 @@ func (cli *Client) DownloadMediaWithPath(
 	if len(mmsType) == 0 {
 		mmsType = mediaTypeToMMSType[mediaType]
 	}
-	for i, host := range mediaConn.Hosts {
-		// TODO omit hash for unencrypted media?
-		mediaURL := fmt.Sprintf("https://%s%s&hash=%s&mms-type=%s&__wa-mms=", host.Hostname, directPath, base64.URLEncoding.EncodeToString(encFileHash), mmsType)
-		data, err = cli.downloadAndDecrypt(ctx, mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash)
-		if err == nil ||
-			errors.Is(err, ErrFileLengthMismatch) ||
-			errors.Is(err, ErrInvalidMediaSHA256) ||
-			errors.Is(err, ErrMediaDownloadFailedWith403) ||
-			errors.Is(err, ErrMediaDownloadFailedWith404) ||
-			errors.Is(err, ErrMediaDownloadFailedWith410) ||
-			errors.Is(err, context.Canceled) {
-			return
-		} else if i >= len(mediaConn.Hosts)-1 {
-			return nil, fmt.Errorf("failed to download media from last host: %w", err)
+	if encSize := cli.getParallelDownloadSize(mediaKey, encFileHash, fileLength); encSize > 0 {
+		buf := make(byteSliceWriterAt, encSize)
+		err = cli.downloadMediaParallel(ctx, mediaConn, directPath, encFileHash, mmsType, encSize, buf)
+		if err == nil {
+			if sha256.Sum256(buf) != *(*[32]byte)(encFileHash) {
+				err = ErrInvalidMediaEncSHA256
+			} else {
+				return decryptMedia(buf[:encSize-mediaHMACLength], buf[encSize-mediaHMACLength:], mediaKey, mediaType, fileLength, encFileHash, fileHash)
+			}
+		}
+		if isPermanentMediaDownloadError(err) {
+			return nil, err
 		}
-		cli.Log.Warnf("Failed to download media: %s, trying with next host...", err)
+		cli.Log.Warnf("Failed to download media in parallel: %v, falling back to a single request...", err)
 	}
+	err = cli.downloadFromMediaHosts(ctx, mediaConn, directPath, encFileHash, mmsType, func(mediaURL string) error {
+		var ciphertext, mac []byte
+		ciphertext, mac, err = cli.downloadPossiblyEncryptedMedia(ctx, mediaURL, encFileHash)
+		if err != nil {
+			return err
+		}
+		data, err = decryptMedia(ciphertext, mac, mediaKey, mediaType, fileLength, encFileHash, fileHash)
+		return err
+	})
 	return
 }
 
+func getMediaDownloadURL(hostname, directPath string, encFileHash []byte, mmsType string) string {
+	// TODO omit hash for unencrypted media?
+	return fmt.Sprintf("https://%s%s&hash=%s&mms-type=%s&__wa-mms=", hostname, directPath, base64.URLEncoding.EncodeToString(encFileHash), mmsType)
+}
+
+// isPermanentMediaDownloadError returns true if the given error means that trying another host won't help.
+func isPermanentMediaDownloadError(err error) bool {
+	return errors.Is(err, ErrFileLengthMismatch) ||
+		errors.Is(err, ErrTooShortFile) ||
+		errors.Is(err, ErrInvalidMediaSHA256) ||
+		errors.Is(err, ErrMediaDownloadFailedWith403) ||
+		errors.Is(err, ErrMediaDownloadFailedWith404) ||
+		errors.Is(err, ErrMediaDownloadFailedWith410) ||
+		errors.Is(err, context.Canceled)
+}
+
+const mediaDownloadRounds = 3
+
+// downloadFromMediaHosts calls fn with the download URL of each media host, ordered by health, until one succeeds
+// or returns an error that won't be fixed by trying another host. If all hosts failed and at least one of the errors
+// was a network error or a 5xx response, all hosts are tried again after a delay.
+func (cli *Client) downloadFromMediaHosts(
+	ctx context.Context,
+	mediaConn *MediaConn,
+	directPath string,
+	encFileHash []byte,
+	mmsType string,
+	fn func(mediaURL string) error,
+) (err error) {
+	for round := 0; round < mediaDownloadRounds; round++ {
+		if round > 0 {
+			retryDuration := time.Duration(round) * time.Second
+			var httpErr DownloadHTTPError
+			if errors.As(err, &httpErr) {
+				retryDuration = retryafter.Parse(httpErr.Response.Header.Get("Retry-After"), retryDuration)
+			}
+			cli.Log.Warnf("Failed to download media from all hosts: %v, retrying in %s...", err, retryDuration)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDuration):
+			}
+		}
+		var shouldRetry bool
+		hosts, _ := mediaConn.SortedHosts()
+		for i, host := range hosts {
+			err = fn(getMediaDownloadURL(host.Hostname, directPath, encFileHash, mmsType))
+			if err == nil {
+				mediaConn.recordHostResult(host.Hostname, true)
+				return nil
+			} else if isPermanentMediaDownloadError(err) || ctx.Err() != nil {
+				return err
+			}
+			mediaConn.recordHostResult(host.Hostname, false)
+			shouldRetry = shouldRetry || shouldRetryMediaDownload(err)
+			if i < len(hosts)-1 {
+				cli.Log.Warnf("Failed to download media from %s: %v, trying with next host...", host.Hostname, err)
+			}
+		}
+		if !shouldRetry {
+			break
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to download media from last host: %w", err)
+	}
+	return err
+}
+
 func (cli *Client) downloadAndDecrypt(
 	ctx context.Context,
 	url string,
@@ -295,11 +390,16 @@ func (cli *Client) downloadAndDecrypt(
 	fileEncSHA256,
 	fileSHA256 []byte,
 ) (data []byte, err error) {
-	iv, cipherKey, macKey, _ := getMediaKeys(mediaKey, appInfo)
 	var ciphertext, mac []byte
 	if ciphertext, mac, err = cli.downloadPossiblyEncryptedMediaWithRetries(ctx, url, fileEncSHA256); err != nil {
+		return
+	}
+	return decryptMedia(ciphertext, mac, mediaKey, appInfo, fileLength, fileEncSHA256, fileSHA256)
+}
 
-	} else if mediaKey == nil && fileEncSHA256 == nil && mac == nil {
+func decryptMedia(ciphertext, mac, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte) (data []byte, err error) {
+	iv, cipherKey, macKey, _ := getMediaKeys(mediaKey, appInfo)
+	if mediaKey == nil && fileEncSHA256 == nil && mac == nil {
 		// Unencrypted media, just return the downloaded data
 		data = ciphertext
 	} else if err = validateMedia(iv, ciphertext, macKey, mac); err != nil {
@@ -332,13 +432,18 @@ func shouldRetryMediaDownload(err error) bool {
 		(errors.As(err, &httpErr) && retryafter.Should(httpErr.StatusCode, true))
 }
 
+func (cli *Client) downloadPossiblyEncryptedMedia(ctx context.Context, url string, checksum []byte) (file, mac []byte, err error) {
+	if checksum == nil {
+		file, err = cli.downloadMedia(ctx, url)
+	} else {
+		file, mac, err = cli.downloadEncryptedMedia(ctx, url, checksum)
+	}
+	return
+}
+
 func (cli *Client) downloadPossiblyEncryptedMediaWithRetries(ctx context.Context, url string, checksum []byte) (file, mac []byte, err error) {
 	for retryNum := 0; retryNum < 5; retryNum++ {
-		if checksum == nil {
-			file, err = cli.downloadMedia(ctx, url)
-		} else {
-			file, mac, err = cli.downloadEncryptedMedia(ctx, url, checksum)
-		}
+		file, mac, err = cli.downloadPossiblyEncryptedMedia(ctx, url, checksum)
 		if err == nil || !shouldRetryMediaDownload(err) {
 			return
 		}
@@ -358,10 +463,22 @@ func (cli *Client) downloadPossiblyEncryptedMediaWithRetries(ctx context.Context
 }
 
 func (cli *Client) doMediaDownloadRequest(ctx context.Context, url string) (*http.Response, error) {
+	return cli.doMediaRangeRequest(ctx, url, 0, -1)
+}
+
+// doMediaRangeRequest requests the given byte range of a media file. If end is negative, the range extends to the end
+// of the file, and if start is also zero, the request is made without a Range header. If the server ignores the range,
+// the bytes before start are discarded, but the response body may still continue past end.
+func (cli *Client) doMediaRangeRequest(ctx context.Context, url string, start, end int64) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
+	if end >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	} else if start > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
 	req.Header.Set("Origin", socket.Origin)
 	req.Header.Set("Referer", socket.Origin+"/")
 	if cli.MessengerConfig != nil {
@@ -372,9 +489,17 @@ func (cli *Client) doMediaDownloadRequest(ctx context.Context, url string) (*htt
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusPartialContent && req.Header.Get("Range") != "" {
+		return resp, nil
+	} else if resp.StatusCode != http.StatusOK {
 		_ = resp.Body.Close()
 		return nil, DownloadHTTPError{Response: resp}
+	} else if start > 0 {
+		_, err = io.CopyN(io.Discard, resp.Body, start)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("failed to skip to start of requested range: %w", err)
+		}
 	}
 	return resp, nil
 }
@@ -386,6 +511,7 @@ func (cli *Client) downloadMedia(ctx context.Context, url string) ([]byte, error
 	}
 	data, err := io.ReadAll(resp.Body)
 	_ = resp.Body.Close()
+	cli.getMetrics().MediaTransfer(MediaTransferDownload, int64(len(data)), err)
 	return data, err
 }
 
@@ -0,0 +1,167 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mau.fi/util/ptr"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/util/cbcutil"
+	"go.mau.fi/whatsmeow/util/hkdfutil"
+)
+
+func TestDownloadStream(t *testing.T) {
+	ctx := context.Background()
+	plaintext := make([]byte, 100_000)
+	_, _ = rand.Read(plaintext)
+	mediaKey := make([]byte, 32)
+	_, _ = rand.Read(mediaKey)
+	keys := hkdfutil.SHA256(mediaKey, nil, []byte(whatsmeow.MediaDocument), 112)
+	var encrypted bytes.Buffer
+	fileSHA256, fileEncSHA256, _, _, err := cbcutil.EncryptStream(keys[16:48], keys[:16], keys[48:80], bytes.NewReader(plaintext), &encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rangeRequests int
+	serve := func(data []byte) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Range") != "" {
+				rangeRequests++
+			}
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		}))
+	}
+	srv := serve(encrypted.Bytes())
+	defer srv.Close()
+	cli := whatsmeow.NewClient(store.NoopDevice, nil)
+	msg := &waE2E.DocumentMessage{
+		URL:           ptr.Ptr(srv.URL + "/media"),
+		MediaKey:      mediaKey,
+		FileSHA256:    fileSHA256,
+		FileEncSHA256: fileEncSHA256,
+		FileLength:    ptr.Ptr(uint64(len(plaintext))),
+	}
+
+	stream, err := cli.DownloadStream(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	} else if !bytes.Equal(data, plaintext) {
+		t.Fatal("Streamed data doesn't match original")
+	} else if rangeRequests != 0 {
+		t.Fatalf("Sequential read made %d range requests", rangeRequests)
+	}
+
+	size, err := stream.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	} else if size != int64(len(plaintext)) {
+		t.Fatalf("Expected size %d, got %d", len(plaintext), size)
+	}
+	for _, offset := range []int64{0, 5, 16, 12345, size - 1} {
+		_, err = stream.Seek(offset, io.SeekStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 1000)
+		n, err := io.ReadFull(stream, buf)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("Failed to read at offset %d: %v", offset, err)
+		} else if !bytes.Equal(buf[:n], plaintext[offset:offset+int64(n)]) {
+			t.Fatalf("Data at offset %d doesn't match original", offset)
+		}
+	}
+	if rangeRequests == 0 {
+		t.Fatal("Seeking didn't make range requests")
+	}
+
+	tampered := bytes.Clone(encrypted.Bytes())
+	tampered[50_000] ^= 1
+	badSrv := serve(tampered)
+	defer badSrv.Close()
+	msg.URL = ptr.Ptr(badSrv.URL + "/media")
+	stream, err = cli.DownloadStream(ctx, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	_, err = io.Copy(io.Discard, stream)
+	if !errors.Is(err, whatsmeow.ErrInvalidMediaHMAC) {
+		t.Fatalf("Expected invalid HMAC error for tampered file, got %v", err)
+	}
+}
+
+func TestMediaDownloadFailover(t *testing.T) {
+	ctx, srv, _, _, cli := newPairedTestClients(t)
+
+	data := bytes.Repeat([]byte("purr"), 1<<18)
+	resp, err := cli.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	msg := &waE2E.DocumentMessage{
+		DirectPath:    ptr.Ptr(resp.DirectPath),
+		MediaKey:      resp.MediaKey,
+		FileSHA256:    resp.FileSHA256,
+		FileEncSHA256: resp.FileEncSHA256,
+		FileLength:    ptr.Ptr(resp.FileLength),
+	}
+	hosts := srv.MediaHosts()
+
+	cli.ParallelDownload = &whatsmeow.ParallelDownloadConfig{MinSize: 1, ChunkSize: 64 * 1024, Concurrency: 4}
+	downloaded, err := cli.Download(ctx, msg)
+	if err != nil {
+		t.Fatalf("Failed to download in parallel: %v", err)
+	} else if !bytes.Equal(downloaded, data) {
+		t.Fatal("Data downloaded in parallel doesn't match uploaded data")
+	}
+	for _, host := range hosts {
+		if requests := srv.MediaDownloadRequests(host); requests < 2 {
+			t.Errorf("Expected parallel download to use %s for multiple chunks, got %d requests", host, requests)
+		}
+	}
+	cli.ParallelDownload = nil
+
+	srv.SetMediaHostDown(hosts[0], true)
+	downloaded, err = cli.Download(ctx, msg)
+	if err != nil {
+		t.Fatalf("Failed to download with primary host down: %v", err)
+	} else if !bytes.Equal(downloaded, data) {
+		t.Fatal("Downloaded data doesn't match uploaded data")
+	}
+	mediaConn, err := cli.DangerousInternals().RefreshMediaConn(ctx, false)
+	if err != nil {
+		t.Fatalf("Failed to get media conn: %v", err)
+	} else if health := mediaConn.HostHealth(hosts[0]); health.ConsecutiveFailures != 1 {
+		t.Errorf("Expected primary host to have 1 failure, got %+v", health)
+	} else if sorted, healthy := mediaConn.SortedHosts(); sorted[0].Hostname != hosts[1] || healthy != 1 {
+		t.Errorf("Failed host wasn't moved to the end of the host list: %+v (%d healthy)", sorted, healthy)
+	}
+	// The failed host should be skipped for the next download
+	downloaded, err = cli.Download(ctx, msg)
+	if err != nil || !bytes.Equal(downloaded, data) {
+		t.Fatalf("Failed to download again: %v", err)
+	} else if health := mediaConn.HostHealth(hosts[0]); health.Failures != 1 {
+		t.Errorf("Failed host was tried again: %+v", health)
+	}
+}
@@ -36,6 +36,11 @@ var (
 	ErrNoPrivacyToken = errors.New("no privacy token stored")
 
 	ErrAppStateUpdate = errors.New("server returned error updating app state")
+
+	ErrSignedPreKeyRotationNotSupported = errors.New("device store doesn't support rotating signed prekeys")
+
+	ErrOutboxDisabled     = errors.New("outbox is not enabled")
+	ErrOutboxNotSupported = errors.New("device store doesn't support the outbox")
 )
 
 // Errors that happen while confirming device pairing
@@ -100,6 +105,10 @@ var (
 	ErrMediaNotAvailableOnPhone = errors.New("media no longer available on phone")
 	// ErrUnknownMediaRetryError is returned by DecryptMediaRetryNotification if the given event contains an unknown error code.
 	ErrUnknownMediaRetryError = errors.New("unknown media retry error")
+	// ErrMediaRetryTimeout is returned by DownloadWithMediaRetry if the phone doesn't respond to the media retry request in time.
+	ErrMediaRetryTimeout = errors.New("timed out waiting for media retry response from phone")
+	// ErrMediaRetryFailed is returned by DownloadWithMediaRetry if the phone responds with a non-success result.
+	ErrMediaRetryFailed = errors.New("phone failed to re-upload media")
 	// ErrInvalidDisappearingTimer is returned by SetDisappearingTimer if the given timer is not one of the allowed values.
 	ErrInvalidDisappearingTimer = errors.New("invalid disappearing timer provided")
 )
@@ -113,6 +122,20 @@ var (
 	ErrInvalidInlineBotID       = errors.New("invalid inline bot ID")
 )
 
+// ServerReturnedError is returned by Client.SendMessage if the server responds to the message with an error code.
+// It can be matched with errors.Is(err, ErrServerReturnedError).
+type ServerReturnedError struct {
+	Code int
+}
+
+func (sre *ServerReturnedError) Error() string {
+	return fmt.Sprintf("%s %d", ErrServerReturnedError.Error(), sre.Code)
+}
+
+func (sre *ServerReturnedError) Is(other error) bool {
+	return other == ErrServerReturnedError
+}
+
 type DownloadHTTPError struct {
 	*http.Response
 }
@@ -126,6 +149,15 @@ func (dhe DownloadHTTPError) Is(other error) bool {
 	return errors.As(other, &otherDHE) && dhe.StatusCode == otherDHE.StatusCode
 }
 
+// UploadHTTPError is returned by the Upload functions if the media server returns a non-200 status code.
+type UploadHTTPError struct {
+	*http.Response
+}
+
+func (uhe UploadHTTPError) Error() string {
+	return fmt.Sprintf("upload failed with status code %d", uhe.StatusCode)
+}
+
 // Some errors that Client.Download can return
 var (
 	ErrMediaDownloadFailedWith403 = DownloadHTTPError{Response: &http.Response{StatusCode: 403}}
@@ -0,0 +1,329 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/libsignal/ecc"
+	"go.mau.fi/libsignal/fingerprint"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waFingerprint"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	// fingerprintIterations is the number of SHA-512 iterations used for the numeric fingerprint, same as in Signal.
+	fingerprintIterations = 5200
+	// fingerprintVersion is the version prefix hashed into numeric fingerprints.
+	fingerprintVersion = 0
+	// scannableFingerprintVersion is the version of the CombinedFingerprint protobuf in QR codes.
+	scannableFingerprintVersion = 1
+)
+
+// Errors returned by the safety number functions.
+var (
+	ErrNoIdentityKey                  = errors.New("no identity key stored for user")
+	ErrFingerprintVersionMismatch     = errors.New("scanned fingerprint has unsupported version")
+	ErrFingerprintMissingFingerprint  = errors.New("scanned fingerprint is missing local or remote data")
+	ErrVerifiedIdentitiesNotSupported = errors.New("identity store doesn't support identity verification")
+)
+
+func (cli *Client) getVerifiedIdentityStore() (store.VerifiedIdentityStore, error) {
+	verifiedStore, ok := cli.Store.Identities.(store.VerifiedIdentityStore)
+	if !ok {
+		return nil, ErrVerifiedIdentitiesNotSupported
+	}
+	return verifiedStore, nil
+}
+
+// SafetyNumber contains the security code for the end-to-end encrypted chat with another user.
+type SafetyNumber struct {
+	// User is the other user.
+	User types.JID
+	// Identity is the identity key of the other user that the safety number was computed from.
+	Identity [32]byte
+	// Number is the 60-digit security code, usually displayed as 12 groups of 5 digits.
+	Number string
+	// QRPayload is the content of the QR code that the other user can scan to verify the code.
+	QRPayload []byte
+}
+
+// Groups returns the safety number split into the 12 groups of 5 digits that are displayed in the official apps.
+func (sn *SafetyNumber) Groups() []string {
+	groups := make([]string, 0, len(sn.Number)/5)
+	for i := 0; i+5 <= len(sn.Number); i += 5 {
+		groups = append(groups, sn.Number[i:i+5])
+	}
+	return groups
+}
+
+type fingerprintParty struct {
+	PN       types.JID
+	LID      types.JID
+	Identity [32]byte
+}
+
+func (fp *fingerprintParty) stableIdentifier() string {
+	if !fp.PN.IsEmpty() {
+		return fp.PN.User
+	}
+	return fp.LID.User
+}
+
+func (fp *fingerprintParty) serializedKey() []byte {
+	return append([]byte{ecc.DjbType}, fp.Identity[:]...)
+}
+
+func (fp *fingerprintParty) numericFingerprint() []byte {
+	pub := fp.serializedKey()
+	hash := make([]byte, 0, 2+len(pub)+len(fp.stableIdentifier()))
+	hash = append(hash, 0, fingerprintVersion)
+	hash = append(hash, pub...)
+	hash = append(hash, fp.stableIdentifier()...)
+	hasher := sha512.New()
+	for range fingerprintIterations {
+		hasher.Reset()
+		hasher.Write(hash)
+		hasher.Write(pub)
+		hash = hasher.Sum(hash[:0])
+	}
+	return hash[:30]
+}
+
+func (fp *fingerprintParty) proto() *waFingerprint.FingerprintData {
+	data := &waFingerprint.FingerprintData{
+		PublicKey:   fp.serializedKey(),
+		HostedState: waFingerprint.HostedState_E2EE.Enum(),
+	}
+	if !fp.PN.IsEmpty() {
+		data.PnIdentifier = []byte(fp.PN.User)
+	}
+	if !fp.LID.IsEmpty() {
+		data.LidIdentifier = []byte(fp.LID.User)
+	}
+	return data
+}
+
+func (fp *fingerprintParty) matches(data *waFingerprint.FingerprintData) bool {
+	if !bytes.Equal(data.GetPublicKey(), fp.serializedKey()) {
+		return false
+	}
+	pnMatches := len(data.PnIdentifier) > 0 && !fp.PN.IsEmpty() && string(data.PnIdentifier) == fp.PN.User
+	lidMatches := len(data.LidIdentifier) > 0 && !fp.LID.IsEmpty() && string(data.LidIdentifier) == fp.LID.User
+	pnMismatch := len(data.PnIdentifier) > 0 && !fp.PN.IsEmpty() && !pnMatches
+	lidMismatch := len(data.LidIdentifier) > 0 && !fp.LID.IsEmpty() && !lidMatches
+	return (pnMatches || lidMatches) && !pnMismatch && !lidMismatch
+}
+
+func (cli *Client) getFingerprintParties(ctx context.Context, user types.JID) (local, remote *fingerprintParty, err error) {
+	ownID := cli.getOwnID()
+	if ownID.IsEmpty() {
+		return nil, nil, ErrNotLoggedIn
+	}
+	local = &fingerprintParty{
+		PN:  ownID.ToNonAD(),
+		LID: cli.Store.GetLID().ToNonAD(),
+	}
+	// Safety numbers are based on the primary device's identity, which is the key that signed our device identity
+	if accountKey := cli.Store.Account.GetAccountSignatureKey(); len(accountKey) == 32 {
+		local.Identity = [32]byte(accountKey)
+	} else {
+		local.Identity = *cli.Store.IdentityKey.Pub
+	}
+	remote = &fingerprintParty{}
+	user = user.ToNonAD()
+	switch user.Server {
+	case types.DefaultUserServer:
+		remote.PN = user
+		remote.LID, err = cli.Store.LIDs.GetLIDForPN(ctx, user)
+	case types.HiddenUserServer:
+		remote.LID = user
+		remote.PN, err = cli.Store.LIDs.GetPNForLID(ctx, user)
+	default:
+		return nil, nil, fmt.Errorf("%w %s", ErrUnknownServer, user.Server)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get LID mapping: %w", err)
+	}
+	identities, err := cli.getVerifiedIdentityStore()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Sessions are migrated to LIDs, so prefer the LID identity if there is one
+	for _, jid := range []types.JID{remote.LID, remote.PN} {
+		if jid.IsEmpty() {
+			continue
+		}
+		// The primary device's identity is used for the whole account
+		identity, err := identities.GetIdentity(ctx, jid.SignalAddress().String())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get identity key of %s: %w", jid, err)
+		} else if identity != nil {
+			remote.Identity = *identity
+			return local, remote, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%w %s", ErrNoIdentityKey, user)
+}
+
+// GetSafetyNumber computes the safety number (also known as the security code) for the chat with the given user.
+//
+// The identity key of the user must already be known, i.e. there must have been at least one message
+// exchanged with the user's primary device.
+func (cli *Client) GetSafetyNumber(ctx context.Context, user types.JID) (*SafetyNumber, error) {
+	if cli == nil {
+		return nil, ErrClientIsNil
+	}
+	local, remote, err := cli.getFingerprintParties(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	display := fingerprint.NewDisplay(local.numericFingerprint(), remote.numericFingerprint())
+	qr, err := proto.Marshal(&waFingerprint.CombinedFingerprint{
+		Version:           proto.Uint32(scannableFingerprintVersion),
+		LocalFingerprint:  local.proto(),
+		RemoteFingerprint: remote.proto(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scannable fingerprint: %w", err)
+	}
+	return &SafetyNumber{
+		User:      user.ToNonAD(),
+		Identity:  remote.Identity,
+		Number:    display.DisplayText(),
+		QRPayload: qr,
+	}, nil
+}
+
+// VerifySafetyNumberQR checks a QR code scanned from the other user's device against our own safety number.
+//
+// If the fingerprint matches, the user's current identity is marked as verified (see IsIdentityVerified).
+// A mismatch is not an error: it's reported with a false return value. An error is only returned
+// if the payload is malformed or the safety number can't be computed.
+func (cli *Client) VerifySafetyNumberQR(ctx context.Context, user types.JID, scanned []byte) (bool, error) {
+	if cli == nil {
+		return false, ErrClientIsNil
+	}
+	var combined waFingerprint.CombinedFingerprint
+	err := proto.Unmarshal(scanned, &combined)
+	if err != nil {
+		return false, fmt.Errorf("failed to unmarshal scanned fingerprint: %w", err)
+	} else if combined.GetVersion() != scannableFingerprintVersion {
+		return false, fmt.Errorf("%w %d", ErrFingerprintVersionMismatch, combined.GetVersion())
+	} else if combined.LocalFingerprint == nil || combined.RemoteFingerprint == nil {
+		return false, ErrFingerprintMissingFingerprint
+	}
+	local, remote, err := cli.getFingerprintParties(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	// The scanned code was generated on the other device, so local and remote are swapped
+	if !remote.matches(combined.LocalFingerprint) || !local.matches(combined.RemoteFingerprint) {
+		return false, nil
+	}
+	return true, cli.putVerifiedIdentity(ctx, remote)
+}
+
+// MarkIdentityVerified marks the current identity of the given user as verified.
+//
+// This should be called after the user has compared the safety number from GetSafetyNumber manually.
+func (cli *Client) MarkIdentityVerified(ctx context.Context, user types.JID) error {
+	if cli == nil {
+		return ErrClientIsNil
+	}
+	_, remote, err := cli.getFingerprintParties(ctx, user)
+	if err != nil {
+		return err
+	}
+	return cli.putVerifiedIdentity(ctx, remote)
+}
+
+func (cli *Client) putVerifiedIdentity(ctx context.Context, remote *fingerprintParty) error {
+	identities, err := cli.getVerifiedIdentityStore()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, jid := range []types.JID{remote.PN, remote.LID} {
+		if jid.IsEmpty() {
+			continue
+		}
+		err := identities.PutVerifiedIdentity(ctx, store.VerifiedIdentity{
+			User:       jid,
+			Identity:   remote.Identity,
+			VerifiedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store verified identity of %s: %w", jid, err)
+		}
+	}
+	return nil
+}
+
+// IsIdentityVerified checks whether the current identity of the given user has been verified
+// with VerifySafetyNumberQR or MarkIdentityVerified.
+//
+// The verified state is cleared automatically when the user's identity changes (see events.IdentityChange).
+func (cli *Client) IsIdentityVerified(ctx context.Context, user types.JID) (bool, error) {
+	if cli == nil {
+		return false, ErrClientIsNil
+	}
+	identities, err := cli.getVerifiedIdentityStore()
+	if err != nil {
+		return false, err
+	}
+	verified, err := identities.GetVerifiedIdentity(ctx, user)
+	if err != nil {
+		return false, fmt.Errorf("failed to get verified identity: %w", err)
+	} else if verified == nil {
+		return false, nil
+	}
+	_, remote, err := cli.getFingerprintParties(ctx, user)
+	if errors.Is(err, ErrNoIdentityKey) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return remote.Identity == verified.Identity, nil
+}
+
+// ClearIdentityVerified removes the verified state of the given user.
+func (cli *Client) ClearIdentityVerified(ctx context.Context, user types.JID) error {
+	if cli == nil {
+		return ErrClientIsNil
+	}
+	identities, err := cli.getVerifiedIdentityStore()
+	if err != nil {
+		return err
+	}
+	user = user.ToNonAD()
+	err = identities.DeleteVerifiedIdentity(ctx, user)
+	if err != nil {
+		return err
+	}
+	var alt types.JID
+	switch user.Server {
+	case types.DefaultUserServer:
+		alt, err = cli.Store.LIDs.GetLIDForPN(ctx, user)
+	case types.HiddenUserServer:
+		alt, err = cli.Store.LIDs.GetPNForLID(ctx, user)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get LID mapping: %w", err)
+	} else if !alt.IsEmpty() {
+		return identities.DeleteVerifiedIdentity(ctx, alt)
+	}
+	return nil
+}
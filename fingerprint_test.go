@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+func TestSafetyNumber(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	aliceCli := pairClient(ctx, t, srv, alice)
+	bobCli := pairClient(ctx, t, srv, bob)
+
+	// Sending a message fetches the prekeys of the recipient's primary device, which stores their identity
+	_, err := aliceCli.SendMessage(ctx, bob.PN, &waE2E.Message{Conversation: proto.String("hello bob")})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	_, err = bobCli.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: proto.String("hello alice")})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+
+	aliceNumber, err := aliceCli.GetSafetyNumber(ctx, bob.PN)
+	if err != nil {
+		t.Fatalf("Failed to get safety number for bob: %v", err)
+	}
+	bobNumber, err := bobCli.GetSafetyNumber(ctx, alice.PN)
+	if err != nil {
+		t.Fatalf("Failed to get safety number for alice: %v", err)
+	}
+	if len(aliceNumber.Number) != 60 {
+		t.Errorf("Unexpected safety number length %d", len(aliceNumber.Number))
+	} else if aliceNumber.Number != bobNumber.Number {
+		t.Errorf("Safety numbers don't match: %s != %s", aliceNumber.Number, bobNumber.Number)
+	}
+
+	if ok, err := bobCli.VerifySafetyNumberQR(ctx, alice.PN, aliceNumber.QRPayload); err != nil {
+		t.Fatalf("Failed to verify QR payload: %v", err)
+	} else if !ok {
+		t.Fatalf("QR payload didn't match")
+	}
+	if ok, _ := bobCli.VerifySafetyNumberQR(ctx, alice.PN, bobNumber.QRPayload); ok {
+		t.Errorf("Own QR payload shouldn't match")
+	}
+	if verified, err := bobCli.IsIdentityVerified(ctx, alice.PN); err != nil || !verified {
+		t.Errorf("Alice wasn't marked as verified: %v", err)
+	}
+	if err = bobCli.ClearIdentityVerified(ctx, alice.PN); err != nil {
+		t.Fatalf("Failed to clear verified state: %v", err)
+	} else if verified, _ := bobCli.IsIdentityVerified(ctx, alice.PN); verified {
+		t.Errorf("Alice is still verified after clearing")
+	}
+}
@@ -529,6 +529,7 @@ func (cli *Client) GetJoinedGroups(ctx context.Context) ([]*types.GroupInfo, err
 			cli.Log.Warnf("Error parsing group %s: %v", parsed.JID, parseErr)
 		}
 		lidPairs, redactedPhones := cli.cacheGroupInfo(parsed, true)
+		cli.updateGroupSnapshot(ctx, parsed)
 		allLIDPairs = append(allLIDPairs, lidPairs...)
 		allRedactedPhones = append(allRedactedPhones, redactedPhones...)
 		infos = append(infos, parsed)
@@ -615,11 +616,11 @@ func (cli *Client) cacheGroupInfo(groupInfo *types.GroupInfo, lock bool) ([]stor
 		cli.groupCacheLock.Lock()
 		defer cli.groupCacheLock.Unlock()
 	}
-	cli.groupCache[groupInfo.JID] = &groupMetaCache{
+	cli.putCachedGroup(cli.BackgroundEventCtx, groupInfo.JID, &groupMetaCache{
 		AddressingMode:             groupInfo.AddressingMode,
 		CommunityAnnouncementGroup: groupInfo.IsAnnounce && groupInfo.IsDefaultSubGroup,
 		Members:                    participants,
-	}
+	})
 	return lidPairs, redactedPhones
 }
 
@@ -645,6 +646,7 @@ func (cli *Client) getGroupInfo(ctx context.Context, jid types.JID, lockParticip
 		return groupInfo, err
 	}
 	lidPairs, redactedPhones := cli.cacheGroupInfo(groupInfo, lockParticipantCache)
+	cli.updateGroupSnapshot(ctx, groupInfo)
 	err = cli.Store.LIDs.PutManyLIDMappings(ctx, lidPairs)
 	if err != nil {
 		cli.Log.Warnf("Failed to store LID mappings for members of %s: %v", jid, err)
@@ -659,14 +661,15 @@ func (cli *Client) getGroupInfo(ctx context.Context, jid types.JID, lockParticip
 func (cli *Client) getCachedGroupData(ctx context.Context, jid types.JID) (*groupMetaCache, error) {
 	cli.groupCacheLock.Lock()
 	defer cli.groupCacheLock.Unlock()
-	if val, ok := cli.groupCache[jid]; ok {
+	if val := cli.getCachedGroup(ctx, jid); val != nil {
 		return val, nil
 	}
 	_, err := cli.getGroupInfo(ctx, jid, false)
 	if err != nil {
 		return nil, err
 	}
-	return cli.groupCache[jid], nil
+	val, _ := cli.groupCache.peek(jid, 0)
+	return val, nil
 }
 
 func parseParticipant(childAG *waBinary.AttrUtility, child *waBinary.Node) types.GroupParticipant {
@@ -971,15 +974,26 @@ func (cli *Client) parseGroupChange(node *waBinary.Node) (*events.GroupInfo, []s
 
 func (cli *Client) updateGroupParticipantCache(evt *events.GroupInfo) {
 	// TODO can the addressing mode change here?
-	if len(evt.Join) == 0 && len(evt.Leave) == 0 {
+	if evt.Delete != nil || evt.Announce != nil || len(evt.UnknownChanges) > 0 {
+		// The announce flag affects the addressing of community announcement groups,
+		// and unknown changes might affect anything, so just drop the cache.
+		cli.groupCacheLock.Lock()
+		cli.deleteCachedGroup(cli.BackgroundEventCtx, evt.JID)
+		cli.groupCacheLock.Unlock()
+		return
+	} else if len(evt.Join) == 0 && len(evt.Leave) == 0 {
 		return
 	}
 	cli.groupCacheLock.Lock()
 	defer cli.groupCacheLock.Unlock()
-	cached, ok := cli.groupCache[evt.JID]
+	_, _, ttl := cli.getMetadataCacheLimits()
+	cached, ok := cli.groupCache.peek(evt.JID, ttl)
 	if !ok {
+		// Make sure an outdated member list isn't loaded from the persistent store later
+		cli.deleteCachedGroup(cli.BackgroundEventCtx, evt.JID)
 		return
 	}
+	defer cli.persistCachedGroup(cli.BackgroundEventCtx, evt.JID, cached)
 Outer:
 	for _, jid := range evt.Join {
 		for _, existingJID := range cached.Members {
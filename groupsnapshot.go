@@ -0,0 +1,182 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"slices"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// updateGroupSnapshot replaces the stored snapshot of the group with the given info,
+// and emits a GroupMembershipDiff if the participants changed compared to the previous snapshot.
+func (cli *Client) updateGroupSnapshot(ctx context.Context, info *types.GroupInfo) {
+	if !cli.EnableGroupSnapshots || cli.Store.GroupSnapshots == nil || info == nil {
+		return
+	}
+	cli.groupSnapshotLock.Lock()
+	defer cli.groupSnapshotLock.Unlock()
+	old, err := cli.Store.GroupSnapshots.GetGroupSnapshot(ctx, info.JID)
+	if err != nil {
+		cli.Log.Warnf("Failed to get snapshot of %s: %v", info.JID, err)
+	}
+	err = cli.Store.GroupSnapshots.PutGroupSnapshot(ctx, info)
+	if err != nil {
+		cli.Log.Warnf("Failed to save snapshot of %s: %v", info.JID, err)
+	}
+	if old == nil {
+		return
+	}
+	diff := diffGroupParticipants(old, info)
+	if len(diff.Join) > 0 || len(diff.Leave) > 0 || len(diff.Promote) > 0 || len(diff.Demote) > 0 {
+		cli.Log.Debugf(
+			"Snapshot of %s was outdated: %d joins, %d leaves, %d promotions, %d demotions",
+			info.JID, len(diff.Join), len(diff.Leave), len(diff.Promote), len(diff.Demote),
+		)
+		// This may be called while holding the group cache lock, so dispatch in the background
+		go cli.dispatchEvent(diff)
+	}
+}
+
+// applyGroupSnapshotChange applies the participant changes from a group notification to the stored snapshot.
+func (cli *Client) applyGroupSnapshotChange(ctx context.Context, evt *events.GroupInfo) {
+	if !cli.EnableGroupSnapshots || cli.Store.GroupSnapshots == nil {
+		return
+	}
+	cli.groupSnapshotLock.Lock()
+	defer cli.groupSnapshotLock.Unlock()
+	if evt.Delete != nil {
+		err := cli.Store.GroupSnapshots.DeleteGroupSnapshot(ctx, evt.JID)
+		if err != nil {
+			cli.Log.Warnf("Failed to delete snapshot of %s: %v", evt.JID, err)
+		}
+		return
+	} else if len(evt.Join) == 0 && len(evt.Leave) == 0 && len(evt.Promote) == 0 && len(evt.Demote) == 0 {
+		return
+	}
+	snapshot, err := cli.Store.GroupSnapshots.GetGroupSnapshot(ctx, evt.JID)
+	if err != nil {
+		cli.Log.Warnf("Failed to get snapshot of %s: %v", evt.JID, err)
+		return
+	} else if snapshot == nil {
+		return
+	}
+	for _, jid := range evt.Join {
+		if !slices.ContainsFunc(snapshot.Participants, func(part types.GroupParticipant) bool { return part.JID == jid }) {
+			participant := types.GroupParticipant{JID: jid}
+			if jid.Server == types.HiddenUserServer {
+				participant.LID = jid
+			} else {
+				participant.PhoneNumber = jid
+			}
+			snapshot.Participants = append(snapshot.Participants, participant)
+		}
+	}
+	snapshot.Participants = slices.DeleteFunc(snapshot.Participants, func(part types.GroupParticipant) bool {
+		return slices.Contains(evt.Leave, part.JID)
+	})
+	for i, part := range snapshot.Participants {
+		if slices.Contains(evt.Promote, part.JID) {
+			snapshot.Participants[i].IsAdmin = true
+		} else if slices.Contains(evt.Demote, part.JID) {
+			snapshot.Participants[i].IsAdmin = false
+			snapshot.Participants[i].IsSuperAdmin = false
+		}
+	}
+	snapshot.ParticipantCount = len(snapshot.Participants)
+	if evt.ParticipantVersionID != "" {
+		snapshot.ParticipantVersionID = evt.ParticipantVersionID
+	}
+	err = cli.Store.GroupSnapshots.PutGroupSnapshot(ctx, snapshot)
+	if err != nil {
+		cli.Log.Warnf("Failed to save snapshot of %s: %v", evt.JID, err)
+	}
+}
+
+// reconcileGroupSnapshots fetches the list of joined groups to find changes that were missed while the client
+// was offline. GetJoinedGroups updates the snapshots of all listed groups, so this only needs to remove
+// the snapshots of groups that are no longer listed.
+func (cli *Client) reconcileGroupSnapshots(ctx context.Context) {
+	if cli.Store.GroupSnapshots == nil {
+		return
+	}
+	jids, err := cli.Store.GroupSnapshots.GetGroupSnapshotJIDs(ctx)
+	if err != nil {
+		cli.Log.Errorf("Failed to get group snapshot list: %v", err)
+		return
+	} else if len(jids) == 0 {
+		return
+	}
+	cli.Log.Debugf("Reconciling %d group snapshots", len(jids))
+	groups, err := cli.GetJoinedGroups(ctx)
+	if err != nil {
+		cli.Log.Warnf("Failed to get joined groups to reconcile snapshots: %v", err)
+		return
+	}
+	joined := make(map[types.JID]struct{}, len(groups))
+	for _, group := range groups {
+		joined[group.JID] = struct{}{}
+	}
+	for _, jid := range jids {
+		if _, ok := joined[jid]; !ok {
+			cli.removeGroupSnapshot(ctx, jid)
+		}
+	}
+}
+
+// removeGroupSnapshot deletes the snapshot of a group that the user is no longer in,
+// and emits a GroupMembershipDiff with all previous participants as leaves.
+func (cli *Client) removeGroupSnapshot(ctx context.Context, jid types.JID) {
+	cli.groupSnapshotLock.Lock()
+	defer cli.groupSnapshotLock.Unlock()
+	old, err := cli.Store.GroupSnapshots.GetGroupSnapshot(ctx, jid)
+	if err != nil {
+		cli.Log.Warnf("Failed to get snapshot of %s: %v", jid, err)
+		return
+	} else if old == nil {
+		return
+	}
+	err = cli.Store.GroupSnapshots.DeleteGroupSnapshot(ctx, jid)
+	if err != nil {
+		cli.Log.Warnf("Failed to delete snapshot of %s: %v", jid, err)
+	}
+	cli.Log.Debugf("No longer in %s, removed snapshot", jid)
+	diff := diffGroupParticipants(old, nil)
+	go cli.dispatchEvent(diff)
+}
+
+func diffGroupParticipants(old, current *types.GroupInfo) *events.GroupMembershipDiff {
+	diff := &events.GroupMembershipDiff{JID: old.JID, Old: old, New: current}
+	oldParticipants := make(map[types.JID]types.GroupParticipant, len(old.Participants))
+	for _, part := range old.Participants {
+		oldParticipants[part.JID] = part
+	}
+	if current != nil {
+		for _, part := range current.Participants {
+			oldPart, ok := oldParticipants[part.JID]
+			if !ok {
+				diff.Join = append(diff.Join, part.JID)
+				continue
+			}
+			delete(oldParticipants, part.JID)
+			if part.IsAdmin && !oldPart.IsAdmin {
+				diff.Promote = append(diff.Promote, part.JID)
+			} else if !part.IsAdmin && oldPart.IsAdmin {
+				diff.Demote = append(diff.Demote, part.JID)
+			}
+		}
+	}
+	// Iterate over the old list rather than the map to keep the order stable
+	for _, part := range old.Participants {
+		if _, ok := oldParticipants[part.JID]; ok {
+			diff.Leave = append(diff.Leave, part.JID)
+		}
+	}
+	return diff
+}
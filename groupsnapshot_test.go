@@ -0,0 +1,86 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"slices"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestGroupSnapshots(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	carol := srv.NewAccount("10000000003")
+	dave := srv.NewAccount("10000000004")
+	group := srv.CreateGroup("Test group", bob, alice, carol)
+	cli := srv.NewClient(nil)
+	cli.EnableGroupSnapshots = true
+	pairExistingClient(ctx, t, srv, alice, cli)
+	diffs := make(chan *events.GroupMembershipDiff, 4)
+	cli.AddEventHandler(func(evt any) {
+		if diff, ok := evt.(*events.GroupMembershipDiff); ok {
+			diffs <- diff
+		}
+	})
+	if _, err := cli.GetGroupInfo(ctx, group.JID); err != nil {
+		t.Fatalf("Failed to get group info: %v", err)
+	}
+
+	// Change the group while the client is offline without sending any notifications
+	cli.Disconnect()
+	if err := srv.SetParticipants(group.JID, bob, alice, dave); err != nil {
+		t.Fatalf("Failed to change participants: %v", err)
+	} else if err = srv.SetAdmin(group.JID, alice, true); err != nil {
+		t.Fatalf("Failed to promote alice: %v", err)
+	}
+	if err := cli.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	select {
+	case diff := <-diffs:
+		if diff.JID != group.JID || diff.New == nil {
+			t.Errorf("Unexpected diff for %s (new info: %v)", diff.JID, diff.New)
+		}
+		if !slices.Equal(diff.Join, []types.JID{dave.LID}) {
+			t.Errorf("Expected %s to join, got %v", dave.LID, diff.Join)
+		}
+		if !slices.Equal(diff.Leave, []types.JID{carol.LID}) {
+			t.Errorf("Expected %s to leave, got %v", carol.LID, diff.Leave)
+		}
+		if !slices.Equal(diff.Promote, []types.JID{alice.LID}) || len(diff.Demote) != 0 {
+			t.Errorf("Expected %s to be promoted, got %v/%v", alice.LID, diff.Promote, diff.Demote)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Didn't get membership diff after reconnecting: %v", ctx.Err())
+	}
+
+	// Being removed from the group should be reported too
+	cli.Disconnect()
+	if err := srv.SetParticipants(group.JID, bob, dave); err != nil {
+		t.Fatalf("Failed to change participants: %v", err)
+	}
+	if err := cli.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	select {
+	case diff := <-diffs:
+		if diff.New != nil || !slices.Contains(diff.Leave, alice.LID) || len(diff.Leave) != 3 {
+			t.Errorf("Expected removal diff with all old participants leaving, got %v", diff.Leave)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Didn't get membership diff after being removed: %v", ctx.Err())
+	}
+	if snapshot, err := cli.Store.GroupSnapshots.GetGroupSnapshot(ctx, group.JID); err != nil {
+		t.Fatalf("Failed to get group snapshot: %v", err)
+	} else if snapshot != nil {
+		t.Errorf("Expected group snapshot to be deleted after removal")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"sync"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// handlerQueueKeys returns the ordering keys of the given node for parallel processing.
+//
+// Nodes that share any key are handled in the order they were received. The keys include the chat
+// (so events in a chat stay ordered) and the sender (so Signal sessions and sender keys are updated in order).
+// A nil return value means the node isn't specific to a chat and must be handled after all previous nodes.
+func (cli *Client) handlerQueueKeys(node *waBinary.Node) []string {
+	switch node.Tag {
+	case "message", "appdata", "receipt", "notification", "chatstate", "presence", "call":
+	default:
+		return nil
+	}
+	ag := node.AttrGetter()
+	from := ag.OptionalJIDOrEmpty("from")
+	if from.IsEmpty() || from.User == "" {
+		// Server notifications may affect any chat
+		return nil
+	}
+	keys := make([]string, 0, 4)
+	addKey := func(jid types.JID) {
+		if jid.IsEmpty() {
+			return
+		}
+		key := jid.ToNonAD().String()
+		for _, existing := range keys {
+			if existing == key {
+				return
+			}
+		}
+		keys = append(keys, key)
+	}
+	addKey(from)
+	for _, attr := range []string{"participant", "recipient", "participant_pn", "participant_lid", "sender_pn", "sender_lid", "peer_recipient_pn", "peer_recipient_lid"} {
+		addKey(ag.OptionalJIDOrEmpty(attr))
+	}
+	return keys
+}
+
+type handlerQueueTask struct {
+	node    *waBinary.Node
+	waitFor []<-chan struct{}
+	done    chan struct{}
+	keys    []string
+}
+
+// parallelHandlerQueueLoop is the same as handlerQueueLoop, but handles nodes in parallel using EventWorkers goroutines.
+func (cli *Client) parallelHandlerQueueLoop(evtCtx, connCtx context.Context, workers int) {
+	cli.Log.Debugf("Starting parallel handler queue loop with %d workers", workers)
+	var tailsLock sync.Mutex
+	tails := make(map[string]chan struct{})
+	var inFlight sync.WaitGroup
+	semaphore := make(chan struct{}, workers)
+	runTask := func(task *handlerQueueTask) {
+		defer func() {
+			tailsLock.Lock()
+			for _, key := range task.keys {
+				if tails[key] == task.done {
+					delete(tails, key)
+				}
+			}
+			tailsLock.Unlock()
+			close(task.done)
+			<-semaphore
+			inFlight.Done()
+		}()
+		for _, ch := range task.waitFor {
+			<-ch
+		}
+		// Like in the sequential loop, stuck handlers are left running in the background after a while,
+		// so they don't block the chat (and barrier nodes) forever.
+		cli.handleQueuedNode(evtCtx, task.node)
+	}
+	for {
+		var node *waBinary.Node
+		select {
+		case node = <-cli.handlerQueue:
+		case <-connCtx.Done():
+			cli.Log.Debugf("Closing parallel handler queue loop")
+			return
+		}
+		keys := cli.handlerQueueKeys(node)
+		if keys == nil {
+			// Nodes that aren't tied to a chat act as a barrier: wait for everything before them
+			// and block everything after them until they're handled.
+			inFlight.Wait()
+			cli.handleQueuedNode(evtCtx, node)
+			continue
+		}
+		select {
+		case semaphore <- struct{}{}:
+		case <-connCtx.Done():
+			cli.Log.Debugf("Closing parallel handler queue loop")
+			return
+		}
+		task := &handlerQueueTask{
+			node:    node,
+			waitFor: make([]<-chan struct{}, 0, len(keys)),
+			done:    make(chan struct{}),
+			keys:    keys,
+		}
+		tailsLock.Lock()
+		for _, key := range keys {
+			if prev, ok := tails[key]; ok {
+				task.waitFor = append(task.waitFor, prev)
+			}
+			tails[key] = task.done
+		}
+		tailsLock.Unlock()
+		inFlight.Add(1)
+		go runTask(task)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestParallelEventOrdering(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	carol := srv.NewAccount("10000000003")
+	group := srv.CreateGroup("Test group", bob, alice, carol)
+	cli := srv.NewClient(nil)
+	cli.EventWorkers = 4
+	pairExistingClient(ctx, t, srv, alice, cli)
+
+	const count = 20
+	connected := make(chan struct{}, 1)
+	received := make(chan *events.Message, 4*count)
+	cli.AddEventHandler(func(evt any) {
+		switch evt := evt.(type) {
+		case *events.Connected:
+			connected <- struct{}{}
+		case *events.Message:
+			if evt.Message.GetConversation() != "" {
+				// Slow handlers shouldn't block other chats, but must not reorder messages within a chat
+				time.Sleep(5 * time.Millisecond)
+				received <- evt
+			}
+		}
+	})
+	cli.Disconnect()
+	if err := cli.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	select {
+	case <-connected:
+	case <-ctx.Done():
+		t.Fatalf("Client didn't reconnect: %v", ctx.Err())
+	}
+
+	for i := range count {
+		text := proto.String(strconv.Itoa(i))
+		if _, err := bob.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: text}); err != nil {
+			t.Fatalf("Failed to send message from bob: %v", err)
+		} else if _, err = bob.Phone.SendMessage(ctx, group.JID, &waE2E.Message{Conversation: text}); err != nil {
+			t.Fatalf("Failed to send group message from bob: %v", err)
+		} else if _, err = carol.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: text}); err != nil {
+			t.Fatalf("Failed to send message from carol: %v", err)
+		} else if _, err = carol.Phone.SendMessage(ctx, group.JID, &waE2E.Message{Conversation: text}); err != nil {
+			t.Fatalf("Failed to send group message from carol: %v", err)
+		}
+	}
+	next := make(map[string]int)
+	for range 4 * count {
+		select {
+		case evt := <-received:
+			key := evt.Info.Chat.String() + "/" + evt.Info.Sender.User
+			if expected := strconv.Itoa(next[key]); evt.Message.GetConversation() != expected {
+				t.Fatalf("Unexpected message %q from %s, expected %q", evt.Message.GetConversation(), key, expected)
+			}
+			next[key]++
+		case <-ctx.Done():
+			t.Fatalf("Client didn't receive all messages: %v", ctx.Err())
+		}
+	}
+}
@@ -26,8 +26,23 @@ const WACertIssuerSerial = 0
 
 var WACertPubKey = [...]byte{0x14, 0x23, 0x75, 0x57, 0x4d, 0xa, 0x58, 0x71, 0x66, 0xaa, 0xe7, 0x1e, 0xbe, 0x51, 0x64, 0x37, 0xc4, 0xa2, 0x8b, 0x73, 0xe3, 0x69, 0x5c, 0x6c, 0xe1, 0xf7, 0xf9, 0x54, 0x5d, 0xa8, 0xee, 0x6b}
 
-// doHandshake implements the Noise_XX_25519_AESGCM_SHA256 handshake for the WhatsApp web API.
+// doHandshake performs the noise handshake for the WhatsApp web API.
+//
+// If EnableNoiseResume is set and the server's static key is known from a previous connection,
+// the shorter Noise_IK pattern is used, otherwise a full Noise_XX handshake is done.
 func (cli *Client) doHandshake(ctx context.Context, fs *socket.FrameSocket, ephemeralKP keys.KeyPair) error {
+	if cli.EnableNoiseResume && cli.Store.ID != nil && len(cli.Store.ServerStaticKey) == 32 {
+		err := cli.doResumeHandshake(ctx, fs, ephemeralKP, [32]byte(cli.Store.ServerStaticKey))
+		if err != nil && ctx.Err() == nil {
+			// Forget the key so that the next attempt does a full handshake
+			cli.Log.Warnf("Noise resume handshake failed, clearing cached server static key")
+			cli.Store.ServerStaticKey = nil
+			if saveErr := cli.Store.Save(ctx); saveErr != nil {
+				cli.Log.Warnf("Failed to clear cached server static key: %v", saveErr)
+			}
+		}
+		return err
+	}
 	nh := socket.NewNoiseHandshake()
 	nh.Start(socket.NoiseStartPattern, fs.Header)
 	nh.Authenticate(ephemeralKP.Pub[:])
@@ -43,27 +58,54 @@ func (cli *Client) doHandshake(ctx context.Context, fs *socket.FrameSocket, ephe
 	if err != nil {
 		return fmt.Errorf("failed to send handshake message: %w", err)
 	}
+	serverHello, err := readHandshakeResponse(fs)
+	if err != nil {
+		return err
+	}
+	return cli.finishXXHandshake(ctx, fs, nh, ephemeralKP, serverHello)
+}
+
+func readHandshakeResponse(fs *socket.FrameSocket) (*waWa6.HandshakeMessage_ServerHello, error) {
 	var resp []byte
 	select {
 	case resp = <-fs.Frames:
 	case <-time.After(NoiseHandshakeResponseTimeout):
-		return fmt.Errorf("timed out waiting for handshake response")
+		return nil, fmt.Errorf("timed out waiting for handshake response")
 	}
 	var handshakeResponse waWa6.HandshakeMessage
-	err = proto.Unmarshal(resp, &handshakeResponse)
+	err := proto.Unmarshal(resp, &handshakeResponse)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal handshake response: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal handshake response: %w", err)
 	}
-	serverEphemeral := handshakeResponse.GetServerHello().GetEphemeral()
-	serverStaticCiphertext := handshakeResponse.GetServerHello().GetStatic()
-	certificateCiphertext := handshakeResponse.GetServerHello().GetPayload()
+	return handshakeResponse.GetServerHello(), nil
+}
+
+func (cli *Client) getHandshakePayload() ([]byte, error) {
+	var clientPayload *waWa6.ClientPayload
+	if cli.GetClientPayload != nil {
+		clientPayload = cli.GetClientPayload()
+	} else {
+		clientPayload = cli.Store.GetClientPayload()
+	}
+	payload, err := proto.Marshal(clientPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal client finish payload: %w", err)
+	}
+	return payload, nil
+}
+
+// finishXXHandshake handles the server hello of a Noise_XX (or Noise_XXfallback) handshake and sends the client finish message.
+func (cli *Client) finishXXHandshake(ctx context.Context, fs *socket.FrameSocket, nh *socket.NoiseHandshake, ephemeralKP keys.KeyPair, serverHello *waWa6.HandshakeMessage_ServerHello) error {
+	serverEphemeral := serverHello.GetEphemeral()
+	serverStaticCiphertext := serverHello.GetStatic()
+	certificateCiphertext := serverHello.GetPayload()
 	if len(serverEphemeral) != 32 || serverStaticCiphertext == nil || certificateCiphertext == nil {
 		return fmt.Errorf("missing parts of handshake response")
 	}
 	serverEphemeralArr := *(*[32]byte)(serverEphemeral)
 
 	nh.Authenticate(serverEphemeral)
-	err = nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, serverEphemeralArr)
+	err := nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, serverEphemeralArr)
 	if err != nil {
 		return fmt.Errorf("failed to mix server ephemeral key in: %w", err)
 	}
@@ -82,7 +124,7 @@ func (cli *Client) doHandshake(ctx context.Context, fs *socket.FrameSocket, ephe
 	certDecrypted, err := nh.Decrypt(certificateCiphertext)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt noise certificate ciphertext: %w", err)
-	} else if err = verifyServerCert(certDecrypted, staticDecrypted); err != nil {
+	} else if err = verifyServerCert(certDecrypted, staticDecrypted, cli.getCertRootKey()); err != nil {
 		return fmt.Errorf("failed to verify server cert: %w", err)
 	}
 
@@ -92,19 +134,12 @@ func (cli *Client) doHandshake(ctx context.Context, fs *socket.FrameSocket, ephe
 		return fmt.Errorf("failed to mix noise private key in: %w", err)
 	}
 
-	var clientPayload *waWa6.ClientPayload
-	if cli.GetClientPayload != nil {
-		clientPayload = cli.GetClientPayload()
-	} else {
-		clientPayload = cli.Store.GetClientPayload()
-	}
-
-	clientFinishPayloadBytes, err := proto.Marshal(clientPayload)
+	clientFinishPayloadBytes, err := cli.getHandshakePayload()
 	if err != nil {
-		return fmt.Errorf("failed to marshal client finish payload: %w", err)
+		return err
 	}
 	encryptedClientFinishPayload := nh.Encrypt(clientFinishPayloadBytes)
-	data, err = proto.Marshal(&waWa6.HandshakeMessage{
+	data, err := proto.Marshal(&waWa6.HandshakeMessage{
 		ClientFinish: &waWa6.HandshakeMessage_ClientFinish{
 			Static:  encryptedPubkey,
 			Payload: encryptedClientFinishPayload,
@@ -124,11 +159,113 @@ func (cli *Client) doHandshake(ctx context.Context, fs *socket.FrameSocket, ephe
 	}
 
 	cli.socket = ns
+	cli.cacheServerStaticKey(ctx, staticDecrypted)
+
+	return nil
+}
+
+// doResumeHandshake implements the Noise_IK_25519_AESGCM_SHA256 handshake, which skips one round trip
+// by encrypting the client's static key and login payload to the server's static key that was cached
+// from a previous connection.
+//
+// If the server can't decrypt the first message (e.g. because its static key changed), it responds with
+// the second message of a Noise_XXfallback handshake instead, which is handled like a normal XX handshake.
+func (cli *Client) doResumeHandshake(ctx context.Context, fs *socket.FrameSocket, ephemeralKP keys.KeyPair, serverStatic [32]byte) error {
+	// The header is cleared after the first frame is sent, but the fallback handshake needs it too
+	header := fs.Header
+	nh := socket.NewNoiseHandshake()
+	nh.Start(socket.NoiseResumePattern, header)
+	nh.Authenticate(serverStatic[:])
+	nh.Authenticate(ephemeralKP.Pub[:])
+	err := nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, serverStatic)
+	if err != nil {
+		return fmt.Errorf("failed to mix server static key in: %w", err)
+	}
+	encryptedPubkey := nh.Encrypt(cli.Store.NoiseKey.Pub[:])
+	err = nh.MixSharedSecretIntoKey(*cli.Store.NoiseKey.Priv, serverStatic)
+	if err != nil {
+		return fmt.Errorf("failed to mix noise private key in: %w", err)
+	}
+	payload, err := cli.getHandshakePayload()
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(&waWa6.HandshakeMessage{
+		ClientHello: &waWa6.HandshakeMessage_ClientHello{
+			Ephemeral: ephemeralKP.Pub[:],
+			Static:    encryptedPubkey,
+			Payload:   nh.Encrypt(payload),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal resume handshake message: %w", err)
+	}
+	err = fs.SendFrame(data)
+	if err != nil {
+		return fmt.Errorf("failed to send resume handshake message: %w", err)
+	}
+	serverHello, err := readHandshakeResponse(fs)
+	if err != nil {
+		return err
+	}
+	if serverHello.GetStatic() != nil {
+		cli.Log.Debugf("Server rejected noise resume handshake, falling back to full handshake")
+		nh = socket.NewNoiseHandshake()
+		nh.Start(socket.NoiseFallbackPattern, header)
+		nh.Authenticate(ephemeralKP.Pub[:])
+		return cli.finishXXHandshake(ctx, fs, nh, ephemeralKP, serverHello)
+	}
+
+	serverEphemeral := serverHello.GetEphemeral()
+	if len(serverEphemeral) != 32 || serverHello.GetPayload() == nil {
+		return fmt.Errorf("missing parts of resume handshake response")
+	}
+	serverEphemeralArr := *(*[32]byte)(serverEphemeral)
+	nh.Authenticate(serverEphemeral)
+	if err = nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, serverEphemeralArr); err != nil {
+		return fmt.Errorf("failed to mix server ephemeral key in: %w", err)
+	} else if err = nh.MixSharedSecretIntoKey(*cli.Store.NoiseKey.Priv, serverEphemeralArr); err != nil {
+		return fmt.Errorf("failed to mix noise private key with server ephemeral key: %w", err)
+	}
+	certDecrypted, err := nh.Decrypt(serverHello.GetPayload())
+	if err != nil {
+		return fmt.Errorf("failed to decrypt resume handshake payload: %w", err)
+	} else if err = verifyServerCert(certDecrypted, serverStatic[:], cli.getCertRootKey()); err != nil {
+		return fmt.Errorf("failed to verify server cert: %w", err)
+	}
 
+	ns, err := nh.Finish(ctx, fs, cli.handleFrame, cli.onDisconnect)
+	if err != nil {
+		return fmt.Errorf("failed to create noise socket: %w", err)
+	}
+	cli.socket = ns
 	return nil
 }
 
-func verifyServerCert(certDecrypted, staticDecrypted []byte) error {
+// cacheServerStaticKey stores the server's static key after a successful full handshake
+// so that the next connection can use the resume handshake.
+func (cli *Client) cacheServerStaticKey(ctx context.Context, key []byte) {
+	if !cli.EnableNoiseResume || bytes.Equal(cli.Store.ServerStaticKey, key) {
+		return
+	}
+	cli.Store.ServerStaticKey = bytes.Clone(key)
+	// Devices that aren't paired yet are saved after pairing, which will include the key
+	if cli.Store.ID != nil {
+		err := cli.Store.Save(ctx)
+		if err != nil {
+			cli.Log.Warnf("Failed to save server static key: %v", err)
+		}
+	}
+}
+
+func (cli *Client) getCertRootKey() [32]byte {
+	if cli.CertRootKey != nil {
+		return *cli.CertRootKey
+	}
+	return WACertPubKey
+}
+
+func verifyServerCert(certDecrypted, staticDecrypted []byte, rootKey [32]byte) error {
 	var certChain waCert.CertChain
 	err := proto.Unmarshal(certDecrypted, &certChain)
 	if err != nil {
@@ -145,7 +282,7 @@ func verifyServerCert(certDecrypted, staticDecrypted []byte) error {
 		return fmt.Errorf("unexpected length of intermediate cert signature %d (expected 64)", len(intermediateCertSignature))
 	} else if len(leafCertSignature) != 64 {
 		return fmt.Errorf("unexpected length of leaf cert signature %d (expected 64)", len(leafCertSignature))
-	} else if !ecc.VerifySignature(ecc.NewDjbECPublicKey(WACertPubKey), intermediateCertDetailsRaw, [64]byte(intermediateCertSignature)) {
+	} else if !ecc.VerifySignature(ecc.NewDjbECPublicKey(rootKey), intermediateCertDetailsRaw, [64]byte(intermediateCertSignature)) {
 		return fmt.Errorf("failed to verify intermediate cert signature")
 	} else if err = proto.Unmarshal(intermediateCertDetailsRaw, &intermediateCertDetails); err != nil {
 		return fmt.Errorf("failed to unmarshal noise certificate details: %w", err)
@@ -0,0 +1,46 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"bytes"
+	"testing"
+
+	"go.mau.fi/whatsmeow/testserver"
+)
+
+func TestNoiseResume(t *testing.T) {
+	ctx, srv, _, _, cli := newPairedTestClients(t)
+	cli.EnableNoiseResume = true
+
+	reconnect := func(expected testserver.HandshakeStats) {
+		t.Helper()
+		cli.Disconnect()
+		if err := cli.Connect(); err != nil {
+			t.Fatalf("Failed to reconnect: %v", err)
+		} else if err = srv.WaitConnected(ctx, *cli.Store.ID); err != nil {
+			t.Fatalf("Client didn't log in after reconnecting: %v", err)
+		} else if stats := srv.HandshakeStats(); stats != expected {
+			t.Fatalf("Unexpected handshake stats %+v, expected %+v", stats, expected)
+		}
+	}
+	// The first connection after pairing happened before EnableNoiseResume was set, so the key isn't cached yet
+	reconnect(testserver.HandshakeStats{Full: 3})
+	if len(cli.Store.ServerStaticKey) != 32 {
+		t.Fatalf("Server static key wasn't cached after full handshake")
+	}
+	reconnect(testserver.HandshakeStats{Full: 3, Resumed: 1})
+	if err := srv.RotateStaticKey(); err != nil {
+		t.Fatalf("Failed to rotate server static key: %v", err)
+	}
+	oldKey := cli.Store.ServerStaticKey
+	reconnect(testserver.HandshakeStats{Full: 3, Resumed: 1, Fallback: 1})
+	if bytes.Equal(oldKey, cli.Store.ServerStaticKey) {
+		t.Fatalf("Server static key wasn't updated after fallback handshake")
+	}
+	reconnect(testserver.HandshakeStats{Full: 3, Resumed: 2, Fallback: 1})
+}
@@ -143,6 +143,10 @@ func (int *DangerousInternalClient) AutoReconnect(ctx context.Context) {
 	int.c.autoReconnect(ctx)
 }
 
+func (int *DangerousInternalClient) AutoReconnectWithCause(ctx context.Context, cause ReconnectCause) {
+	int.c.autoReconnectWithCause(ctx, cause)
+}
+
 func (int *DangerousInternalClient) UnlockedDisconnect() {
 	int.c.unlockedDisconnect()
 }
@@ -715,7 +719,7 @@ func (int *DangerousInternalClient) GetFBIDDevicesInternal(ctx context.Context,
 	return int.c.getFBIDDevicesInternal(ctx, jids)
 }
 
-func (int *DangerousInternalClient) GetFBIDDevices(ctx context.Context, jids []types.JID) ([]types.JID, error) {
+func (int *DangerousInternalClient) GetFBIDDevices(ctx context.Context, jids []types.JID) ([]types.JID, []*store.CachedDevices, error) {
 	return int.c.getFBIDDevices(ctx, jids)
 }
 
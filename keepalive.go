@@ -45,9 +45,10 @@ func (cli *Client) keepAliveLoop(ctx, connCtx context.Context) {
 				})
 				if cli.EnableAutoReconnect && time.Since(lastSuccess) > KeepAliveMaxFailTime {
 					cli.Log.Debugf("Forcing reconnect due to keepalive failure")
+					cli.setConnectionState(types.ConnectionStateDisconnected, string(ReconnectCauseKeepAliveTimeout))
 					cli.Disconnect()
 					cli.resetExpectedDisconnect()
-					go cli.autoReconnect(ctx)
+					go cli.autoReconnectWithCause(ctx, ReconnectCauseKeepAliveTimeout)
 				}
 			} else {
 				if errorCount > 0 {
@@ -63,6 +64,7 @@ func (cli *Client) keepAliveLoop(ctx, connCtx context.Context) {
 }
 
 func (cli *Client) sendKeepAlive(ctx context.Context) (isSuccess, shouldContinue bool) {
+	start := time.Now()
 	respCh, err := cli.sendIQAsync(ctx, infoQuery{
 		Namespace: "w:p",
 		Type:      "get",
@@ -72,14 +74,17 @@ func (cli *Client) sendKeepAlive(ctx context.Context) (isSuccess, shouldContinue
 		return false, false
 	} else if err != nil {
 		cli.Log.Warnf("Failed to send keepalive: %v", err)
+		cli.getMetrics().KeepAlive(time.Since(start), err)
 		return false, true
 	}
 	select {
 	case <-respCh:
 		// All good
+		cli.getMetrics().KeepAlive(time.Since(start), nil)
 		return true, true
 	case <-time.After(KeepAliveResponseDeadline):
 		cli.Log.Warnf("Keepalive timed out")
+		cli.getMetrics().KeepAlive(time.Since(start), ErrIQTimedOut)
 		return false, true
 	case <-ctx.Done():
 		return false, false
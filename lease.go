@@ -0,0 +1,161 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mau.fi/util/random"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// SessionLeaseConfig contains options for the session lease, which prevents multiple processes
+// from connecting the same device at the same time (which would make them keep replacing each other's streams).
+//
+// The lease is acquired from Store.Leases before connecting and renewed periodically while the client is connected
+// or reconnecting. It's released by Disconnect. If another process holds the lease, connecting fails with
+// store.ErrSessionLeaseHeld. If InitialAutoReconnect is enabled, the client will keep trying to acquire the lease
+// in the background, which can be used for active/passive failover between hosts.
+type SessionLeaseConfig struct {
+	// Owner identifies this process. Defaults to the hostname, process ID and a random suffix.
+	Owner string
+	// TTL is how long the lease is valid without renewal. Defaults to 30 seconds.
+	TTL time.Duration
+	// RenewInterval is how often the lease is renewed. Defaults to a third of TTL.
+	RenewInterval time.Duration
+}
+
+const sessionLeaseReleaseTimeout = 10 * time.Second
+
+func (cli *Client) getSessionLeaseOwner() string {
+	if cli.SessionLease.Owner != "" {
+		return cli.SessionLease.Owner
+	} else if cli.sessionLeaseOwner == "" {
+		hostname, _ := os.Hostname()
+		cli.sessionLeaseOwner = fmt.Sprintf("%s/%d/%s", hostname, os.Getpid(), random.String(8))
+	}
+	return cli.sessionLeaseOwner
+}
+
+func (cli *Client) getSessionLeaseTTL() (ttl, renewInterval time.Duration) {
+	ttl, renewInterval = cli.SessionLease.TTL, cli.SessionLease.RenewInterval
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	if renewInterval <= 0 || renewInterval >= ttl {
+		renewInterval = ttl / 3
+	}
+	return
+}
+
+// acquireSessionLease takes the session lease if it's enabled and starts the renewal loop.
+// This must be called while holding socketLock.
+func (cli *Client) acquireSessionLease(ctx context.Context) error {
+	if cli.SessionLease == nil || cli.Store.ID == nil || cli.Store.Leases == nil {
+		return nil
+	}
+	// Wait for a previous release that's still in progress, so it doesn't delete the new lease
+	cli.sessionLeaseReleasing.Lock()
+	cli.sessionLeaseReleasing.Unlock()
+	ttl, renewInterval := cli.getSessionLeaseTTL()
+	owner := cli.getSessionLeaseOwner()
+	expiresAt := time.Now().Add(ttl)
+	// The store is captured here so that the renewal loop doesn't need to read the device store,
+	// which is written concurrently when the device is logged out.
+	jid, leases := *cli.Store.ID, cli.Store.Leases
+	err := leases.AcquireSessionLease(ctx, owner, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to acquire session lease: %w", err)
+	}
+	if cli.stopSessionLease == nil {
+		cli.Log.Debugf("Acquired session lease for %s as %s", jid, owner)
+		leaseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		cli.stopSessionLease = cancel
+		cli.sessionLeaseStore = leases
+		go cli.renewSessionLeaseLoop(leaseCtx, leases, jid, owner, ttl, renewInterval, expiresAt)
+	}
+	return nil
+}
+
+func (cli *Client) renewSessionLeaseLoop(
+	ctx context.Context,
+	leases store.SessionLeaseStore,
+	jid types.JID,
+	owner string,
+	ttl, renewInterval time.Duration,
+	expiresAt time.Time,
+) {
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		newExpiry := time.Now().Add(ttl)
+		err := leases.RenewSessionLease(ctx, owner, newExpiry)
+		if ctx.Err() != nil {
+			return
+		} else if err == nil {
+			expiresAt = newExpiry
+			continue
+		} else if cli.ConnectionState() == types.ConnectionStateLoggedOut {
+			// The device was logged out and deleted, which deletes the lease too
+			return
+		} else if !errors.Is(err, store.ErrSessionLeaseHeld) && time.Now().Before(expiresAt) {
+			cli.Log.Warnf("Failed to renew session lease for %s (will retry): %v", jid, err)
+			continue
+		}
+		cli.Log.Errorf("Lost session lease for %s, disconnecting: %v", jid, err)
+		cli.Disconnect()
+		cli.dispatchEvent(&events.SessionLeaseLost{Error: err})
+		return
+	}
+}
+
+// stopSessionLeaseRenewal stops the renewal loop and returns the store that the lease should be released from,
+// or nil if there's nothing to release. This must be called while holding socketLock.
+func (cli *Client) stopSessionLeaseRenewal() store.SessionLeaseStore {
+	if cli.stopSessionLease == nil {
+		return nil
+	}
+	cli.stopSessionLease()
+	cli.stopSessionLease = nil
+	leases := cli.sessionLeaseStore
+	cli.sessionLeaseStore = nil
+	if cli.ConnectionState() == types.ConnectionStateLoggedOut {
+		// Deleting the device deletes the lease too
+		return nil
+	}
+	cli.sessionLeaseReleasing.Lock()
+	return leases
+}
+
+// releaseSessionLease releases the session lease in the store returned by stopSessionLeaseRenewal.
+// This should be called after unlocking socketLock, so that a slow database doesn't block connecting and disconnecting.
+func (cli *Client) releaseSessionLease(leases store.SessionLeaseStore) {
+	if leases == nil {
+		return
+	}
+	defer cli.sessionLeaseReleasing.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), sessionLeaseReleaseTimeout)
+	defer cancel()
+	err := leases.ReleaseSessionLease(ctx, cli.getSessionLeaseOwner())
+	if err != nil {
+		cli.Log.Warnf("Failed to release session lease: %v", err)
+	} else {
+		cli.Log.Debugf("Released session lease")
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"errors"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+)
+
+func TestSessionLease(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	active := pairClient(ctx, t, srv, alice)
+	active.Disconnect()
+	active.SessionLease = &whatsmeow.SessionLeaseConfig{Owner: "active"}
+	if err := active.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect with lease: %v", err)
+	}
+
+	passive := whatsmeow.NewClient(active.Store, nil)
+	srv.Configure(passive)
+	passive.SessionLease = &whatsmeow.SessionLeaseConfig{Owner: "passive"}
+	defer passive.Disconnect()
+	if err := passive.Connect(); !errors.Is(err, store.ErrSessionLeaseHeld) {
+		t.Fatalf("Expected session lease error when connecting second client, got %v", err)
+	}
+	active.Disconnect()
+	if err := passive.Connect(); err != nil {
+		t.Fatalf("Failed to connect second client after first one released the lease: %v", err)
+	} else if err = srv.WaitConnected(ctx, *passive.Store.ID); err != nil {
+		t.Fatalf("Second client didn't log in: %v", err)
+	}
+	if err := active.Connect(); !errors.Is(err, store.ErrSessionLeaseHeld) {
+		t.Fatalf("Expected session lease error when reconnecting first client, got %v", err)
+	}
+}
@@ -0,0 +1,428 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"go.mau.fi/util/ptr"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// ManagerContainer is the part of a device container that the Manager needs.
+// It's implemented by both sqlstore.Container and memstore.Container.
+type ManagerContainer interface {
+	GetAllDevices(ctx context.Context) ([]*store.Device, error)
+	NewDevice() *store.Device
+}
+
+// ManagedEvent is an event emitted by one of the clients owned by a Manager.
+type ManagedEvent struct {
+	// Account is the device JID of the client, or an empty JID if the client hasn't been paired yet.
+	Account types.JID
+	Client  *Client
+	Event   any
+}
+
+// ManagedEventHandler is a function that receives events from all clients of a Manager.
+type ManagedEventHandler func(evt *ManagedEvent)
+
+type wrappedManagedEventHandler struct {
+	fn ManagedEventHandler
+	id uint32
+}
+
+var (
+	ErrManagerNotStarted     = errors.New("manager hasn't been started")
+	ErrManagerAlreadyStarted = errors.New("manager has already been started")
+	ErrManagerStopped        = errors.New("manager has been stopped")
+)
+
+// Manager owns one Client for each device in a container and manages their lifecycles.
+//
+// All clients share the same HTTP clients for media, websockets and pre-login requests.
+// Note that this means Client.SetProxy on one client will also change the proxy of all other clients,
+// so proxies should be configured with SetProxy on the manager instead.
+type Manager struct {
+	Container ManagerContainer
+	Log       waLog.Logger
+
+	// MaxConcurrentConnects is the maximum number of clients that are connecting at the same time in Start.
+	// Defaults to 4.
+	MaxConcurrentConnects int
+	// ConfigureClient is called for every client before it's connected, which can be used to set options
+	// like EnableAutoReconnect or add event handlers that need direct access to the client.
+	ConfigureClient func(cli *Client)
+
+	mediaHTTP     *http.Client
+	websocketHTTP *http.Client
+	preLoginHTTP  *http.Client
+
+	clients  map[types.JID]*Client
+	accounts map[*Client]types.JID
+	pairing  map[*Client]struct{}
+	lock     sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopped  bool
+	inFlight sync.WaitGroup
+
+	eventHandlers     []wrappedManagedEventHandler
+	eventHandlersLock sync.RWMutex
+	nextHandlerID     atomic.Uint32
+}
+
+// NewManager creates a new Manager for the devices in the given container.
+// Call Start to create and connect clients for all existing devices.
+func NewManager(container ManagerContainer, log waLog.Logger) *Manager {
+	if log == nil {
+		log = waLog.Noop
+	}
+	baseHTTPClient := &http.Client{
+		Transport: (http.DefaultTransport.(*http.Transport)).Clone(),
+	}
+	return &Manager{
+		Container: container,
+		Log:       log,
+
+		mediaHTTP:     ptr.Clone(baseHTTPClient),
+		websocketHTTP: ptr.Clone(baseHTTPClient),
+		preLoginHTTP:  ptr.Clone(baseHTTPClient),
+
+		clients:  make(map[types.JID]*Client),
+		accounts: make(map[*Client]types.JID),
+		pairing:  make(map[*Client]struct{}),
+	}
+}
+
+// SetProxy sets the proxy for the HTTP clients shared by all clients of the manager.
+// This should be called before Start.
+func (mgr *Manager) SetProxy(proxy Proxy, opts ...SetProxyOptions) {
+	var opt SetProxyOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	transport := (http.DefaultTransport.(*http.Transport)).Clone()
+	transport.Proxy = proxy
+	if !opt.NoWebsocket {
+		mgr.preLoginHTTP.Transport = transport
+		if !opt.OnlyLogin {
+			mgr.websocketHTTP.Transport = transport
+		}
+	}
+	if !opt.NoMedia {
+		mgr.mediaHTTP.Transport = transport
+	}
+}
+
+// AddEventHandler registers a function to receive events from all clients of the manager.
+//
+// Handlers are called synchronously from the client's event dispatcher, like handlers added with Client.AddEventHandler.
+func (mgr *Manager) AddEventHandler(handler ManagedEventHandler) uint32 {
+	id := mgr.nextHandlerID.Add(1)
+	mgr.eventHandlersLock.Lock()
+	mgr.eventHandlers = append(mgr.eventHandlers, wrappedManagedEventHandler{handler, id})
+	mgr.eventHandlersLock.Unlock()
+	return id
+}
+
+// RemoveEventHandler removes a handler added with AddEventHandler. If the handler is found, this returns true.
+//
+// Like Client.RemoveEventHandler, this must not be called directly from an event handler.
+func (mgr *Manager) RemoveEventHandler(id uint32) bool {
+	mgr.eventHandlersLock.Lock()
+	defer mgr.eventHandlersLock.Unlock()
+	for index := range mgr.eventHandlers {
+		if mgr.eventHandlers[index].id == id {
+			mgr.eventHandlers = append(mgr.eventHandlers[:index], mgr.eventHandlers[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (mgr *Manager) dispatchEvent(account types.JID, cli *Client, evt any) {
+	managedEvt := &ManagedEvent{Account: account, Client: cli, Event: evt}
+	mgr.eventHandlersLock.RLock()
+	defer mgr.eventHandlersLock.RUnlock()
+	for _, handler := range mgr.eventHandlers {
+		handler.fn(managedEvt)
+	}
+}
+
+func (mgr *Manager) newClient(device *store.Device) *Client {
+	log := mgr.Log.Sub("Pairing")
+	if device.ID != nil {
+		log = mgr.Log.Sub(device.ID.String())
+	}
+	cli := NewClient(device, log)
+	cli.SetMediaHTTPClient(mgr.mediaHTTP)
+	cli.SetWebsocketHTTPClient(mgr.websocketHTTP)
+	cli.SetPreLoginHTTPClient(mgr.preLoginHTTP)
+	cli.AddEventHandler(func(evt any) {
+		mgr.handleClientEvent(cli, evt)
+	})
+	if mgr.ConfigureClient != nil {
+		mgr.ConfigureClient(cli)
+	}
+	return cli
+}
+
+func (mgr *Manager) isStopped() bool {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	return mgr.stopped
+}
+
+func (mgr *Manager) addClient(jid types.JID, cli *Client) {
+	mgr.clients[jid] = cli
+	mgr.accounts[cli] = jid
+}
+
+func (mgr *Manager) removeClient(jid types.JID) (*Client, bool) {
+	cli, ok := mgr.clients[jid]
+	if ok {
+		delete(mgr.clients, jid)
+		delete(mgr.accounts, cli)
+	}
+	return cli, ok
+}
+
+func (mgr *Manager) handleClientEvent(cli *Client, evt any) {
+	// The device store is written by the client's own goroutines (e.g. while pairing),
+	// so the account JID is taken from the event or the manager's own state instead of cli.Store.
+	mgr.lock.Lock()
+	account := mgr.accounts[cli]
+	switch typedEvt := evt.(type) {
+	case *events.PairSuccess:
+		account = typedEvt.ID
+		delete(mgr.pairing, cli)
+		if !mgr.stopped {
+			mgr.addClient(account, cli)
+		}
+	case *events.LoggedOut:
+		// The client deletes the device from the store by itself, so it just needs to be forgotten here.
+		if !account.IsEmpty() {
+			mgr.removeClient(account)
+		}
+	}
+	mgr.lock.Unlock()
+	if _, ok := evt.(*events.LoggedOut); ok {
+		go cli.Disconnect()
+	}
+	mgr.dispatchEvent(account, cli, evt)
+}
+
+// Start creates clients for all devices in the container and connects them.
+// At most MaxConcurrentConnects clients are connecting at the same time.
+//
+// The context is used as the base context of all clients (see Client.ConnectContext), so it must not be canceled
+// until the manager is stopped. Errors from individual clients don't stop other clients from connecting,
+// they're all returned joined together.
+func (mgr *Manager) Start(ctx context.Context) error {
+	mgr.lock.Lock()
+	if mgr.stopped {
+		mgr.lock.Unlock()
+		return ErrManagerStopped
+	} else if mgr.ctx != nil {
+		mgr.lock.Unlock()
+		return ErrManagerAlreadyStarted
+	}
+	mgr.ctx, mgr.cancel = context.WithCancel(ctx)
+	ctx = mgr.ctx
+	mgr.lock.Unlock()
+	devices, err := mgr.Container.GetAllDevices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+	concurrency := mgr.MaxConcurrentConnects
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	sema := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var errs []error
+	var errsLock sync.Mutex
+	for _, device := range devices {
+		if mgr.isStopped() {
+			break
+		}
+		// Client setup calls ConfigureClient, so it must happen without holding the lock
+		cli := mgr.newClient(device)
+		mgr.lock.Lock()
+		if mgr.stopped {
+			mgr.lock.Unlock()
+			break
+		}
+		mgr.addClient(*device.ID, cli)
+		mgr.inFlight.Add(1)
+		mgr.lock.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer mgr.inFlight.Done()
+			select {
+			case sema <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sema }()
+			if err := cli.ConnectContext(ctx); err != nil {
+				errsLock.Lock()
+				errs = append(errs, fmt.Errorf("failed to connect %s: %w", device.ID, err))
+				errsLock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+	return errors.Join(errs...)
+}
+
+// Pair creates a new device and connects it to WhatsApp to be paired. The returned channel works the same way as
+// the one from Client.GetQRChannel. Alternatively, Client.PairPhone can be called on the returned client
+// to pair using a code.
+//
+// After the pairing succeeds, the client is owned by the manager like clients created in Start.
+// If pairing fails, the client is disconnected and forgotten. The context only applies to the QR channel,
+// the client itself uses the context passed to Start.
+func (mgr *Manager) Pair(ctx context.Context) (*Client, <-chan QRChannelItem, error) {
+	mgr.lock.Lock()
+	if mgr.stopped {
+		mgr.lock.Unlock()
+		return nil, nil, ErrManagerStopped
+	} else if mgr.ctx == nil {
+		mgr.lock.Unlock()
+		return nil, nil, ErrManagerNotStarted
+	}
+	baseCtx := mgr.ctx
+	cli := mgr.newClient(mgr.Container.NewDevice())
+	mgr.pairing[cli] = struct{}{}
+	mgr.lock.Unlock()
+	qrChan, err := cli.GetQRChannel(ctx)
+	if err == nil {
+		err = cli.ConnectContext(baseCtx)
+	}
+	if err != nil {
+		mgr.forgetPairing(cli)
+		return nil, nil, err
+	}
+	wrappedChan := make(chan QRChannelItem, 8)
+	go func() {
+		defer close(wrappedChan)
+		for item := range qrChan {
+			switch item.Event {
+			case QRChannelEventCode, QRChannelSuccess.Event, QRChannelScannedWithoutMultidevice.Event:
+			default:
+				mgr.forgetPairing(cli)
+			}
+			wrappedChan <- item
+		}
+	}()
+	return cli, wrappedChan, nil
+}
+
+func (mgr *Manager) forgetPairing(cli *Client) {
+	mgr.lock.Lock()
+	_, ok := mgr.pairing[cli]
+	delete(mgr.pairing, cli)
+	mgr.lock.Unlock()
+	if ok {
+		cli.Disconnect()
+	}
+}
+
+// Client returns the client for the given device JID, or nil if the manager doesn't have a client for it.
+func (mgr *Manager) Client(jid types.JID) *Client {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	return mgr.clients[jid]
+}
+
+// Clients returns all paired clients owned by the manager.
+func (mgr *Manager) Clients() []*Client {
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+	clients := make([]*Client, 0, len(mgr.clients))
+	for _, cli := range mgr.clients {
+		clients = append(clients, cli)
+	}
+	return clients
+}
+
+// Remove logs out the given account, deletes it from the store and forgets its client.
+func (mgr *Manager) Remove(ctx context.Context, jid types.JID) error {
+	mgr.lock.Lock()
+	cli, ok := mgr.removeClient(jid)
+	mgr.lock.Unlock()
+	if !ok {
+		return ErrNotLoggedIn
+	}
+	err := cli.Logout(ctx)
+	if err != nil {
+		// Keep the client so that removing can be retried
+		mgr.lock.Lock()
+		mgr.addClient(jid, cli)
+		mgr.lock.Unlock()
+	}
+	return err
+}
+
+// Stop cancels ongoing connection attempts and automatic reconnections, then disconnects all clients,
+// including ones that are still pairing. The manager can't be restarted after stopping.
+func (mgr *Manager) Stop(ctx context.Context) error {
+	mgr.lock.Lock()
+	if mgr.stopped {
+		mgr.lock.Unlock()
+		return nil
+	}
+	mgr.stopped = true
+	if mgr.cancel != nil {
+		mgr.cancel()
+	}
+	clients := make([]*Client, 0, len(mgr.clients)+len(mgr.pairing))
+	for _, cli := range mgr.clients {
+		clients = append(clients, cli)
+	}
+	for cli := range mgr.pairing {
+		clients = append(clients, cli)
+	}
+	mgr.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		// Wait for canceled connection attempts first, in case some of them finished connecting anyway
+		mgr.inFlight.Wait()
+		var wg sync.WaitGroup
+		for _, cli := range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cli.Disconnect()
+			}()
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
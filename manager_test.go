@@ -0,0 +1,85 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"context"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store/memstore"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestManager(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	container := memstore.New(nil)
+
+	newManager := func() (*whatsmeow.Manager, chan *whatsmeow.ManagedEvent) {
+		mgr := whatsmeow.NewManager(container, nil)
+		mgr.ConfigureClient = srv.Configure
+		managedEvents := make(chan *whatsmeow.ManagedEvent, 32)
+		mgr.AddEventHandler(func(evt *whatsmeow.ManagedEvent) {
+			switch evt.Event.(type) {
+			case *events.Connected, *events.LoggedOut:
+				managedEvents <- evt
+			}
+		})
+		if err := mgr.Start(ctx); err != nil {
+			t.Fatalf("Failed to start manager: %v", err)
+		}
+		return mgr, managedEvents
+	}
+	waitEvent := func(managedEvents chan *whatsmeow.ManagedEvent) *whatsmeow.ManagedEvent {
+		t.Helper()
+		select {
+		case evt := <-managedEvents:
+			return evt
+		case <-ctx.Done():
+			t.Fatalf("Didn't get event from manager: %v", ctx.Err())
+			return nil
+		}
+	}
+
+	mgr, managedEvents := newManager()
+	_, qrChan, err := mgr.Pair(ctx)
+	if err != nil {
+		t.Fatalf("Failed to start pairing: %v", err)
+	}
+	jid, err := alice.Pair(ctx, (<-qrChan).Code)
+	if err != nil {
+		t.Fatalf("Failed to pair: %v", err)
+	}
+	if evt := waitEvent(managedEvents); evt.Account != jid {
+		t.Fatalf("Unexpected account %s in connected event, expected %s", evt.Account, jid)
+	} else if mgr.Client(jid) != evt.Client {
+		t.Fatalf("Paired client wasn't added to manager")
+	}
+	if err = mgr.Stop(ctx); err != nil {
+		t.Fatalf("Failed to stop manager: %v", err)
+	} else if mgr.Client(jid).IsConnected() {
+		t.Fatalf("Client still connected after stopping manager")
+	}
+
+	// A new manager should connect the paired device from the container automatically
+	mgr, managedEvents = newManager()
+	defer mgr.Stop(context.Background())
+	if evt := waitEvent(managedEvents); evt.Account != jid {
+		t.Fatalf("Unexpected account %s in connected event, expected %s", evt.Account, jid)
+	}
+	if err = alice.RemoveDevice(jid); err != nil {
+		t.Fatalf("Failed to remove device: %v", err)
+	}
+	if evt := waitEvent(managedEvents); evt.Account != jid {
+		t.Fatalf("Unexpected account %s in logged out event, expected %s", evt.Account, jid)
+	} else if _, ok := evt.Event.(*events.LoggedOut); !ok {
+		t.Fatalf("Unexpected event %T, expected logged out", evt.Event)
+	} else if len(mgr.Clients()) != 0 {
+		t.Fatalf("Logged out client wasn't removed from manager")
+	}
+}
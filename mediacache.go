@@ -0,0 +1,315 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.mau.fi/whatsmeow/socket"
+)
+
+// MediaCache is a content-addressed cache for attachments, which can be set in Client.MediaCache
+// to avoid downloading and uploading the same files repeatedly.
+//
+// Files are keyed by the SHA256 hash of the plaintext (FileSHA256) and of the encrypted file (FileEncSHA256).
+// Uploads are keyed by the plaintext hash and media type, as the encryption keys depend on the media type.
+//
+// Get methods must return nil and no error if the requested entry isn't cached.
+type MediaCache interface {
+	// GetMedia returns the decrypted data of a file. The file should be looked up by the plaintext hash first,
+	// and then by the encrypted file hash. Either hash may be empty.
+	GetMedia(ctx context.Context, fileSHA256, fileEncSHA256 []byte) ([]byte, error)
+	// PutMedia stores the decrypted data of a file. Either hash may be empty.
+	// This is called after downloading files and after uploading byte slices with Client.Upload,
+	// but not by Client.UploadReader, which streams the data and never has the whole file in memory.
+	PutMedia(ctx context.Context, fileSHA256, fileEncSHA256, data []byte) error
+
+	// GetUpload returns the result of a previous upload of a file with the given plaintext hash and media type.
+	GetUpload(ctx context.Context, fileSHA256 []byte, mediaType MediaType) (*UploadResponse, error)
+	// PutUpload stores the result of an upload.
+	PutUpload(ctx context.Context, mediaType MediaType, resp *UploadResponse) error
+	// DeleteUpload removes a previous upload result, e.g. because the server no longer has the file.
+	DeleteUpload(ctx context.Context, fileSHA256 []byte, mediaType MediaType) error
+}
+
+// FSMediaCache is a MediaCache that stores files in a directory.
+//
+// Nothing is ever deleted from the cache automatically (other than uploads that the server no longer has),
+// so the directory should be cleaned up periodically if it's used for a long time.
+type FSMediaCache struct {
+	Dir string
+}
+
+var _ MediaCache = (*FSMediaCache)(nil)
+
+// NewFSMediaCache creates a filesystem-backed MediaCache in the given directory.
+func NewFSMediaCache(dir string) (*FSMediaCache, error) {
+	for _, subdir := range []string{"files", "enc", "uploads"} {
+		err := os.MkdirAll(filepath.Join(dir, subdir), 0700)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create media cache directory: %w", err)
+		}
+	}
+	return &FSMediaCache{Dir: dir}, nil
+}
+
+// writeFile writes the file to a temporary path first and then renames it,
+// so that concurrent readers never see partially written files.
+func (fmc *FSMediaCache) writeFile(path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.Write(data)
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+	}
+	return err
+}
+
+func readOptionalFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// GetMedia implements MediaCache. Entries stored with an encrypted file hash have a pointer file
+// in the enc directory, which contains the name of the actual data file.
+func (fmc *FSMediaCache) GetMedia(ctx context.Context, fileSHA256, fileEncSHA256 []byte) ([]byte, error) {
+	if len(fileSHA256) > 0 {
+		data, err := readOptionalFile(filepath.Join(fmc.Dir, "files", hex.EncodeToString(fileSHA256)))
+		if data != nil || err != nil {
+			return data, err
+		}
+	}
+	if len(fileEncSHA256) > 0 {
+		key, err := readOptionalFile(filepath.Join(fmc.Dir, "enc", hex.EncodeToString(fileEncSHA256)))
+		if key == nil || err != nil {
+			return nil, err
+		}
+		return readOptionalFile(filepath.Join(fmc.Dir, "files", filepath.Base(string(key))))
+	}
+	return nil, nil
+}
+
+// PutMedia implements MediaCache.
+func (fmc *FSMediaCache) PutMedia(ctx context.Context, fileSHA256, fileEncSHA256, data []byte) error {
+	key := hex.EncodeToString(fileSHA256)
+	if len(fileSHA256) == 0 {
+		key = hex.EncodeToString(fileEncSHA256)
+	}
+	if key == "" {
+		return nil
+	}
+	err := fmc.writeFile(filepath.Join(fmc.Dir, "files", key), data)
+	if err != nil {
+		return err
+	}
+	if len(fileEncSHA256) > 0 {
+		err = fmc.writeFile(filepath.Join(fmc.Dir, "enc", hex.EncodeToString(fileEncSHA256)), []byte(key))
+	}
+	return err
+}
+
+type fsCachedUpload struct {
+	URL           string `json:"url"`
+	DirectPath    string `json:"direct_path"`
+	Handle        string `json:"handle,omitempty"`
+	ObjectID      string `json:"object_id,omitempty"`
+	MediaKey      []byte `json:"media_key"`
+	FileEncSHA256 []byte `json:"file_enc_sha256"`
+	FileSHA256    []byte `json:"file_sha256"`
+	FileLength    uint64 `json:"file_length"`
+}
+
+func (fmc *FSMediaCache) uploadPath(fileSHA256 []byte, mediaType MediaType) string {
+	typeName := strings.ToLower(strings.ReplaceAll(string(mediaType), " ", "-"))
+	return filepath.Join(fmc.Dir, "uploads", fmt.Sprintf("%s-%s.json", hex.EncodeToString(fileSHA256), typeName))
+}
+
+// GetUpload implements MediaCache.
+func (fmc *FSMediaCache) GetUpload(ctx context.Context, fileSHA256 []byte, mediaType MediaType) (*UploadResponse, error) {
+	data, err := readOptionalFile(fmc.uploadPath(fileSHA256, mediaType))
+	if data == nil || err != nil {
+		return nil, err
+	}
+	var cached fsCachedUpload
+	err = json.Unmarshal(data, &cached)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cached upload: %w", err)
+	}
+	return &UploadResponse{
+		URL:           cached.URL,
+		DirectPath:    cached.DirectPath,
+		Handle:        cached.Handle,
+		ObjectID:      cached.ObjectID,
+		MediaKey:      cached.MediaKey,
+		FileEncSHA256: cached.FileEncSHA256,
+		FileSHA256:    cached.FileSHA256,
+		FileLength:    cached.FileLength,
+	}, nil
+}
+
+// PutUpload implements MediaCache.
+func (fmc *FSMediaCache) PutUpload(ctx context.Context, mediaType MediaType, resp *UploadResponse) error {
+	data, err := json.Marshal(&fsCachedUpload{
+		URL:           resp.URL,
+		DirectPath:    resp.DirectPath,
+		Handle:        resp.Handle,
+		ObjectID:      resp.ObjectID,
+		MediaKey:      resp.MediaKey,
+		FileEncSHA256: resp.FileEncSHA256,
+		FileSHA256:    resp.FileSHA256,
+		FileLength:    resp.FileLength,
+	})
+	if err != nil {
+		return err
+	}
+	return fmc.writeFile(fmc.uploadPath(resp.FileSHA256, mediaType), data)
+}
+
+// DeleteUpload implements MediaCache.
+func (fmc *FSMediaCache) DeleteUpload(ctx context.Context, fileSHA256 []byte, mediaType MediaType) error {
+	err := os.Remove(fmc.uploadPath(fileSHA256, mediaType))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (cli *Client) getCachedMedia(ctx context.Context, fileSHA256, fileEncSHA256 []byte) []byte {
+	if cli.MediaCache == nil || (len(fileSHA256) == 0 && len(fileEncSHA256) == 0) {
+		return nil
+	}
+	data, err := cli.MediaCache.GetMedia(ctx, fileSHA256, fileEncSHA256)
+	if err != nil {
+		cli.Log.Warnf("Failed to get file from media cache: %v", err)
+		return nil
+	} else if data != nil && len(fileSHA256) == 32 && sha256.Sum256(data) != [32]byte(fileSHA256) {
+		cli.Log.Warnf("Cached file %x doesn't match its hash, ignoring it", fileSHA256)
+		return nil
+	}
+	return data
+}
+
+func (cli *Client) putCachedMedia(ctx context.Context, fileSHA256, fileEncSHA256, data []byte) {
+	if cli.MediaCache == nil || (len(fileSHA256) == 0 && len(fileEncSHA256) == 0) {
+		return
+	}
+	err := cli.MediaCache.PutMedia(ctx, fileSHA256, fileEncSHA256, data)
+	if err != nil {
+		cli.Log.Warnf("Failed to store file in media cache: %v", err)
+	}
+}
+
+// downloadWithCache returns the file from the media cache if it's there,
+// and otherwise calls the given function and stores the result in the cache.
+func (cli *Client) downloadWithCache(ctx context.Context, fileSHA256, fileEncSHA256 []byte, download func() ([]byte, error)) ([]byte, error) {
+	if data := cli.getCachedMedia(ctx, fileSHA256, fileEncSHA256); data != nil {
+		return data, nil
+	}
+	data, err := download()
+	if err == nil {
+		cli.putCachedMedia(ctx, fileSHA256, fileEncSHA256, data)
+	}
+	return data, err
+}
+
+// getCachedUpload returns a previous upload of the same file if the media server still has it.
+func (cli *Client) getCachedUpload(ctx context.Context, fileSHA256 []byte, fileLength uint64, mediaType MediaType) *UploadResponse {
+	if cli.MediaCache == nil {
+		return nil
+	}
+	cached, err := cli.MediaCache.GetUpload(ctx, fileSHA256, mediaType)
+	if err != nil {
+		cli.Log.Warnf("Failed to get upload from media cache: %v", err)
+		return nil
+	} else if cached == nil {
+		return nil
+	} else if cached.FileLength != fileLength || len(cached.MediaKey) != 32 {
+		err = errors.New("cached upload doesn't match file")
+	} else {
+		err = cli.checkUploadedMedia(ctx, cached.DirectPath, cached.FileEncSHA256, mediaType)
+		if err != nil && !errors.Is(err, ErrMediaDownloadFailedWith404) && !errors.Is(err, ErrMediaDownloadFailedWith410) {
+			// The file may still be on the server, so keep the cached upload for the next call
+			cli.Log.Warnf("Failed to check if previous upload of %x still exists, uploading again: %v", fileSHA256, err)
+			return nil
+		}
+	}
+	if err != nil {
+		cli.Log.Debugf("Not reusing previous upload of %x: %v", fileSHA256, err)
+		err = cli.MediaCache.DeleteUpload(ctx, fileSHA256, mediaType)
+		if err != nil {
+			cli.Log.Warnf("Failed to delete upload from media cache: %v", err)
+		}
+		return nil
+	}
+	cli.Log.Debugf("Reusing previous upload of %x (%s)", fileSHA256, cached.DirectPath)
+	return cached
+}
+
+func (cli *Client) putCachedUpload(ctx context.Context, mediaType MediaType, resp *UploadResponse) {
+	if cli.MediaCache == nil {
+		return
+	}
+	err := cli.MediaCache.PutUpload(ctx, mediaType, resp)
+	if err != nil {
+		cli.Log.Warnf("Failed to store upload in media cache: %v", err)
+	}
+}
+
+// checkUploadedMedia checks that the media server still has the given file by sending a HEAD request to it.
+func (cli *Client) checkUploadedMedia(ctx context.Context, directPath string, encFileHash []byte, mediaType MediaType) error {
+	if !strings.HasPrefix(directPath, "/") {
+		return fmt.Errorf("invalid direct path %q", directPath)
+	}
+	mediaConn, err := cli.refreshMediaConn(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to refresh media connections: %w", err)
+	}
+	hosts, _ := mediaConn.SortedHosts()
+	if len(hosts) == 0 {
+		return fmt.Errorf("no media hosts available")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, getMediaDownloadURL(hosts[0].Hostname, directPath, encFileHash, mediaTypeToMMSType[mediaType]), nil)
+	if err != nil {
+		return fmt.Errorf("failed to prepare request: %w", err)
+	}
+	req.Header.Set("Origin", socket.Origin)
+	req.Header.Set("Referer", socket.Origin+"/")
+	if cli.MessengerConfig != nil {
+		req.Header.Set("User-Agent", cli.MessengerConfig.UserAgent)
+	}
+	resp, err := cli.mediaHTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return DownloadHTTPError{Response: resp}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+func TestMediaCache(t *testing.T) {
+	ctx, srv, _, _, cli := newPairedTestClients(t)
+	var err error
+	cli.MediaCache, err = whatsmeow.NewFSMediaCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create media cache: %v", err)
+	}
+	hosts := srv.MediaHosts()
+	downloadRequests := func() (total int) {
+		for _, host := range hosts {
+			total += srv.MediaDownloadRequests(host)
+		}
+		return
+	}
+
+	data := bytes.Repeat([]byte("hiss"), 4096)
+	resp, err := cli.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	uploaded := srv.MediaBytesReceived()
+	reused, err := cli.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload again: %v", err)
+	} else if srv.MediaBytesReceived() != uploaded {
+		t.Error("Identical file was uploaded again")
+	} else if reused.DirectPath != resp.DirectPath || !bytes.Equal(reused.MediaKey, resp.MediaKey) {
+		t.Error("Reused upload doesn't match original upload")
+	}
+	imageResp, err := cli.Upload(ctx, data, whatsmeow.MediaImage)
+	if err != nil {
+		t.Fatalf("Failed to upload as image: %v", err)
+	} else if imageResp.DirectPath == resp.DirectPath {
+		t.Error("Upload was reused for a different media type")
+	}
+
+	msg := &waE2E.DocumentMessage{
+		DirectPath:    proto.String(resp.DirectPath),
+		MediaKey:      resp.MediaKey,
+		FileSHA256:    resp.FileSHA256,
+		FileEncSHA256: resp.FileEncSHA256,
+		FileLength:    proto.Uint64(resp.FileLength),
+	}
+	requestsBefore := downloadRequests()
+	downloaded, err := cli.Download(ctx, msg)
+	if err != nil {
+		t.Fatalf("Failed to download: %v", err)
+	} else if !bytes.Equal(downloaded, data) {
+		t.Fatal("Downloaded data doesn't match uploaded data")
+	} else if downloadRequests() != requestsBefore {
+		t.Error("Uploaded file wasn't served from cache")
+	}
+
+	// Files downloaded from the server should be cached too
+	cli.MediaCache, err = whatsmeow.NewFSMediaCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create media cache: %v", err)
+	}
+	for i := range 2 {
+		downloaded, err = cli.Download(ctx, msg)
+		if err != nil || !bytes.Equal(downloaded, data) {
+			t.Fatalf("Failed to download (attempt %d): %v", i+1, err)
+		}
+	}
+	if requests := downloadRequests() - requestsBefore; requests != 1 {
+		t.Errorf("Expected 1 download request with an empty cache, got %d", requests)
+	}
+
+	// Uploads that the server no longer has must not be reused
+	_, err = cli.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload to new cache: %v", err)
+	}
+	cachedResp, err := cli.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload again: %v", err)
+	}
+
+	// Temporary errors while checking the previous upload must not drop it from the cache
+	for _, host := range srv.MediaHosts() {
+		srv.SetMediaHostDown(host, true)
+	}
+	if _, err = cli.Upload(ctx, data, whatsmeow.MediaDocument); err == nil {
+		t.Fatal("Upload succeeded while all media hosts were down")
+	}
+	for _, host := range srv.MediaHosts() {
+		srv.SetMediaHostDown(host, false)
+	}
+	uploaded = srv.MediaBytesReceived()
+	if resp, err := cli.Upload(ctx, data, whatsmeow.MediaDocument); err != nil {
+		t.Fatalf("Failed to upload after media hosts recovered: %v", err)
+	} else if resp.DirectPath != cachedResp.DirectPath || srv.MediaBytesReceived() != uploaded {
+		t.Error("Cached upload wasn't reused after a temporary error")
+	}
+
+	srv.DeleteMedia(cachedResp.DirectPath)
+	uploaded = srv.MediaBytesReceived()
+	freshResp, err := cli.Upload(ctx, data, whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload after server deleted file: %v", err)
+	} else if freshResp.DirectPath == cachedResp.DirectPath || srv.MediaBytesReceived() == uploaded {
+		t.Error("Expired upload was reused")
+	} else if _, ok := srv.GetMedia(freshResp.DirectPath); !ok {
+		t.Error("Fresh upload isn't on the server")
+	}
+}
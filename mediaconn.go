@@ -9,6 +9,8 @@ package whatsmeow
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	waBinary "go.mau.fi/whatsmeow/binary"
@@ -34,6 +36,99 @@ type MediaConn struct {
 	MaxBuckets int
 	FetchedAt  time.Time
 	Hosts      []MediaConnHost
+
+	health *mediaHostHealth
+}
+
+// MediaHostHealth contains statistics about downloads from a single media host.
+type MediaHostHealth struct {
+	Successes int
+	Failures  int
+	// ConsecutiveFailures is the number of failed requests since the last successful one.
+	ConsecutiveFailures int
+	LastFailure         time.Time
+}
+
+// mediaHostPenaltyDuration is how long failures count against a host when ordering hosts for downloads.
+const mediaHostPenaltyDuration = 5 * time.Minute
+
+func (mhh *MediaHostHealth) score() int {
+	if mhh == nil || time.Since(mhh.LastFailure) > mediaHostPenaltyDuration {
+		return 0
+	}
+	return mhh.ConsecutiveFailures
+}
+
+type mediaHostHealth struct {
+	lock  sync.Mutex
+	hosts map[string]*MediaHostHealth
+}
+
+// HostHealth returns the download statistics of the given host.
+func (mc *MediaConn) HostHealth(hostname string) MediaHostHealth {
+	if mc.health == nil {
+		return MediaHostHealth{}
+	}
+	mc.health.lock.Lock()
+	defer mc.health.lock.Unlock()
+	health, ok := mc.health.hosts[hostname]
+	if !ok {
+		return MediaHostHealth{}
+	}
+	return *health
+}
+
+// SortedHosts returns the hosts ordered by health. Hosts that failed recently are moved to the end,
+// otherwise the order from the server is kept. The number of hosts that haven't failed recently is also returned.
+func (mc *MediaConn) SortedHosts() (hosts []MediaConnHost, healthy int) {
+	hosts = slices.Clone(mc.Hosts)
+	if mc.health == nil {
+		return hosts, len(hosts)
+	}
+	mc.health.lock.Lock()
+	defer mc.health.lock.Unlock()
+	slices.SortStableFunc(hosts, func(a, b MediaConnHost) int {
+		return mc.health.hosts[a.Hostname].score() - mc.health.hosts[b.Hostname].score()
+	})
+	for _, host := range hosts {
+		if mc.health.hosts[host.Hostname].score() == 0 {
+			healthy++
+		}
+	}
+	return
+}
+
+func (mc *MediaConn) recordHostResult(hostname string, success bool) {
+	if mc.health == nil {
+		return
+	}
+	mc.health.lock.Lock()
+	defer mc.health.lock.Unlock()
+	health, ok := mc.health.hosts[hostname]
+	if !ok {
+		health = &MediaHostHealth{}
+		mc.health.hosts[hostname] = health
+	}
+	if success {
+		health.Successes++
+		health.ConsecutiveFailures = 0
+	} else {
+		health.Failures++
+		health.ConsecutiveFailures++
+		health.LastFailure = time.Now()
+	}
+}
+
+func (mc *MediaConn) inheritHealth(old *MediaConn) {
+	if mc.health == nil || old == nil || old.health == nil {
+		return
+	}
+	old.health.lock.Lock()
+	defer old.health.lock.Unlock()
+	for hostname, health := range old.health.hosts {
+		healthCopy := *health
+		mc.health.hosts[hostname] = &healthCopy
+	}
 }
 
 // Expiry returns the time when the MediaConn expires.
@@ -48,11 +143,13 @@ func (cli *Client) refreshMediaConn(ctx context.Context, force bool) (*MediaConn
 	cli.mediaConnLock.Lock()
 	defer cli.mediaConnLock.Unlock()
 	if cli.mediaConnCache == nil || force || time.Now().After(cli.mediaConnCache.Expiry()) {
-		var err error
-		cli.mediaConnCache, err = cli.queryMediaConn(ctx)
+		newConn, err := cli.queryMediaConn(ctx)
 		if err != nil {
 			return nil, err
 		}
+		// Keep host health across refreshes, as the host list usually stays the same
+		newConn.inheritHealth(cli.mediaConnCache)
+		cli.mediaConnCache = newConn
 	}
 	return cli.mediaConnCache, nil
 }
@@ -70,7 +167,7 @@ func (cli *Client) queryMediaConn(ctx context.Context) (*MediaConn, error) {
 		return nil, fmt.Errorf("failed to query media connections: unexpected child tag")
 	}
 	respMC := resp.GetChildren()[0]
-	var mc MediaConn
+	mc := MediaConn{health: &mediaHostHealth{hosts: make(map[string]*MediaHostHealth)}}
 	ag := respMC.AttrGetter()
 	mc.FetchedAt = time.Now()
 	mc.Auth = ag.String("auth")
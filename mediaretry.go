@@ -8,7 +8,9 @@ package whatsmeow
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"go.mau.fi/util/random"
 	"google.golang.org/protobuf/proto"
@@ -43,7 +45,8 @@ func encryptMediaRetryReceipt(messageID types.MessageID, mediaKey []byte) (ciphe
 // SendMediaRetryReceipt sends a request to the phone to re-upload the media in a message.
 //
 // This is mostly relevant when handling history syncs and getting a 404 or 410 error downloading media.
-// Rough example on how to use it (will not work out of the box, you must adjust it depending on what you need exactly):
+// DownloadWithMediaRetry can be used to do the whole flow automatically. If you want to do it manually,
+// here's a rough example on how to use it (will not work out of the box, you must adjust it depending on what you need exactly):
 //
 //	var mediaRetryCache map[types.MessageID]*waE2E.ImageMessage
 //
@@ -181,5 +184,142 @@ func (cli *Client) handleMediaRetryNotification(ctx context.Context, node *waBin
 		cli.Log.Warnf("Failed to parse media retry notification: %v", err)
 		return
 	}
+	cli.resolveMediaRetryWaiter(evt)
 	cli.dispatchEvent(evt)
 }
+
+const defaultMediaRetryTimeout = 1 * time.Minute
+
+type mediaRetryWaiter struct {
+	done  chan struct{}
+	evt   *events.MediaRetry
+	err   error
+	users int
+}
+
+// mediaRetryKey identifies a media retry request. Message IDs are only unique within a chat and sender,
+// so the same fields as in the <rmr> element of the receipt are included.
+type mediaRetryKey struct {
+	chat   types.JID
+	sender types.JID
+	id     types.MessageID
+}
+
+func newMediaRetryKey(info *types.MessageInfo) mediaRetryKey {
+	key := mediaRetryKey{chat: info.Chat.ToNonAD(), id: info.ID}
+	// The participant is only included in the receipt for groups (see SendMediaRetryReceipt)
+	if info.IsGroup {
+		key.sender = info.Sender.ToNonAD()
+	}
+	return key
+}
+
+func (cli *Client) resolveMediaRetryWaiter(evt *events.MediaRetry) {
+	key := mediaRetryKey{chat: evt.ChatID.ToNonAD(), sender: evt.SenderID.ToNonAD(), id: evt.MessageID}
+	cli.mediaRetryLock.Lock()
+	defer cli.mediaRetryLock.Unlock()
+	waiter, ok := cli.mediaRetryWaiters[key]
+	if ok {
+		delete(cli.mediaRetryWaiters, key)
+		waiter.evt = evt
+		close(waiter.done)
+	}
+}
+
+func (cli *Client) failMediaRetryWaiter(key mediaRetryKey, waiter *mediaRetryWaiter, err error) {
+	cli.mediaRetryLock.Lock()
+	defer cli.mediaRetryLock.Unlock()
+	if cli.mediaRetryWaiters[key] == waiter {
+		delete(cli.mediaRetryWaiters, key)
+		waiter.err = err
+		close(waiter.done)
+	}
+}
+
+func (cli *Client) leaveMediaRetryWaiter(key mediaRetryKey, waiter *mediaRetryWaiter) {
+	cli.mediaRetryLock.Lock()
+	defer cli.mediaRetryLock.Unlock()
+	waiter.users--
+	// If nobody is waiting anymore, forget the request so that the next call sends a new receipt
+	if waiter.users == 0 && cli.mediaRetryWaiters[key] == waiter {
+		delete(cli.mediaRetryWaiters, key)
+	}
+}
+
+// waitMediaRetry sends a media retry receipt for the given message and waits for the phone to respond.
+// If there's already a request in progress for the same message, this won't send another receipt
+// and will wait for the response to the existing request instead.
+func (cli *Client) waitMediaRetry(ctx context.Context, info *types.MessageInfo, mediaKey []byte) (*events.MediaRetry, error) {
+	timeout := cli.MediaRetryTimeout
+	if timeout <= 0 {
+		timeout = defaultMediaRetryTimeout
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	key := newMediaRetryKey(info)
+	cli.mediaRetryLock.Lock()
+	waiter, alreadyRequested := cli.mediaRetryWaiters[key]
+	if !alreadyRequested {
+		waiter = &mediaRetryWaiter{done: make(chan struct{})}
+		cli.mediaRetryWaiters[key] = waiter
+	}
+	waiter.users++
+	cli.mediaRetryLock.Unlock()
+	defer cli.leaveMediaRetryWaiter(key, waiter)
+
+	if alreadyRequested {
+		cli.Log.Debugf("Waiting for existing media retry request for %s", info.ID)
+	} else if err := cli.SendMediaRetryReceipt(timeoutCtx, info, mediaKey); err != nil {
+		err = fmt.Errorf("failed to send media retry receipt: %w", err)
+		cli.failMediaRetryWaiter(key, waiter, err)
+		return nil, err
+	} else {
+		cli.Log.Debugf("Sent media retry receipt for %s", info.ID)
+	}
+
+	select {
+	case <-waiter.done:
+		return waiter.evt, waiter.err
+	case <-timeoutCtx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrMediaRetryTimeout
+	}
+}
+
+// DownloadWithMediaRetry downloads the given media and automatically asks the phone to re-upload it
+// if the download fails with a 404 or 410 error.
+//
+// The message info is required for sending the media retry receipt (see SendMediaRetryReceipt).
+// The phone's response is waited for up to Client.MediaRetryTimeout, after which ErrMediaRetryTimeout is returned.
+// If multiple calls request the same message at the same time, only one retry receipt is sent and all of them
+// use the same response. The response is still dispatched as an *events.MediaRetry to event handlers too.
+//
+// The message itself is not modified. The new direct path is only used for this download,
+// so callers that want to download the same media again later should store it themselves.
+func (cli *Client) DownloadWithMediaRetry(ctx context.Context, info *types.MessageInfo, msg DownloadableMessage) ([]byte, error) {
+	if cli == nil {
+		return nil, ErrClientIsNil
+	}
+	data, err := cli.Download(ctx, msg)
+	if !errors.Is(err, ErrMediaDownloadFailedWith404) && !errors.Is(err, ErrMediaDownloadFailedWith410) {
+		return data, err
+	}
+	cli.Log.Debugf("Media in %s is no longer available on server (%v), requesting re-upload from phone", info.ID, err)
+	evt, err := cli.waitMediaRetry(ctx, info, msg.GetMediaKey())
+	if err != nil {
+		return nil, err
+	}
+	notif, err := DecryptMediaRetryNotification(evt, msg.GetMediaKey())
+	if err != nil {
+		return nil, err
+	} else if notif.GetResult() != waMmsRetry.MediaRetryNotification_SUCCESS {
+		return nil, fmt.Errorf("%w: %s", ErrMediaRetryFailed, notif.GetResult())
+	} else if notif.GetDirectPath() == "" {
+		return nil, fmt.Errorf("%w: response didn't contain direct path", ErrMediaRetryFailed)
+	}
+	mediaType := GetMediaType(msg)
+	return cli.DownloadMediaWithPath(ctx, notif.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType])
+}
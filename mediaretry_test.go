@@ -0,0 +1,107 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/testserver"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestDownloadWithMediaRetry(t *testing.T) {
+	ctx, _, alice, bob, cli := newPairedTestClients(t)
+
+	data := bytes.Repeat([]byte("meow"), 1024)
+	resp, err := cli.Upload(ctx, data, whatsmeow.MediaImage)
+	if err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	msg := &waE2E.ImageMessage{
+		DirectPath:    proto.String("/v/t62.7118-24/expired.enc?ccb=11-4"),
+		MediaKey:      resp.MediaKey,
+		FileSHA256:    resp.FileSHA256,
+		FileEncSHA256: resp.FileEncSHA256,
+		FileLength:    proto.Uint64(resp.FileLength),
+	}
+	info := &types.MessageInfo{
+		MessageSource: types.MessageSource{Chat: bob.PN, Sender: bob.PN},
+		ID:            "3EB0EXPIREDMEDIA",
+	}
+	waitRetryReceipt := func() *testserver.Receipt {
+		t.Helper()
+		receipt, err := alice.Phone.WaitReceipt(ctx)
+		if err != nil {
+			t.Fatalf("Phone didn't get media retry receipt: %v", err)
+		} else if receipt.Type != "server-error" || receipt.ID != info.ID {
+			t.Fatalf("Unexpected receipt %s/%s on phone", receipt.Type, receipt.ID)
+		}
+		return receipt
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			downloaded, err := cli.DownloadWithMediaRetry(ctx, info, msg)
+			if err == nil && !bytes.Equal(downloaded, data) {
+				err = errors.New("downloaded data doesn't match uploaded data")
+			}
+			errs[i] = err
+		}()
+	}
+	receipt := waitRetryReceipt()
+	// Give the other download time to join the existing request
+	time.Sleep(100 * time.Millisecond)
+	if err = alice.Phone.RespondMediaRetry(receipt, resp.MediaKey, resp.DirectPath); err != nil {
+		t.Fatalf("Failed to respond to media retry: %v", err)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Download %d failed: %v", i, err)
+		}
+	}
+	shortCtx, shortCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	extra, err := alice.Phone.WaitReceipt(shortCtx)
+	shortCancel()
+	if err == nil {
+		t.Errorf("Concurrent downloads sent multiple media retry receipts (got extra %s receipt)", extra.Type)
+	} else if msg.GetDirectPath() == resp.DirectPath {
+		t.Error("DownloadWithMediaRetry modified the message")
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := cli.DownloadWithMediaRetry(ctx, info, msg)
+		errChan <- err
+	}()
+	if err = alice.Phone.RespondMediaRetry(waitRetryReceipt(), resp.MediaKey, ""); err != nil {
+		t.Fatalf("Failed to respond to media retry: %v", err)
+	}
+	if err = <-errChan; !errors.Is(err, whatsmeow.ErrMediaNotAvailableOnPhone) {
+		t.Errorf("Expected media not available error, got %v", err)
+	}
+
+	cli.MediaRetryTimeout = 200 * time.Millisecond
+	_, err = cli.DownloadWithMediaRetry(ctx, info, msg)
+	if !errors.Is(err, whatsmeow.ErrMediaRetryTimeout) {
+		t.Errorf("Expected timeout error when phone doesn't respond, got %v", err)
+	}
+	waitRetryReceipt()
+}
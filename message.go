@@ -285,7 +285,9 @@ func (cli *Client) handlePlaintextMessage(ctx context.Context, info *types.Messa
 			OriginalTS: meta.AttrGetter().UnixTime("original_msg_t"),
 		}
 	}
-	return cli.dispatchEvent(evt.UnwrapRaw())
+	evt.UnwrapRaw()
+	cli.storeMessageEvent(ctx, evt)
+	return cli.dispatchEventCtx(ctx, evt)
 }
 
 func (cli *Client) migrateSessionStore(ctx context.Context, pn, lid types.JID) {
@@ -296,6 +298,13 @@ func (cli *Client) migrateSessionStore(ctx context.Context, pn, lid types.JID) {
 }
 
 func (cli *Client) decryptMessages(ctx context.Context, info *types.MessageInfo, node *waBinary.Node) {
+	ctx, span := cli.getTracer().Start(
+		ctx, "whatsmeow.decryptMessages",
+		TraceAttribute{Key: "chat", Value: info.Chat.String()},
+		TraceAttribute{Key: "sender", Value: info.Sender.String()},
+		TraceAttribute{Key: "message_id", Value: info.ID},
+	)
+	defer span.End(nil)
 	unavailableNode, ok := node.GetOptionalChildByTag("unavailable")
 	if ok && len(node.GetChildrenByTag("enc")) == 0 {
 		uType := events.UnavailableType(unavailableNode.AttrGetter().String("type"))
@@ -304,7 +313,7 @@ func (cli *Client) decryptMessages(ctx context.Context, info *types.MessageInfo,
 			cli.immediateRequestMessageFromPhone(ctx, info)
 			cli.sendAck(ctx, node, 0)
 		})
-		cli.dispatchEvent(&events.UndecryptableMessage{Info: *info, IsUnavailable: true, UnavailableType: uType})
+		cli.dispatchEventCtx(ctx, &events.UndecryptableMessage{Info: *info, IsUnavailable: true, UnavailableType: uType})
 		return
 	}
 
@@ -340,11 +349,12 @@ func (cli *Client) decryptMessages(ctx context.Context, info *types.MessageInfo,
 		var decrypted []byte
 		var ciphertextHash *[32]byte
 		var err error
+		decryptCtx, decryptSpan := cli.getTracer().Start(ctx, "whatsmeow.decrypt", TraceAttribute{Key: "enc_type", Value: encType})
 		if encType == "pkmsg" || encType == "msg" {
-			decrypted, ciphertextHash, err = cli.decryptDM(ctx, &child, senderEncryptionJID, encType == "pkmsg", info.Timestamp)
+			decrypted, ciphertextHash, err = cli.decryptDM(decryptCtx, &child, senderEncryptionJID, encType == "pkmsg", info.Timestamp)
 			containsDirectMsg = true
 		} else if info.IsGroup && encType == "skmsg" {
-			decrypted, ciphertextHash, err = cli.decryptGroupMsg(ctx, &child, senderEncryptionJID, info.Chat, info.Timestamp)
+			decrypted, ciphertextHash, err = cli.decryptGroupMsg(decryptCtx, &child, senderEncryptionJID, info.Chat, info.Timestamp)
 		} else if encType == "msmsg" && info.Sender.IsBot() {
 			targetSenderJID := info.MsgMetaInfo.TargetSender
 			if targetSenderJID.User == "" {
@@ -362,19 +372,21 @@ func (cli *Client) decryptMessages(ctx context.Context, info *types.MessageInfo,
 			}
 			var msMsg waE2E.MessageSecretMessage
 			var messageSecret []byte
-			if messageSecret, _, err = cli.Store.MsgSecrets.GetMessageSecret(ctx, info.Chat, targetSenderJID, info.MsgMetaInfo.TargetID); err != nil {
+			if messageSecret, _, err = cli.Store.MsgSecrets.GetMessageSecret(decryptCtx, info.Chat, targetSenderJID, info.MsgMetaInfo.TargetID); err != nil {
 				err = fmt.Errorf("failed to get message secret for %s: %v", info.MsgMetaInfo.TargetID, err)
 			} else if messageSecret == nil {
 				err = fmt.Errorf("message secret for %s not found", info.MsgMetaInfo.TargetID)
 			} else if err = proto.Unmarshal(child.Content.([]byte), &msMsg); err != nil {
 				err = fmt.Errorf("failed to unmarshal MessageSecretMessage protobuf: %v", err)
 			} else {
-				decrypted, err = cli.decryptBotMessage(ctx, messageSecret, &msMsg, decryptMessageID, targetSenderJID, info)
+				decrypted, err = cli.decryptBotMessage(decryptCtx, messageSecret, &msMsg, decryptMessageID, targetSenderJID, info)
 			}
 		} else {
 			cli.Log.Warnf("Unhandled encrypted message (type %s) from %s", encType, info.SourceString())
+			decryptSpan.End(nil)
 			continue
 		}
+		decryptSpan.End(err)
 
 		if errors.Is(err, EventAlreadyProcessed) {
 			cli.Log.Debugf("Ignoring message %s from %s: %v", info.ID, info.SourceString(), err)
@@ -400,10 +412,12 @@ func (cli *Client) decryptMessages(ctx context.Context, info *types.MessageInfo,
 				go cli.sendRetryReceipt(context.WithoutCancel(ctx), node, info, isUnavailable)
 				go cli.sendAck(ctx, node, 0)
 			}
-			cli.dispatchEvent(&events.UndecryptableMessage{
+			decryptFailMode := events.DecryptFailMode(ag.OptionalString("decrypt-fail"))
+			cli.getMetrics().DecryptFailure(decryptFailMode, isUnavailable)
+			cli.dispatchEventCtx(ctx, &events.UndecryptableMessage{
 				Info:            *info,
 				IsUnavailable:   isUnavailable,
-				DecryptFailMode: events.DecryptFailMode(ag.OptionalString("decrypt-fail")),
+				DecryptFailMode: decryptFailMode,
 			})
 			return
 		}
@@ -446,8 +460,9 @@ func (cli *Client) decryptMessages(ctx context.Context, info *types.MessageInfo,
 					Msg("Deleted event plaintext from buffer")
 			}
 
-			if time.Since(cli.lastDecryptedBufferClear) > 12*time.Hour && ctx.Err() == nil {
-				cli.lastDecryptedBufferClear = time.Now()
+			lastClear := cli.lastDecryptedBufferClear.Load()
+			if time.Since(time.Unix(0, lastClear)) > 12*time.Hour && ctx.Err() == nil &&
+				cli.lastDecryptedBufferClear.CompareAndSwap(lastClear, time.Now().UnixNano()) {
 				go func() {
 					err := cli.Store.EventBuffer.DeleteOldBufferedHashes(context.WithoutCancel(ctx))
 					if err != nil {
@@ -478,6 +493,12 @@ func (cli *Client) clearUntrustedIdentity(ctx context.Context, target types.JID)
 	if err != nil {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
+	if _, ok := cli.Store.Identities.(store.VerifiedIdentityStore); ok {
+		err = cli.ClearIdentityVerified(ctx, target)
+		if err != nil {
+			cli.Log.Warnf("Failed to clear verified state of %s after identity change: %v", target, err)
+		}
+	}
 	go cli.dispatchEvent(&events.IdentityChange{JID: target, Timestamp: time.Now(), Implicit: true})
 	return nil
 }
@@ -490,6 +511,10 @@ func (cli *Client) bufferedDecrypt(
 	serverTimestamp time.Time,
 	decrypt func(context.Context) ([]byte, error),
 ) (plaintext []byte, ciphertextHash [32]byte, err error) {
+	ctx, span := cli.getTracer().Start(ctx, "whatsmeow.bufferedDecrypt")
+	defer func() {
+		span.End(err)
+	}()
 	if !cli.EnableDecryptedEventBuffer {
 		plaintext, err = decrypt(ctx)
 		return
@@ -715,6 +740,7 @@ func (cli *Client) DownloadHistorySync(ctx context.Context, notif *waE2E.History
 			cli.handleHistoricalPushNames(ctx, historySync.GetPushnames())
 		} else if len(historySync.GetConversations()) > 0 {
 			cli.storeHistoricalMessageSecrets(ctx, historySync.GetConversations())
+			cli.storeHistoricalMessages(ctx, historySync.GetConversations())
 		}
 		if len(historySync.GetPhoneNumberToLidMappings()) > 0 {
 			cli.storeHistoricalPNLIDMappings(ctx, historySync.GetPhoneNumberToLidMappings())
@@ -782,6 +808,7 @@ func (cli *Client) handlePlaceholderResendResponse(msg *waE2E.PeerDataOperationR
 			cli.Log.Warnf("Failed to parse web message info in item #%d of response to %s: %v", i+1, reqID, err)
 		} else {
 			msgEvt.UnavailableRequestID = reqID
+			cli.storeMessageEvent(cli.BackgroundEventCtx, msgEvt)
 			ok = !cli.dispatchEvent(msgEvt) && ok
 		}
 	}
@@ -1034,8 +1061,9 @@ func (cli *Client) handleDecryptedMessage(ctx context.Context, info *types.Messa
 	if !ok {
 		return false
 	}
-	evt := &events.Message{Info: *info, RawMessage: msg, RetryCount: retryCount}
-	return cli.dispatchEvent(evt.UnwrapRaw())
+	evt := (&events.Message{Info: *info, RawMessage: msg, RetryCount: retryCount}).UnwrapRaw()
+	cli.storeMessageEvent(ctx, evt)
+	return cli.dispatchEventCtx(ctx, evt)
 }
 
 func (cli *Client) sendProtocolMessageReceipt(ctx context.Context, id types.MessageID, msgType types.ReceiptType) {
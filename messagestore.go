@@ -0,0 +1,217 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func (cli *Client) messageStoreEnabled() bool {
+	return cli.EnableMessageStore && cli.Store.Messages != nil
+}
+
+// hasStorableContent returns false if the message only contains metadata like sender key distribution messages.
+func hasStorableContent(msg *waE2E.Message) bool {
+	if msg == nil {
+		return false
+	} else if msg.SenderKeyDistributionMessage == nil && msg.MessageContextInfo == nil {
+		return proto.Size(msg) > 0
+	}
+	clone := proto.Clone(msg).(*waE2E.Message)
+	clone.SenderKeyDistributionMessage = nil
+	clone.MessageContextInfo = nil
+	return proto.Size(clone) > 0
+}
+
+func (cli *Client) storeMessageEvent(ctx context.Context, evt *events.Message) {
+	if !cli.messageStoreEnabled() {
+		return
+	}
+	content := evt.RawMessage
+	if content.GetDeviceSentMessage().GetMessage() != nil {
+		content = content.GetDeviceSentMessage().GetMessage()
+	}
+	err := cli.storeMessageContent(ctx, &evt.Info, content, evt.Message)
+	if err != nil {
+		cli.Log.Errorf("Failed to store message %s in message store: %v", evt.Info.ID, err)
+	}
+}
+
+func (cli *Client) storeSentMessage(ctx context.Context, to, ownID types.JID, id types.MessageID, message *waE2E.Message, ts time.Time) {
+	if !cli.messageStoreEnabled() {
+		return
+	}
+	info := &types.MessageInfo{
+		MessageSource: types.MessageSource{
+			Chat:     to,
+			Sender:   ownID,
+			IsFromMe: true,
+			IsGroup:  to.Server == types.GroupServer,
+		},
+		ID:        id,
+		Timestamp: ts,
+	}
+	unwrapped := (&events.Message{RawMessage: message}).UnwrapRaw().Message
+	err := cli.storeMessageContent(ctx, info, message, unwrapped)
+	if err != nil {
+		cli.Log.Errorf("Failed to store sent message %s in message store: %v", id, err)
+	}
+}
+
+func (cli *Client) storeMessageContent(ctx context.Context, info *types.MessageInfo, content, unwrapped *waE2E.Message) error {
+	if protoMsg := unwrapped.GetProtocolMessage(); protoMsg != nil {
+		switch protoMsg.GetType() {
+		case waE2E.ProtocolMessage_REVOKE:
+			return cli.Store.Messages.PutMessageRevoke(ctx, info.Chat, protoMsg.GetKey().GetID(), info.Timestamp)
+		case waE2E.ProtocolMessage_MESSAGE_EDIT:
+			editedAt := info.Timestamp
+			if protoMsg.GetTimestampMS() != 0 {
+				editedAt = time.UnixMilli(protoMsg.GetTimestampMS())
+			}
+			return cli.Store.Messages.PutMessageEdit(ctx, info.Chat, protoMsg.GetKey().GetID(), protoMsg.GetEditedMessage(), editedAt)
+		default:
+			return nil
+		}
+	} else if reaction := unwrapped.GetReactionMessage(); reaction != nil {
+		reactedAt := info.Timestamp
+		if reaction.GetSenderTimestampMS() != 0 {
+			reactedAt = time.UnixMilli(reaction.GetSenderTimestampMS())
+		}
+		return cli.Store.Messages.PutReaction(ctx, info.Chat, reaction.GetKey().GetID(), store.MessageReaction{
+			Sender:    info.Sender,
+			Reaction:  reaction.GetText(),
+			Timestamp: reactedAt,
+		})
+	} else if !hasStorableContent(unwrapped) {
+		return nil
+	}
+	return cli.Store.Messages.PutMessages(ctx, []*store.StoredMessage{{
+		Chat:      info.Chat,
+		Sender:    info.Sender,
+		ID:        info.ID,
+		IsFromMe:  info.IsFromMe,
+		Timestamp: info.Timestamp,
+		Message:   content,
+	}})
+}
+
+func (cli *Client) storeHistoricalMessages(ctx context.Context, conversations []*waHistorySync.Conversation) {
+	if !cli.messageStoreEnabled() {
+		return
+	}
+	var messages []*store.StoredMessage
+	var reactionCount int
+	for _, conv := range conversations {
+		chatJID, _ := types.ParseJID(conv.GetID())
+		if chatJID.IsEmpty() {
+			continue
+		}
+		for _, histMsg := range conv.GetMessages() {
+			evt, err := cli.ParseWebMessage(chatJID, histMsg.GetMessage())
+			if err != nil {
+				cli.Log.Debugf("Failed to parse message in history sync for message store: %v", err)
+				continue
+			}
+			if evt.Message.GetProtocolMessage() != nil || evt.Message.GetReactionMessage() != nil {
+				// Protocol messages and reactions are already applied to their targets in history syncs
+				continue
+			} else if hasStorableContent(evt.Message) {
+				content := evt.RawMessage
+				if content.GetDeviceSentMessage().GetMessage() != nil {
+					content = content.GetDeviceSentMessage().GetMessage()
+				}
+				messages = append(messages, &store.StoredMessage{
+					Chat:      evt.Info.Chat,
+					Sender:    evt.Info.Sender,
+					ID:        evt.Info.ID,
+					IsFromMe:  evt.Info.IsFromMe,
+					Timestamp: evt.Info.Timestamp,
+					Message:   content,
+				})
+			}
+			for _, reaction := range histMsg.GetMessage().GetReactions() {
+				sender := evt.Info.Sender
+				if reaction.GetKey().GetFromMe() {
+					sender = cli.getOwnID().ToNonAD()
+				} else if reaction.GetKey().GetParticipant() != "" {
+					sender, _ = types.ParseJID(reaction.GetKey().GetParticipant())
+				} else if !evt.Info.IsGroup {
+					sender = chatJID
+				}
+				err = cli.Store.Messages.PutReaction(ctx, chatJID, evt.Info.ID, store.MessageReaction{
+					Sender:    sender,
+					Reaction:  reaction.GetText(),
+					Timestamp: time.UnixMilli(reaction.GetSenderTimestampMS()),
+				})
+				if err != nil {
+					cli.Log.Errorf("Failed to store reaction to %s from history sync: %v", evt.Info.ID, err)
+				} else {
+					reactionCount++
+				}
+			}
+		}
+	}
+	if len(messages) > 0 {
+		err := cli.Store.Messages.PutMessages(ctx, messages)
+		if err != nil {
+			cli.Log.Errorf("Failed to store %d messages from history sync: %v", len(messages), err)
+			return
+		}
+	}
+	cli.Log.Debugf("Stored %d messages and %d reactions from history sync in message store", len(messages), reactionCount)
+}
+
+func (cli *Client) getStoredMessageForRetry(ctx context.Context, chats []types.JID, id types.MessageID) *waE2E.Message {
+	if !cli.messageStoreEnabled() {
+		return nil
+	}
+	for _, chat := range chats {
+		if chat.IsEmpty() {
+			continue
+		}
+		msg, err := cli.Store.Messages.GetMessage(ctx, chat, id)
+		if err != nil {
+			cli.Log.Warnf("Failed to get message %s/%s from message store: %v", chat, id, err)
+		} else if msg != nil && msg.IsFromMe && msg.Message != nil {
+			return msg.Message
+		}
+	}
+	return nil
+}
+
+// getOldestStoredMessage returns the info of the oldest message in the message store for the given chat,
+// or nil if the store isn't enabled or doesn't have any messages in the chat.
+func (cli *Client) getOldestStoredMessage(ctx context.Context, chat types.JID) *types.MessageInfo {
+	if !cli.messageStoreEnabled() || chat.IsEmpty() {
+		return nil
+	}
+	oldest, err := cli.Store.Messages.GetMessages(ctx, store.MessageQuery{Chat: chat, Limit: 1})
+	if err != nil {
+		cli.Log.Warnf("Failed to get oldest message in %s from message store: %v", chat, err)
+		return nil
+	} else if len(oldest) == 0 {
+		return nil
+	}
+	return &types.MessageInfo{
+		MessageSource: types.MessageSource{
+			Chat:     oldest[0].Chat,
+			Sender:   oldest[0].Sender,
+			IsFromMe: oldest[0].IsFromMe,
+		},
+		ID:        oldest[0].ID,
+		Timestamp: oldest[0].Timestamp,
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestMessageStore(t *testing.T) {
+	ctx, _, alice, bob, cli := newPairedTestClients(t)
+	cli.EnableMessageStore = true
+
+	received := make(chan *events.Message, 3)
+	cli.AddEventHandler(func(evt any) {
+		if msg, ok := evt.(*events.Message); ok && msg.Info.Sender.User == bob.PN.User {
+			received <- msg
+		}
+	})
+
+	sent, err := cli.SendMessage(ctx, bob.PN, &waE2E.Message{Conversation: proto.String("hello bob")})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	incomingID, err := bob.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: proto.String("hello alice")})
+	if err != nil {
+		t.Fatalf("Failed to send message from simulated device: %v", err)
+	}
+	_, err = bob.Phone.SendMessage(ctx, alice.PN, cli.BuildReaction(bob.PN, alice.PN, sent.ID, "👍"))
+	if err != nil {
+		t.Fatalf("Failed to send reaction from simulated device: %v", err)
+	}
+	_, err = bob.Phone.SendMessage(ctx, alice.PN, cli.BuildEdit(alice.PN, incomingID, &waE2E.Message{Conversation: proto.String("hi alice")}))
+	if err != nil {
+		t.Fatalf("Failed to send edit from simulated device: %v", err)
+	}
+	for range 3 {
+		select {
+		case <-received:
+		case <-ctx.Done():
+			t.Fatalf("Client didn't receive messages: %v", ctx.Err())
+		}
+	}
+
+	messages, err := cli.Store.Messages.GetMessages(ctx, store.MessageQuery{Chat: bob.PN})
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	} else if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages in chat with bob, got %d", len(messages))
+	}
+	if own, _ := cli.Store.Messages.GetMessage(ctx, bob.PN, sent.ID); own == nil || !own.IsFromMe || own.Message.GetConversation() != "hello bob" {
+		t.Errorf("Unexpected sent message %+v", own)
+	}
+	reactions, _ := cli.Store.Messages.GetReactions(ctx, bob.PN, sent.ID)
+	if len(reactions) != 1 || reactions[0].Reaction != "👍" {
+		t.Errorf("Unexpected reactions %+v", reactions)
+	}
+	incoming, err := cli.Store.Messages.GetMessage(ctx, bob.PN, incomingID)
+	if err != nil || incoming == nil {
+		t.Fatalf("Incoming message wasn't stored: %v", err)
+	} else if incoming.Message.GetConversation() != "hi alice" || incoming.EditedAt.IsZero() {
+		t.Errorf("Edit wasn't applied to incoming message: %+v", incoming)
+	}
+
+	historyReq := cli.BuildHistorySyncRequestFromStore(ctx, &types.MessageInfo{MessageSource: types.MessageSource{Chat: bob.PN}}, 50)
+	onDemand := historyReq.GetProtocolMessage().GetPeerDataOperationRequestMessage().GetHistorySyncOnDemandRequest()
+	if onDemand.GetOldestMsgID() != messages[0].ID {
+		t.Errorf("History sync request doesn't start from oldest stored message: %s != %s", onDemand.GetOldestMsgID(), messages[0].ID)
+	}
+}
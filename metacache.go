@@ -0,0 +1,346 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"container/list"
+	"context"
+	"time"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// MetadataCacheConfig contains options for the group member and user device list caches,
+// which are used to avoid fetching the recipient list from the server for every sent message.
+type MetadataCacheConfig struct {
+	// MaxGroups is the maximum number of groups kept in memory. Defaults to 1000.
+	MaxGroups int
+	// MaxUsers is the maximum number of device lists kept in memory. Defaults to 10000.
+	MaxUsers int
+	// TTL is how long cached data is used before fetching it from the server again.
+	// Defaults to 24 hours. Set to a negative value to never expire entries.
+	TTL time.Duration
+	// Persist makes the caches also store entries in Store.MetadataCache, so they survive restarts.
+	// This has no effect if the store doesn't support it (Store.MetadataCache is nil).
+	Persist bool
+}
+
+// CacheStats contains statistics about a cache.
+type CacheStats struct {
+	// Size is the number of entries currently kept in memory.
+	Size int
+	// Hits is the number of lookups that were answered from memory.
+	Hits uint64
+	// StoreHits is the number of lookups that weren't in memory, but were found in the persistent store.
+	StoreHits uint64
+	// Misses is the number of lookups that had to be fetched from the server.
+	Misses uint64
+	// Evictions is the number of entries that were removed because the cache was full.
+	Evictions uint64
+	// Expirations is the number of entries that were removed because they were older than the TTL.
+	Expirations uint64
+}
+
+// MetadataCacheStats contains statistics about the group member and user device list caches.
+type MetadataCacheStats struct {
+	Groups  CacheStats
+	Devices CacheStats
+}
+
+// GetMetadataCacheStats returns hit/miss statistics for the group member and user device list caches.
+func (cli *Client) GetMetadataCacheStats() MetadataCacheStats {
+	if cli == nil {
+		return MetadataCacheStats{}
+	}
+	var stats MetadataCacheStats
+	cli.groupCacheLock.Lock()
+	stats.Groups = cli.groupCache.getStats()
+	cli.groupCacheLock.Unlock()
+	cli.userDevicesCacheLock.Lock()
+	stats.Devices = cli.userDevicesCache.getStats()
+	cli.userDevicesCacheLock.Unlock()
+	return stats
+}
+
+// getMetadataCacheStore returns the store for persisting cached metadata, or nil if persisting is disabled.
+func (cli *Client) getMetadataCacheStore() store.MetadataCacheStore {
+	if !cli.MetadataCache.Persist {
+		return nil
+	}
+	return cli.Store.MetadataCache
+}
+
+func (cli *Client) getMetadataCacheLimits() (maxGroups, maxUsers int, ttl time.Duration) {
+	maxGroups, maxUsers, ttl = cli.MetadataCache.MaxGroups, cli.MetadataCache.MaxUsers, cli.MetadataCache.TTL
+	if maxGroups <= 0 {
+		maxGroups = 1000
+	}
+	if maxUsers <= 0 {
+		maxUsers = 10000
+	}
+	if ttl == 0 {
+		ttl = 24 * time.Hour
+	}
+	return
+}
+
+// lruCache is a size-bounded map that evicts the least recently used entries and expires old entries.
+// It's not thread-safe, the caller must take care of locking. The zero value is ready to use.
+type lruCache[K comparable, V any] struct {
+	items map[K]*list.Element
+	order list.List
+	stats CacheStats
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	updatedAt time.Time
+}
+
+func (c *lruCache[K, V]) lookup(key K, ttl time.Duration) (*lruEntry[K, V], bool) {
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry[K, V])
+	if ttl > 0 && time.Since(entry.updatedAt) > ttl {
+		c.remove(elem)
+		c.stats.Expirations++
+		return nil, false
+	}
+	return entry, true
+}
+
+// get returns the value for the given key and marks it as recently used. Hits are counted in the stats,
+// but misses must be counted by the caller, as the value may still be found in the persistent store.
+func (c *lruCache[K, V]) get(key K, ttl time.Duration) (V, bool) {
+	entry, ok := c.lookup(key, ttl)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(c.items[key])
+	return entry.value, true
+}
+
+// peek returns the value for the given key without affecting the stats or the LRU order.
+func (c *lruCache[K, V]) peek(key K, ttl time.Duration) (V, bool) {
+	entry, ok := c.lookup(key, ttl)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (c *lruCache[K, V]) put(key K, value V, maxSize int) {
+	c.putWithTime(key, value, time.Now(), maxSize)
+}
+
+func (c *lruCache[K, V]) putWithTime(key K, value V, updatedAt time.Time, maxSize int) {
+	if c.items == nil {
+		c.items = make(map[K]*list.Element)
+	}
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.updatedAt = updatedAt
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, updatedAt: updatedAt})
+	for maxSize > 0 && c.order.Len() > maxSize {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *lruCache[K, V]) delete(key K) {
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+func (c *lruCache[K, V]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry[K, V]).key)
+}
+
+func (c *lruCache[K, V]) getStats() CacheStats {
+	stats := c.stats
+	stats.Size = c.order.Len()
+	return stats
+}
+
+// getCachedGroup returns the cached metadata of the given group from memory or the persistent store.
+// This must be called while holding groupCacheLock. If nil is returned, the lookup was counted as a miss.
+func (cli *Client) getCachedGroup(ctx context.Context, jid types.JID) *groupMetaCache {
+	maxGroups, _, ttl := cli.getMetadataCacheLimits()
+	if cached, ok := cli.groupCache.get(jid, ttl); ok {
+		return cached
+	} else if metaStore := cli.getMetadataCacheStore(); metaStore != nil {
+		stored, err := metaStore.GetCachedGroup(ctx, jid)
+		if err != nil {
+			cli.Log.Warnf("Failed to get cached metadata of %s from store: %v", jid, err)
+		} else if stored != nil && (ttl < 0 || time.Since(stored.UpdatedAt) <= ttl) {
+			cli.groupCache.stats.StoreHits++
+			cached = &groupMetaCache{
+				AddressingMode:             stored.AddressingMode,
+				CommunityAnnouncementGroup: stored.CommunityAnnouncementGroup,
+				Members:                    stored.Members,
+			}
+			cli.groupCache.putWithTime(jid, cached, stored.UpdatedAt, maxGroups)
+			return cached
+		}
+	}
+	cli.groupCache.stats.Misses++
+	return nil
+}
+
+// putCachedGroup saves the given group metadata in the cache. This must be called while holding groupCacheLock.
+func (cli *Client) putCachedGroup(ctx context.Context, jid types.JID, data *groupMetaCache) {
+	maxGroups, _, _ := cli.getMetadataCacheLimits()
+	cli.groupCache.put(jid, data, maxGroups)
+	cli.persistCachedGroup(ctx, jid, data)
+}
+
+func (cli *Client) persistCachedGroup(ctx context.Context, jid types.JID, data *groupMetaCache) {
+	metaStore := cli.getMetadataCacheStore()
+	if metaStore == nil {
+		return
+	}
+	err := metaStore.PutCachedGroup(ctx, &store.CachedGroup{
+		JID:                        jid,
+		AddressingMode:             data.AddressingMode,
+		CommunityAnnouncementGroup: data.CommunityAnnouncementGroup,
+		Members:                    data.Members,
+		UpdatedAt:                  time.Now(),
+	})
+	if err != nil {
+		cli.Log.Warnf("Failed to save cached metadata of %s: %v", jid, err)
+	}
+}
+
+// deleteCachedGroup removes the given group from the cache. This must be called while holding groupCacheLock.
+func (cli *Client) deleteCachedGroup(ctx context.Context, jid types.JID) {
+	cli.groupCache.delete(jid)
+	if metaStore := cli.getMetadataCacheStore(); metaStore != nil {
+		err := metaStore.DeleteCachedGroup(ctx, jid)
+		if err != nil {
+			cli.Log.Warnf("Failed to delete cached metadata of %s: %v", jid, err)
+		}
+	}
+}
+
+// getCachedDevices returns the cached device list of the given user from memory or the persistent store.
+// This must be called while holding userDevicesCacheLock. If false is returned, the lookup was counted as a miss.
+func (cli *Client) getCachedDevices(ctx context.Context, jid types.JID) (deviceCache, bool) {
+	_, maxUsers, ttl := cli.getMetadataCacheLimits()
+	if cached, ok := cli.userDevicesCache.get(jid, ttl); ok {
+		return cached, true
+	} else if metaStore := cli.getMetadataCacheStore(); metaStore != nil {
+		stored, err := metaStore.GetCachedDevices(ctx, jid)
+		if err != nil {
+			cli.Log.Warnf("Failed to get cached device list of %s from store: %v", jid, err)
+		} else if stored != nil && (ttl < 0 || time.Since(stored.UpdatedAt) <= ttl) {
+			cli.userDevicesCache.stats.StoreHits++
+			cached = deviceCache{devices: stored.Devices, dhash: stored.DHash}
+			cli.userDevicesCache.putWithTime(jid, cached, stored.UpdatedAt, maxUsers)
+			return cached, true
+		}
+	}
+	cli.userDevicesCache.stats.Misses++
+	return deviceCache{}, false
+}
+
+// getManyCachedDevices is like getCachedDevices, but looks up all users that aren't in memory
+// from the persistent store with a single query. This must be called while holding userDevicesCacheLock.
+func (cli *Client) getManyCachedDevices(ctx context.Context, jids []types.JID) map[types.JID]deviceCache {
+	_, maxUsers, ttl := cli.getMetadataCacheLimits()
+	result := make(map[types.JID]deviceCache, len(jids))
+	var missing []types.JID
+	for _, jid := range jids {
+		if cached, ok := cli.userDevicesCache.get(jid, ttl); ok {
+			result[jid] = cached
+		} else {
+			missing = append(missing, jid)
+		}
+	}
+	var stored map[types.JID]*store.CachedDevices
+	if metaStore := cli.getMetadataCacheStore(); metaStore != nil && len(missing) > 0 {
+		var err error
+		stored, err = metaStore.GetManyCachedDevices(ctx, missing)
+		if err != nil {
+			cli.Log.Warnf("Failed to get cached device lists of %d users from store: %v", len(missing), err)
+		}
+	}
+	for _, jid := range missing {
+		storedDevices, ok := stored[jid]
+		if ok && storedDevices != nil && (ttl < 0 || time.Since(storedDevices.UpdatedAt) <= ttl) {
+			cli.userDevicesCache.stats.StoreHits++
+			cached := deviceCache{devices: storedDevices.Devices, dhash: storedDevices.DHash}
+			cli.userDevicesCache.putWithTime(jid, cached, storedDevices.UpdatedAt, maxUsers)
+			result[jid] = cached
+		} else {
+			cli.userDevicesCache.stats.Misses++
+		}
+	}
+	return result
+}
+
+// putCachedDevices saves the given device list in the cache. This must be called while holding userDevicesCacheLock.
+func (cli *Client) putCachedDevices(ctx context.Context, jid types.JID, data deviceCache) {
+	if toPersist := cli.cacheDevices(jid, data); toPersist != nil {
+		err := cli.Store.MetadataCache.PutCachedDevices(ctx, toPersist)
+		if err != nil {
+			cli.Log.Warnf("Failed to save cached device list of %s: %v", jid, err)
+		}
+	}
+}
+
+// cacheDevices saves the given device list in memory and returns the entry that should be persisted,
+// or nil if persisting is disabled. This must be called while holding userDevicesCacheLock.
+func (cli *Client) cacheDevices(jid types.JID, data deviceCache) *store.CachedDevices {
+	_, maxUsers, _ := cli.getMetadataCacheLimits()
+	cli.userDevicesCache.put(jid, data, maxUsers)
+	if cli.getMetadataCacheStore() == nil {
+		return nil
+	}
+	return &store.CachedDevices{
+		User:      jid,
+		Devices:   data.devices,
+		DHash:     data.dhash,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// persistCachedDevices saves the given device lists returned by cacheDevices in the persistent store.
+// This should be called after releasing userDevicesCacheLock, so that other lookups don't wait for the write.
+func (cli *Client) persistCachedDevices(ctx context.Context, devices []*store.CachedDevices) {
+	metaStore := cli.getMetadataCacheStore()
+	if metaStore == nil || len(devices) == 0 {
+		return
+	}
+	err := metaStore.PutManyCachedDevices(ctx, devices)
+	if err != nil {
+		cli.Log.Warnf("Failed to save cached device lists of %d users: %v", len(devices), err)
+	}
+}
+
+// deleteCachedDevices removes the given user from the cache. This must be called while holding userDevicesCacheLock.
+func (cli *Client) deleteCachedDevices(ctx context.Context, jid types.JID) {
+	cli.userDevicesCache.delete(jid)
+	if metaStore := cli.getMetadataCacheStore(); metaStore != nil {
+		err := metaStore.DeleteCachedDevices(ctx, jid)
+		if err != nil {
+			cli.Log.Warnf("Failed to delete cached device list of %s: %v", jid, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestMetadataCache(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	group := srv.CreateGroup("Test group", bob, alice)
+	cli := pairClient(ctx, t, srv, alice)
+	cli.MetadataCache.Persist = true
+
+	sendToGroup := func(cli *whatsmeow.Client) {
+		t.Helper()
+		_, err := cli.SendMessage(ctx, group.JID, &waE2E.Message{Conversation: proto.String("hello group")})
+		if err != nil {
+			t.Fatalf("Failed to send message: %v", err)
+		} else if _, err = bob.Phone.WaitMessage(ctx); err != nil {
+			t.Fatalf("Bob didn't receive message: %v", err)
+		}
+	}
+	sendToGroup(cli)
+	sendToGroup(cli)
+	if stats := cli.GetMetadataCacheStats().Groups; stats.Misses != 1 || stats.Hits != 1 || stats.Size != 1 {
+		t.Errorf("Unexpected group cache stats after two sends: %+v", stats)
+	}
+
+	// A new client using the same store should find the group in the persistent cache
+	restarted := whatsmeow.NewClient(cli.Store, nil)
+	srv.Configure(restarted)
+	restarted.MetadataCache.Persist = true
+	cli.Disconnect()
+	if err := restarted.Connect(); err != nil {
+		t.Fatalf("Failed to connect restarted client: %v", err)
+	}
+	defer restarted.Disconnect()
+	if err := srv.WaitConnected(ctx, *restarted.Store.ID); err != nil {
+		t.Fatalf("Restarted client didn't log in: %v", err)
+	}
+	sendToGroup(restarted)
+	if stats := restarted.GetMetadataCacheStats().Groups; stats.StoreHits != 1 || stats.Misses != 0 {
+		t.Errorf("Unexpected group cache stats after restart: %+v", stats)
+	}
+	if stats := restarted.GetMetadataCacheStats().Devices; stats.StoreHits == 0 || stats.Misses != 0 {
+		t.Errorf("Unexpected device cache stats after restart: %+v", stats)
+	}
+
+	// Group changes that may affect the recipients should drop the cached metadata
+	groupChanged := make(chan struct{}, 1)
+	restarted.AddEventHandler(func(evt any) {
+		if _, ok := evt.(*events.GroupInfo); ok {
+			groupChanged <- struct{}{}
+		}
+	})
+	err := srv.Push(alice.PN, waBinary.Node{
+		Tag: "notification",
+		Attrs: waBinary.Attrs{
+			"id":          "1",
+			"type":        "w:gp2",
+			"from":        group.JID,
+			"participant": bob.PN,
+			"t":           time.Now().Unix(),
+		},
+		Content: []waBinary.Node{{Tag: "announcement", Attrs: waBinary.Attrs{"v_id": "1"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to push group notification: %v", err)
+	}
+	select {
+	case <-groupChanged:
+	case <-ctx.Done():
+		t.Fatalf("Didn't get group info event: %v", ctx.Err())
+	}
+	if cached, err := restarted.Store.MetadataCache.GetCachedGroup(ctx, group.JID); err != nil {
+		t.Fatalf("Failed to get persisted group cache: %v", err)
+	} else if cached != nil {
+		t.Errorf("Expected group notification to remove persisted group cache")
+	}
+	sendToGroup(restarted)
+	if stats := restarted.GetMetadataCacheStats().Groups; stats.Misses != 1 {
+		t.Errorf("Expected group cache miss after group notification, got %+v", stats)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ReconnectCause describes why the client is reconnecting to WhatsApp.
+type ReconnectCause string
+
+const (
+	// ReconnectCauseInitialConnectFailed means the first connection attempt failed and InitialAutoReconnect is enabled.
+	ReconnectCauseInitialConnectFailed ReconnectCause = "initial_connect_failed"
+	// ReconnectCauseDisconnected means the websocket was closed unexpectedly.
+	ReconnectCauseDisconnected ReconnectCause = "disconnected"
+	// ReconnectCauseStreamError means the server sent a stream error (e.g. 503) before disconnecting.
+	ReconnectCauseStreamError ReconnectCause = "stream_error"
+	// ReconnectCauseConnectFailure means the server rejected the connection with a temporary failure.
+	ReconnectCauseConnectFailure ReconnectCause = "connect_failure"
+	// ReconnectCauseKeepAliveTimeout means keepalive pings failed for longer than KeepAliveMaxFailTime.
+	ReconnectCauseKeepAliveTimeout ReconnectCause = "keepalive_timeout"
+	// ReconnectCauseLogin means the server asked the client to reconnect after pairing (stream error 515).
+	ReconnectCauseLogin ReconnectCause = "login"
+)
+
+// MediaTransferDirection is the direction of a media transfer reported to Metrics.
+type MediaTransferDirection string
+
+const (
+	MediaTransferUpload   MediaTransferDirection = "upload"
+	MediaTransferDownload MediaTransferDirection = "download"
+)
+
+// Metrics receives measurements of client internals. It can be set in Client.Metrics to export them
+// to a monitoring system. Methods are called synchronously from the code being measured,
+// so implementations must be thread-safe and shouldn't block.
+//
+// Implementations should embed NoopMetrics, so that they keep compiling when new methods are added.
+type Metrics interface {
+	// Reconnect is called before every reconnection attempt. The attempt number starts from 1.
+	Reconnect(cause ReconnectCause, attempt int)
+	// KeepAlive is called after each keepalive ping with the round-trip time.
+	// If the ping failed or timed out, err is non-nil and rtt is the time spent waiting.
+	KeepAlive(rtt time.Duration, err error)
+	// IQ is called when an info query completes, with the namespace (xmlns) of the query.
+	IQ(namespace string, latency time.Duration, err error)
+	// SendMessage is called when SendMessage or SendFBMessage returns, with the timings of each phase of sending.
+	SendMessage(chat types.JID, timings MessageDebugTimings, err error)
+	// DecryptFailure is called when an incoming message fails to decrypt.
+	DecryptFailure(mode events.DecryptFailMode, isUnavailable bool)
+	// RetryReceiptSent is called when a retry receipt is sent for an undecryptable incoming message.
+	RetryReceiptSent(retryCount int)
+	// RetryReceiptReceived is called when another device sends a retry receipt for one of our messages.
+	RetryReceiptReceived(retryCount int)
+	// HandlerQueueDepth is called with the number of queued incoming nodes whenever a new node is queued.
+	HandlerQueueDepth(depth int)
+	// MediaTransfer is called after a media upload or download with the number of bytes transferred.
+	MediaTransfer(direction MediaTransferDirection, bytes int64, err error)
+}
+
+// NoopMetrics is an implementation of Metrics that does nothing. It's the default value of Client.Metrics.
+type NoopMetrics struct{}
+
+var _ Metrics = NoopMetrics{}
+
+func (NoopMetrics) Reconnect(ReconnectCause, int)                      {}
+func (NoopMetrics) KeepAlive(time.Duration, error)                     {}
+func (NoopMetrics) IQ(string, time.Duration, error)                    {}
+func (NoopMetrics) SendMessage(types.JID, MessageDebugTimings, error)  {}
+func (NoopMetrics) DecryptFailure(events.DecryptFailMode, bool)        {}
+func (NoopMetrics) RetryReceiptSent(int)                               {}
+func (NoopMetrics) RetryReceiptReceived(int)                           {}
+func (NoopMetrics) HandlerQueueDepth(int)                              {}
+func (NoopMetrics) MediaTransfer(MediaTransferDirection, int64, error) {}
+
+// getMetrics returns Client.Metrics, or NoopMetrics if it's nil.
+func (cli *Client) getMetrics() Metrics {
+	if cli.Metrics == nil {
+		return NoopMetrics{}
+	}
+	return cli.Metrics
+}
+
+func (cli *Client) setDisconnectCause(cause ReconnectCause) {
+	cli.disconnectCause.Store(&cause)
+}
@@ -46,6 +46,12 @@ func (cli *Client) handleEncryptNotification(ctx context.Context, node *waBinary
 		if err != nil {
 			cli.Log.Warnf("Failed to delete all sessions of %s from store after identity change: %v", from, err)
 		}
+		if _, ok := cli.Store.Identities.(store.VerifiedIdentityStore); ok {
+			err = cli.ClearIdentityVerified(ctx, from)
+			if err != nil {
+				cli.Log.Warnf("Failed to clear verified state of %s after identity change: %v", from, err)
+			}
+		}
 		ts := node.AttrGetter().UnixTime("t")
 		cli.dispatchEvent(&events.IdentityChange{JID: from, Timestamp: ts})
 	} else {
@@ -105,15 +111,24 @@ func (cli *Client) handleDeviceNotification(ctx context.Context, node *waBinary.
 	if fromLID != nil {
 		cli.StoreLIDPNMapping(ctx, *fromLID, from)
 	}
-	cached, ok := cli.userDevicesCache[from]
+	_, _, ttl := cli.getMetadataCacheLimits()
+	cached, ok := cli.userDevicesCache.peek(from, ttl)
 	if !ok {
 		cli.Log.Debugf("No device list cached for %s, ignoring device list notification", from)
+		// Make sure an outdated device list isn't loaded from the persistent store later
+		cli.deleteCachedDevices(ctx, from)
+		if fromLID != nil {
+			cli.deleteCachedDevices(ctx, *fromLID)
+		}
 		return
 	}
+	// Copy the device lists, as they may still be in use by senders that don't hold the cache lock
+	cached.devices = slices.Clone(cached.devices)
 	var cachedLID deviceCache
 	var cachedLIDHash string
 	if fromLID != nil {
-		cachedLID = cli.userDevicesCache[*fromLID]
+		cachedLID, _ = cli.userDevicesCache.peek(*fromLID, ttl)
+		cachedLID.devices = slices.Clone(cachedLID.devices)
 		cachedLIDHash = participantListHashV2(cachedLID.devices)
 	}
 	cachedParticipantHash := participantListHashV2(cached.devices)
@@ -142,7 +157,7 @@ func (cli *Client) handleDeviceNotification(ctx context.Context, node *waBinary.
 		case "update":
 			// Exact meaning of "update" is unknown, clear device list cache to be safe
 			cli.Log.Debugf("%s's device list updated, dropping cached devices", from)
-			delete(cli.userDevicesCache, from)
+			cli.deleteCachedDevices(ctx, from)
 			continue
 		default:
 			cli.Log.Debugf("Unknown device list change tag %s", child.Tag)
@@ -151,19 +166,21 @@ func (cli *Client) handleDeviceNotification(ctx context.Context, node *waBinary.
 		newParticipantHash := participantListHashV2(cached.devices)
 		if newParticipantHash == deviceHash {
 			cli.Log.Debugf("%s's device list hash changed from %s to %s (%s). New hash matches", from, cachedParticipantHash, deviceHash, child.Tag)
-			cli.userDevicesCache[from] = cached
+			cached.dhash = deviceHash
+			cli.putCachedDevices(ctx, from, cached)
 		} else {
 			cli.Log.Warnf("%s's device list hash changed from %s to %s (%s). New hash doesn't match (%s)", from, cachedParticipantHash, deviceHash, child.Tag, newParticipantHash)
-			delete(cli.userDevicesCache, from)
+			cli.deleteCachedDevices(ctx, from)
 		}
 		if fromLID != nil && changedDeviceLID != nil && deviceLIDHash != "" {
 			newLIDParticipantHash := participantListHashV2(cachedLID.devices)
 			if newLIDParticipantHash == deviceLIDHash {
 				cli.Log.Debugf("%s's device list hash changed from %s to %s (%s). New hash matches", fromLID, cachedLIDHash, deviceLIDHash, child.Tag)
-				cli.userDevicesCache[*fromLID] = cachedLID
+				cachedLID.dhash = deviceLIDHash
+				cli.putCachedDevices(ctx, *fromLID, cachedLID)
 			} else {
 				cli.Log.Warnf("%s's device list hash changed from %s to %s (%s). New hash doesn't match (%s)", fromLID, cachedLIDHash, deviceLIDHash, child.Tag, newLIDParticipantHash)
-				delete(cli.userDevicesCache, *fromLID)
+				cli.deleteCachedDevices(ctx, *fromLID)
 			}
 		}
 	}
@@ -174,7 +191,7 @@ func (cli *Client) handleFBDeviceNotification(ctx context.Context, node *waBinar
 	defer cli.userDevicesCacheLock.Unlock()
 	jid := node.AttrGetter().JID("from")
 	userDevices := parseFBDeviceList(jid, node.GetChildByTag("devices"))
-	cli.userDevicesCache[jid] = userDevices
+	cli.putCachedDevices(ctx, jid, userDevices)
 }
 
 func (cli *Client) handleOwnDevicesNotification(ctx context.Context, node *waBinary.Node) {
@@ -185,9 +202,11 @@ func (cli *Client) handleOwnDevicesNotification(ctx context.Context, node *waBin
 		cli.Log.Debugf("Ignoring own device change notification, session was deleted")
 		return
 	}
-	cached, ok := cli.userDevicesCache[ownID]
+	_, _, ttl := cli.getMetadataCacheLimits()
+	cached, ok := cli.userDevicesCache.peek(ownID, ttl)
 	if !ok {
 		cli.Log.Debugf("Ignoring own device change notification, device list not cached")
+		cli.deleteCachedDevices(ctx, ownID)
 		return
 	}
 	oldHash := participantListHashV2(cached.devices)
@@ -202,10 +221,10 @@ func (cli *Client) handleOwnDevicesNotification(ctx context.Context, node *waBin
 	newHash := participantListHashV2(newDeviceList)
 	if newHash != expectedNewHash {
 		cli.Log.Debugf("Received own device list change notification %s -> %s, but expected hash was %s", oldHash, newHash, expectedNewHash)
-		delete(cli.userDevicesCache, ownID)
+		cli.deleteCachedDevices(ctx, ownID)
 	} else {
 		cli.Log.Debugf("Received own device list change notification %s -> %s", oldHash, newHash)
-		cli.userDevicesCache[ownID] = deviceCache{devices: newDeviceList, dhash: expectedNewHash}
+		cli.putCachedDevices(ctx, ownID, deviceCache{devices: newDeviceList, dhash: expectedNewHash})
 	}
 }
 
@@ -443,6 +462,12 @@ func (cli *Client) handleNotification(ctx context.Context, node *waBinary.Node)
 			if err != nil {
 				cli.Log.Warnf("Failed to store redacted phones from group notification: %v", err)
 			}
+			switch typedEvt := evt.(type) {
+			case *events.GroupInfo:
+				cli.applyGroupSnapshotChange(ctx, typedEvt)
+			case *events.JoinedGroup:
+				cli.updateGroupSnapshot(ctx, &typedEvt.GroupInfo)
+			}
 			cancelled = cli.dispatchEvent(evt)
 		}
 	case "picture":
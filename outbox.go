@@ -0,0 +1,297 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+var defaultOutboxRetryPolicy = &ExponentialReconnectPolicy{
+	Base:   2 * time.Second,
+	Max:    5 * time.Minute,
+	Jitter: 0.2,
+}
+
+type outboxQueue struct {
+	messages []*store.OutboxMessage
+}
+
+// EnqueueMessage saves the given message in the outbox (Store.Outbox) and returns immediately.
+// Messages are sent in the background in the order they were queued, one chat at a time,
+// and they're retried after failures and reconnects until they're sent or fail permanently.
+// The outbox must be enabled with Client.EnableOutbox, and the device store must support it.
+//
+// The message ID is generated before the message is saved, so the returned ID can be used to track
+// the message immediately. Delivery state changes are emitted as events.OutboxStatus.
+//
+// If SendRequestExtra.ID is set and a message with the same ID is already queued for the chat,
+// the message is not queued again. Other fields of SendRequestExtra are not stored in the outbox.
+func (cli *Client) EnqueueMessage(ctx context.Context, to types.JID, message *waE2E.Message, extra ...SendRequestExtra) (types.MessageID, error) {
+	if cli == nil {
+		return "", ErrClientIsNil
+	} else if !cli.EnableOutbox {
+		return "", ErrOutboxDisabled
+	} else if cli.Store.Outbox == nil {
+		return "", ErrOutboxNotSupported
+	} else if cli.Store.ID == nil {
+		return "", ErrNotLoggedIn
+	} else if len(extra) > 1 {
+		return "", errors.New("only one extra parameter may be provided to EnqueueMessage")
+	}
+	// Queues are keyed by chat, so device JIDs must be normalized to avoid separate queues for the same chat
+	to = to.ToNonAD()
+	var id types.MessageID
+	if len(extra) == 1 {
+		id = extra[0].ID
+	}
+	if id == "" {
+		id = cli.GenerateMessageID()
+	}
+
+	cli.outboxLock.Lock()
+	defer cli.outboxLock.Unlock()
+	err := cli.loadOutboxLocked(ctx)
+	if err != nil {
+		return "", err
+	}
+	queuedAt := time.Now()
+	if !queuedAt.After(cli.outboxLastQueuedAt) {
+		// The queue time is used for ordering when loading the outbox, so it must be strictly increasing
+		queuedAt = cli.outboxLastQueuedAt.Add(time.Microsecond)
+	}
+	msg := &store.OutboxMessage{
+		Chat:     to,
+		ID:       id,
+		Message:  message,
+		QueuedAt: queuedAt,
+	}
+	inserted, err := cli.Store.Outbox.PutOutboxMessage(ctx, msg)
+	if err != nil {
+		return "", fmt.Errorf("failed to save message to outbox: %w", err)
+	} else if !inserted {
+		cli.Log.Debugf("Message %s to %s is already in the outbox", id, to)
+		return id, nil
+	}
+	cli.outboxLastQueuedAt = queuedAt
+	cli.addToOutboxQueueLocked(msg)
+	return id, nil
+}
+
+// loadOutbox loads messages that were persisted in the outbox before the client was restarted.
+func (cli *Client) loadOutbox(ctx context.Context) {
+	cli.outboxLock.Lock()
+	defer cli.outboxLock.Unlock()
+	err := cli.loadOutboxLocked(ctx)
+	if err != nil {
+		cli.Log.Errorf("Failed to load outbox: %v", err)
+	}
+}
+
+func (cli *Client) loadOutboxLocked(ctx context.Context) error {
+	if cli.outboxLoaded || cli.Store.Outbox == nil {
+		return nil
+	}
+	messages, err := cli.Store.Outbox.GetOutboxMessages(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load outbox: %w", err)
+	}
+	cli.outboxLoaded = true
+	if len(messages) > 0 {
+		cli.Log.Infof("Loaded %d messages from outbox", len(messages))
+	}
+	for _, msg := range messages {
+		if msg.QueuedAt.After(cli.outboxLastQueuedAt) {
+			cli.outboxLastQueuedAt = msg.QueuedAt
+		}
+		cli.addToOutboxQueueLocked(msg)
+	}
+	return nil
+}
+
+func (cli *Client) addToOutboxQueueLocked(msg *store.OutboxMessage) {
+	queue, ok := cli.outboxQueues[msg.Chat]
+	if !ok {
+		queue = &outboxQueue{}
+		cli.outboxQueues[msg.Chat] = queue
+		go cli.runOutboxQueue(msg.Chat, queue)
+	}
+	queue.messages = append(queue.messages, msg)
+	go cli.dispatchEvent(&events.OutboxStatus{
+		Chat:    msg.Chat,
+		ID:      msg.ID,
+		State:   events.OutboxStateQueued,
+		Attempt: msg.Attempts,
+	})
+}
+
+func (cli *Client) runOutboxQueue(chat types.JID, queue *outboxQueue) {
+	for {
+		cli.outboxLock.Lock()
+		if len(queue.messages) == 0 || cli.outboxQueues[chat] != queue {
+			if cli.outboxQueues[chat] == queue {
+				delete(cli.outboxQueues, chat)
+			}
+			cli.outboxLock.Unlock()
+			return
+		}
+		msg := queue.messages[0]
+		cli.outboxLock.Unlock()
+		if !cli.deliverOutboxMessage(chat, queue, msg) {
+			return
+		}
+		cli.outboxLock.Lock()
+		queue.messages = slices.Delete(queue.messages, 0, 1)
+		cli.outboxLock.Unlock()
+	}
+}
+
+// deliverOutboxMessage sends the given message, retrying until it's either sent or fails permanently.
+// The return value is false if the client was logged out and the queue should be abandoned.
+func (cli *Client) deliverOutboxMessage(chat types.JID, queue *outboxQueue, msg *store.OutboxMessage) bool {
+	ctx := cli.BackgroundEventCtx
+	for {
+		if !cli.waitForOutboxOnline(ctx, chat, queue) {
+			return false
+		}
+		msg.Attempts++
+		cli.dispatchEvent(&events.OutboxStatus{
+			Chat:    msg.Chat,
+			ID:      msg.ID,
+			State:   events.OutboxStateSending,
+			Attempt: msg.Attempts,
+		})
+		resp, err := cli.SendMessage(ctx, msg.Chat, msg.Message, SendRequestExtra{ID: msg.ID})
+		if err == nil {
+			cli.removeFromOutbox(ctx, msg)
+			cli.dispatchEvent(&events.OutboxStatus{
+				Chat:      msg.Chat,
+				ID:        msg.ID,
+				State:     events.OutboxStateSent,
+				Attempt:   msg.Attempts,
+				Timestamp: resp.Timestamp,
+			})
+			return true
+		}
+		policy := cli.OutboxRetryPolicy
+		if policy == nil {
+			policy = defaultOutboxRetryPolicy
+		}
+		decision := policy.NextAttempt(ReconnectAttempt{
+			Attempt:   msg.Attempts - 1,
+			LastError: err,
+		})
+		if isPermanentOutboxError(err) || decision.Stop {
+			cli.Log.Errorf("Failed to send outbox message %s to %s after %d attempts: %v", msg.ID, msg.Chat, msg.Attempts, err)
+			cli.removeFromOutbox(ctx, msg)
+			cli.dispatchEvent(&events.OutboxStatus{
+				Chat:    msg.Chat,
+				ID:      msg.ID,
+				State:   events.OutboxStateFailed,
+				Attempt: msg.Attempts,
+				Error:   err,
+			})
+			return true
+		}
+		cli.Log.Warnf("Failed to send outbox message %s to %s (attempt #%d), retrying in %s: %v", msg.ID, msg.Chat, msg.Attempts, decision.Delay, err)
+		if dbErr := cli.Store.Outbox.PutOutboxAttempts(ctx, msg.Chat, msg.ID, msg.Attempts); dbErr != nil {
+			cli.Log.Warnf("Failed to save outbox attempt count for %s: %v", msg.ID, dbErr)
+		}
+		cli.dispatchEvent(&events.OutboxStatus{
+			Chat:    msg.Chat,
+			ID:      msg.ID,
+			State:   events.OutboxStateRetrying,
+			Attempt: msg.Attempts,
+			Error:   err,
+		})
+		if !cli.outboxOnline.IsSet() {
+			// The client disconnected, so retry as soon as it's back online
+			continue
+		}
+		select {
+		case <-time.After(decision.Delay):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (cli *Client) waitForOutboxOnline(ctx context.Context, chat types.JID, queue *outboxQueue) bool {
+	for !cli.outboxOnline.IsSet() {
+		if cli.ConnectionState() == types.ConnectionStateLoggedOut {
+			return false
+		} else if cli.outboxOnline.Wait(ctx) != nil {
+			return false
+		}
+	}
+	// Make sure the queue wasn't cleared by a logout while waiting
+	cli.outboxLock.Lock()
+	defer cli.outboxLock.Unlock()
+	return cli.outboxQueues[chat] == queue
+}
+
+func (cli *Client) removeFromOutbox(ctx context.Context, msg *store.OutboxMessage) {
+	err := cli.Store.Outbox.DeleteOutboxMessage(ctx, msg.Chat, msg.ID)
+	if err != nil {
+		cli.Log.Warnf("Failed to delete message %s from outbox: %v", msg.ID, err)
+	}
+}
+
+// updateOutboxOnline starts or pauses the outbox workers when the connection state changes.
+// This is called from setConnectionState while holding connStateLock.
+func (cli *Client) updateOutboxOnline(state types.ConnectionState) {
+	switch state {
+	case types.ConnectionStateSyncingOffline, types.ConnectionStateOnline:
+		cli.outboxOnline.Set()
+	case types.ConnectionStateLoggedOut:
+		cli.outboxOnline.Clear()
+		cli.outboxOnline.Notify()
+		go cli.clearOutboxQueues()
+	default:
+		cli.outboxOnline.Clear()
+	}
+}
+
+func (cli *Client) clearOutboxQueues() {
+	cli.outboxLock.Lock()
+	defer cli.outboxLock.Unlock()
+	clear(cli.outboxQueues)
+	cli.outboxLoaded = false
+	cli.outboxLastQueuedAt = time.Time{}
+}
+
+func isPermanentOutboxError(err error) bool {
+	var serverErr *ServerReturnedError
+	var iqErr *IQError
+	switch {
+	case errors.Is(err, ErrRecipientADJID),
+		errors.Is(err, ErrInvalidInlineBotID),
+		errors.Is(err, ErrBroadcastListUnsupported),
+		errors.Is(err, ErrUnknownServer):
+		return true
+	case errors.As(err, &serverErr):
+		return isPermanentOutboxStatus(serverErr.Code)
+	case errors.As(err, &iqErr):
+		return isPermanentOutboxStatus(iqErr.Code)
+	default:
+		return false
+	}
+}
+
+// isPermanentOutboxStatus checks if an error status code from the server means that retrying won't help.
+// Server errors (5xx), timeouts and rate limits are temporary, other client errors (4xx) are permanent.
+func isPermanentOutboxStatus(code int) bool {
+	return code >= 400 && code < 500 && code != 408 && code != 429
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx, _, _, bob, cli := newPairedTestClients(t)
+	cli.Disconnect()
+
+	if _, err := cli.EnqueueMessage(ctx, bob.PN, &waE2E.Message{}); !errors.Is(err, whatsmeow.ErrOutboxDisabled) {
+		t.Fatalf("Expected outbox disabled error, got %v", err)
+	}
+	cli.EnableOutbox = true
+	outboxStore := cli.Store.Outbox
+	cli.Store.Outbox = nil
+	if _, err := cli.EnqueueMessage(ctx, bob.PN, &waE2E.Message{}); !errors.Is(err, whatsmeow.ErrOutboxNotSupported) {
+		t.Fatalf("Expected outbox not supported error, got %v", err)
+	}
+	cli.Store.Outbox = outboxStore
+	sent := make(chan *events.OutboxStatus, 4)
+	cli.AddEventHandler(func(evt any) {
+		if status, ok := evt.(*events.OutboxStatus); ok && status.State == events.OutboxStateSent {
+			sent <- status
+		}
+	})
+	var ids []types.MessageID
+	for _, text := range []string{"first", "second", "third"} {
+		id, err := cli.EnqueueMessage(ctx, bob.PN, &waE2E.Message{Conversation: proto.String(text)})
+		if err != nil {
+			t.Fatalf("Failed to enqueue message: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	// Enqueuing the same ID again shouldn't send the message twice
+	id, err := cli.EnqueueMessage(ctx, bob.PN, &waE2E.Message{Conversation: proto.String("duplicate")}, whatsmeow.SendRequestExtra{ID: ids[1]})
+	if err != nil {
+		t.Fatalf("Failed to enqueue duplicate message: %v", err)
+	} else if id != ids[1] {
+		t.Errorf("Expected duplicate enqueue to return %s, got %s", ids[1], id)
+	}
+
+	if err = cli.Connect(); err != nil {
+		t.Fatalf("Failed to reconnect: %v", err)
+	}
+	for i, expected := range []string{"first", "second", "third"} {
+		msg, err := bob.Phone.WaitMessage(ctx)
+		if err != nil {
+			t.Fatalf("Bob didn't receive message #%d: %v", i+1, err)
+		} else if msg.Message.GetConversation() != expected {
+			t.Errorf("Expected message #%d to be %q, got %q", i+1, expected, msg.Message.GetConversation())
+		} else if msg.Info.ID != ids[i] {
+			t.Errorf("Expected message #%d to have ID %s, got %s", i+1, ids[i], msg.Info.ID)
+		}
+	}
+	for i := range ids {
+		select {
+		case status := <-sent:
+			if status.ID != ids[i] {
+				t.Errorf("Expected sent event #%d for %s, got %s", i+1, ids[i], status.ID)
+			}
+		case <-ctx.Done():
+			t.Fatalf("Didn't get sent event #%d: %v", i+1, ctx.Err())
+		}
+	}
+	remaining, err := cli.Store.Outbox.GetOutboxMessages(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get outbox messages: %v", err)
+	} else if len(remaining) != 0 {
+		t.Errorf("Expected outbox to be empty, got %d messages", len(remaining))
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/testserver"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// newTestServer starts a fake server that's closed when the test ends.
+// The returned context is canceled when the test ends or after 30 seconds.
+func newTestServer(t *testing.T) (context.Context, *testserver.Server) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	srv, err := testserver.New(nil)
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = srv.Close()
+	})
+	return ctx, srv
+}
+
+// newPairedTestClients starts a fake server with two accounts, alice and bob,
+// and returns a client that's paired as a companion device of alice.
+func newPairedTestClients(t *testing.T) (
+	context.Context, *testserver.Server, *testserver.Account, *testserver.Account, *whatsmeow.Client,
+) {
+	t.Helper()
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	return ctx, srv, alice, bob, pairClient(ctx, t, srv, alice)
+}
+
+func pairClient(ctx context.Context, t *testing.T, srv *testserver.Server, acc *testserver.Account) *whatsmeow.Client {
+	t.Helper()
+	return pairExistingClient(ctx, t, srv, acc, srv.NewClient(nil))
+}
+
+// pairExistingClient is like pairClient, but allows configuring the client before it's connected.
+func pairExistingClient(
+	ctx context.Context, t *testing.T, srv *testserver.Server, acc *testserver.Account, cli *whatsmeow.Client,
+) *whatsmeow.Client {
+	t.Helper()
+	qrChan, err := cli.GetQRChannel(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get QR channel: %v", err)
+	}
+	if err = cli.Connect(); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(cli.Disconnect)
+	var qr whatsmeow.QRChannelItem
+	select {
+	case qr = <-qrChan:
+	case <-ctx.Done():
+		t.Fatalf("Didn't get QR code: %v", ctx.Err())
+	}
+	jid, err := acc.Pair(ctx, qr.Code)
+	if err != nil {
+		t.Fatalf("Failed to pair: %v", err)
+	}
+	if err = srv.WaitConnected(ctx, jid); err != nil {
+		t.Fatalf("Client didn't reconnect after pairing: %v", err)
+	}
+	return cli
+}
+
+func TestPairAndMessage(t *testing.T) {
+	ctx, _, alice, bob, cli := newPairedTestClients(t)
+
+	received := make(chan *events.Message, 1)
+	cli.AddEventHandler(func(evt any) {
+		// Sender key distribution messages are dispatched as separate events, so skip them
+		if msg, ok := evt.(*events.Message); ok && msg.Message.GetConversation() != "" {
+			received <- msg
+		}
+	})
+
+	_, err := cli.SendMessage(ctx, bob.PN, &waE2E.Message{Conversation: proto.String("hello bob")})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	msg, err := bob.Phone.WaitMessage(ctx)
+	if err != nil {
+		t.Fatalf("Bob didn't receive message: %v", err)
+	} else if msg.Message.GetConversation() != "hello bob" {
+		t.Errorf("Unexpected message content %q", msg.Message.GetConversation())
+	}
+
+	_, err = bob.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: proto.String("hello alice")})
+	if err != nil {
+		t.Fatalf("Failed to send message from simulated device: %v", err)
+	}
+	select {
+	case evt := <-received:
+		if evt.Message.GetConversation() != "hello alice" {
+			t.Errorf("Unexpected message content %q", evt.Message.GetConversation())
+		}
+	case <-ctx.Done():
+		t.Fatalf("Client didn't receive message: %v", ctx.Err())
+	}
+}
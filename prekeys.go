@@ -27,6 +27,12 @@ const (
 	WantedPreKeyCount = 50
 	// MinPreKeyCount is the number of prekeys when the client will upload a new batch of prekeys to the WhatsApp servers.
 	MinPreKeyCount = 5
+
+	// SignedPreKeyRotationInterval is how old the signed prekey can be before the client rotates it after connecting.
+	SignedPreKeyRotationInterval = 7 * 24 * time.Hour
+	// SignedPreKeyGracePeriod is how long old signed prekeys are kept after rotating,
+	// so that prekey messages encrypted by senders who fetched the old key can still be decrypted.
+	SignedPreKeyGracePeriod = 30 * 24 * time.Hour
 )
 
 func (cli *Client) getServerPreKeyCount(ctx context.Context) (int, error) {
@@ -95,6 +101,111 @@ func (cli *Client) uploadPreKeys(ctx context.Context, initialUpload bool) {
 	return
 }
 
+// RotateSignedPreKey generates a new signed prekey and uploads it to the WhatsApp servers.
+//
+// The previous signed prekey is kept in the store for SignedPreKeyGracePeriod after rotating.
+// The client automatically rotates the key after connecting if it's older than SignedPreKeyRotationInterval,
+// so this only needs to be called manually if you want to force a rotation.
+//
+// Rotating requires a store that supports keeping old signed prekeys (Store.SignedPreKeys).
+// If the store doesn't support it, ErrSignedPreKeyRotationNotSupported is returned.
+func (cli *Client) RotateSignedPreKey(ctx context.Context) error {
+	if cli == nil {
+		return ErrClientIsNil
+	} else if cli.Store.SignedPreKeys == nil {
+		return ErrSignedPreKeyRotationNotSupported
+	}
+	cli.uploadPreKeysLock.Lock()
+	defer cli.uploadPreKeysLock.Unlock()
+	return cli.rotateSignedPreKey(ctx)
+}
+
+func (cli *Client) rotateSignedPreKey(ctx context.Context) error {
+	oldKey := cli.Store.SignedPreKey
+	// Signed prekey IDs are 24 bits, and 0 is never used
+	newKeyID := oldKey.KeyID%0xffffff + 1
+	// If a previous rotation failed, the server may have accepted the key anyway,
+	// so the same key must be reused instead of generating a different one with the same ID.
+	pending, err := cli.Store.SignedPreKeys.GetSignedPreKey(ctx, newKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to check for pending signed prekey: %w", err)
+	}
+	var newKey *keys.PreKey
+	if pending != nil {
+		newKey = pending.Key
+	} else {
+		newKey = cli.Store.IdentityKey.CreateSignedPreKey(newKeyID)
+	}
+	now := time.Now()
+	// Store both keys before uploading, so that the new key can be used for decrypting
+	// even if something fails after the server has accepted it.
+	err = cli.Store.SignedPreKeys.PutSignedPreKey(ctx, oldKey, now)
+	if err != nil {
+		return fmt.Errorf("failed to store old signed prekey: %w", err)
+	}
+	err = cli.Store.SignedPreKeys.PutSignedPreKey(ctx, newKey, now)
+	if err != nil {
+		return fmt.Errorf("failed to store new signed prekey: %w", err)
+	}
+	cli.Log.Infof("Rotating signed prekey from %d to %d", oldKey.KeyID, newKey.KeyID)
+	_, err = cli.sendIQ(ctx, infoQuery{
+		Namespace: "encrypt",
+		Type:      "set",
+		To:        types.ServerJID,
+		Content: []waBinary.Node{{
+			Tag:     "rotate",
+			Content: []waBinary.Node{preKeyToNode(newKey)},
+		}},
+	})
+	if err != nil {
+		// Retire the new key too, the server may still have accepted it even though we didn't get a response.
+		retireErr := cli.Store.SignedPreKeys.RetireSignedPreKey(ctx, newKey.KeyID, now)
+		if retireErr != nil {
+			cli.Log.Warnf("Failed to mark unused signed prekey %d as retired: %v", newKey.KeyID, retireErr)
+		}
+		return fmt.Errorf("failed to upload new signed prekey: %w", err)
+	}
+	cli.Store.SignedPreKey = newKey
+	err = cli.Store.Save(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to save device after rotating signed prekey: %w", err)
+	}
+	err = cli.Store.SignedPreKeys.RetireSignedPreKey(ctx, oldKey.KeyID, now)
+	if err != nil {
+		return fmt.Errorf("failed to mark old signed prekey as retired: %w", err)
+	}
+	return nil
+}
+
+func (cli *Client) checkSignedPreKeyRotation(ctx context.Context) {
+	if cli.Store.SignedPreKeys == nil {
+		// Without a place to keep the old key, rotating would break decrypting messages sent to the old key
+		return
+	}
+	cli.uploadPreKeysLock.Lock()
+	defer cli.uploadPreKeysLock.Unlock()
+	current, err := cli.Store.SignedPreKeys.GetSignedPreKey(ctx, cli.Store.SignedPreKey.KeyID)
+	if err != nil {
+		cli.Log.Errorf("Failed to get current signed prekey from database: %v", err)
+		return
+	} else if current == nil {
+		// The key was created before rotation was implemented (or the store was reset), start the clock from now
+		err = cli.Store.SignedPreKeys.PutSignedPreKey(ctx, cli.Store.SignedPreKey, time.Now())
+		if err != nil {
+			cli.Log.Errorf("Failed to store current signed prekey: %v", err)
+		}
+	} else if time.Since(current.CreatedAt) > SignedPreKeyRotationInterval {
+		err = cli.rotateSignedPreKey(ctx)
+		if err != nil {
+			cli.Log.Errorf("Failed to rotate signed prekey: %v", err)
+		}
+	}
+	err = cli.Store.SignedPreKeys.DeleteRetiredSignedPreKeys(ctx, time.Now().Add(-SignedPreKeyGracePeriod))
+	if err != nil {
+		cli.Log.Warnf("Failed to delete expired signed prekeys: %v", err)
+	}
+}
+
 func (cli *Client) fetchPreKeysNoError(ctx context.Context, retryDevices []types.JID) map[types.JID]*prekey.Bundle {
 	if len(retryDevices) == 0 {
 		return nil
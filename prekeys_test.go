@@ -0,0 +1,72 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestSignedPreKeyRotation(t *testing.T) {
+	ctx, _, alice, bob, cli := newPairedTestClients(t)
+
+	received := make(chan *events.Message, 1)
+	cli.AddEventHandler(func(evt any) {
+		if msg, ok := evt.(*events.Message); ok && msg.Message.GetConversation() != "" {
+			received <- msg
+		}
+	})
+
+	oldKeyID := cli.Store.SignedPreKey.KeyID
+	if err := cli.RotateSignedPreKey(ctx); err != nil {
+		t.Fatalf("Failed to rotate signed prekey: %v", err)
+	} else if cli.Store.SignedPreKey.KeyID == oldKeyID {
+		t.Fatalf("Signed prekey ID didn't change")
+	}
+	if oldKey, err := cli.Store.LoadSignedPreKey(ctx, oldKeyID); err != nil || oldKey == nil {
+		t.Errorf("Old signed prekey wasn't kept after rotating: %v", err)
+	}
+
+	_, err := bob.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: proto.String("hello alice")})
+	if err != nil {
+		t.Fatalf("Failed to send message from simulated device: %v", err)
+	}
+	select {
+	case evt := <-received:
+		if evt.Message.GetConversation() != "hello alice" {
+			t.Errorf("Unexpected message content %q", evt.Message.GetConversation())
+		}
+	case <-ctx.Done():
+		t.Fatalf("Client didn't receive message encrypted with new signed prekey: %v", ctx.Err())
+	}
+
+	// A key left behind by a failed rotation must be reused, as the server may have accepted it
+	pendingKey := cli.Store.IdentityKey.CreateSignedPreKey(cli.Store.SignedPreKey.KeyID%0xffffff + 1)
+	if err = cli.Store.SignedPreKeys.PutSignedPreKey(ctx, pendingKey, time.Now()); err != nil {
+		t.Fatalf("Failed to store pending signed prekey: %v", err)
+	}
+	if err = cli.RotateSignedPreKey(ctx); err != nil {
+		t.Fatalf("Failed to rotate signed prekey again: %v", err)
+	} else if cli.Store.SignedPreKey.KeyID != pendingKey.KeyID || *cli.Store.SignedPreKey.Priv != *pendingKey.Priv {
+		t.Error("Rotation didn't reuse the pending signed prekey")
+	}
+
+	// Stores without signed prekey support can't rotate and only know the current key
+	cli.Store.SignedPreKeys = nil
+	if err = cli.RotateSignedPreKey(ctx); !errors.Is(err, whatsmeow.ErrSignedPreKeyRotationNotSupported) {
+		t.Errorf("Expected rotation not supported error, got %v", err)
+	} else if ok, err := cli.Store.ContainsSignedPreKey(ctx, oldKeyID); err != nil || ok {
+		t.Errorf("Expected old signed prekey to be unknown without store, got %t/%v", ok, err)
+	}
+}
@@ -34,7 +34,7 @@ func (cli *Client) handleReceipt(ctx context.Context, node *waBinary.Node) {
 				}
 			}()
 		}
-		cancelled = cli.dispatchEvent(receipt)
+		cancelled = cli.dispatchEventCtx(ctx, receipt)
 	}
 }
 
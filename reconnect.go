@@ -0,0 +1,154 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ReconnectAttempt contains information about an automatic reconnection that is about to be scheduled.
+type ReconnectAttempt struct {
+	// Attempt is the number of failed attempts since the last successful connection (0 for the first attempt).
+	Attempt int
+	// Cause is the reason the client disconnected.
+	Cause ReconnectCause
+	// LastError is the error from the previous attempt, or nil if this is the first attempt.
+	LastError error
+}
+
+// ReconnectDecision is the result of a ReconnectPolicy.
+type ReconnectDecision struct {
+	// Delay is how long to wait before trying to reconnect.
+	Delay time.Duration
+	// Stop means the client should give up reconnecting.
+	Stop bool
+	// CircuitOpen means the delay was extended by a circuit breaker.
+	CircuitOpen bool
+}
+
+// ReconnectPolicy decides when and whether the client should try to reconnect automatically.
+//
+// Policies may be shared between multiple clients, so implementations must be thread-safe.
+type ReconnectPolicy interface {
+	NextAttempt(attempt ReconnectAttempt) ReconnectDecision
+}
+
+// LinearReconnectPolicy is the default reconnect policy, which waits 2 seconds longer after each failed attempt.
+type LinearReconnectPolicy struct{}
+
+func (LinearReconnectPolicy) NextAttempt(attempt ReconnectAttempt) ReconnectDecision {
+	return ReconnectDecision{Delay: time.Duration(attempt.Attempt) * 2 * time.Second}
+}
+
+// ExponentialReconnectPolicy waits exponentially longer after each failed attempt, with random jitter
+// so that many clients disconnected at the same time don't all reconnect at the same time.
+type ExponentialReconnectPolicy struct {
+	// Base is the delay before the first attempt. Defaults to 1 second.
+	Base time.Duration
+	// Max is the maximum delay. Defaults to 5 minutes.
+	Max time.Duration
+	// Multiplier is how much the delay grows after each attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter is the fraction of the delay that is randomized, between 0 and 1.
+	// For example, 0.5 means the actual delay is between 50% and 100% of the computed delay.
+	// Defaults to 0.2. Set to a negative value to disable jitter.
+	Jitter float64
+}
+
+const defaultReconnectJitter = 0.2
+
+func (erp *ExponentialReconnectPolicy) NextAttempt(attempt ReconnectAttempt) ReconnectDecision {
+	base, maxDelay, multiplier := erp.Base, erp.Max, erp.Multiplier
+	if base <= 0 {
+		base = 1 * time.Second
+	}
+	if maxDelay <= 0 {
+		maxDelay = 5 * time.Minute
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	jitter := erp.Jitter
+	if jitter == 0 {
+		jitter = defaultReconnectJitter
+	}
+	delay := min(float64(base)*math.Pow(multiplier, float64(attempt.Attempt)), float64(maxDelay))
+	if jitter > 0 {
+		delay -= delay * min(jitter, 1) * rand.Float64()
+	}
+	return ReconnectDecision{Delay: time.Duration(delay)}
+}
+
+// CappedReconnectPolicy stops reconnecting after MaxAttempts failed attempts.
+type CappedReconnectPolicy struct {
+	// Policy decides the delay between attempts. Defaults to LinearReconnectPolicy.
+	Policy ReconnectPolicy
+	// MaxAttempts is the maximum number of attempts after a disconnection.
+	MaxAttempts int
+}
+
+func (crp *CappedReconnectPolicy) NextAttempt(attempt ReconnectAttempt) ReconnectDecision {
+	if attempt.Attempt >= crp.MaxAttempts {
+		return ReconnectDecision{Stop: true}
+	}
+	return policyOrDefault(crp.Policy).NextAttempt(attempt)
+}
+
+// CircuitBreakerReconnectPolicy pauses reconnecting for a while if the server keeps rejecting connections
+// (ReconnectCauseConnectFailure) or sending stream errors like 503 (ReconnectCauseStreamError).
+//
+// Stream errors usually happen after a successful connection, which resets the attempt counter,
+// so without a circuit breaker the client would keep reconnecting immediately.
+// Failures are counted per policy instance, so sharing one instance between clients makes the breaker trip
+// based on the failures of all of them.
+type CircuitBreakerReconnectPolicy struct {
+	// Policy decides the delay when the circuit is closed. Defaults to LinearReconnectPolicy.
+	Policy ReconnectPolicy
+	// Threshold is the number of connect failures or stream errors within Window that trips the circuit breaker.
+	Threshold int
+	// Window is the time window for counting failures.
+	Window time.Duration
+	// Cooldown is the minimum delay after the circuit breaker trips.
+	Cooldown time.Duration
+
+	lock     sync.Mutex
+	failures []time.Time
+}
+
+func (cbp *CircuitBreakerReconnectPolicy) NextAttempt(attempt ReconnectAttempt) ReconnectDecision {
+	decision := policyOrDefault(cbp.Policy).NextAttempt(attempt)
+	// Only count the first attempt after each disconnection, later attempts failed for other reasons
+	if decision.Stop || attempt.LastError != nil ||
+		(attempt.Cause != ReconnectCauseConnectFailure && attempt.Cause != ReconnectCauseStreamError) {
+		return decision
+	}
+	cbp.lock.Lock()
+	defer cbp.lock.Unlock()
+	now := time.Now()
+	validFrom := 0
+	for validFrom < len(cbp.failures) && now.Sub(cbp.failures[validFrom]) > cbp.Window {
+		validFrom++
+	}
+	cbp.failures = append(cbp.failures[validFrom:], now)
+	if len(cbp.failures) >= cbp.Threshold {
+		// Reset the counter so that the circuit is half-open after the cooldown
+		cbp.failures = cbp.failures[:0]
+		decision.Delay = max(decision.Delay, cbp.Cooldown)
+		decision.CircuitOpen = true
+	}
+	return decision
+}
+
+func policyOrDefault(policy ReconnectPolicy) ReconnectPolicy {
+	if policy == nil {
+		return LinearReconnectPolicy{}
+	}
+	return policy
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func TestReconnectPolicies(t *testing.T) {
+	exp := &whatsmeow.ExponentialReconnectPolicy{Base: time.Second, Max: 10 * time.Second, Jitter: 0.5}
+	for attempt, maxDelay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		delay := exp.NextAttempt(whatsmeow.ReconnectAttempt{Attempt: attempt}).Delay
+		if delay > maxDelay || delay < maxDelay/2 {
+			t.Errorf("Attempt %d: delay %s not in [%s, %s]", attempt, delay, maxDelay/2, maxDelay)
+		}
+	}
+
+	// Jitter is enabled by default, so the delays of separate attempts shouldn't all be equal
+	defaultExp := &whatsmeow.ExponentialReconnectPolicy{Base: time.Second}
+	first := defaultExp.NextAttempt(whatsmeow.ReconnectAttempt{Attempt: 3}).Delay
+	jittered := false
+	for range 10 {
+		if defaultExp.NextAttempt(whatsmeow.ReconnectAttempt{Attempt: 3}).Delay != first {
+			jittered = true
+			break
+		}
+	}
+	if !jittered {
+		t.Error("Exponential policy didn't apply jitter by default")
+	} else if noJitter := (&whatsmeow.ExponentialReconnectPolicy{Base: time.Second, Jitter: -1}).NextAttempt(whatsmeow.ReconnectAttempt{Attempt: 3}).Delay; noJitter != 8*time.Second {
+		t.Errorf("Expected exact delay with jitter disabled, got %s", noJitter)
+	}
+
+	capped := &whatsmeow.CappedReconnectPolicy{MaxAttempts: 3}
+	if capped.NextAttempt(whatsmeow.ReconnectAttempt{Attempt: 2}).Stop {
+		t.Error("Capped policy stopped before reaching max attempts")
+	} else if !capped.NextAttempt(whatsmeow.ReconnectAttempt{Attempt: 3}).Stop {
+		t.Error("Capped policy didn't stop after max attempts")
+	}
+
+	breaker := &whatsmeow.CircuitBreakerReconnectPolicy{Threshold: 3, Window: time.Minute, Cooldown: time.Minute}
+	streamError := whatsmeow.ReconnectAttempt{Cause: whatsmeow.ReconnectCauseStreamError}
+	for range 2 {
+		if breaker.NextAttempt(streamError).CircuitOpen {
+			t.Fatal("Circuit breaker tripped too early")
+		}
+		// Retries after other errors and disconnections for other reasons don't count as failures
+		breaker.NextAttempt(whatsmeow.ReconnectAttempt{Cause: whatsmeow.ReconnectCauseStreamError, LastError: errors.New("meow")})
+		breaker.NextAttempt(whatsmeow.ReconnectAttempt{Cause: whatsmeow.ReconnectCauseDisconnected})
+	}
+	if decision := breaker.NextAttempt(streamError); !decision.CircuitOpen || decision.Delay != time.Minute {
+		t.Errorf("Circuit breaker didn't trip after threshold: %+v", decision)
+	} else if breaker.NextAttempt(streamError).CircuitOpen {
+		t.Error("Circuit breaker didn't reset after tripping")
+	}
+}
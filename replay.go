@@ -0,0 +1,60 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.mau.fi/whatsmeow/capture"
+)
+
+func (cli *Client) captureFrame(dir capture.Direction, data []byte) {
+	if cli.CaptureWriter == nil {
+		return
+	}
+	err := cli.CaptureWriter.WriteFrame(dir, time.Now(), data)
+	if err != nil {
+		cli.Log.Warnf("Failed to write %s node to capture: %v", dir, err)
+	}
+}
+
+// Replay feeds the received nodes in a capture into the client's node handlers,
+// as if they had been received from the server.
+//
+// Nodes are handled synchronously one by one in the order they were recorded, which makes replays deterministic.
+// Sent nodes in the capture are skipped. The client doesn't need to be connected: any nodes that the handlers
+// try to send (e.g. acks and receipts) will fail with ErrNotConnected, which is only logged.
+// The device store must contain whatever state the handlers need (e.g. Signal sessions for decrypting messages).
+func (cli *Client) Replay(ctx context.Context, reader *capture.Reader) error {
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read capture entry: %w", err)
+		} else if entry.Direction != capture.DirectionRecv {
+			continue
+		}
+		node, err := entry.Node()
+		if err != nil {
+			return fmt.Errorf("failed to decode node captured at %s: %w", entry.Time, err)
+		}
+		cli.recvLog.Debugf("%s", node.XMLString())
+		if cli.receiveResponse(ctx, node) {
+			continue
+		} else if handler, ok := cli.nodeHandlers[node.Tag]; ok {
+			handler(ctx, node)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
@@ -149,10 +149,14 @@ func (cli *Client) sendIQAsync(ctx context.Context, query infoQuery) (<-chan *wa
 
 const defaultRequestTimeout = 75 * time.Second
 
-func (cli *Client) sendIQ(ctx context.Context, query infoQuery) (*waBinary.Node, error) {
+func (cli *Client) sendIQ(ctx context.Context, query infoQuery) (res *waBinary.Node, err error) {
 	if query.Timeout == 0 {
 		query.Timeout = defaultRequestTimeout
 	}
+	start := time.Now()
+	defer func() {
+		cli.getMetrics().IQ(query.Namespace, time.Since(start), err)
+	}()
 	resChan, data, err := cli.sendIQAsyncAndGetData(ctx, &query)
 	if err != nil {
 		return nil, err
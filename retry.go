@@ -91,7 +91,12 @@ func (cli *Client) getMessageForRetry(ctx context.Context, receipt *events.Recei
 			return &msg, nil
 		}
 	}
-	waMsg := cli.GetMessageForRetry(receipt.Sender, receipt.Chat, messageID)
+	waMsg := cli.getStoredMessageForRetry(ctx, []types.JID{receipt.Chat, altChat}, messageID)
+	if waMsg != nil {
+		cli.Log.Debugf("Found message in message store to accept retry receipt for %s/%s from %s", receipt.Chat, messageID, receipt.Sender)
+		return &RecentMessage{wa: waMsg}, nil
+	}
+	waMsg = cli.GetMessageForRetry(receipt.Sender, receipt.Chat, messageID)
 	if waMsg != nil {
 		cli.Log.Debugf("Found message in GetMessageForRetry to accept retry receipt for %s/%s from %s", receipt.Chat, messageID, receipt.Sender)
 		return &RecentMessage{wa: waMsg}, nil
@@ -138,6 +143,7 @@ func (cli *Client) handleRetryReceipt(ctx context.Context, receipt *events.Recei
 	if !ag.OK() {
 		return ag.Error()
 	}
+	cli.getMetrics().RetryReceiptReceived(retryCount)
 	msg, err := cli.getMessageForRetry(ctx, receipt, messageID)
 	if err != nil {
 		return err
@@ -170,7 +176,9 @@ func (cli *Client) handleRetryReceipt(ctx context.Context, receipt *events.Recei
 	if receipt.IsGroup {
 		builder := groups.NewGroupSessionBuilder(cli.Store, pbSerializer)
 		senderKeyName := protocol.NewSenderKeyName(receipt.Chat.String(), cli.getOwnLID().SignalAddress())
+		unlockSenderKey := cli.signalLocks.Lock(senderKeyLockKey(receipt.Chat.String()))
 		signalSKDMessage, err := builder.Create(ctx, senderKeyName)
+		unlockSenderKey()
 		if err != nil {
 			cli.Log.Warnf("Failed to create sender key distribution message to include in retry of %s in %s to %s: %v", messageID, receipt.Chat, receipt.Sender, err)
 		} else if msg.wa != nil {
@@ -267,8 +275,12 @@ func (cli *Client) handleRetryReceipt(ctx context.Context, receipt *events.Recei
 				encryptionIdentity = lidForPN
 			}
 		}
+		// Sends to other chats may be using the same session concurrently
+		unlockSession := cli.signalLocks.Lock(encryptionIdentity.SignalAddress().String())
 		encrypted, includeDeviceIdentity, err = cli.encryptMessageForDevice(ctx, plaintext, encryptionIdentity, bundle, encAttrs, nil)
+		unlockSession()
 	} else {
+		unlockSession := cli.signalLocks.Lock(receipt.Sender.SignalAddress().String())
 		encrypted, err = cli.encryptMessageForDeviceV3(ctx, &waMsgTransport.MessageTransport_Payload{
 			ApplicationPayload: &waCommon.SubProtocol{
 				Payload: plaintext,
@@ -276,6 +288,7 @@ func (cli *Client) handleRetryReceipt(ctx context.Context, receipt *events.Recei
 			},
 			FutureProof: waCommon.FutureProofBehavior_PLACEHOLDER.Enum(),
 		}, fbSKDM, fbDSM, receipt.Sender, bundle, encAttrs)
+		unlockSession()
 	}
 	if err != nil {
 		return fmt.Errorf("failed to encrypt message for retry: %w", err)
@@ -458,5 +471,7 @@ func (cli *Client) sendRetryReceipt(ctx context.Context, node *waBinary.Node, in
 	err := cli.sendNode(ctx, payload)
 	if err != nil {
 		cli.Log.Errorf("Failed to send retry receipt for %s: %v", id, err)
+	} else {
+		cli.getMetrics().RetryReceiptSent(retryCount)
 	}
 }
@@ -210,6 +210,15 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	if len(req.ID) == 0 {
 		req.ID = cli.GenerateMessageID()
 	}
+	ctx, span := cli.getTracer().Start(
+		ctx, "whatsmeow.SendMessage",
+		TraceAttribute{Key: "chat", Value: to.String()},
+		TraceAttribute{Key: "message_id", Value: req.ID},
+	)
+	defer func() {
+		cli.getMetrics().SendMessage(to, resp.DebugTimings, err)
+		span.End(err)
+	}()
 	if to.Server == types.NewsletterServer {
 		// TODO somehow deduplicate this with the code in sendNewsletter?
 		if message.EditedMessage != nil {
@@ -294,11 +303,12 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 
 	var groupParticipants []types.JID
 	if to.Server == types.GroupServer || to.Server == types.BroadcastServer {
-		start := time.Now()
+		phaseCtx, endPhase := cli.startSendPhase(ctx, "get_participants", &resp.DebugTimings.GetParticipants)
 		if to.Server == types.GroupServer {
 			var cachedData *groupMetaCache
-			cachedData, err = cli.getCachedGroupData(ctx, to)
+			cachedData, err = cli.getCachedGroupData(phaseCtx, to)
 			if err != nil {
+				endPhase(err)
 				err = fmt.Errorf("failed to get group members: %w", err)
 				return
 			}
@@ -313,34 +323,38 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 				extraParams.addressingMode = types.AddressingModePN
 			}
 		} else {
-			groupParticipants, err = cli.getBroadcastListParticipants(ctx, to)
+			groupParticipants, err = cli.getBroadcastListParticipants(phaseCtx, to)
 			if err != nil {
+				endPhase(err)
 				err = fmt.Errorf("failed to get broadcast list members: %w", err)
 				return
 			}
 		}
-		resp.DebugTimings.GetParticipants = time.Since(start)
+		endPhase(nil)
 	} else if to.Server == types.HiddenUserServer {
 		ownID = cli.getOwnLID()
 	} else if to.Server == types.DefaultUserServer && cli.Store.LIDMigrationTimestamp > 0 && !req.Peer {
-		start := time.Now()
+		phaseCtx, endPhase := cli.startSendPhase(ctx, "lid_fetch", &resp.DebugTimings.LIDFetch)
 		var toLID types.JID
-		toLID, err = cli.Store.LIDs.GetLIDForPN(ctx, to)
+		toLID, err = cli.Store.LIDs.GetLIDForPN(phaseCtx, to)
 		if err != nil {
+			endPhase(err)
 			err = fmt.Errorf("failed to get LID for PN %s: %w", to, err)
 			return
 		} else if toLID.IsEmpty() {
 			var info map[types.JID]types.UserInfo
-			info, err = cli.GetUserInfo(ctx, []types.JID{to})
+			info, err = cli.GetUserInfo(phaseCtx, []types.JID{to})
 			if err != nil {
+				endPhase(err)
 				err = fmt.Errorf("failed to get user info for %s to fill LID cache: %w", to, err)
 				return
 			} else if toLID = info[to].LID; toLID.IsEmpty() {
 				err = fmt.Errorf("no LID found for %s from server", to)
+				endPhase(err)
 				return
 			}
 		}
-		resp.DebugTimings.LIDFetch = time.Since(start)
+		endPhase(nil)
 		cli.Log.Debugf("Replacing SendMessage destination with LID as migration timestamp is set %s -> %s", to, toLID)
 		to = toLID
 		ownID = cli.getOwnLID()
@@ -365,13 +379,13 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 
 	resp.Sender = ownID
 
-	start := time.Now()
-	// Sending multiple messages at a time can cause weird issues and makes it harder to retry safely
-	// This is also required for the session prefetching that makes group sends faster
-	// (everything will explode if you send a message to the same user twice in parallel)
-	cli.messageSendLock.Lock()
-	resp.DebugTimings.Queue = time.Since(start)
-	defer cli.messageSendLock.Unlock()
+	_, endQueue := cli.startSendPhase(ctx, "queue", &resp.DebugTimings.Queue)
+	// Sending multiple messages to the same chat at a time can cause weird issues and makes it harder to retry safely.
+	// Sends to different chats can run in parallel, the Signal sessions they share are locked separately
+	// in encryptMessageForDevices (everything will explode if you encrypt for the same device twice in parallel).
+	unlockChat := cli.chatSendLocks.Lock(to.String())
+	endQueue(nil)
+	defer unlockChat()
 
 	respChan := cli.waitResponse(req.ID)
 	// Peer message retries aren't implemented yet
@@ -403,11 +417,11 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	default:
 		err = fmt.Errorf("%w %s", ErrUnknownServer, to.Server)
 	}
-	start = time.Now()
 	if err != nil {
 		cli.cancelResponse(req.ID, respChan)
 		return
 	}
+	_, endResp := cli.startSendPhase(ctx, "resp", &resp.DebugTimings.Resp)
 	var respNode *waBinary.Node
 	var timeoutChan <-chan time.Time
 	if req.Timeout > 0 {
@@ -420,17 +434,19 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	case <-timeoutChan:
 		cli.cancelResponse(req.ID, respChan)
 		err = ErrMessageTimedOut
+		endResp(err)
 		return
 	case <-ctx.Done():
 		cli.cancelResponse(req.ID, respChan)
 		err = ctx.Err()
+		endResp(err)
 		return
 	}
-	resp.DebugTimings.Resp = time.Since(start)
+	endResp(nil)
 	if isDisconnectNode(respNode) {
-		start = time.Now()
-		respNode, err = cli.retryFrame(ctx, "message send", req.ID, data, respNode, 0)
-		resp.DebugTimings.Retry = time.Since(start)
+		retryCtx, endRetry := cli.startSendPhase(ctx, "retry", &resp.DebugTimings.Retry)
+		respNode, err = cli.retryFrame(retryCtx, "message send", req.ID, data, respNode, 0)
+		endRetry(err)
 		if err != nil {
 			return
 		}
@@ -439,7 +455,7 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	resp.ServerID = types.MessageServerID(ag.OptionalInt("server_id"))
 	resp.Timestamp = ag.UnixTime("t")
 	if errorCode := ag.Int("error"); errorCode != 0 {
-		err = fmt.Errorf("%w %d", ErrServerReturnedError, errorCode)
+		err = &ServerReturnedError{Code: errorCode}
 	}
 	expectedPHash := ag.OptionalString("phash")
 	if len(expectedPHash) > 0 && phash != expectedPHash {
@@ -448,16 +464,19 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 		case types.GroupServer:
 			// TODO also invalidate device list caches
 			cli.groupCacheLock.Lock()
-			delete(cli.groupCache, to)
+			cli.deleteCachedGroup(ctx, to)
 			cli.groupCacheLock.Unlock()
 		case types.BroadcastServer:
 			// TODO do something
 		case types.DefaultUserServer, types.HiddenUserServer, types.BotServer, types.HostedServer, types.HostedLIDServer:
 			cli.userDevicesCacheLock.Lock()
-			delete(cli.userDevicesCache, to)
+			cli.deleteCachedDevices(ctx, to)
 			cli.userDevicesCacheLock.Unlock()
 		}
 	}
+	if err == nil && !req.Peer {
+		cli.storeSentMessage(ctx, to, ownID, req.ID, message, resp.Timestamp)
+	}
 	return
 }
 
@@ -558,7 +577,12 @@ func (cli *Client) BuildUnavailableMessageRequest(chat, sender types.JID, id str
 //
 // The response will contain to `count` messages immediately before the given message.
 // The recommended number of messages to request at a time is 50.
+//
+// This returns nil if lastKnownMessageInfo is nil.
 func (cli *Client) BuildHistorySyncRequest(lastKnownMessageInfo *types.MessageInfo, count int) *waE2E.Message {
+	if lastKnownMessageInfo == nil {
+		return nil
+	}
 	return &waE2E.Message{
 		ProtocolMessage: &waE2E.ProtocolMessage{
 			Type: waE2E.ProtocolMessage_PEER_DATA_OPERATION_REQUEST_MESSAGE.Enum(),
@@ -576,6 +600,20 @@ func (cli *Client) BuildHistorySyncRequest(lastKnownMessageInfo *types.MessageIn
 	}
 }
 
+// BuildHistorySyncRequestFromStore is like BuildHistorySyncRequest, but if EnableMessageStore is set,
+// the oldest message in the message store for the same chat is used instead when it's older than the given message
+// (or when the given message has no ID), so the request continues from where the stored history ends.
+func (cli *Client) BuildHistorySyncRequestFromStore(ctx context.Context, lastKnownMessageInfo *types.MessageInfo, count int) *waE2E.Message {
+	if lastKnownMessageInfo == nil {
+		return nil
+	}
+	if oldest := cli.getOldestStoredMessage(ctx, lastKnownMessageInfo.Chat); oldest != nil &&
+		(lastKnownMessageInfo.ID == "" || oldest.Timestamp.Before(lastKnownMessageInfo.Timestamp)) {
+		lastKnownMessageInfo = oldest
+	}
+	return cli.BuildHistorySyncRequest(lastKnownMessageInfo, count)
+}
+
 // EditWindow specifies how long a message can be edited for after it was sent.
 const EditWindow = 20 * time.Minute
 
@@ -702,9 +740,9 @@ func (cli *Client) sendNewsletter(
 		attrs["edit"] = string(types.EditAttributeAdminRevoke)
 		message = nil
 	}
-	start := time.Now()
+	_, endMarshal := cli.startSendPhase(ctx, "marshal", &timings.Marshal)
 	plaintext, _, err := marshalMessage(to, message)
-	timings.Marshal = time.Since(start)
+	endMarshal(err)
 	if err != nil {
 		return nil, err
 	}
@@ -723,9 +761,9 @@ func (cli *Client) sendNewsletter(
 		Attrs:   attrs,
 		Content: []waBinary.Node{plaintextNode},
 	}
-	start = time.Now()
-	data, err := cli.sendNodeAndGetData(ctx, node)
-	timings.Send = time.Since(start)
+	sendCtx, endSend := cli.startSendPhase(ctx, "send", &timings.Send)
+	data, err := cli.sendNodeAndGetData(sendCtx, node)
+	endSend(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send message node: %w", err)
 	}
@@ -749,18 +787,21 @@ func (cli *Client) sendGroup(
 	timings *MessageDebugTimings,
 	extraParams nodeExtraParams,
 ) (string, []byte, error) {
-	start := time.Now()
+	_, endMarshal := cli.startSendPhase(ctx, "marshal", &timings.Marshal)
 	plaintext, _, err := marshalMessage(to, message)
-	timings.Marshal = time.Since(start)
+	endMarshal(err)
 	if err != nil {
 		return "", nil, err
 	}
 
-	start = time.Now()
+	encryptCtx, endEncrypt := cli.startSendPhase(ctx, "group_encrypt", &timings.GroupEncrypt)
 	builder := groups.NewGroupSessionBuilder(cli.Store, pbSerializer)
 	senderKeyName := protocol.NewSenderKeyName(to.String(), cli.getOwnLID().SignalAddress())
-	signalSKDMessage, err := builder.Create(ctx, senderKeyName)
+	unlockSenderKey := cli.signalLocks.Lock(senderKeyLockKey(to.String()))
+	signalSKDMessage, err := builder.Create(encryptCtx, senderKeyName)
 	if err != nil {
+		unlockSenderKey()
+		endEncrypt(err)
 		return "", nil, fmt.Errorf("failed to create sender key distribution message to send %s to %s: %w", id, to, err)
 	}
 	skdMessage := &waE2E.Message{
@@ -771,16 +812,19 @@ func (cli *Client) sendGroup(
 	}
 	skdPlaintext, err := proto.Marshal(skdMessage)
 	if err != nil {
+		unlockSenderKey()
+		endEncrypt(err)
 		return "", nil, fmt.Errorf("failed to marshal sender key distribution message to send %s to %s: %w", id, to, err)
 	}
 
 	cipher := groups.NewGroupCipher(builder, senderKeyName, cli.Store)
-	encrypted, err := cipher.Encrypt(ctx, padMessage(plaintext))
+	encrypted, err := cipher.Encrypt(encryptCtx, padMessage(plaintext))
+	unlockSenderKey()
+	endEncrypt(err)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to encrypt group message to send %s to %s: %w", id, to, err)
 	}
 	ciphertext := encrypted.SignedSerialize()
-	timings.GroupEncrypt = time.Since(start)
 
 	node, allDevices, err := cli.prepareMessageNode(
 		ctx, to, id, message, participants, skdPlaintext, nil, timings, extraParams,
@@ -804,9 +848,9 @@ func (cli *Client) sendGroup(
 		node.Content = append(node.GetChildren(), cli.getMessageReportingToken(plaintext, message, ownID, to, id))
 	}
 
-	start = time.Now()
-	data, err := cli.sendNodeAndGetData(ctx, *node)
-	timings.Send = time.Since(start)
+	sendCtx, endSend := cli.startSendPhase(ctx, "send", &timings.Send)
+	data, err := cli.sendNodeAndGetData(sendCtx, *node)
+	endSend(err)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to send message node: %w", err)
 	}
@@ -824,9 +868,9 @@ func (cli *Client) sendPeerMessage(
 	if err != nil {
 		return nil, err
 	}
-	start := time.Now()
-	data, err := cli.sendNodeAndGetData(ctx, *node)
-	timings.Send = time.Since(start)
+	sendCtx, endSend := cli.startSendPhase(ctx, "send", &timings.Send)
+	data, err := cli.sendNodeAndGetData(sendCtx, *node)
+	endSend(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send message node: %w", err)
 	}
@@ -842,9 +886,9 @@ func (cli *Client) sendDM(
 	timings *MessageDebugTimings,
 	extraParams nodeExtraParams,
 ) (string, []byte, error) {
-	start := time.Now()
+	_, endMarshal := cli.startSendPhase(ctx, "marshal", &timings.Marshal)
 	messagePlaintext, deviceSentMessagePlaintext, err := marshalMessage(to, message)
-	timings.Marshal = time.Since(start)
+	endMarshal(err)
 	if err != nil {
 		return "", nil, err
 	}
@@ -871,9 +915,9 @@ func (cli *Client) sendDM(
 		})
 	}
 
-	start = time.Now()
-	data, err := cli.sendNodeAndGetData(ctx, *node)
-	timings.Send = time.Since(start)
+	sendCtx, endSend := cli.startSendPhase(ctx, "send", &timings.Send)
+	data, err := cli.sendNodeAndGetData(sendCtx, *node)
+	endSend(err)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to send message node: %w", err)
 	}
@@ -1048,9 +1092,9 @@ func (cli *Client) preparePeerMessageNode(
 	if message.GetProtocolMessage().GetType() == waE2E.ProtocolMessage_APP_STATE_SYNC_KEY_REQUEST {
 		attrs["push_priority"] = "high"
 	}
-	start := time.Now()
+	_, endMarshal := cli.startSendPhase(ctx, "marshal", &timings.Marshal)
 	plaintext, err := proto.Marshal(message)
-	timings.Marshal = time.Since(start)
+	endMarshal(err)
 	if err != nil {
 		err = fmt.Errorf("failed to marshal message: %w", err)
 		return nil, err
@@ -1062,9 +1106,11 @@ func (cli *Client) preparePeerMessageNode(
 			return nil, fmt.Errorf("failed to get LID for PN %s: %w", to, err)
 		}
 	}
-	start = time.Now()
-	encrypted, isPreKey, err := cli.encryptMessageForDevice(ctx, plaintext, encryptionIdentity, nil, nil, nil)
-	timings.PeerEncrypt = time.Since(start)
+	encryptCtx, endEncrypt := cli.startSendPhase(ctx, "peer_encrypt", &timings.PeerEncrypt)
+	unlockSession := cli.signalLocks.Lock(encryptionIdentity.SignalAddress().String())
+	encrypted, isPreKey, err := cli.encryptMessageForDevice(encryptCtx, plaintext, encryptionIdentity, nil, nil, nil)
+	unlockSession()
+	endEncrypt(err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt peer message for %s: %v", to, err)
 	}
@@ -1140,9 +1186,9 @@ func (cli *Client) prepareMessageNode(
 	timings *MessageDebugTimings,
 	extraParams nodeExtraParams,
 ) (*waBinary.Node, []types.JID, error) {
-	start := time.Now()
-	allDevices, err := cli.GetUserDevices(ctx, participants)
-	timings.GetDevices = time.Since(start)
+	devicesCtx, endDevices := cli.startSendPhase(ctx, "get_devices", &timings.GetDevices)
+	allDevices, err := cli.GetUserDevices(devicesCtx, participants)
+	endDevices(err)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get device list: %w", err)
 	}
@@ -1176,11 +1222,11 @@ func (cli *Client) prepareMessageNode(
 		encAttrs["decrypt-fail"] = string(events.DecryptFailHide)
 	}
 
-	start = time.Now()
+	encryptCtx, endEncrypt := cli.startSendPhase(ctx, "peer_encrypt", &timings.PeerEncrypt)
 	participantNodes, includeIdentity, err := cli.encryptMessageForDevices(
-		ctx, allDevices, id, plaintext, dsmPlaintext, encAttrs,
+		encryptCtx, allDevices, id, plaintext, dsmPlaintext, encAttrs,
 	)
-	timings.PeerEncrypt = time.Since(start)
+	endEncrypt(err)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -1276,6 +1322,9 @@ func (cli *Client) encryptMessageForDevices(
 		sessionAddressToJID[addr] = jid
 	}
 
+	// The cached sessions are written back at the end, so nobody else may touch them in the meantime
+	unlockSessions := cli.signalLocks.Lock(sessionAddresses...)
+	defer unlockSessions()
 	existingSessions, ctx, err := cli.Store.WithCachedSessions(ctx, sessionAddresses)
 	if err != nil {
 		return nil, false, fmt.Errorf("failed to prefetch sessions: %w", err)
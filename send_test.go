@@ -0,0 +1,56 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestGroupMessage(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	group := srv.CreateGroup("Test group", bob, alice)
+	cli := pairClient(ctx, t, srv, alice)
+
+	received := make(chan *events.Message, 1)
+	cli.AddEventHandler(func(evt any) {
+		// Sender key distribution messages are dispatched as separate events, so skip them
+		if msg, ok := evt.(*events.Message); ok && msg.Message.GetConversation() != "" {
+			received <- msg
+		}
+	})
+
+	_, err := cli.SendMessage(ctx, group.JID, &waE2E.Message{Conversation: proto.String("hello group")})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	msg, err := bob.Phone.WaitMessage(ctx)
+	if err != nil {
+		t.Fatalf("Bob didn't receive message: %v", err)
+	} else if msg.Message.GetConversation() != "hello group" || msg.Info.Chat != group.JID {
+		t.Errorf("Unexpected message %q in %s", msg.Message.GetConversation(), msg.Info.Chat)
+	}
+
+	_, err = bob.Phone.SendMessage(ctx, group.JID, &waE2E.Message{Conversation: proto.String("hello alice")})
+	if err != nil {
+		t.Fatalf("Failed to send message from simulated device: %v", err)
+	}
+	select {
+	case evt := <-received:
+		if evt.Message.GetConversation() != "hello alice" || evt.Info.Chat != group.JID {
+			t.Errorf("Unexpected message %q in %s", evt.Message.GetConversation(), evt.Info.Chat)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Client didn't receive message: %v", ctx.Err())
+	}
+}
@@ -59,6 +59,11 @@ func (cli *Client) SendFBMessage(
 	} else if len(extra) == 1 {
 		req = extra[0]
 	}
+	ctx, span := cli.getTracer().Start(ctx, "whatsmeow.SendFBMessage", TraceAttribute{Key: "chat", Value: to.String()})
+	defer func() {
+		cli.getMetrics().SendMessage(to, resp.DebugTimings, err)
+		span.End(err)
+	}()
 	var subproto waMsgApplication.MessageApplication_SubProtocolPayload
 	subproto.FutureProof = waCommon.FutureProofBehavior_PLACEHOLDER.Enum()
 	switch typedMsg := message.(type) {
@@ -131,11 +136,11 @@ func (cli *Client) SendFBMessage(
 	}
 	resp.ID = req.ID
 
-	start := time.Now()
-	// Sending multiple messages at a time can cause weird issues and makes it harder to retry safely
-	cli.messageSendLock.Lock()
-	resp.DebugTimings.Queue = time.Since(start)
-	defer cli.messageSendLock.Unlock()
+	_, endQueue := cli.startSendPhase(ctx, "queue", &resp.DebugTimings.Queue)
+	// Sending multiple messages to the same chat at a time can cause weird issues and makes it harder to retry safely
+	unlockChat := cli.chatSendLocks.Lock(to.String())
+	endQueue(nil)
+	defer unlockChat()
 
 	respChan := cli.waitResponse(req.ID)
 	if !req.Peer {
@@ -156,11 +161,11 @@ func (cli *Client) SendFBMessage(
 	default:
 		err = fmt.Errorf("%w %s", ErrUnknownServer, to.Server)
 	}
-	start = time.Now()
 	if err != nil {
 		cli.cancelResponse(req.ID, respChan)
 		return
 	}
+	_, endResp := cli.startSendPhase(ctx, "resp", &resp.DebugTimings.Resp)
 	var respNode *waBinary.Node
 	var timeoutChan <-chan time.Time
 	if req.Timeout > 0 {
@@ -173,17 +178,19 @@ func (cli *Client) SendFBMessage(
 	case <-timeoutChan:
 		cli.cancelResponse(req.ID, respChan)
 		err = ErrMessageTimedOut
+		endResp(err)
 		return
 	case <-ctx.Done():
 		cli.cancelResponse(req.ID, respChan)
 		err = ctx.Err()
+		endResp(err)
 		return
 	}
-	resp.DebugTimings.Resp = time.Since(start)
+	endResp(nil)
 	if isDisconnectNode(respNode) {
-		start = time.Now()
-		respNode, err = cli.retryFrame(ctx, "message send", req.ID, data, respNode, 0)
-		resp.DebugTimings.Retry = time.Since(start)
+		retryCtx, endRetry := cli.startSendPhase(ctx, "retry", &resp.DebugTimings.Retry)
+		respNode, err = cli.retryFrame(retryCtx, "message send", req.ID, data, respNode, 0)
+		endRetry(err)
 		if err != nil {
 			return
 		}
@@ -192,14 +199,14 @@ func (cli *Client) SendFBMessage(
 	resp.ServerID = types.MessageServerID(ag.OptionalInt("server_id"))
 	resp.Timestamp = ag.UnixTime("t")
 	if errorCode := ag.Int("error"); errorCode != 0 {
-		err = fmt.Errorf("%w %d", ErrServerReturnedError, errorCode)
+		err = &ServerReturnedError{Code: errorCode}
 	}
 	expectedPHash := ag.OptionalString("phash")
 	if len(expectedPHash) > 0 && phash != expectedPHash {
 		cli.Log.Warnf("Server returned different participant list hash when sending to %s. Some devices may not have received the message.", to)
 		// TODO also invalidate device list caches
 		cli.groupCacheLock.Lock()
-		delete(cli.groupCache, to)
+		cli.deleteCachedGroup(ctx, to)
 		cli.groupCacheLock.Unlock()
 	}
 	return
@@ -217,20 +224,24 @@ func (cli *Client) sendGroupV3(
 ) (string, []byte, error) {
 	var groupMeta *groupMetaCache
 	var err error
-	start := time.Now()
+	participantsCtx, endParticipants := cli.startSendPhase(ctx, "get_participants", &timings.GetParticipants)
 	if to.Server == types.GroupServer {
-		groupMeta, err = cli.getCachedGroupData(ctx, to)
+		groupMeta, err = cli.getCachedGroupData(participantsCtx, to)
 		if err != nil {
+			endParticipants(err)
 			return "", nil, fmt.Errorf("failed to get group members: %w", err)
 		}
 	}
-	timings.GetParticipants = time.Since(start)
+	endParticipants(nil)
 
-	start = time.Now()
+	encryptCtx, endEncrypt := cli.startSendPhase(ctx, "group_encrypt", &timings.GroupEncrypt)
 	builder := groups.NewGroupSessionBuilder(cli.Store, pbSerializer)
 	senderKeyName := protocol.NewSenderKeyName(to.String(), ownID.SignalAddress())
-	signalSKDMessage, err := builder.Create(ctx, senderKeyName)
+	unlockSenderKey := cli.signalLocks.Lock(senderKeyLockKey(to.String()))
+	signalSKDMessage, err := builder.Create(encryptCtx, senderKeyName)
 	if err != nil {
+		unlockSenderKey()
+		endEncrypt(err)
 		return "", nil, fmt.Errorf("failed to create sender key distribution message to send %s to %s: %w", id, to, err)
 	}
 	skdm := &waMsgTransport.MessageTransport_Protocol_Ancillary_SenderKeyDistributionMessage{
@@ -264,14 +275,17 @@ func (cli *Client) sendGroupV3(
 		},
 	})
 	if err != nil {
+		unlockSenderKey()
+		endEncrypt(err)
 		return "", nil, fmt.Errorf("failed to marshal message transport: %w", err)
 	}
-	encrypted, err := cipher.Encrypt(ctx, plaintext)
+	encrypted, err := cipher.Encrypt(encryptCtx, plaintext)
+	unlockSenderKey()
+	endEncrypt(err)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to encrypt group message to send %s to %s: %w", id, to, err)
 	}
 	ciphertext := encrypted.SignedSerialize()
-	timings.GroupEncrypt = time.Since(start)
 
 	node, allDevices, err := cli.prepareMessageNodeV3(
 		ctx, to, ownID, id, nil, skdm, msgAttrs, frankingTag, groupMeta.Members, timings,
@@ -292,9 +306,9 @@ func (cli *Client) sendGroupV3(
 	}
 	node.Content = append(node.GetChildren(), skMsg)
 
-	start = time.Now()
-	data, err := cli.sendNodeAndGetData(ctx, *node)
-	timings.Send = time.Since(start)
+	sendCtx, endSend := cli.startSendPhase(ctx, "send", &timings.Send)
+	data, err := cli.sendNodeAndGetData(sendCtx, *node)
+	endSend(err)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to send message node: %w", err)
 	}
@@ -323,9 +337,9 @@ func (cli *Client) sendDMV3(
 	if err != nil {
 		return nil, "", err
 	}
-	start := time.Now()
-	data, err := cli.sendNodeAndGetData(ctx, *node)
-	timings.Send = time.Since(start)
+	sendCtx, endSend := cli.startSendPhase(ctx, "send", &timings.Send)
+	data, err := cli.sendNodeAndGetData(sendCtx, *node)
+	endSend(err)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to send message node: %w", err)
 	}
@@ -438,9 +452,9 @@ func (cli *Client) prepareMessageNodeV3(
 	participants []types.JID,
 	timings *MessageDebugTimings,
 ) (*waBinary.Node, []types.JID, error) {
-	start := time.Now()
-	allDevices, err := cli.GetUserDevices(ctx, participants)
-	timings.GetDevices = time.Since(start)
+	devicesCtx, endDevices := cli.startSendPhase(ctx, "get_devices", &timings.GetDevices)
+	allDevices, err := cli.GetUserDevices(devicesCtx, participants)
+	endDevices(err)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get device list: %w", err)
 	}
@@ -467,12 +481,12 @@ func (cli *Client) prepareMessageNodeV3(
 		Phash:          proto.String(""),
 	}
 
-	start = time.Now()
-	participantNodes, err := cli.encryptMessageForDevicesV3(ctx, allDevices, ownID, id, payload, skdm, dsm, encAttrs)
+	encryptCtx, endEncrypt := cli.startSendPhase(ctx, "peer_encrypt", &timings.PeerEncrypt)
+	participantNodes, err := cli.encryptMessageForDevicesV3(encryptCtx, allDevices, ownID, id, payload, skdm, dsm, encAttrs)
+	endEncrypt(err)
 	if err != nil {
 		return nil, nil, err
 	}
-	timings.PeerEncrypt = time.Since(start)
 	content := make([]waBinary.Node, 0, 4)
 	content = append(content, waBinary.Node{
 		Tag:     "participants",
@@ -531,6 +545,8 @@ func (cli *Client) encryptMessageForDevicesV3(
 		sessionAddresses = append(sessionAddresses, addr)
 		sessionAddressToJID[addr] = jid
 	}
+	unlockSessions := cli.signalLocks.Lock(sessionAddresses...)
+	defer unlockSessions()
 	existingSessions, ctx, err := cli.Store.WithCachedSessions(ctx, sessionAddresses)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prefetch sessions: %w", err)
@@ -0,0 +1,68 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"slices"
+	"sync"
+)
+
+// keyedMutex is a set of mutexes identified by string keys. Mutexes are created on demand
+// and removed when nobody is holding or waiting for them. The zero value is ready to use.
+type keyedMutex struct {
+	lock  sync.Mutex
+	locks map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock locks all the given keys and returns a function that unlocks them.
+//
+// Keys are always locked in sorted order, so callers that need multiple keys can't deadlock each other.
+// However, callers must not call Lock again before unlocking the previous keys from the same keyedMutex.
+func (km *keyedMutex) Lock(keys ...string) (unlock func()) {
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	entries := make([]*keyedMutexEntry, len(keys))
+	km.lock.Lock()
+	if km.locks == nil {
+		km.locks = make(map[string]*keyedMutexEntry)
+	}
+	for i, key := range keys {
+		entry, ok := km.locks[key]
+		if !ok {
+			entry = &keyedMutexEntry{}
+			km.locks[key] = entry
+		}
+		entry.refs++
+		entries[i] = entry
+	}
+	km.lock.Unlock()
+	for _, entry := range entries {
+		entry.Lock()
+	}
+	return func() {
+		km.lock.Lock()
+		defer km.lock.Unlock()
+		for i, entry := range entries {
+			entry.Unlock()
+			entry.refs--
+			if entry.refs == 0 {
+				delete(km.locks, keys[i])
+			}
+		}
+	}
+}
+
+// senderKeyLockKey returns the signalLocks key for our own sender key in the given group.
+func senderKeyLockKey(group string) string {
+	return "senderkey:" + group
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/testserver"
+)
+
+func TestParallelSend(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	carol := srv.NewAccount("10000000003")
+	cli := pairClient(ctx, t, srv, alice)
+
+	// Sends to different chats run in parallel, but all of them encrypt a copy for alice's phone,
+	// so this would break the shared session if it wasn't locked properly.
+	const perChat = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perChat)
+	for _, to := range []*testserver.Account{bob, carol} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perChat {
+				_, err := cli.SendMessage(ctx, to.PN, &waE2E.Message{Conversation: proto.String(strconv.Itoa(i))})
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	for _, to := range []*testserver.Account{bob, carol} {
+		for i := range perChat {
+			msg, err := to.Phone.WaitMessage(ctx)
+			if err != nil {
+				t.Fatalf("%s didn't receive message #%d: %v", to.PN, i, err)
+			} else if msg.Message.GetConversation() != strconv.Itoa(i) {
+				t.Errorf("Expected message #%d to %s to be %q, got %q", i, to.PN, strconv.Itoa(i), msg.Message.GetConversation())
+			}
+		}
+	}
+	for i := range 2 * perChat {
+		if _, err := alice.Phone.WaitMessage(ctx); err != nil {
+			t.Fatalf("Alice's phone didn't receive sent message copy #%d: %v", i, err)
+		}
+	}
+}
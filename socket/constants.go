@@ -25,6 +25,10 @@ const (
 
 const (
 	NoiseStartPattern = "Noise_XX_25519_AESGCM_SHA256\x00\x00\x00\x00"
+	// NoiseResumePattern is used when the client already knows the server's static key from a previous connection.
+	NoiseResumePattern = "Noise_IK_25519_AESGCM_SHA256\x00\x00\x00\x00"
+	// NoiseFallbackPattern is used when the server can't decrypt the first message of a Noise_IK handshake.
+	NoiseFallbackPattern = "Noise_XXfallback_25519_AESGCM_SHA256"
 
 	WAMagicValue = 6
 )
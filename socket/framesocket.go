@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/coder/websocket"
 
@@ -35,7 +36,7 @@ type FrameSocket struct {
 
 	Header []byte
 
-	closed bool
+	closed atomic.Bool
 
 	incomingLength int
 	receivedLength int
@@ -56,34 +57,39 @@ func NewFrameSocket(log waLog.Logger, client *http.Client) *FrameSocket {
 }
 
 func (fs *FrameSocket) IsConnected() bool {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
 	return fs.conn != nil
 }
 
 func (fs *FrameSocket) Close(code websocket.StatusCode) {
 	fs.lock.Lock()
-	defer fs.lock.Unlock()
-
-	if fs.conn == nil {
+	conn, cancel := fs.conn, fs.cancel
+	if conn == nil {
+		fs.lock.Unlock()
 		return
 	}
+	fs.closed.Store(true)
+	fs.conn = nil
+	fs.cancel = nil
+	onDisconnect, parentCtx := fs.OnDisconnect, fs.parentCtx
+	// The close handshake may block for a while, so don't hold the lock during it
+	fs.lock.Unlock()
 
-	fs.closed = true
 	if code > 0 {
-		err := fs.conn.Close(code, "")
+		err := conn.Close(code, "")
 		if err != nil {
 			fs.log.Warnf("Error sending close to websocket: %v", err)
 		}
 	} else {
-		err := fs.conn.CloseNow()
+		err := conn.CloseNow()
 		if err != nil {
 			fs.log.Debugf("Error force closing websocket: %v", err)
 		}
 	}
-	fs.conn = nil
-	fs.cancel()
-	fs.cancel = nil
-	if fs.OnDisconnect != nil {
-		go fs.OnDisconnect(fs.parentCtx, code == 0)
+	cancel()
+	if onDisconnect != nil {
+		go onDisconnect(parentCtx, code == 0)
 	}
 }
 
@@ -121,7 +127,9 @@ func (fs *FrameSocket) Context() context.Context {
 }
 
 func (fs *FrameSocket) SendFrame(data []byte) error {
-	conn := fs.conn
+	fs.lock.Lock()
+	conn, ctx := fs.conn, fs.cancelCtx
+	fs.lock.Unlock()
 	if conn == nil {
 		return ErrSocketClosed
 	}
@@ -149,7 +157,7 @@ func (fs *FrameSocket) SendFrame(data []byte) error {
 	// Copy actual frame data
 	copy(wholeFrame[headerLength+FrameLengthSize:], data)
 
-	return conn.Write(fs.cancelCtx, websocket.MessageBinary, wholeFrame)
+	return conn.Write(ctx, websocket.MessageBinary, wholeFrame)
 }
 
 func (fs *FrameSocket) frameComplete() {
@@ -212,7 +220,7 @@ func (fs *FrameSocket) readPump(conn *websocket.Conn, ctx context.Context) {
 		msgType, data, err := conn.Read(ctx)
 		if err != nil {
 			// Ignore the error if the context has been closed
-			if !fs.closed && !errors.Is(ctx.Err(), context.Canceled) {
+			if !fs.closed.Load() && !errors.Is(ctx.Err(), context.Canceled) {
 				fs.log.Errorf("Error reading from websocket: %v", err)
 			}
 			return
@@ -81,12 +81,8 @@ func (nh *NoiseHandshake) Finish(
 	frameHandler FrameHandler,
 	disconnectHandler DisconnectHandler,
 ) (*NoiseSocket, error) {
-	if write, read, err := nh.extractAndExpand(nh.salt, nil); err != nil {
-		return nil, fmt.Errorf("failed to extract final keys: %w", err)
-	} else if writeKey, err := gcmutil.Prepare(write); err != nil {
-		return nil, fmt.Errorf("failed to create final write cipher: %w", err)
-	} else if readKey, err := gcmutil.Prepare(read); err != nil {
-		return nil, fmt.Errorf("failed to create final read cipher: %w", err)
+	if writeKey, readKey, err := nh.FinalKeys(); err != nil {
+		return nil, err
 	} else if ns, err := newNoiseSocket(ctx, fs, writeKey, readKey, frameHandler, disconnectHandler); err != nil {
 		return nil, fmt.Errorf("failed to create noise socket: %w", err)
 	} else {
@@ -94,6 +90,21 @@ func (nh *NoiseHandshake) Finish(
 	}
 }
 
+// FinalKeys derives the transport ciphers from a completed handshake.
+//
+// The keys are returned from the initiator's point of view,
+// so the responder must use the first one for reading and the second one for writing.
+func (nh *NoiseHandshake) FinalKeys() (writeKey, readKey cipher.AEAD, err error) {
+	if write, read, err := nh.extractAndExpand(nh.salt, nil); err != nil {
+		return nil, nil, fmt.Errorf("failed to extract final keys: %w", err)
+	} else if writeKey, err = gcmutil.Prepare(write); err != nil {
+		return nil, nil, fmt.Errorf("failed to create final write cipher: %w", err)
+	} else if readKey, err = gcmutil.Prepare(read); err != nil {
+		return nil, nil, fmt.Errorf("failed to create final read cipher: %w", err)
+	}
+	return
+}
+
 func (nh *NoiseHandshake) MixSharedSecretIntoKey(priv, pub [32]byte) error {
 	secret, err := curve25519.X25519(priv[:], pub[:])
 	if err != nil {
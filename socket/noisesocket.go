@@ -80,7 +80,9 @@ func (ns *NoiseSocket) Stop(disconnect, allowOnDisconnect bool) {
 	if ns.destroyed.CompareAndSwap(false, true) {
 		close(ns.stopConsumer)
 		if !allowOnDisconnect {
+			ns.fs.lock.Lock()
 			ns.fs.OnDisconnect = nil
+			ns.fs.lock.Unlock()
 		}
 		if disconnect {
 			ns.fs.Close(websocket.StatusNormalClosure)
@@ -0,0 +1,163 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package memstore contains an in-memory implementation of the interfaces in the store package.
+//
+// Everything is kept in memory, but the whole container can optionally be snapshotted to a single file
+// and restored from it later, which lets devices survive restarts without a database.
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	"go.mau.fi/util/random"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// Container is an in-memory store.DeviceContainer that can contain multiple whatsmeow sessions.
+type Container struct {
+	log  waLog.Logger
+	lids *LIDMap
+
+	// path is the file that the container is automatically snapshotted to, set by Open.
+	path string
+
+	lock    sync.Mutex
+	devices map[types.JID]*store.Device
+}
+
+var _ store.DeviceContainer = (*Container)(nil)
+
+// New creates a new empty in-memory container.
+//
+// The logger can be nil and will default to a no-op logger.
+func New(log waLog.Logger) *Container {
+	if log == nil {
+		log = waLog.Noop
+	}
+	return &Container{
+		log:     log,
+		lids:    NewLIDMap(),
+		devices: make(map[types.JID]*store.Device),
+	}
+}
+
+// GetAllDevices returns all the devices in the container.
+func (c *Container) GetAllDevices(_ context.Context) ([]*store.Device, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	devices := make([]*store.Device, 0, len(c.devices))
+	for _, device := range c.devices {
+		devices = append(devices, device)
+	}
+	slices.SortFunc(devices, func(a, b *store.Device) int {
+		return cmp.Compare(a.ID.String(), b.ID.String())
+	})
+	return devices, nil
+}
+
+// GetFirstDevice is a convenience method for getting the first device in the store. If there are
+// no devices, then a new device will be created. You should only use this if you don't want to
+// have multiple sessions simultaneously.
+func (c *Container) GetFirstDevice(ctx context.Context) (*store.Device, error) {
+	devices, err := c.GetAllDevices(ctx)
+	if err != nil {
+		return nil, err
+	} else if len(devices) == 0 {
+		return c.NewDevice(), nil
+	}
+	return devices[0], nil
+}
+
+// GetDevice finds the device with the specified JID in the container.
+//
+// If the device is not found, nil is returned instead.
+func (c *Container) GetDevice(_ context.Context, jid types.JID) (*store.Device, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.devices[jid], nil
+}
+
+// NewDevice creates a new device in this container.
+//
+// The device isn't added to the container before Save is called. However, the pairing process will automatically
+// call Save after a successful pairing, so you most likely don't need to call it yourself.
+func (c *Container) NewDevice() *store.Device {
+	device := &store.Device{
+		Log:       c.log,
+		Container: c,
+
+		NoiseKey:       keys.NewKeyPair(),
+		IdentityKey:    keys.NewKeyPair(),
+		RegistrationID: rand.Uint32(),
+		AdvSecretKey:   random.Bytes(32),
+	}
+	device.SignedPreKey = device.IdentityKey.CreateSignedPreKey(1)
+	return device
+}
+
+// ErrDeviceIDMustBeSet is the error returned by PutDevice if you try to save a device before knowing its JID.
+var ErrDeviceIDMustBeSet = errors.New("device JID must be known before saving")
+
+// PutDevice stores the given device in this container. This should be called through Device.Save()
+// (which usually doesn't need to be called manually, as the library does that automatically when relevant).
+//
+// If the container was created with Open, the snapshot file is also updated.
+func (c *Container) PutDevice(_ context.Context, device *store.Device) error {
+	if device.ID == nil {
+		return ErrDeviceIDMustBeSet
+	}
+	if !device.Initialized {
+		c.initializeDevice(device, NewMemoryStore())
+	}
+	c.lock.Lock()
+	c.devices[*device.ID] = device
+	c.lock.Unlock()
+	return c.autoSave()
+}
+
+// DeleteDevice deletes the given device from this container. This should be called through Device.Delete()
+func (c *Container) DeleteDevice(_ context.Context, device *store.Device) error {
+	if device.ID == nil {
+		return ErrDeviceIDMustBeSet
+	}
+	c.lock.Lock()
+	delete(c.devices, *device.ID)
+	c.lock.Unlock()
+	return c.autoSave()
+}
+
+func (c *Container) initializeDevice(device *store.Device, innerStore *MemoryStore) {
+	device.Identities = innerStore
+	device.Sessions = innerStore
+	device.PreKeys = innerStore
+	device.SignedPreKeys = innerStore
+	device.SenderKeys = innerStore
+	device.AppStateKeys = innerStore
+	device.AppState = innerStore
+	device.Contacts = innerStore
+	device.ChatSettings = innerStore
+	device.MsgSecrets = innerStore
+	device.PrivacyTokens = innerStore
+	device.EventBuffer = innerStore
+	device.Messages = innerStore
+	device.Leases = innerStore
+	device.Outbox = innerStore
+	device.MetadataCache = innerStore
+	device.GroupSnapshots = innerStore
+	device.LIDs = c.lids
+	device.Container = c
+	device.Initialized = true
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func (s *MemoryStore) PutGroupSnapshot(_ context.Context, info *types.GroupInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal group info: %w", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.GroupInfo[info.JID] = data
+	return nil
+}
+
+func (s *MemoryStore) GetGroupSnapshot(_ context.Context, group types.JID) (*types.GroupInfo, error) {
+	s.lock.Lock()
+	data, ok := s.data.GroupInfo[group]
+	s.lock.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	var info types.GroupInfo
+	err := json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group info: %w", err)
+	}
+	return &info, nil
+}
+
+func (s *MemoryStore) GetGroupSnapshotJIDs(_ context.Context) ([]types.JID, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	jids := make([]types.JID, 0, len(s.data.GroupInfo))
+	for jid := range s.data.GroupInfo {
+		jids = append(jids, jid)
+	}
+	return jids, nil
+}
+
+func (s *MemoryStore) DeleteGroupSnapshot(_ context.Context, group types.JID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data.GroupInfo, group)
+	return nil
+}
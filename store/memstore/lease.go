@@ -0,0 +1,45 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/whatsmeow/store"
+)
+
+func (s *MemoryStore) AcquireSessionLease(_ context.Context, owner string, expiresAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.leaseOwner != "" && s.leaseOwner != owner && time.Now().Before(s.leaseExpiry) {
+		return store.ErrSessionLeaseHeld
+	}
+	s.leaseOwner = owner
+	s.leaseExpiry = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) RenewSessionLease(_ context.Context, owner string, expiresAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.leaseOwner != owner {
+		return store.ErrSessionLeaseHeld
+	}
+	s.leaseExpiry = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) ReleaseSessionLease(_ context.Context, owner string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.leaseOwner == owner {
+		s.leaseOwner = ""
+		s.leaseExpiry = time.Time{}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// LIDMap is an in-memory implementation of store.LIDStore.
+type LIDMap struct {
+	lock    sync.RWMutex
+	pnToLID map[string]string
+	lidToPN map[string]string
+}
+
+var _ store.LIDStore = (*LIDMap)(nil)
+
+// NewLIDMap creates a new empty LIDMap.
+func NewLIDMap() *LIDMap {
+	return &LIDMap{
+		pnToLID: make(map[string]string),
+		lidToPN: make(map[string]string),
+	}
+}
+
+func (m *LIDMap) PutManyLIDMappings(ctx context.Context, mappings []store.LIDMapping) error {
+	for _, mapping := range mappings {
+		if mapping.LID.Server != types.HiddenUserServer || mapping.PN.Server != types.DefaultUserServer {
+			continue
+		}
+		_ = m.PutLIDMapping(ctx, mapping.LID, mapping.PN)
+	}
+	return nil
+}
+
+func (m *LIDMap) PutLIDMapping(ctx context.Context, lid, pn types.JID) error {
+	if lid.Server != types.HiddenUserServer || pn.Server != types.DefaultUserServer {
+		return errors.New("invalid PutLIDMapping call " + lid.String() + "/" + pn.String())
+	}
+	m.lock.Lock()
+	if oldLID, ok := m.pnToLID[pn.User]; ok && oldLID != lid.User {
+		delete(m.lidToPN, oldLID)
+	}
+	m.pnToLID[pn.User] = lid.User
+	m.lidToPN[lid.User] = pn.User
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *LIDMap) GetPNForLID(ctx context.Context, lid types.JID) (types.JID, error) {
+	m.lock.RLock()
+	pn, ok := m.lidToPN[lid.User]
+	m.lock.RUnlock()
+	if !ok {
+		return types.EmptyJID, nil
+	}
+	return types.JID{User: pn, Device: lid.Device, Server: types.DefaultUserServer}, nil
+}
+
+func (m *LIDMap) GetLIDForPN(ctx context.Context, pn types.JID) (types.JID, error) {
+	m.lock.RLock()
+	lid, ok := m.pnToLID[pn.User]
+	m.lock.RUnlock()
+	if !ok {
+		return types.EmptyJID, nil
+	}
+	return types.JID{User: lid, Device: pn.Device, Server: types.HiddenUserServer}, nil
+}
+
+func (m *LIDMap) GetManyLIDsForPNs(ctx context.Context, pns []types.JID) (map[types.JID]types.JID, error) {
+	if len(pns) == 0 {
+		return nil, nil
+	}
+	result := make(map[types.JID]types.JID, len(pns))
+	m.lock.RLock()
+	for _, pn := range pns {
+		if lid, ok := m.pnToLID[pn.User]; ok && pn.Server == types.DefaultUserServer {
+			result[pn] = types.JID{User: lid, Device: pn.Device, Server: types.HiddenUserServer}
+		}
+	}
+	m.lock.RUnlock()
+	return result, nil
+}
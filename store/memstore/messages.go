@@ -0,0 +1,182 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+type messageKey struct {
+	Chat types.JID
+	ID   types.MessageID
+}
+
+// storedMessage is a store.StoredMessage with the content in the protobuf wire format,
+// so that it can be snapshotted with gob.
+type storedMessage struct {
+	Sender    types.JID
+	IsFromMe  bool
+	Timestamp time.Time
+	Message   []byte
+	EditedAt  time.Time
+	RevokedAt time.Time
+}
+
+func (msg *storedMessage) toStore(key messageKey) (*store.StoredMessage, error) {
+	out := &store.StoredMessage{
+		Chat:      key.Chat,
+		Sender:    msg.Sender,
+		ID:        key.ID,
+		IsFromMe:  msg.IsFromMe,
+		Timestamp: msg.Timestamp,
+		EditedAt:  msg.EditedAt,
+		RevokedAt: msg.RevokedAt,
+	}
+	if msg.Message != nil {
+		out.Message = &waE2E.Message{}
+		err := proto.Unmarshal(msg.Message, out.Message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message %s: %w", key.ID, err)
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) PutMessages(ctx context.Context, messages []*store.StoredMessage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, msg := range messages {
+		key := messageKey{Chat: msg.Chat.ToNonAD(), ID: msg.ID}
+		if _, exists := s.data.Messages[key]; exists {
+			continue
+		}
+		content, err := proto.Marshal(msg.Message)
+		if err != nil {
+			return fmt.Errorf("failed to marshal message %s: %w", msg.ID, err)
+		}
+		s.data.Messages[key] = &storedMessage{
+			Sender:    msg.Sender.ToNonAD(),
+			IsFromMe:  msg.IsFromMe,
+			Timestamp: msg.Timestamp,
+			Message:   content,
+			EditedAt:  msg.EditedAt,
+			RevokedAt: msg.RevokedAt,
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutMessageEdit(ctx context.Context, chat types.JID, id types.MessageID, newContent *waE2E.Message, editedAt time.Time) error {
+	content, err := proto.Marshal(newContent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal edited message: %w", err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if msg, ok := s.data.Messages[messageKey{Chat: chat.ToNonAD(), ID: id}]; ok && msg.RevokedAt.IsZero() {
+		msg.Message = content
+		msg.EditedAt = editedAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutMessageRevoke(ctx context.Context, chat types.JID, id types.MessageID, revokedAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if msg, ok := s.data.Messages[messageKey{Chat: chat.ToNonAD(), ID: id}]; ok {
+		msg.Message = nil
+		msg.RevokedAt = revokedAt
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutReaction(ctx context.Context, chat types.JID, id types.MessageID, reaction store.MessageReaction) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := messageKey{Chat: chat.ToNonAD(), ID: id}
+	sender := reaction.Sender.ToNonAD()
+	if reaction.Reaction == "" {
+		delete(s.data.Reactions[key], sender)
+		return nil
+	}
+	reactions := s.data.Reactions[key]
+	if reactions == nil {
+		reactions = make(map[types.JID]store.MessageReaction)
+		s.data.Reactions[key] = reactions
+	}
+	reaction.Sender = sender
+	reactions[sender] = reaction
+	return nil
+}
+
+func (s *MemoryStore) GetMessage(ctx context.Context, chat types.JID, id types.MessageID) (*store.StoredMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := messageKey{Chat: chat.ToNonAD(), ID: id}
+	msg, ok := s.data.Messages[key]
+	if !ok {
+		return nil, nil
+	}
+	return msg.toStore(key)
+}
+
+func (s *MemoryStore) GetMessages(ctx context.Context, query store.MessageQuery) ([]*store.StoredMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	chat := query.Chat.ToNonAD()
+	var messages []*store.StoredMessage
+	for key, msg := range s.data.Messages {
+		if (!chat.IsEmpty() && key.Chat != chat) ||
+			(!query.After.IsZero() && !msg.Timestamp.After(query.After)) ||
+			(!query.Before.IsZero() && !msg.Timestamp.Before(query.Before)) {
+			continue
+		}
+		converted, err := msg.toStore(key)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, converted)
+	}
+	slices.SortFunc(messages, func(a, b *store.StoredMessage) int {
+		diff := a.Timestamp.Compare(b.Timestamp)
+		if diff == 0 {
+			diff = strings.Compare(a.ID, b.ID)
+		}
+		if query.Descending {
+			return -diff
+		}
+		return diff
+	})
+	if query.Limit > 0 && len(messages) > query.Limit {
+		messages = messages[:query.Limit]
+	}
+	return messages, nil
+}
+
+func (s *MemoryStore) GetReactions(ctx context.Context, chat types.JID, id types.MessageID) ([]store.MessageReaction, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	reactionMap := s.data.Reactions[messageKey{Chat: chat.ToNonAD(), ID: id}]
+	reactions := make([]store.MessageReaction, 0, len(reactionMap))
+	for _, reaction := range reactionMap {
+		reactions = append(reactions, reaction)
+	}
+	slices.SortFunc(reactions, func(a, b store.MessageReaction) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return reactions, nil
+}
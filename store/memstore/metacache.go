@@ -0,0 +1,95 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"context"
+	"slices"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func (s *MemoryStore) PutCachedGroup(_ context.Context, group *store.CachedGroup) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stored := *group
+	stored.Members = slices.Clone(group.Members)
+	s.data.GroupCache[group.JID] = stored
+	return nil
+}
+
+func (s *MemoryStore) GetCachedGroup(_ context.Context, group types.JID) (*store.CachedGroup, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stored, ok := s.data.GroupCache[group]
+	if !ok {
+		return nil, nil
+	}
+	stored.Members = slices.Clone(stored.Members)
+	return &stored, nil
+}
+
+func (s *MemoryStore) DeleteCachedGroup(_ context.Context, group types.JID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data.GroupCache, group)
+	return nil
+}
+
+func (s *MemoryStore) PutCachedDevices(_ context.Context, devices *store.CachedDevices) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stored := *devices
+	stored.Devices = slices.Clone(devices.Devices)
+	s.data.DeviceCache[devices.User] = stored
+	return nil
+}
+
+func (s *MemoryStore) PutManyCachedDevices(_ context.Context, devices []*store.CachedDevices) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, cached := range devices {
+		stored := *cached
+		stored.Devices = slices.Clone(cached.Devices)
+		s.data.DeviceCache[cached.User] = stored
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetCachedDevices(_ context.Context, user types.JID) (*store.CachedDevices, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stored, ok := s.data.DeviceCache[user]
+	if !ok {
+		return nil, nil
+	}
+	stored.Devices = slices.Clone(stored.Devices)
+	return &stored, nil
+}
+
+func (s *MemoryStore) GetManyCachedDevices(_ context.Context, users []types.JID) (map[types.JID]*store.CachedDevices, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make(map[types.JID]*store.CachedDevices, len(users))
+	for _, user := range users {
+		stored, ok := s.data.DeviceCache[user]
+		if !ok {
+			continue
+		}
+		stored.Devices = slices.Clone(stored.Devices)
+		result[user] = &stored
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) DeleteCachedDevices(_ context.Context, user types.JID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data.DeviceCache, user)
+	return nil
+}
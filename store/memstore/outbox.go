@@ -0,0 +1,87 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// storedOutboxMessage is a store.OutboxMessage with the content in the protobuf wire format,
+// so that it can be snapshotted with gob.
+type storedOutboxMessage struct {
+	Message  []byte
+	QueuedAt time.Time
+	Attempts int
+}
+
+func (s *MemoryStore) PutOutboxMessage(_ context.Context, msg *store.OutboxMessage) (bool, error) {
+	content, err := proto.Marshal(msg.Message)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal message %s: %w", msg.ID, err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key := messageKey{Chat: msg.Chat.ToNonAD(), ID: msg.ID}
+	if _, exists := s.data.Outbox[key]; exists {
+		return false, nil
+	}
+	s.data.Outbox[key] = &storedOutboxMessage{
+		Message:  content,
+		QueuedAt: msg.QueuedAt,
+		Attempts: msg.Attempts,
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) PutOutboxAttempts(_ context.Context, chat types.JID, id types.MessageID, attempts int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if msg, ok := s.data.Outbox[messageKey{Chat: chat.ToNonAD(), ID: id}]; ok {
+		msg.Attempts = attempts
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteOutboxMessage(_ context.Context, chat types.JID, id types.MessageID) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.data.Outbox, messageKey{Chat: chat.ToNonAD(), ID: id})
+	return nil
+}
+
+func (s *MemoryStore) GetOutboxMessages(_ context.Context) ([]*store.OutboxMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	messages := make([]*store.OutboxMessage, 0, len(s.data.Outbox))
+	for key, msg := range s.data.Outbox {
+		parsed := &waE2E.Message{}
+		err := proto.Unmarshal(msg.Message, parsed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message %s: %w", key.ID, err)
+		}
+		messages = append(messages, &store.OutboxMessage{
+			Chat:     key.Chat,
+			ID:       key.ID,
+			Message:  parsed,
+			QueuedAt: msg.QueuedAt,
+			Attempts: msg.Attempts,
+		})
+	}
+	slices.SortFunc(messages, func(a, b *store.OutboxMessage) int {
+		return a.QueuedAt.Compare(b.QueuedAt)
+	})
+	return messages, nil
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// SnapshotVersion is the current version of the snapshot format.
+const SnapshotVersion = 1
+
+// ErrUnsupportedSnapshotVersion is returned by ReadSnapshot if the snapshot was written by a newer version.
+var ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+
+// ErrNoSnapshotPath is returned by Save if the container wasn't created with Open.
+var ErrNoSnapshotPath = errors.New("container doesn't have a snapshot path")
+
+type snapshot struct {
+	Version int
+	Devices []*deviceSnapshot
+	PNToLID map[string]string
+}
+
+type deviceSnapshot struct {
+	NoiseKey       *keys.KeyPair
+	IdentityKey    *keys.KeyPair
+	SignedPreKey   *keys.PreKey
+	RegistrationID uint32
+	AdvSecretKey   []byte
+
+	ID  types.JID
+	LID types.JID
+
+	Account      []byte
+	Platform     string
+	BusinessName string
+	PushName     string
+
+	LIDMigrationTimestamp int64
+	ServerStaticKey       []byte
+
+	FacebookUUID uuid.UUID
+
+	// Data is the gob-encoded storeData of the device
+	Data []byte
+}
+
+// Open creates a container that is backed by a snapshot file.
+//
+// If the file exists, the container is restored from it. The file is rewritten automatically whenever a device
+// is added or removed, but other data (sessions, prekeys, etc.) is only written when Save is called,
+// so Save should be called periodically and before shutting down.
+func Open(path string, log waLog.Logger) (*Container, error) {
+	c := New(log)
+	c.path = path
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+	err = c.ReadSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return c, nil
+}
+
+// Save writes a snapshot of the container to the file it was opened from.
+func (c *Container) Save() error {
+	if c.path == "" {
+		return ErrNoSnapshotPath
+	}
+	return c.SaveFile(c.path)
+}
+
+func (c *Container) autoSave() error {
+	if c.path == "" {
+		return nil
+	}
+	return c.Save()
+}
+
+// SaveFile writes a snapshot of the container to the given file.
+//
+// The snapshot is first written to a temporary file in the same directory,
+// which is then renamed over the target, so a crash in the middle won't corrupt an existing snapshot.
+func (c *Container) SaveFile(path string) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+	}()
+	if err = c.WriteSnapshot(tempFile); err != nil {
+		return err
+	} else if err = tempFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	} else if err = tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	} else if err = os.Rename(tempFile.Name(), path); err != nil {
+		return fmt.Errorf("failed to move snapshot into place: %w", err)
+	}
+	return nil
+}
+
+// WriteSnapshot writes all devices and their data in the container to the given writer.
+func (c *Container) WriteSnapshot(w io.Writer) error {
+	c.lock.Lock()
+	devices := make([]*store.Device, 0, len(c.devices))
+	for _, device := range c.devices {
+		devices = append(devices, device)
+	}
+	c.lock.Unlock()
+
+	snap := &snapshot{
+		Version: SnapshotVersion,
+		Devices: make([]*deviceSnapshot, 0, len(devices)),
+	}
+	for _, device := range devices {
+		devSnap, err := snapshotDevice(device)
+		if err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", device.ID, err)
+		}
+		snap.Devices = append(snap.Devices, devSnap)
+	}
+	c.lids.lock.RLock()
+	snap.PNToLID = c.lids.pnToLID
+	err := gob.NewEncoder(w).Encode(snap)
+	c.lids.lock.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return nil
+}
+
+func snapshotDevice(device *store.Device) (*deviceSnapshot, error) {
+	memStore, ok := device.Sessions.(*MemoryStore)
+	if !ok {
+		return nil, fmt.Errorf("unexpected session store type %T", device.Sessions)
+	}
+	var account []byte
+	if device.Account != nil {
+		var err error
+		account, err = proto.Marshal(device.Account)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal account: %w", err)
+		}
+	}
+	var data bytes.Buffer
+	memStore.lock.Lock()
+	err := gob.NewEncoder(&data).Encode(&memStore.data)
+	memStore.lock.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode store data: %w", err)
+	}
+	return &deviceSnapshot{
+		NoiseKey:       device.NoiseKey,
+		IdentityKey:    device.IdentityKey,
+		SignedPreKey:   device.SignedPreKey,
+		RegistrationID: device.RegistrationID,
+		AdvSecretKey:   device.AdvSecretKey,
+
+		ID:  *device.ID,
+		LID: device.LID,
+
+		Account:      account,
+		Platform:     device.Platform,
+		BusinessName: device.BusinessName,
+		PushName:     device.PushName,
+
+		LIDMigrationTimestamp: device.LIDMigrationTimestamp,
+		ServerStaticKey:       device.ServerStaticKey,
+
+		FacebookUUID: device.FacebookUUID,
+
+		Data: data.Bytes(),
+	}, nil
+}
+
+// ReadSnapshot replaces the contents of the container with a snapshot written by WriteSnapshot.
+//
+// Device instances that were previously returned by the container are not updated,
+// so this should generally only be called before creating any clients.
+func (c *Container) ReadSnapshot(r io.Reader) error {
+	var snap snapshot
+	err := gob.NewDecoder(r).Decode(&snap)
+	if err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	} else if snap.Version > SnapshotVersion {
+		return fmt.Errorf("%w %d", ErrUnsupportedSnapshotVersion, snap.Version)
+	}
+	lids := NewLIDMap()
+	for pn, lid := range snap.PNToLID {
+		lids.pnToLID[pn] = lid
+		lids.lidToPN[lid] = pn
+	}
+	devices := make(map[types.JID]*store.Device, len(snap.Devices))
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lids = lids
+	for _, devSnap := range snap.Devices {
+		device, err := c.restoreDevice(devSnap)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", devSnap.ID, err)
+		}
+		devices[*device.ID] = device
+	}
+	c.devices = devices
+	return nil
+}
+
+func (c *Container) restoreDevice(devSnap *deviceSnapshot) (*store.Device, error) {
+	memStore := NewMemoryStore()
+	err := gob.NewDecoder(bytes.NewReader(devSnap.Data)).Decode(&memStore.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode store data: %w", err)
+	}
+	// gob doesn't encode empty maps, so make sure nested maps are initialized
+	for _, state := range memStore.data.AppState {
+		if state.MACs == nil {
+			state.MACs = make(map[string][]byte)
+		}
+	}
+	var account *waAdv.ADVSignedDeviceIdentity
+	if devSnap.Account != nil {
+		account = &waAdv.ADVSignedDeviceIdentity{}
+		err = proto.Unmarshal(devSnap.Account, account)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account: %w", err)
+		}
+	}
+	jid := devSnap.ID
+	device := &store.Device{
+		Log: c.log,
+
+		NoiseKey:       devSnap.NoiseKey,
+		IdentityKey:    devSnap.IdentityKey,
+		SignedPreKey:   devSnap.SignedPreKey,
+		RegistrationID: devSnap.RegistrationID,
+		AdvSecretKey:   devSnap.AdvSecretKey,
+
+		ID:  &jid,
+		LID: devSnap.LID,
+
+		Account:      account,
+		Platform:     devSnap.Platform,
+		BusinessName: devSnap.BusinessName,
+		PushName:     devSnap.PushName,
+
+		LIDMigrationTimestamp: devSnap.LIDMigrationTimestamp,
+		ServerStaticKey:       devSnap.ServerStaticKey,
+
+		FacebookUUID: devSnap.FacebookUUID,
+	}
+	c.initializeDevice(device, memStore)
+	return device, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/memstore"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestSnapshotRoundtrip(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "whatsmeow.snapshot")
+	container, err := memstore.Open(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to open container: %v", err)
+	}
+	jid := types.JID{User: "10000000001", Device: 5, Server: types.DefaultUserServer}
+	lid := types.JID{User: "100000000000001", Device: 5, Server: types.HiddenUserServer}
+	device := container.NewDevice()
+	device.ID = &jid
+	device.PushName = "Alice"
+	device.ServerStaticKey = bytes.Repeat([]byte{7}, 32)
+	if err = device.Save(ctx); err != nil {
+		t.Fatalf("Failed to save device: %v", err)
+	}
+	_ = device.Sessions.PutSession(ctx, "10000000002.0:1", []byte("session"))
+	_ = device.AppState.PutAppStateVersion(ctx, "regular", 3, [128]byte{1})
+	_ = device.LIDs.PutLIDMapping(ctx, lid.ToNonAD(), jid.ToNonAD())
+	preKeys, _ := device.PreKeys.GetOrGenPreKeys(ctx, 5)
+	_ = device.PreKeys.MarkPreKeysAsUploaded(ctx, preKeys[len(preKeys)-1].KeyID)
+	if err = container.Save(); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored, err := memstore.Open(path, nil)
+	if err != nil {
+		t.Fatalf("Failed to restore container: %v", err)
+	}
+	restoredDevice, _ := restored.GetDevice(ctx, jid)
+	if restoredDevice == nil {
+		t.Fatalf("Device wasn't restored")
+	} else if restoredDevice.PushName != "Alice" {
+		t.Errorf("Unexpected push name %q", restoredDevice.PushName)
+	} else if *restoredDevice.IdentityKey.Priv != *device.IdentityKey.Priv {
+		t.Errorf("Identity key wasn't restored")
+	} else if !bytes.Equal(restoredDevice.ServerStaticKey, device.ServerStaticKey) {
+		t.Errorf("Server static key wasn't restored")
+	}
+	if sess, _ := restoredDevice.Sessions.GetSession(ctx, "10000000002.0:1"); !bytes.Equal(sess, []byte("session")) {
+		t.Errorf("Unexpected session %q", sess)
+	}
+	if count, _ := restoredDevice.PreKeys.UploadedPreKeyCount(ctx); count != 5 {
+		t.Errorf("Unexpected uploaded prekey count %d", count)
+	}
+	if version, _, _ := restoredDevice.AppState.GetAppStateVersion(ctx, "regular"); version != 3 {
+		t.Errorf("Unexpected app state version %d", version)
+	}
+	// The app state entry had no MACs, make sure writing them after restoring works
+	err = restoredDevice.AppState.PutAppStateMutationMACs(ctx, "regular", 4, []store.AppStateMutationMAC{{IndexMAC: []byte{1}, ValueMAC: []byte{2}}})
+	if err != nil {
+		t.Errorf("Failed to put app state MACs: %v", err)
+	}
+	if restoredLID, _ := restoredDevice.LIDs.GetLIDForPN(ctx, jid); restoredLID != lid {
+		t.Errorf("Unexpected LID %s", restoredLID)
+	}
+}
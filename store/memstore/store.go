@@ -0,0 +1,711 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+)
+
+type storedPreKey struct {
+	Key      *keys.PreKey
+	Uploaded bool
+}
+
+type senderKeyID struct {
+	Group string
+	User  string
+}
+
+type msgSecretKey struct {
+	Chat   types.JID
+	Sender types.JID
+	ID     types.MessageID
+}
+
+type appStateData struct {
+	Version uint64
+	Hash    [128]byte
+	MACs    map[string][]byte
+}
+
+// storeData contains all the data of a MemoryStore. It's kept in a separate struct so it can be snapshotted easily.
+type storeData struct {
+	Identities    map[string][32]byte
+	Verified      map[types.JID]store.VerifiedIdentity
+	Sessions      map[string][]byte
+	PreKeys       map[uint32]*storedPreKey
+	NextPreKeyID  uint32
+	SignedPreKeys map[uint32]*store.SignedPreKeyEntry
+	SenderKeys    map[senderKeyID][]byte
+	AppStateKeys  map[string]store.AppStateSyncKey
+	AppState      map[string]*appStateData
+	Contacts      map[types.JID]types.ContactInfo
+	ChatSettings  map[types.JID]types.LocalChatSettings
+	MsgSecrets    map[msgSecretKey][]byte
+	PrivacyTokens map[types.JID]store.PrivacyToken
+	EventBuffer   map[[32]byte]*store.BufferedEvent
+	Messages      map[messageKey]*storedMessage
+	Reactions     map[messageKey]map[types.JID]store.MessageReaction
+	Outbox        map[messageKey]*storedOutboxMessage
+	GroupCache    map[types.JID]store.CachedGroup
+	DeviceCache   map[types.JID]store.CachedDevices
+	GroupInfo     map[types.JID][]byte // JSON-encoded group snapshots
+}
+
+// MemoryStore is an in-memory implementation of all the session-specific stores for a single device.
+type MemoryStore struct {
+	lock sync.Mutex
+	data storeData
+
+	// The session lease isn't part of storeData, as it shouldn't be included in snapshots.
+	leaseOwner  string
+	leaseExpiry time.Time
+}
+
+var _ store.AllSessionSpecificStores = (*MemoryStore)(nil)
+var _ store.SignedPreKeyStore = (*MemoryStore)(nil)
+var _ store.VerifiedIdentityStore = (*MemoryStore)(nil)
+var _ store.MessageStore = (*MemoryStore)(nil)
+var _ store.SessionLeaseStore = (*MemoryStore)(nil)
+var _ store.OutboxStore = (*MemoryStore)(nil)
+var _ store.MetadataCacheStore = (*MemoryStore)(nil)
+var _ store.GroupSnapshotStore = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a new empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newStoreData()}
+}
+
+func newStoreData() storeData {
+	return storeData{
+		Identities:    make(map[string][32]byte),
+		Verified:      make(map[types.JID]store.VerifiedIdentity),
+		Sessions:      make(map[string][]byte),
+		PreKeys:       make(map[uint32]*storedPreKey),
+		NextPreKeyID:  1,
+		SignedPreKeys: make(map[uint32]*store.SignedPreKeyEntry),
+		SenderKeys:    make(map[senderKeyID][]byte),
+		AppStateKeys:  make(map[string]store.AppStateSyncKey),
+		AppState:      make(map[string]*appStateData),
+		Contacts:      make(map[types.JID]types.ContactInfo),
+		ChatSettings:  make(map[types.JID]types.LocalChatSettings),
+		MsgSecrets:    make(map[msgSecretKey][]byte),
+		PrivacyTokens: make(map[types.JID]store.PrivacyToken),
+		EventBuffer:   make(map[[32]byte]*store.BufferedEvent),
+		Messages:      make(map[messageKey]*storedMessage),
+		Reactions:     make(map[messageKey]map[types.JID]store.MessageReaction),
+		Outbox:        make(map[messageKey]*storedOutboxMessage),
+		GroupCache:    make(map[types.JID]store.CachedGroup),
+		DeviceCache:   make(map[types.JID]store.CachedDevices),
+		GroupInfo:     make(map[types.JID][]byte),
+	}
+}
+
+func deleteByPrefix[V any](m map[string]V, prefix string) {
+	for key := range m {
+		if strings.HasPrefix(key, prefix) {
+			delete(m, key)
+		}
+	}
+}
+
+func (s *MemoryStore) PutIdentity(ctx context.Context, address string, key [32]byte) error {
+	s.lock.Lock()
+	s.data.Identities[address] = key
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DeleteAllIdentities(ctx context.Context, phone string) error {
+	s.lock.Lock()
+	deleteByPrefix(s.data.Identities, phone+":")
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DeleteIdentity(ctx context.Context, address string) error {
+	s.lock.Lock()
+	delete(s.data.Identities, address)
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) IsTrustedIdentity(ctx context.Context, address string, key [32]byte) (bool, error) {
+	s.lock.Lock()
+	existing, ok := s.data.Identities[address]
+	s.lock.Unlock()
+	// Trust if not known, it'll be saved automatically later
+	return !ok || existing == key, nil
+}
+
+func (s *MemoryStore) GetIdentity(ctx context.Context, address string) (*[32]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	existing, ok := s.data.Identities[address]
+	if !ok {
+		return nil, nil
+	}
+	return &existing, nil
+}
+
+func (s *MemoryStore) PutVerifiedIdentity(ctx context.Context, verified store.VerifiedIdentity) error {
+	s.lock.Lock()
+	s.data.Verified[verified.User.ToNonAD()] = verified
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetVerifiedIdentity(ctx context.Context, user types.JID) (*store.VerifiedIdentity, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	verified, ok := s.data.Verified[user.ToNonAD()]
+	if !ok {
+		return nil, nil
+	}
+	return &verified, nil
+}
+
+func (s *MemoryStore) DeleteVerifiedIdentity(ctx context.Context, user types.JID) error {
+	s.lock.Lock()
+	delete(s.data.Verified, user.ToNonAD())
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetSession(ctx context.Context, address string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.data.Sessions[address], nil
+}
+
+func (s *MemoryStore) HasSession(ctx context.Context, address string) (bool, error) {
+	s.lock.Lock()
+	_, ok := s.data.Sessions[address]
+	s.lock.Unlock()
+	return ok, nil
+}
+
+func (s *MemoryStore) GetManySessions(ctx context.Context, addresses []string) (map[string][]byte, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make(map[string][]byte, len(addresses))
+	for _, addr := range addresses {
+		result[addr] = s.data.Sessions[addr]
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) PutSession(ctx context.Context, address string, session []byte) error {
+	s.lock.Lock()
+	s.data.Sessions[address] = session
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) PutManySessions(ctx context.Context, sessions map[string][]byte) error {
+	s.lock.Lock()
+	for addr, sess := range sessions {
+		s.data.Sessions[addr] = sess
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DeleteAllSessions(ctx context.Context, phone string) error {
+	s.lock.Lock()
+	deleteByPrefix(s.data.Sessions, phone+":")
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DeleteSession(ctx context.Context, address string) error {
+	s.lock.Lock()
+	delete(s.data.Sessions, address)
+	s.lock.Unlock()
+	return nil
+}
+
+func migrateByPrefix[V any](m map[string]V, pnSignal, lidSignal string) {
+	for key, value := range m {
+		if strings.HasPrefix(key, pnSignal+":") {
+			m[lidSignal+strings.TrimPrefix(key, pnSignal)] = value
+			delete(m, key)
+		}
+	}
+}
+
+func (s *MemoryStore) MigratePNToLID(ctx context.Context, pn, lid types.JID) error {
+	pnSignal := pn.SignalAddressUser()
+	lidSignal := lid.SignalAddressUser()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	migrateByPrefix(s.data.Sessions, pnSignal, lidSignal)
+	migrateByPrefix(s.data.Identities, pnSignal, lidSignal)
+	for key, value := range s.data.SenderKeys {
+		if strings.HasPrefix(key.User, pnSignal+":") {
+			s.data.SenderKeys[senderKeyID{Group: key.Group, User: lidSignal + strings.TrimPrefix(key.User, pnSignal)}] = value
+			delete(s.data.SenderKeys, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) genOnePreKey(uploaded bool) *keys.PreKey {
+	key := keys.NewPreKey(s.data.NextPreKeyID)
+	s.data.NextPreKeyID++
+	s.data.PreKeys[key.KeyID] = &storedPreKey{Key: key, Uploaded: uploaded}
+	return key
+}
+
+func (s *MemoryStore) GetOrGenPreKeys(ctx context.Context, count uint32) ([]*keys.PreKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	newKeys := make([]*keys.PreKey, 0, count)
+	for _, key := range s.data.PreKeys {
+		if !key.Uploaded {
+			newKeys = append(newKeys, key.Key)
+		}
+	}
+	slices.SortFunc(newKeys, func(a, b *keys.PreKey) int {
+		return int(a.KeyID) - int(b.KeyID)
+	})
+	if uint32(len(newKeys)) > count {
+		newKeys = newKeys[:count]
+	}
+	for uint32(len(newKeys)) < count {
+		newKeys = append(newKeys, s.genOnePreKey(false))
+	}
+	return newKeys, nil
+}
+
+func (s *MemoryStore) GenOnePreKey(ctx context.Context) (*keys.PreKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.genOnePreKey(true), nil
+}
+
+func (s *MemoryStore) GetPreKey(ctx context.Context, id uint32) (*keys.PreKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key, ok := s.data.PreKeys[id]
+	if !ok {
+		return nil, nil
+	}
+	return key.Key, nil
+}
+
+func (s *MemoryStore) RemovePreKey(ctx context.Context, id uint32) error {
+	s.lock.Lock()
+	delete(s.data.PreKeys, id)
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) MarkPreKeysAsUploaded(ctx context.Context, upToID uint32) error {
+	s.lock.Lock()
+	for id, key := range s.data.PreKeys {
+		if id <= upToID {
+			key.Uploaded = true
+		}
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) UploadedPreKeyCount(ctx context.Context) (count int, err error) {
+	s.lock.Lock()
+	for _, key := range s.data.PreKeys {
+		if key.Uploaded {
+			count++
+		}
+	}
+	s.lock.Unlock()
+	return
+}
+
+func (s *MemoryStore) PutSignedPreKey(ctx context.Context, key *keys.PreKey, createdAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.data.SignedPreKeys[key.KeyID]; !ok {
+		s.data.SignedPreKeys[key.KeyID] = &store.SignedPreKeyEntry{Key: key, CreatedAt: createdAt}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetSignedPreKey(ctx context.Context, id uint32) (*store.SignedPreKeyEntry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.data.SignedPreKeys[id]
+	if !ok {
+		return nil, nil
+	}
+	entryCopy := *entry
+	return &entryCopy, nil
+}
+
+func (s *MemoryStore) RetireSignedPreKey(ctx context.Context, id uint32, retiredAt time.Time) error {
+	s.lock.Lock()
+	if entry, ok := s.data.SignedPreKeys[id]; ok && entry.RetiredAt.IsZero() {
+		entry.RetiredAt = retiredAt
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DeleteRetiredSignedPreKeys(ctx context.Context, retiredBefore time.Time) error {
+	s.lock.Lock()
+	for id, entry := range s.data.SignedPreKeys {
+		if !entry.RetiredAt.IsZero() && entry.RetiredAt.Before(retiredBefore) {
+			delete(s.data.SignedPreKeys, id)
+		}
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) PutSenderKey(ctx context.Context, group, user string, session []byte) error {
+	s.lock.Lock()
+	s.data.SenderKeys[senderKeyID{group, user}] = session
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetSenderKey(ctx context.Context, group, user string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.data.SenderKeys[senderKeyID{group, user}], nil
+}
+
+func (s *MemoryStore) PutAppStateSyncKey(ctx context.Context, id []byte, key store.AppStateSyncKey) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if existing, ok := s.data.AppStateKeys[string(id)]; !ok || key.Timestamp > existing.Timestamp {
+		s.data.AppStateKeys[string(id)] = key
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetAppStateSyncKey(ctx context.Context, id []byte) (*store.AppStateSyncKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	key, ok := s.data.AppStateKeys[string(id)]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (s *MemoryStore) GetLatestAppStateSyncKeyID(ctx context.Context) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var latestID []byte
+	var latestTS int64
+	for id, key := range s.data.AppStateKeys {
+		if latestID == nil || key.Timestamp > latestTS {
+			latestID = []byte(id)
+			latestTS = key.Timestamp
+		}
+	}
+	return latestID, nil
+}
+
+func (s *MemoryStore) GetAllAppStateSyncKeys(ctx context.Context) ([]*store.AppStateSyncKey, error) {
+	s.lock.Lock()
+	out := make([]*store.AppStateSyncKey, 0, len(s.data.AppStateKeys))
+	for _, key := range s.data.AppStateKeys {
+		if len(key.Data) > 0 {
+			out = append(out, &key)
+		}
+	}
+	s.lock.Unlock()
+	slices.SortFunc(out, func(a, b *store.AppStateSyncKey) int {
+		return int(b.Timestamp - a.Timestamp)
+	})
+	return out, nil
+}
+
+func (s *MemoryStore) PutAppStateVersion(ctx context.Context, name string, version uint64, hash [128]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, ok := s.data.AppState[name]
+	if !ok {
+		state = &appStateData{MACs: make(map[string][]byte)}
+		s.data.AppState[name] = state
+	}
+	state.Version = version
+	state.Hash = hash
+	return nil
+}
+
+func (s *MemoryStore) GetAppStateVersion(ctx context.Context, name string) (version uint64, hash [128]byte, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state, ok := s.data.AppState[name]; ok {
+		version, hash = state.Version, state.Hash
+	}
+	return
+}
+
+func (s *MemoryStore) DeleteAppStateVersion(ctx context.Context, name string) error {
+	s.lock.Lock()
+	delete(s.data.AppState, name)
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) PutAppStateMutationMACs(ctx context.Context, name string, version uint64, mutations []store.AppStateMutationMAC) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	state, ok := s.data.AppState[name]
+	if !ok {
+		state = &appStateData{MACs: make(map[string][]byte)}
+		s.data.AppState[name] = state
+	}
+	for _, mutation := range mutations {
+		state.MACs[string(mutation.IndexMAC)] = mutation.ValueMAC
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteAppStateMutationMACs(ctx context.Context, name string, indexMACs [][]byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state, ok := s.data.AppState[name]; ok {
+		for _, indexMAC := range indexMACs {
+			delete(state.MACs, string(indexMAC))
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetAppStateMutationMAC(ctx context.Context, name string, indexMAC []byte) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if state, ok := s.data.AppState[name]; ok {
+		return state.MACs[string(indexMAC)], nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) PutPushName(ctx context.Context, user types.JID, pushName string) (bool, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	contact := s.data.Contacts[user]
+	if contact.PushName == pushName {
+		return false, "", nil
+	}
+	previousName := contact.PushName
+	contact.PushName = pushName
+	contact.Found = true
+	s.data.Contacts[user] = contact
+	return true, previousName, nil
+}
+
+func (s *MemoryStore) PutBusinessName(ctx context.Context, user types.JID, businessName string) (bool, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	contact := s.data.Contacts[user]
+	if contact.BusinessName == businessName {
+		return false, "", nil
+	}
+	previousName := contact.BusinessName
+	contact.BusinessName = businessName
+	contact.Found = true
+	s.data.Contacts[user] = contact
+	return true, previousName, nil
+}
+
+func (s *MemoryStore) PutContactName(ctx context.Context, user types.JID, firstName, fullName string) error {
+	s.lock.Lock()
+	contact := s.data.Contacts[user]
+	contact.FirstName = firstName
+	contact.FullName = fullName
+	contact.Found = true
+	s.data.Contacts[user] = contact
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) PutAllContactNames(ctx context.Context, contacts []store.ContactEntry) error {
+	for _, entry := range contacts {
+		_ = s.PutContactName(ctx, entry.JID, entry.FirstName, entry.FullName)
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutManyRedactedPhones(ctx context.Context, entries []store.RedactedPhoneEntry) error {
+	s.lock.Lock()
+	for _, entry := range entries {
+		contact := s.data.Contacts[entry.JID]
+		contact.RedactedPhone = entry.RedactedPhone
+		contact.Found = true
+		s.data.Contacts[entry.JID] = contact
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetContact(ctx context.Context, user types.JID) (types.ContactInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.data.Contacts[user], nil
+}
+
+func (s *MemoryStore) GetAllContacts(ctx context.Context) (map[types.JID]types.ContactInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	output := make(map[types.JID]types.ContactInfo, len(s.data.Contacts))
+	for jid, contact := range s.data.Contacts {
+		if contact.Found {
+			output[jid] = contact
+		}
+	}
+	return output, nil
+}
+
+func (s *MemoryStore) updateChatSettings(chat types.JID, fn func(settings *types.LocalChatSettings)) {
+	s.lock.Lock()
+	settings := s.data.ChatSettings[chat]
+	fn(&settings)
+	settings.Found = true
+	s.data.ChatSettings[chat] = settings
+	s.lock.Unlock()
+}
+
+func (s *MemoryStore) PutMutedUntil(ctx context.Context, chat types.JID, mutedUntil time.Time) error {
+	s.updateChatSettings(chat, func(settings *types.LocalChatSettings) {
+		settings.MutedUntil = mutedUntil
+	})
+	return nil
+}
+
+func (s *MemoryStore) PutPinned(ctx context.Context, chat types.JID, pinned bool) error {
+	s.updateChatSettings(chat, func(settings *types.LocalChatSettings) {
+		settings.Pinned = pinned
+	})
+	return nil
+}
+
+func (s *MemoryStore) PutArchived(ctx context.Context, chat types.JID, archived bool) error {
+	s.updateChatSettings(chat, func(settings *types.LocalChatSettings) {
+		settings.Archived = archived
+	})
+	return nil
+}
+
+func (s *MemoryStore) GetChatSettings(ctx context.Context, chat types.JID) (types.LocalChatSettings, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.data.ChatSettings[chat], nil
+}
+
+func (s *MemoryStore) PutMessageSecrets(ctx context.Context, inserts []store.MessageSecretInsert) error {
+	for _, insert := range inserts {
+		_ = s.PutMessageSecret(ctx, insert.Chat, insert.Sender, insert.ID, insert.Secret)
+	}
+	return nil
+}
+
+func (s *MemoryStore) PutMessageSecret(ctx context.Context, chat, sender types.JID, id types.MessageID, secret []byte) error {
+	key := msgSecretKey{Chat: chat.ToNonAD(), Sender: sender.ToNonAD(), ID: id}
+	s.lock.Lock()
+	if _, exists := s.data.MsgSecrets[key]; !exists {
+		s.data.MsgSecrets[key] = secret
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetMessageSecret(ctx context.Context, chat, sender types.JID, id types.MessageID) ([]byte, types.JID, error) {
+	key := msgSecretKey{Chat: chat.ToNonAD(), Sender: sender.ToNonAD(), ID: id}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	secret, ok := s.data.MsgSecrets[key]
+	if !ok {
+		return nil, types.EmptyJID, nil
+	}
+	return secret, key.Sender, nil
+}
+
+func (s *MemoryStore) PutPrivacyTokens(ctx context.Context, tokens ...store.PrivacyToken) error {
+	s.lock.Lock()
+	for _, token := range tokens {
+		token.User = token.User.ToNonAD()
+		s.data.PrivacyTokens[token.User] = token
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) GetPrivacyToken(ctx context.Context, user types.JID) (*store.PrivacyToken, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	token, ok := s.data.PrivacyTokens[user.ToNonAD()]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (s *MemoryStore) GetBufferedEvent(ctx context.Context, ciphertextHash [32]byte) (*store.BufferedEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	buf, ok := s.data.EventBuffer[ciphertextHash]
+	if !ok {
+		return nil, nil
+	}
+	copied := *buf
+	return &copied, nil
+}
+
+func (s *MemoryStore) PutBufferedEvent(ctx context.Context, ciphertextHash [32]byte, plaintext []byte, serverTimestamp time.Time) error {
+	s.lock.Lock()
+	s.data.EventBuffer[ciphertextHash] = &store.BufferedEvent{
+		Plaintext:  bytes.Clone(plaintext),
+		InsertTime: time.Now(),
+		ServerTime: serverTimestamp,
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DoDecryptionTxn(ctx context.Context, fn func(context.Context) error) error {
+	return fn(ctx)
+}
+
+func (s *MemoryStore) ClearBufferedEventPlaintext(ctx context.Context, ciphertextHash [32]byte) error {
+	s.lock.Lock()
+	if buf, ok := s.data.EventBuffer[ciphertextHash]; ok {
+		buf.Plaintext = nil
+	}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) DeleteOldBufferedHashes(ctx context.Context) error {
+	cutoff := time.Now().Add(-14 * 24 * time.Hour)
+	s.lock.Lock()
+	for hash, buf := range s.data.EventBuffer {
+		if buf.InsertTime.Before(cutoff) {
+			delete(s.data.EventBuffer, hash)
+		}
+	}
+	s.lock.Unlock()
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"time"
 
+	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/util/keys"
 )
@@ -42,6 +43,13 @@ var NoopDevice = &Device{
 }
 
 var _ AllStores = (*NoopStore)(nil)
+var _ SignedPreKeyStore = (*NoopStore)(nil)
+var _ VerifiedIdentityStore = (*NoopStore)(nil)
+var _ MessageStore = (*NoopStore)(nil)
+var _ SessionLeaseStore = (*NoopStore)(nil)
+var _ OutboxStore = (*NoopStore)(nil)
+var _ MetadataCacheStore = (*NoopStore)(nil)
+var _ GroupSnapshotStore = (*NoopStore)(nil)
 var _ DeviceContainer = (*NoopStore)(nil)
 
 func (n *NoopStore) PutIdentity(ctx context.Context, address string, key [32]byte) error {
@@ -60,6 +68,22 @@ func (n *NoopStore) IsTrustedIdentity(ctx context.Context, address string, key [
 	return false, n.Error
 }
 
+func (n *NoopStore) GetIdentity(ctx context.Context, address string) (*[32]byte, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) PutVerifiedIdentity(ctx context.Context, verified VerifiedIdentity) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetVerifiedIdentity(ctx context.Context, user types.JID) (*VerifiedIdentity, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) DeleteVerifiedIdentity(ctx context.Context, user types.JID) error {
+	return n.Error
+}
+
 func (n *NoopStore) GetSession(ctx context.Context, address string) ([]byte, error) {
 	return nil, n.Error
 }
@@ -116,6 +140,22 @@ func (n *NoopStore) UploadedPreKeyCount(ctx context.Context) (int, error) {
 	return 0, n.Error
 }
 
+func (n *NoopStore) PutSignedPreKey(ctx context.Context, key *keys.PreKey, createdAt time.Time) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetSignedPreKey(ctx context.Context, id uint32) (*SignedPreKeyEntry, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) RetireSignedPreKey(ctx context.Context, id uint32, retiredAt time.Time) error {
+	return n.Error
+}
+
+func (n *NoopStore) DeleteRetiredSignedPreKeys(ctx context.Context, retiredBefore time.Time) error {
+	return n.Error
+}
+
 func (n *NoopStore) PutSenderKey(ctx context.Context, group, user string, session []byte) error {
 	return n.Error
 }
@@ -275,3 +315,107 @@ func (n *NoopStore) PutManyLIDMappings(ctx context.Context, mappings []LIDMappin
 func (n *NoopStore) PutLIDMapping(ctx context.Context, lid types.JID, jid types.JID) error {
 	return n.Error
 }
+
+func (n *NoopStore) PutMessages(ctx context.Context, messages []*StoredMessage) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutMessageEdit(ctx context.Context, chat types.JID, id types.MessageID, newContent *waE2E.Message, editedAt time.Time) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutMessageRevoke(ctx context.Context, chat types.JID, id types.MessageID, revokedAt time.Time) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutReaction(ctx context.Context, chat types.JID, id types.MessageID, reaction MessageReaction) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetMessage(ctx context.Context, chat types.JID, id types.MessageID) (*StoredMessage, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) GetMessages(ctx context.Context, query MessageQuery) ([]*StoredMessage, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) GetReactions(ctx context.Context, chat types.JID, id types.MessageID) ([]MessageReaction, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) AcquireSessionLease(ctx context.Context, owner string, expiresAt time.Time) error {
+	return n.Error
+}
+
+func (n *NoopStore) RenewSessionLease(ctx context.Context, owner string, expiresAt time.Time) error {
+	return n.Error
+}
+
+func (n *NoopStore) ReleaseSessionLease(ctx context.Context, owner string) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutOutboxMessage(ctx context.Context, msg *OutboxMessage) (bool, error) {
+	return false, n.Error
+}
+
+func (n *NoopStore) PutOutboxAttempts(ctx context.Context, chat types.JID, id types.MessageID, attempts int) error {
+	return n.Error
+}
+
+func (n *NoopStore) DeleteOutboxMessage(ctx context.Context, chat types.JID, id types.MessageID) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetOutboxMessages(ctx context.Context) ([]*OutboxMessage, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) PutCachedGroup(ctx context.Context, group *CachedGroup) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetCachedGroup(ctx context.Context, group types.JID) (*CachedGroup, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) DeleteCachedGroup(ctx context.Context, group types.JID) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutCachedDevices(ctx context.Context, devices *CachedDevices) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutManyCachedDevices(ctx context.Context, devices []*CachedDevices) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetCachedDevices(ctx context.Context, user types.JID) (*CachedDevices, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) GetManyCachedDevices(ctx context.Context, users []types.JID) (map[types.JID]*CachedDevices, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) DeleteCachedDevices(ctx context.Context, user types.JID) error {
+	return n.Error
+}
+
+func (n *NoopStore) PutGroupSnapshot(ctx context.Context, info *types.GroupInfo) error {
+	return n.Error
+}
+
+func (n *NoopStore) GetGroupSnapshot(ctx context.Context, group types.JID) (*types.GroupInfo, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) GetGroupSnapshotJIDs(ctx context.Context) ([]types.JID, error) {
+	return nil, n.Error
+}
+
+func (n *NoopStore) DeleteGroupSnapshot(ctx context.Context, group types.JID) error {
+	return n.Error
+}
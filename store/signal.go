@@ -137,13 +137,24 @@ func (device *Device) DeleteAllSessions(ctx context.Context) error {
 }
 
 func (device *Device) LoadSignedPreKey(ctx context.Context, signedPreKeyID uint32) (*record.SignedPreKey, error) {
-	if signedPreKeyID == device.SignedPreKey.KeyID {
-		return record.NewSignedPreKey(signedPreKeyID, 0, ecc.NewECKeyPair(
-			ecc.NewDjbECPublicKey(*device.SignedPreKey.Pub),
-			ecc.NewDjbECPrivateKey(*device.SignedPreKey.Priv),
-		), *device.SignedPreKey.Signature, nil), nil
-	}
-	return nil, nil
+	key := device.SignedPreKey
+	if signedPreKeyID != key.KeyID {
+		if device.SignedPreKeys == nil {
+			// Stores without SignedPreKeys only have the current key
+			return nil, nil
+		}
+		entry, err := device.SignedPreKeys.GetSignedPreKey(ctx, signedPreKeyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load signed prekey %d: %w", signedPreKeyID, err)
+		} else if entry == nil {
+			return nil, nil
+		}
+		key = entry.Key
+	}
+	return record.NewSignedPreKey(signedPreKeyID, 0, ecc.NewECKeyPair(
+		ecc.NewDjbECPublicKey(*key.Pub),
+		ecc.NewDjbECPrivateKey(*key.Priv),
+	), *key.Signature, nil), nil
 }
 
 func (device *Device) LoadSignedPreKeys(ctx context.Context) ([]*record.SignedPreKey, error) {
@@ -155,7 +166,16 @@ func (device *Device) StoreSignedPreKey(ctx context.Context, signedPreKeyID uint
 }
 
 func (device *Device) ContainsSignedPreKey(ctx context.Context, signedPreKeyID uint32) (bool, error) {
-	panic("not implemented")
+	if signedPreKeyID == device.SignedPreKey.KeyID {
+		return true, nil
+	} else if device.SignedPreKeys == nil {
+		return false, nil
+	}
+	entry, err := device.SignedPreKeys.GetSignedPreKey(ctx, signedPreKeyID)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if store has signed prekey %d: %w", signedPreKeyID, err)
+	}
+	return entry != nil, nil
 }
 
 func (device *Device) RemoveSignedPreKey(ctx context.Context, signedPreKeyID uint32) error {
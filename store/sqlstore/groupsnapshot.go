@@ -0,0 +1,65 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.mau.fi/util/dbutil"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	putGroupSnapshotQuery = `
+		INSERT INTO whatsmeow_group_snapshots (our_jid, group_jid, info) VALUES ($1, $2, $3)
+		ON CONFLICT (our_jid, group_jid) DO UPDATE SET info=excluded.info
+	`
+	getGroupSnapshotQuery     = `SELECT info FROM whatsmeow_group_snapshots WHERE our_jid=$1 AND group_jid=$2`
+	getGroupSnapshotJIDsQuery = `SELECT group_jid FROM whatsmeow_group_snapshots WHERE our_jid=$1`
+	deleteGroupSnapshotQuery  = `DELETE FROM whatsmeow_group_snapshots WHERE our_jid=$1 AND group_jid=$2`
+)
+
+func (s *SQLStore) PutGroupSnapshot(ctx context.Context, info *types.GroupInfo) error {
+	_, err := s.db.Exec(ctx, putGroupSnapshotQuery, s.JID, info.JID, dbutil.JSON{Data: info})
+	return err
+}
+
+func (s *SQLStore) GetGroupSnapshot(ctx context.Context, group types.JID) (*types.GroupInfo, error) {
+	var info types.GroupInfo
+	err := s.db.QueryRow(ctx, getGroupSnapshotQuery, s.JID, group).Scan(dbutil.JSON{Data: &info})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+func (s *SQLStore) GetGroupSnapshotJIDs(ctx context.Context) ([]types.JID, error) {
+	rows, err := s.db.Query(ctx, getGroupSnapshotJIDsQuery, s.JID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jids []types.JID
+	for rows.Next() {
+		var jid types.JID
+		if err = rows.Scan(&jid); err != nil {
+			return nil, err
+		}
+		jids = append(jids, jid)
+	}
+	return jids, rows.Err()
+}
+
+func (s *SQLStore) DeleteGroupSnapshot(ctx context.Context, group types.JID) error {
+	_, err := s.db.Exec(ctx, deleteGroupSnapshotQuery, s.JID, group)
+	return err
+}
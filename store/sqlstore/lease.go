@@ -0,0 +1,57 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/whatsmeow/store"
+)
+
+const (
+	acquireSessionLeaseQuery = `
+		INSERT INTO whatsmeow_session_lease (our_jid, owner, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (our_jid) DO UPDATE
+			SET owner=excluded.owner, expires_at=excluded.expires_at
+			WHERE whatsmeow_session_lease.owner=excluded.owner OR whatsmeow_session_lease.expires_at<$4
+	`
+	renewSessionLeaseQuery   = `UPDATE whatsmeow_session_lease SET expires_at=$3 WHERE our_jid=$1 AND owner=$2`
+	releaseSessionLeaseQuery = `DELETE FROM whatsmeow_session_lease WHERE our_jid=$1 AND owner=$2`
+)
+
+func (s *SQLStore) AcquireSessionLease(ctx context.Context, owner string, expiresAt time.Time) error {
+	res, err := s.db.Exec(ctx, acquireSessionLeaseQuery, s.JID, owner, expiresAt.UnixMilli(), time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	// If the conflict update was skipped because the lease is held by someone else, no rows are affected.
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return store.ErrSessionLeaseHeld
+	}
+	return nil
+}
+
+func (s *SQLStore) RenewSessionLease(ctx context.Context, owner string, expiresAt time.Time) error {
+	res, err := s.db.Exec(ctx, renewSessionLeaseQuery, s.JID, owner, expiresAt.UnixMilli())
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return store.ErrSessionLeaseHeld
+	}
+	return nil
+}
+
+func (s *SQLStore) ReleaseSessionLease(ctx context.Context, owner string) error {
+	_, err := s.db.Exec(ctx, releaseSessionLeaseQuery, s.JID, owner)
+	return err
+}
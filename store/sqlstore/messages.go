@@ -0,0 +1,206 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	putMessageQuery = `
+		INSERT INTO whatsmeow_messages (our_jid, chat_jid, message_id, sender_jid, from_me, timestamp, message, edited_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (our_jid, chat_jid, message_id) DO NOTHING
+	`
+	putMessageEditQuery = `
+		UPDATE whatsmeow_messages SET message=$4, edited_at=$5
+		WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3 AND revoked_at IS NULL
+	`
+	putMessageRevokeQuery = `
+		UPDATE whatsmeow_messages SET message=NULL, revoked_at=$4
+		WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3
+	`
+	putReactionQuery = `
+		INSERT INTO whatsmeow_message_reactions (our_jid, chat_jid, message_id, sender_jid, reaction, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (our_jid, chat_jid, message_id, sender_jid) DO UPDATE
+			SET reaction=excluded.reaction, timestamp=excluded.timestamp
+	`
+	deleteReactionQuery = `
+		DELETE FROM whatsmeow_message_reactions WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3 AND sender_jid=$4
+	`
+	getMessageBaseQuery = `
+		SELECT chat_jid, message_id, sender_jid, from_me, timestamp, message, edited_at, revoked_at
+		FROM whatsmeow_messages
+	`
+	getMessageQuery   = getMessageBaseQuery + `WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3`
+	getReactionsQuery = `
+		SELECT sender_jid, reaction, timestamp FROM whatsmeow_message_reactions
+		WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3
+		ORDER BY timestamp
+	`
+)
+
+func nullableUnixMilli(ts time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: ts.UnixMilli(), Valid: !ts.IsZero()}
+}
+
+func (s *SQLStore) PutMessages(ctx context.Context, messages []*store.StoredMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return s.db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		for _, msg := range messages {
+			content, err := proto.Marshal(msg.Message)
+			if err != nil {
+				return fmt.Errorf("failed to marshal message %s: %w", msg.ID, err)
+			}
+			if msg.Message == nil {
+				content = nil
+			}
+			_, err = s.db.Exec(ctx, putMessageQuery,
+				s.JID, msg.Chat.ToNonAD(), msg.ID, msg.Sender.ToNonAD(), msg.IsFromMe, msg.Timestamp.UnixMilli(),
+				content, nullableUnixMilli(msg.EditedAt), nullableUnixMilli(msg.RevokedAt),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) PutMessageEdit(ctx context.Context, chat types.JID, id types.MessageID, newContent *waE2E.Message, editedAt time.Time) error {
+	content, err := proto.Marshal(newContent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal edited message: %w", err)
+	}
+	_, err = s.db.Exec(ctx, putMessageEditQuery, s.JID, chat.ToNonAD(), id, content, editedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLStore) PutMessageRevoke(ctx context.Context, chat types.JID, id types.MessageID, revokedAt time.Time) error {
+	_, err := s.db.Exec(ctx, putMessageRevokeQuery, s.JID, chat.ToNonAD(), id, revokedAt.UnixMilli())
+	return err
+}
+
+func (s *SQLStore) PutReaction(ctx context.Context, chat types.JID, id types.MessageID, reaction store.MessageReaction) error {
+	var err error
+	if reaction.Reaction == "" {
+		_, err = s.db.Exec(ctx, deleteReactionQuery, s.JID, chat.ToNonAD(), id, reaction.Sender.ToNonAD())
+	} else {
+		_, err = s.db.Exec(ctx, putReactionQuery, s.JID, chat.ToNonAD(), id, reaction.Sender.ToNonAD(), reaction.Reaction, reaction.Timestamp.UnixMilli())
+	}
+	return err
+}
+
+func scanStoredMessage(row dbutil.Scannable) (*store.StoredMessage, error) {
+	var msg store.StoredMessage
+	var timestamp int64
+	var content []byte
+	var editedAt, revokedAt sql.NullInt64
+	err := row.Scan(&msg.Chat, &msg.ID, &msg.Sender, &msg.IsFromMe, &timestamp, &content, &editedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	msg.Timestamp = time.UnixMilli(timestamp)
+	if editedAt.Valid {
+		msg.EditedAt = time.UnixMilli(editedAt.Int64)
+	}
+	if revokedAt.Valid {
+		msg.RevokedAt = time.UnixMilli(revokedAt.Int64)
+	}
+	if content != nil {
+		msg.Message = &waE2E.Message{}
+		err = proto.Unmarshal(content, msg.Message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message %s: %w", msg.ID, err)
+		}
+	}
+	return &msg, nil
+}
+
+func (s *SQLStore) GetMessage(ctx context.Context, chat types.JID, id types.MessageID) (*store.StoredMessage, error) {
+	return scanStoredMessage(s.db.QueryRow(ctx, getMessageQuery, s.JID, chat.ToNonAD(), id))
+}
+
+func (s *SQLStore) GetMessages(ctx context.Context, query store.MessageQuery) ([]*store.StoredMessage, error) {
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString(getMessageBaseQuery)
+	queryBuilder.WriteString("WHERE our_jid=$1")
+	args := []any{s.JID}
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		_, _ = fmt.Fprintf(&queryBuilder, " AND %s$%d", condition, len(args))
+	}
+	if !query.Chat.IsEmpty() {
+		addCondition("chat_jid=", query.Chat.ToNonAD())
+	}
+	if !query.After.IsZero() {
+		addCondition("timestamp>", query.After.UnixMilli())
+	}
+	if !query.Before.IsZero() {
+		addCondition("timestamp<", query.Before.UnixMilli())
+	}
+	if query.Descending {
+		queryBuilder.WriteString(" ORDER BY timestamp DESC, message_id DESC")
+	} else {
+		queryBuilder.WriteString(" ORDER BY timestamp, message_id")
+	}
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		_, _ = fmt.Fprintf(&queryBuilder, " LIMIT $%d", len(args))
+	}
+	rows, err := s.db.Query(ctx, queryBuilder.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []*store.StoredMessage
+	for rows.Next() {
+		msg, err := scanStoredMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func (s *SQLStore) GetReactions(ctx context.Context, chat types.JID, id types.MessageID) ([]store.MessageReaction, error) {
+	rows, err := s.db.Query(ctx, getReactionsQuery, s.JID, chat.ToNonAD(), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reactions []store.MessageReaction
+	for rows.Next() {
+		var reaction store.MessageReaction
+		var timestamp int64
+		err = rows.Scan(&reaction.Sender, &reaction.Reaction, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+		reaction.Timestamp = time.UnixMilli(timestamp)
+		reactions = append(reactions, reaction)
+	}
+	return reactions, rows.Err()
+}
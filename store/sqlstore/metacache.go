@@ -0,0 +1,162 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mau.fi/util/dbutil"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	putCachedGroupQuery = `
+		INSERT INTO whatsmeow_group_cache (our_jid, group_jid, addressing_mode, community_announcement, members, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (our_jid, group_jid) DO UPDATE
+			SET addressing_mode=excluded.addressing_mode, community_announcement=excluded.community_announcement,
+			    members=excluded.members, updated_at=excluded.updated_at
+	`
+	getCachedGroupQuery = `
+		SELECT addressing_mode, community_announcement, members, updated_at FROM whatsmeow_group_cache
+		WHERE our_jid=$1 AND group_jid=$2
+	`
+	deleteCachedGroupQuery = `DELETE FROM whatsmeow_group_cache WHERE our_jid=$1 AND group_jid=$2`
+	putCachedDevicesQuery  = `
+		INSERT INTO whatsmeow_device_cache (our_jid, user_jid, dhash, devices, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (our_jid, user_jid) DO UPDATE
+			SET dhash=excluded.dhash, devices=excluded.devices, updated_at=excluded.updated_at
+	`
+	getCachedDevicesQuery = `
+		SELECT dhash, devices, updated_at FROM whatsmeow_device_cache
+		WHERE our_jid=$1 AND user_jid=$2
+	`
+	getManyCachedDevicesQueryPostgres = `
+		SELECT user_jid, dhash, devices, updated_at FROM whatsmeow_device_cache
+		WHERE our_jid=$1 AND user_jid = ANY($2)
+	`
+	getManyCachedDevicesQueryGeneric = `
+		SELECT user_jid, dhash, devices, updated_at FROM whatsmeow_device_cache
+		WHERE our_jid=$1 AND user_jid IN (%s)
+	`
+	deleteCachedDevicesQuery = `DELETE FROM whatsmeow_device_cache WHERE our_jid=$1 AND user_jid=$2`
+)
+
+func (s *SQLStore) PutCachedGroup(ctx context.Context, group *store.CachedGroup) error {
+	_, err := s.db.Exec(
+		ctx, putCachedGroupQuery, s.JID, group.JID, string(group.AddressingMode), group.CommunityAnnouncementGroup,
+		dbutil.JSON{Data: group.Members}, group.UpdatedAt.UnixMilli(),
+	)
+	return err
+}
+
+func (s *SQLStore) GetCachedGroup(ctx context.Context, group types.JID) (*store.CachedGroup, error) {
+	cached := store.CachedGroup{JID: group}
+	var addressingMode string
+	var updatedAt int64
+	err := s.db.QueryRow(ctx, getCachedGroupQuery, s.JID, group).
+		Scan(&addressingMode, &cached.CommunityAnnouncementGroup, dbutil.JSON{Data: &cached.Members}, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cached.AddressingMode = types.AddressingMode(addressingMode)
+	cached.UpdatedAt = time.UnixMilli(updatedAt)
+	return &cached, nil
+}
+
+func (s *SQLStore) DeleteCachedGroup(ctx context.Context, group types.JID) error {
+	_, err := s.db.Exec(ctx, deleteCachedGroupQuery, s.JID, group)
+	return err
+}
+
+func (s *SQLStore) PutCachedDevices(ctx context.Context, devices *store.CachedDevices) error {
+	_, err := s.db.Exec(
+		ctx, putCachedDevicesQuery, s.JID, devices.User, devices.DHash,
+		dbutil.JSON{Data: devices.Devices}, devices.UpdatedAt.UnixMilli(),
+	)
+	return err
+}
+
+func (s *SQLStore) PutManyCachedDevices(ctx context.Context, devices []*store.CachedDevices) error {
+	return s.db.DoTxn(ctx, nil, func(ctx context.Context) error {
+		for _, cached := range devices {
+			err := s.PutCachedDevices(ctx, cached)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) GetCachedDevices(ctx context.Context, user types.JID) (*store.CachedDevices, error) {
+	cached := store.CachedDevices{User: user}
+	var updatedAt int64
+	err := s.db.QueryRow(ctx, getCachedDevicesQuery, s.JID, user).
+		Scan(&cached.DHash, dbutil.JSON{Data: &cached.Devices}, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cached.UpdatedAt = time.UnixMilli(updatedAt)
+	return &cached, nil
+}
+
+func (s *SQLStore) GetManyCachedDevices(ctx context.Context, users []types.JID) (map[types.JID]*store.CachedDevices, error) {
+	if len(users) == 0 {
+		return nil, nil
+	}
+	userStrings := make([]string, len(users))
+	for i, user := range users {
+		userStrings[i] = user.String()
+	}
+	var rows dbutil.Rows
+	var err error
+	if s.db.Dialect == dbutil.Postgres && PostgresArrayWrapper != nil {
+		rows, err = s.db.Query(ctx, getManyCachedDevicesQueryPostgres, s.JID, PostgresArrayWrapper(userStrings))
+	} else {
+		args := make([]any, len(userStrings)+1)
+		placeholders := make([]string, len(userStrings))
+		args[0] = s.JID
+		for i, user := range userStrings {
+			args[i+1] = user
+			placeholders[i] = fmt.Sprintf("$%d", i+2)
+		}
+		rows, err = s.db.Query(ctx, fmt.Sprintf(getManyCachedDevicesQueryGeneric, strings.Join(placeholders, ",")), args...)
+	}
+	result := make(map[types.JID]*store.CachedDevices, len(users))
+	err = dbutil.NewRowIterWithError(rows, func(row dbutil.Scannable) (*store.CachedDevices, error) {
+		var cached store.CachedDevices
+		var updatedAt int64
+		err := row.Scan(&cached.User, &cached.DHash, dbutil.JSON{Data: &cached.Devices}, &updatedAt)
+		cached.UpdatedAt = time.UnixMilli(updatedAt)
+		return &cached, err
+	}, err).Iter(func(cached *store.CachedDevices) (bool, error) {
+		result[cached.User] = cached
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *SQLStore) DeleteCachedDevices(ctx context.Context, user types.JID) error {
+	_, err := s.db.Exec(ctx, deleteCachedDevicesQuery, s.JID, user)
+	return err
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	putOutboxMessageQuery = `
+		INSERT INTO whatsmeow_outbox (our_jid, chat_jid, message_id, message, queued_at, attempts)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (our_jid, chat_jid, message_id) DO NOTHING
+	`
+	putOutboxAttemptsQuery   = `UPDATE whatsmeow_outbox SET attempts=$4 WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3`
+	deleteOutboxMessageQuery = `DELETE FROM whatsmeow_outbox WHERE our_jid=$1 AND chat_jid=$2 AND message_id=$3`
+	getOutboxMessagesQuery   = `
+		SELECT chat_jid, message_id, message, queued_at, attempts FROM whatsmeow_outbox
+		WHERE our_jid=$1
+		ORDER BY queued_at, message_id
+	`
+)
+
+func (s *SQLStore) PutOutboxMessage(ctx context.Context, msg *store.OutboxMessage) (bool, error) {
+	content, err := proto.Marshal(msg.Message)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal message %s: %w", msg.ID, err)
+	}
+	res, err := s.db.Exec(ctx, putOutboxMessageQuery, s.JID, msg.Chat.ToNonAD(), msg.ID, content, msg.QueuedAt.UnixMicro(), msg.Attempts)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (s *SQLStore) PutOutboxAttempts(ctx context.Context, chat types.JID, id types.MessageID, attempts int) error {
+	_, err := s.db.Exec(ctx, putOutboxAttemptsQuery, s.JID, chat.ToNonAD(), id, attempts)
+	return err
+}
+
+func (s *SQLStore) DeleteOutboxMessage(ctx context.Context, chat types.JID, id types.MessageID) error {
+	_, err := s.db.Exec(ctx, deleteOutboxMessageQuery, s.JID, chat.ToNonAD(), id)
+	return err
+}
+
+func (s *SQLStore) GetOutboxMessages(ctx context.Context) ([]*store.OutboxMessage, error) {
+	rows, err := s.db.Query(ctx, getOutboxMessagesQuery, s.JID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []*store.OutboxMessage
+	for rows.Next() {
+		var msg store.OutboxMessage
+		var content []byte
+		var queuedAt int64
+		err = rows.Scan(&msg.Chat, &msg.ID, &content, &queuedAt, &msg.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		msg.QueuedAt = time.UnixMicro(queuedAt)
+		msg.Message = &waE2E.Message{}
+		err = proto.Unmarshal(content, msg.Message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal message %s: %w", msg.ID, err)
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, rows.Err()
+}
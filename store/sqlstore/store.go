@@ -68,6 +68,13 @@ func NewSQLStore(c *Container, jid types.JID) *SQLStore {
 }
 
 var _ store.AllSessionSpecificStores = (*SQLStore)(nil)
+var _ store.SignedPreKeyStore = (*SQLStore)(nil)
+var _ store.VerifiedIdentityStore = (*SQLStore)(nil)
+var _ store.MessageStore = (*SQLStore)(nil)
+var _ store.SessionLeaseStore = (*SQLStore)(nil)
+var _ store.OutboxStore = (*SQLStore)(nil)
+var _ store.MetadataCacheStore = (*SQLStore)(nil)
+var _ store.GroupSnapshotStore = (*SQLStore)(nil)
 
 const (
 	putIdentityQuery = `
@@ -77,6 +84,13 @@ const (
 	deleteAllIdentitiesQuery = `DELETE FROM whatsmeow_identity_keys WHERE our_jid=$1 AND their_id LIKE $2`
 	deleteIdentityQuery      = `DELETE FROM whatsmeow_identity_keys WHERE our_jid=$1 AND their_id=$2`
 	getIdentityQuery         = `SELECT identity FROM whatsmeow_identity_keys WHERE our_jid=$1 AND their_id=$2`
+
+	putVerifiedIdentityQuery = `
+		INSERT INTO whatsmeow_verified_identities (our_jid, their_jid, identity, verified_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (our_jid, their_jid) DO UPDATE SET identity=excluded.identity, verified_at=excluded.verified_at
+	`
+	getVerifiedIdentityQuery    = `SELECT identity, verified_at FROM whatsmeow_verified_identities WHERE our_jid=$1 AND their_jid=$2`
+	deleteVerifiedIdentityQuery = `DELETE FROM whatsmeow_verified_identities WHERE our_jid=$1 AND their_jid=$2`
 )
 
 func (s *SQLStore) PutIdentity(ctx context.Context, address string, key [32]byte) error {
@@ -108,6 +122,48 @@ func (s *SQLStore) IsTrustedIdentity(ctx context.Context, address string, key [3
 	return *(*[32]byte)(existingIdentity) == key, nil
 }
 
+func (s *SQLStore) GetIdentity(ctx context.Context, address string) (*[32]byte, error) {
+	var identity []byte
+	err := s.db.QueryRow(ctx, getIdentityQuery, s.JID, address).Scan(&identity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if len(identity) != 32 {
+		return nil, ErrInvalidLength
+	}
+	return (*[32]byte)(identity), nil
+}
+
+func (s *SQLStore) PutVerifiedIdentity(ctx context.Context, verified store.VerifiedIdentity) error {
+	_, err := s.db.Exec(ctx, putVerifiedIdentityQuery, s.JID, verified.User.ToNonAD(), verified.Identity[:], verified.VerifiedAt.Unix())
+	return err
+}
+
+func (s *SQLStore) GetVerifiedIdentity(ctx context.Context, user types.JID) (*store.VerifiedIdentity, error) {
+	user = user.ToNonAD()
+	var identity []byte
+	var verifiedAt int64
+	err := s.db.QueryRow(ctx, getVerifiedIdentityQuery, s.JID, user).Scan(&identity, &verifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if len(identity) != 32 {
+		return nil, ErrInvalidLength
+	}
+	return &store.VerifiedIdentity{
+		User:       user,
+		Identity:   *(*[32]byte)(identity),
+		VerifiedAt: time.Unix(verifiedAt, 0),
+	}, nil
+}
+
+func (s *SQLStore) DeleteVerifiedIdentity(ctx context.Context, user types.JID) error {
+	_, err := s.db.Exec(ctx, deleteVerifiedIdentityQuery, s.JID, user.ToNonAD())
+	return err
+}
+
 const (
 	getSessionQuery             = `SELECT session FROM whatsmeow_sessions WHERE our_jid=$1 AND their_id=$2`
 	hasSessionQuery             = `SELECT true FROM whatsmeow_sessions WHERE our_jid=$1 AND their_id=$2`
@@ -414,6 +470,58 @@ func (s *SQLStore) UploadedPreKeyCount(ctx context.Context) (count int, err erro
 	return
 }
 
+const (
+	putSignedPreKeyQuery = `
+		INSERT INTO whatsmeow_signed_pre_keys (jid, key_id, key, signature, created_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (jid, key_id) DO NOTHING
+	`
+	getSignedPreKeyQuery            = `SELECT key_id, key, signature, created_at, retired_at FROM whatsmeow_signed_pre_keys WHERE jid=$1 AND key_id=$2`
+	retireSignedPreKeyQuery         = `UPDATE whatsmeow_signed_pre_keys SET retired_at=$3 WHERE jid=$1 AND key_id=$2 AND retired_at IS NULL`
+	deleteRetiredSignedPreKeysQuery = `DELETE FROM whatsmeow_signed_pre_keys WHERE jid=$1 AND retired_at<$2`
+)
+
+func (s *SQLStore) PutSignedPreKey(ctx context.Context, key *keys.PreKey, createdAt time.Time) error {
+	_, err := s.db.Exec(ctx, putSignedPreKeyQuery, s.JID, key.KeyID, key.Priv[:], key.Signature[:], createdAt.Unix())
+	return err
+}
+
+func (s *SQLStore) GetSignedPreKey(ctx context.Context, id uint32) (*store.SignedPreKeyEntry, error) {
+	var priv, sig []byte
+	var keyID uint32
+	var createdAt int64
+	var retiredAt sql.NullInt64
+	err := s.db.QueryRow(ctx, getSignedPreKeyQuery, s.JID, id).Scan(&keyID, &priv, &sig, &createdAt, &retiredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if len(priv) != 32 || len(sig) != 64 {
+		return nil, ErrInvalidLength
+	}
+	entry := &store.SignedPreKeyEntry{
+		Key: &keys.PreKey{
+			KeyPair:   *keys.NewKeyPairFromPrivateKey(*(*[32]byte)(priv)),
+			KeyID:     keyID,
+			Signature: (*[64]byte)(sig),
+		},
+		CreatedAt: time.Unix(createdAt, 0),
+	}
+	if retiredAt.Valid {
+		entry.RetiredAt = time.Unix(retiredAt.Int64, 0)
+	}
+	return entry, nil
+}
+
+func (s *SQLStore) RetireSignedPreKey(ctx context.Context, id uint32, retiredAt time.Time) error {
+	_, err := s.db.Exec(ctx, retireSignedPreKeyQuery, s.JID, id, retiredAt.Unix())
+	return err
+}
+
+func (s *SQLStore) DeleteRetiredSignedPreKeys(ctx context.Context, retiredBefore time.Time) error {
+	_, err := s.db.Exec(ctx, deleteRetiredSignedPreKeysQuery, s.JID, retiredBefore.Unix())
+	return err
+}
+
 const (
 	getSenderKeyQuery = `SELECT sender_key FROM whatsmeow_sender_keys WHERE our_jid=$1 AND chat_id=$2 AND sender_id=$3`
 	putSenderKeyQuery = `
@@ -1,4 +1,4 @@
--- v0 -> v11 (compatible with v8+): Latest schema
+-- v0 -> v19 (compatible with v8+): Latest schema
 CREATE TABLE whatsmeow_device (
 	jid TEXT PRIMARY KEY,
 	lid TEXT,
@@ -24,7 +24,9 @@ CREATE TABLE whatsmeow_device (
 	business_name TEXT NOT NULL DEFAULT '',
 	push_name     TEXT NOT NULL DEFAULT '',
 
-	lid_migration_ts BIGINT NOT NULL DEFAULT 0
+	lid_migration_ts BIGINT NOT NULL DEFAULT 0,
+
+	server_static_key bytea CHECK ( length(server_static_key) = 32 )
 );
 
 CREATE TABLE whatsmeow_identity_keys (
@@ -36,6 +38,16 @@ CREATE TABLE whatsmeow_identity_keys (
 	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
 );
 
+CREATE TABLE whatsmeow_verified_identities (
+	our_jid     TEXT,
+	their_jid   TEXT,
+	identity    bytea  NOT NULL CHECK ( length(identity) = 32 ),
+	verified_at BIGINT NOT NULL,
+
+	PRIMARY KEY (our_jid, their_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
 CREATE TABLE whatsmeow_pre_keys (
 	jid      TEXT,
 	key_id   INTEGER          CHECK ( key_id >= 0 AND key_id < 16777216 ),
@@ -46,6 +58,18 @@ CREATE TABLE whatsmeow_pre_keys (
 	FOREIGN KEY (jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
 );
 
+CREATE TABLE whatsmeow_signed_pre_keys (
+	jid        TEXT,
+	key_id     INTEGER          CHECK ( key_id >= 0 AND key_id < 16777216 ),
+	key        bytea   NOT NULL CHECK ( length(key) = 32 ),
+	signature  bytea   NOT NULL CHECK ( length(signature) = 64 ),
+	created_at BIGINT  NOT NULL,
+	retired_at BIGINT,
+
+	PRIMARY KEY (jid, key_id),
+	FOREIGN KEY (jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
 CREATE TABLE whatsmeow_sessions (
 	our_jid  TEXT,
 	their_id TEXT,
@@ -154,3 +178,84 @@ CREATE TABLE whatsmeow_event_buffer (
 	PRIMARY KEY (our_jid, ciphertext_hash),
 	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
 );
+
+CREATE TABLE whatsmeow_messages (
+	our_jid    TEXT,
+	chat_jid   TEXT,
+	message_id TEXT,
+	sender_jid TEXT    NOT NULL,
+	from_me    BOOLEAN NOT NULL,
+	timestamp  BIGINT  NOT NULL,
+	message    bytea,
+	edited_at  BIGINT,
+	revoked_at BIGINT,
+
+	PRIMARY KEY (our_jid, chat_jid, message_id),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX whatsmeow_messages_chat_timestamp_idx ON whatsmeow_messages (our_jid, chat_jid, timestamp);
+
+CREATE TABLE whatsmeow_message_reactions (
+	our_jid    TEXT,
+	chat_jid   TEXT,
+	message_id TEXT,
+	sender_jid TEXT,
+	reaction   TEXT   NOT NULL,
+	timestamp  BIGINT NOT NULL,
+
+	PRIMARY KEY (our_jid, chat_jid, message_id, sender_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE whatsmeow_session_lease (
+	our_jid    TEXT PRIMARY KEY,
+	owner      TEXT   NOT NULL,
+	expires_at BIGINT NOT NULL,
+
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE whatsmeow_outbox (
+	our_jid    TEXT,
+	chat_jid   TEXT,
+	message_id TEXT,
+	message    bytea   NOT NULL,
+	queued_at  BIGINT  NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+
+	PRIMARY KEY (our_jid, chat_jid, message_id),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE whatsmeow_group_cache (
+	our_jid                TEXT,
+	group_jid              TEXT,
+	addressing_mode        TEXT    NOT NULL,
+	community_announcement BOOLEAN NOT NULL,
+	members                jsonb   NOT NULL,
+	updated_at             BIGINT  NOT NULL,
+
+	PRIMARY KEY (our_jid, group_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE whatsmeow_device_cache (
+	our_jid    TEXT,
+	user_jid   TEXT,
+	dhash      TEXT   NOT NULL,
+	devices    jsonb  NOT NULL,
+	updated_at BIGINT NOT NULL,
+
+	PRIMARY KEY (our_jid, user_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE whatsmeow_group_snapshots (
+	our_jid   TEXT,
+	group_jid TEXT,
+	info      jsonb NOT NULL,
+
+	PRIMARY KEY (our_jid, group_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,12 @@
+-- v12 (compatible with v8+): Store old signed prekeys for rotation
+CREATE TABLE whatsmeow_signed_pre_keys (
+	jid        TEXT,
+	key_id     INTEGER          CHECK ( key_id >= 0 AND key_id < 16777216 ),
+	key        bytea   NOT NULL CHECK ( length(key) = 32 ),
+	signature  bytea   NOT NULL CHECK ( length(signature) = 64 ),
+	created_at BIGINT  NOT NULL,
+	retired_at BIGINT,
+
+	PRIMARY KEY (jid, key_id),
+	FOREIGN KEY (jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,29 @@
+-- v14 (compatible with v8+): Add message history store
+CREATE TABLE whatsmeow_messages (
+	our_jid    TEXT,
+	chat_jid   TEXT,
+	message_id TEXT,
+	sender_jid TEXT    NOT NULL,
+	from_me    BOOLEAN NOT NULL,
+	timestamp  BIGINT  NOT NULL,
+	message    bytea,
+	edited_at  BIGINT,
+	revoked_at BIGINT,
+
+	PRIMARY KEY (our_jid, chat_jid, message_id),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX whatsmeow_messages_chat_timestamp_idx ON whatsmeow_messages (our_jid, chat_jid, timestamp);
+
+CREATE TABLE whatsmeow_message_reactions (
+	our_jid    TEXT,
+	chat_jid   TEXT,
+	message_id TEXT,
+	sender_jid TEXT,
+	reaction   TEXT   NOT NULL,
+	timestamp  BIGINT NOT NULL,
+
+	PRIMARY KEY (our_jid, chat_jid, message_id, sender_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
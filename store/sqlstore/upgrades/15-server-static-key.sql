@@ -0,0 +1,2 @@
+-- v15 (compatible with v8+): Add cached server static key to device table
+ALTER TABLE whatsmeow_device ADD COLUMN server_static_key bytea CHECK ( length(server_static_key) = 32 );
@@ -0,0 +1,8 @@
+-- v16 (compatible with v8+): Add session lease table
+CREATE TABLE whatsmeow_session_lease (
+	our_jid    TEXT PRIMARY KEY,
+	owner      TEXT   NOT NULL,
+	expires_at BIGINT NOT NULL,
+
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
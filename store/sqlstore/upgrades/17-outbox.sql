@@ -0,0 +1,12 @@
+-- v17 (compatible with v8+): Add outbox for outgoing messages
+CREATE TABLE whatsmeow_outbox (
+	our_jid    TEXT,
+	chat_jid   TEXT,
+	message_id TEXT,
+	message    bytea   NOT NULL,
+	queued_at  BIGINT  NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+
+	PRIMARY KEY (our_jid, chat_jid, message_id),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,23 @@
+-- v18 (compatible with v8+): Add persistent group and device metadata caches
+CREATE TABLE whatsmeow_group_cache (
+	our_jid                TEXT,
+	group_jid              TEXT,
+	addressing_mode        TEXT    NOT NULL,
+	community_announcement BOOLEAN NOT NULL,
+	members                jsonb   NOT NULL,
+	updated_at             BIGINT  NOT NULL,
+
+	PRIMARY KEY (our_jid, group_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE whatsmeow_device_cache (
+	our_jid    TEXT,
+	user_jid   TEXT,
+	dhash      TEXT   NOT NULL,
+	devices    jsonb  NOT NULL,
+	updated_at BIGINT NOT NULL,
+
+	PRIMARY KEY (our_jid, user_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,9 @@
+-- v19 (compatible with v8+): Add group membership snapshots
+CREATE TABLE whatsmeow_group_snapshots (
+	our_jid   TEXT,
+	group_jid TEXT,
+	info      jsonb NOT NULL,
+
+	PRIMARY KEY (our_jid, group_jid),
+	FOREIGN KEY (our_jid) REFERENCES whatsmeow_device(jid) ON DELETE CASCADE ON UPDATE CASCADE
+);
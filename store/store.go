@@ -9,16 +9,25 @@ package store
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 
 	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/util/keys"
 	waLog "go.mau.fi/whatsmeow/util/log"
 )
 
+// VerifiedIdentity is an identity key that the user has verified by comparing safety numbers with the other party.
+type VerifiedIdentity struct {
+	User       types.JID
+	Identity   [32]byte
+	VerifiedAt time.Time
+}
+
 type IdentityStore interface {
 	PutIdentity(ctx context.Context, address string, key [32]byte) error
 	DeleteAllIdentities(ctx context.Context, phone string) error
@@ -26,6 +35,16 @@ type IdentityStore interface {
 	IsTrustedIdentity(ctx context.Context, address string, key [32]byte) (bool, error)
 }
 
+// VerifiedIdentityStore is an optional extension of IdentityStore that is needed for safety numbers
+// and identity verification. The identity store in Device.Identities is type-asserted to this interface.
+type VerifiedIdentityStore interface {
+	GetIdentity(ctx context.Context, address string) (*[32]byte, error)
+
+	PutVerifiedIdentity(ctx context.Context, verified VerifiedIdentity) error
+	GetVerifiedIdentity(ctx context.Context, user types.JID) (*VerifiedIdentity, error)
+	DeleteVerifiedIdentity(ctx context.Context, user types.JID) error
+}
+
 type SessionStore interface {
 	GetSession(ctx context.Context, address string) ([]byte, error)
 	HasSession(ctx context.Context, address string) (bool, error)
@@ -46,6 +65,27 @@ type PreKeyStore interface {
 	UploadedPreKeyCount(ctx context.Context) (int, error)
 }
 
+// SignedPreKeyEntry is a signed prekey along with its rotation metadata.
+type SignedPreKeyEntry struct {
+	Key       *keys.PreKey
+	CreatedAt time.Time
+	// RetiredAt is the time when the key was replaced by a newer key, or zero if it's still the current key.
+	RetiredAt time.Time
+}
+
+// SignedPreKeyStore stores signed prekeys, so that old keys can still be used for decrypting
+// prekey messages for a while after rotating to a new key.
+//
+// This is optional: store implementations that don't support it can leave Device.SignedPreKeys nil,
+// in which case the signed prekey is never rotated.
+type SignedPreKeyStore interface {
+	// PutSignedPreKey stores the given signed prekey. If a key with the same ID already exists, it's not modified.
+	PutSignedPreKey(ctx context.Context, key *keys.PreKey, createdAt time.Time) error
+	GetSignedPreKey(ctx context.Context, id uint32) (*SignedPreKeyEntry, error)
+	RetireSignedPreKey(ctx context.Context, id uint32, retiredAt time.Time) error
+	DeleteRetiredSignedPreKeys(ctx context.Context, retiredBefore time.Time) error
+}
+
 type SenderKeyStore interface {
 	PutSenderKey(ctx context.Context, group, user string, session []byte) error
 	GetSenderKey(ctx context.Context, group, user string) ([]byte, error)
@@ -160,6 +200,148 @@ type EventBuffer interface {
 	DeleteOldBufferedHashes(ctx context.Context) error
 }
 
+// StoredMessage is a message in the MessageStore.
+type StoredMessage struct {
+	Chat      types.JID
+	Sender    types.JID
+	ID        types.MessageID
+	IsFromMe  bool
+	Timestamp time.Time
+	// Message is the content of the message as it was sent, i.e. including wrappers like ephemeral messages,
+	// but not the device sent wrapper. If the message has been edited, this is the latest content.
+	// Revoked messages have no content.
+	Message *waE2E.Message
+
+	EditedAt  time.Time
+	RevokedAt time.Time
+}
+
+// MessageReaction is a reaction to a message in the MessageStore.
+type MessageReaction struct {
+	Sender    types.JID
+	Reaction  string
+	Timestamp time.Time
+}
+
+// MessageQuery contains the parameters for MessageStore.GetMessages.
+type MessageQuery struct {
+	// Chat limits the query to a single chat. If empty, messages from all chats are returned.
+	Chat types.JID
+	// After and Before are exclusive bounds for the message timestamp. Zero values mean no bound.
+	After  time.Time
+	Before time.Time
+	// Limit is the maximum number of messages to return. Zero means no limit.
+	Limit int
+	// Messages are sorted by timestamp in ascending order by default. If Descending is true, the newest
+	// messages are returned first, which is useful for paginating backwards with Before and Limit.
+	Descending bool
+}
+
+// MessageStore stores sent and received messages (see Client.EnableMessageStore).
+//
+// This is optional: store implementations that don't support it can leave Device.Messages nil.
+type MessageStore interface {
+	PutMessages(ctx context.Context, messages []*StoredMessage) error
+	PutMessageEdit(ctx context.Context, chat types.JID, id types.MessageID, newContent *waE2E.Message, editedAt time.Time) error
+	PutMessageRevoke(ctx context.Context, chat types.JID, id types.MessageID, revokedAt time.Time) error
+	// PutReaction stores a reaction to a message. An empty reaction removes the previous reaction of the sender.
+	PutReaction(ctx context.Context, chat types.JID, id types.MessageID, reaction MessageReaction) error
+	GetMessage(ctx context.Context, chat types.JID, id types.MessageID) (*StoredMessage, error)
+	GetMessages(ctx context.Context, query MessageQuery) ([]*StoredMessage, error)
+	GetReactions(ctx context.Context, chat types.JID, id types.MessageID) ([]MessageReaction, error)
+}
+
+// OutboxMessage is an outgoing message that is stored until the server acknowledges it.
+type OutboxMessage struct {
+	Chat    types.JID
+	ID      types.MessageID
+	Message *waE2E.Message
+	// QueuedAt is when the message was added to the outbox. Messages in the same chat are sent in this order.
+	QueuedAt time.Time
+	// Attempts is the number of failed send attempts so far.
+	Attempts int
+}
+
+// OutboxStore stores messages queued with Client.EnqueueMessage until they're sent.
+//
+// This is optional: store implementations that don't support it can leave Device.Outbox nil.
+type OutboxStore interface {
+	// PutOutboxMessage adds a message to the outbox. If a message with the same chat and ID is already
+	// in the outbox, nothing is changed and false is returned.
+	PutOutboxMessage(ctx context.Context, msg *OutboxMessage) (inserted bool, err error)
+	PutOutboxAttempts(ctx context.Context, chat types.JID, id types.MessageID, attempts int) error
+	DeleteOutboxMessage(ctx context.Context, chat types.JID, id types.MessageID) error
+	// GetOutboxMessages returns all messages in the outbox sorted by QueuedAt.
+	GetOutboxMessages(ctx context.Context) ([]*OutboxMessage, error)
+}
+
+// CachedGroup is the group metadata that is needed for sending messages to a group.
+type CachedGroup struct {
+	JID                        types.JID
+	AddressingMode             types.AddressingMode
+	CommunityAnnouncementGroup bool
+	Members                    []types.JID
+	UpdatedAt                  time.Time
+}
+
+// CachedDevices is the device list of a user along with the device list hash (dhash) sent by the server.
+type CachedDevices struct {
+	User      types.JID
+	Devices   []types.JID
+	DHash     string
+	UpdatedAt time.Time
+}
+
+// MetadataCacheStore persists the group member and user device caches, so that the first message
+// to each chat after a restart doesn't have to fetch them from the server again.
+//
+// This is optional: store implementations that don't support it can leave Device.MetadataCache nil.
+type MetadataCacheStore interface {
+	PutCachedGroup(ctx context.Context, group *CachedGroup) error
+	// GetCachedGroup returns the cached group metadata, or nil if the group isn't cached.
+	GetCachedGroup(ctx context.Context, group types.JID) (*CachedGroup, error)
+	DeleteCachedGroup(ctx context.Context, group types.JID) error
+	PutCachedDevices(ctx context.Context, devices *CachedDevices) error
+	// PutManyCachedDevices stores the device lists of multiple users at once.
+	PutManyCachedDevices(ctx context.Context, devices []*CachedDevices) error
+	// GetCachedDevices returns the cached device list of the user, or nil if the user isn't cached.
+	GetCachedDevices(ctx context.Context, user types.JID) (*CachedDevices, error)
+	// GetManyCachedDevices returns the cached device lists of the given users. Users that aren't cached are omitted.
+	GetManyCachedDevices(ctx context.Context, users []types.JID) (map[types.JID]*CachedDevices, error)
+	DeleteCachedDevices(ctx context.Context, user types.JID) error
+}
+
+// GroupSnapshotStore stores the last known info of groups, which is used to find changes that were missed while offline.
+//
+// This is optional: store implementations that don't support it can leave Device.GroupSnapshots nil.
+type GroupSnapshotStore interface {
+	PutGroupSnapshot(ctx context.Context, info *types.GroupInfo) error
+	// GetGroupSnapshot returns the stored info of the given group, or nil if there's no snapshot of the group.
+	GetGroupSnapshot(ctx context.Context, group types.JID) (*types.GroupInfo, error)
+	GetGroupSnapshotJIDs(ctx context.Context) ([]types.JID, error)
+	DeleteGroupSnapshot(ctx context.Context, group types.JID) error
+}
+
+// ErrSessionLeaseHeld is returned by SessionLeaseStore methods if another owner holds an unexpired lease for the device.
+var ErrSessionLeaseHeld = errors.New("session lease is held by another owner")
+
+// SessionLeaseStore stores leases that prevent multiple processes from connecting the same device at the same time.
+//
+// Leases expire at the given time unless renewed, so a process that crashes doesn't block other processes forever.
+// Expiry times are compared using the local clock of each process, so the clocks of all hosts sharing a store
+// should be reasonably synchronized.
+//
+// This is optional: store implementations that don't support it can leave Device.Leases nil.
+type SessionLeaseStore interface {
+	// AcquireSessionLease takes the lease for the given owner if it's free, expired or already held by the same owner.
+	AcquireSessionLease(ctx context.Context, owner string, expiresAt time.Time) error
+	// RenewSessionLease extends a lease that is held by the given owner.
+	// If the lease was taken by another owner after it expired, ErrSessionLeaseHeld is returned.
+	RenewSessionLease(ctx context.Context, owner string, expiresAt time.Time) error
+	// ReleaseSessionLease releases the lease if it's held by the given owner.
+	ReleaseSessionLease(ctx context.Context, owner string) error
+}
+
 type LIDMapping struct {
 	LID types.JID
 	PN  types.JID
@@ -219,22 +401,32 @@ type Device struct {
 
 	LIDMigrationTimestamp int64
 
+	// ServerStaticKey is the static noise key of the WhatsApp server from the last full handshake.
+	// It's used for the resume handshake (see Client.EnableNoiseResume).
+	ServerStaticKey []byte
+
 	FacebookUUID uuid.UUID
 
-	Initialized   bool
-	Identities    IdentityStore
-	Sessions      SessionStore
-	PreKeys       PreKeyStore
-	SenderKeys    SenderKeyStore
-	AppStateKeys  AppStateSyncKeyStore
-	AppState      AppStateStore
-	Contacts      ContactStore
-	ChatSettings  ChatSettingsStore
-	MsgSecrets    MsgSecretStore
-	PrivacyTokens PrivacyTokenStore
-	EventBuffer   EventBuffer
-	LIDs          LIDStore
-	Container     DeviceContainer
+	Initialized    bool
+	Identities     IdentityStore
+	Sessions       SessionStore
+	PreKeys        PreKeyStore
+	SignedPreKeys  SignedPreKeyStore
+	SenderKeys     SenderKeyStore
+	AppStateKeys   AppStateSyncKeyStore
+	AppState       AppStateStore
+	Contacts       ContactStore
+	ChatSettings   ChatSettingsStore
+	MsgSecrets     MsgSecretStore
+	PrivacyTokens  PrivacyTokenStore
+	EventBuffer    EventBuffer
+	Messages       MessageStore
+	Leases         SessionLeaseStore
+	Outbox         OutboxStore
+	MetadataCache  MetadataCacheStore
+	GroupSnapshots GroupSnapshotStore
+	LIDs           LIDStore
+	Container      DeviceContainer
 }
 
 func (device *Device) GetJID() types.JID {
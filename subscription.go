@@ -0,0 +1,220 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// EventFilter contains filters for events received through a Subscription.
+//
+// The chat, sender and from me filters apply to events that have a message source
+// (e.g. *events.Message, *events.Receipt and *events.ChatPresence). Events without a source
+// are only delivered if none of those filters are set. Empty fields don't filter anything.
+type EventFilter struct {
+	// Chats only allows events in the given chats.
+	Chats []types.JID
+	// Senders only allows events from the given users. Device IDs are ignored when comparing.
+	Senders []types.JID
+	// IsFromMe only allows events sent by (true) or not sent by (false) the current user.
+	IsFromMe *bool
+	// MessageTypes only allows messages whose type (e.g. "text", "reaction") or media type (e.g. "image")
+	// is one of the given values. This only applies to *events.Message and *events.UndecryptableMessage.
+	MessageTypes []string
+}
+
+func eventSource(evt any) (*types.MessageSource, *types.MessageInfo) {
+	switch typedEvt := evt.(type) {
+	case *events.Message:
+		return &typedEvt.Info.MessageSource, &typedEvt.Info
+	case *events.UndecryptableMessage:
+		return &typedEvt.Info.MessageSource, &typedEvt.Info
+	case *events.Receipt:
+		return &typedEvt.MessageSource, nil
+	case *events.ChatPresence:
+		return &typedEvt.MessageSource, nil
+	default:
+		return nil, nil
+	}
+}
+
+func jidListContains(list []types.JID, jid types.JID, alt types.JID) bool {
+	for _, item := range list {
+		if item.ToNonAD() == jid.ToNonAD() || (!alt.IsEmpty() && item.ToNonAD() == alt.ToNonAD()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match checks if the given event passes the filter.
+func (ef *EventFilter) Match(evt any) bool {
+	if ef == nil {
+		return true
+	}
+	source, info := eventSource(evt)
+	if source == nil {
+		return len(ef.Chats) == 0 && len(ef.Senders) == 0 && ef.IsFromMe == nil && len(ef.MessageTypes) == 0
+	}
+	if len(ef.Chats) > 0 && !jidListContains(ef.Chats, source.Chat, source.RecipientAlt) {
+		return false
+	} else if len(ef.Senders) > 0 && !jidListContains(ef.Senders, source.Sender, source.SenderAlt) {
+		return false
+	} else if ef.IsFromMe != nil && *ef.IsFromMe != source.IsFromMe {
+		return false
+	} else if len(ef.MessageTypes) > 0 {
+		if info == nil {
+			return false
+		} else if !slices.Contains(ef.MessageTypes, info.Type) && (info.MediaType == "" || !slices.Contains(ef.MessageTypes, info.MediaType)) {
+			return false
+		}
+	}
+	return true
+}
+
+// SubscribeOptions contains options for Subscribe and SubscribeChan.
+type SubscribeOptions struct {
+	// Filter limits which events are delivered to the subscription.
+	Filter *EventFilter
+	// BufferSize is the size of the channel events are queued in. If set, the handler function
+	// is called from a dedicated goroutine in the order events were emitted, so a slow handler doesn't block
+	// other event handlers (until the buffer is full). SubscribeChan always uses a channel,
+	// and defaults to a size of 32 if this is zero.
+	BufferSize int
+	// Async makes every event be handled in a new goroutine. Ordering is not guaranteed in this mode.
+	// This is ignored if BufferSize is set.
+	Async bool
+}
+
+// Subscription is a single typed event handler registered with Subscribe or SubscribeChan.
+type Subscription struct {
+	cli     *Client
+	id      atomic.Uint32
+	stopped atomic.Bool
+	stop    chan struct{}
+	once    sync.Once
+	onClose func()
+}
+
+// ID returns the event handler ID of the subscription.
+func (sub *Subscription) ID() uint32 {
+	return sub.id.Load()
+}
+
+// Unsubscribe stops the subscription. No new events will be delivered after this returns.
+//
+// Unlike RemoveEventHandler, this can be called directly from inside the subscription's handler.
+// The underlying event handler is removed in the background and channels returned by SubscribeChan
+// are closed after that.
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.stopped.Store(true)
+		close(sub.stop)
+		go func() {
+			sub.cli.RemoveEventHandler(sub.id.Load())
+			if sub.onClose != nil {
+				sub.onClose()
+			}
+		}()
+	})
+}
+
+func newSubscription(cli *Client) *Subscription {
+	return &Subscription{cli: cli, stop: make(chan struct{})}
+}
+
+func (sub *Subscription) register(opts *SubscribeOptions, deliver func(any)) *Subscription {
+	var filter *EventFilter
+	if opts != nil {
+		filter = opts.Filter
+	}
+	cli := sub.cli
+	id := atomic.AddUint32(&nextHandlerID, 1)
+	sub.id.Store(id)
+	cli.eventHandlersLock.Lock()
+	cli.eventHandlers = append(cli.eventHandlers, wrappedEventHandler{func(evt any) bool {
+		if !sub.stopped.Load() && filter.Match(evt) {
+			deliver(evt)
+		}
+		return true
+	}, id})
+	if cli.subscriptions == nil {
+		cli.subscriptions = make(map[uint32]*Subscription)
+	}
+	cli.subscriptions[id] = sub
+	cli.eventHandlersLock.Unlock()
+	return sub
+}
+
+// Subscribe registers a handler that only receives events of type T which match the filter in opts.
+//
+// For example, to only receive text messages in a specific chat:
+//
+//	sub := whatsmeow.Subscribe(cli, func(evt *events.Message) {
+//		fmt.Println("Received", evt.Message.GetConversation())
+//	}, &whatsmeow.SubscribeOptions{
+//		Filter: &whatsmeow.EventFilter{Chats: []types.JID{chatJID}, MessageTypes: []string{"text"}},
+//	})
+//	defer sub.Unsubscribe()
+//
+// Subscriptions are normal event handlers, so they're called in the same order as handlers added with AddEventHandler.
+func Subscribe[T any](cli *Client, handler func(T), opts *SubscribeOptions) *Subscription {
+	if opts != nil && opts.BufferSize > 0 {
+		sub, ch := SubscribeChan[T](cli, opts)
+		go func() {
+			for evt := range ch {
+				if !sub.stopped.Load() {
+					handler(evt)
+				}
+			}
+		}()
+		return sub
+	}
+	async := opts != nil && opts.Async
+	return newSubscription(cli).register(opts, func(rawEvt any) {
+		evt, ok := rawEvt.(T)
+		if !ok {
+			return
+		} else if async {
+			go handler(evt)
+		} else {
+			handler(evt)
+		}
+	})
+}
+
+// SubscribeChan registers a subscription that delivers events of type T matching the filter in opts to a channel.
+//
+// The channel is closed after Unsubscribe is called. If the channel is full, the event dispatcher will block
+// until there's room or the subscription is stopped, so the channel should be read continuously.
+func SubscribeChan[T any](cli *Client, opts *SubscribeOptions) (*Subscription, <-chan T) {
+	bufferSize := 32
+	if opts != nil && opts.BufferSize > 0 {
+		bufferSize = opts.BufferSize
+	}
+	ch := make(chan T, bufferSize)
+	sub := newSubscription(cli)
+	sub.onClose = func() {
+		close(ch)
+	}
+	sub.register(opts, func(rawEvt any) {
+		evt, ok := rawEvt.(T)
+		if !ok {
+			return
+		}
+		select {
+		case ch <- evt:
+		case <-sub.stop:
+		}
+	})
+	return sub, ch
+}
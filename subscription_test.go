@@ -0,0 +1,63 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/testserver"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestSubscribe(t *testing.T) {
+	ctx, srv := newTestServer(t)
+	alice := srv.NewAccount("10000000001")
+	bob := srv.NewAccount("10000000002")
+	carol := srv.NewAccount("10000000003")
+	cli := pairClient(ctx, t, srv, alice)
+
+	sub, messages := whatsmeow.SubscribeChan[*events.Message](cli, &whatsmeow.SubscribeOptions{
+		Filter: &whatsmeow.EventFilter{Chats: []types.JID{bob.PN}, MessageTypes: []string{"text"}},
+	})
+	for _, sender := range []*testserver.Account{carol, bob} {
+		_, err := sender.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: proto.String("hello from " + sender.PN.User)})
+		if err != nil {
+			t.Fatalf("Failed to send message from %s: %v", sender.PN, err)
+		}
+	}
+	select {
+	case evt := <-messages:
+		if evt.Info.Chat != bob.PN || evt.Message.GetConversation() != "hello from "+bob.PN.User {
+			t.Errorf("Unexpected message %q in %s", evt.Message.GetConversation(), evt.Info.Chat)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Subscription didn't receive message: %v", ctx.Err())
+	}
+	sub.Unsubscribe()
+	for range messages {
+		t.Errorf("Unexpected extra message after unsubscribing")
+	}
+
+	_, receipts := whatsmeow.SubscribeChan[*events.Receipt](cli, nil)
+	cli.RemoveEventHandlers()
+	closed := make(chan struct{})
+	go func() {
+		for range receipts {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		t.Fatalf("Subscription channel wasn't closed after removing event handlers: %v", ctx.Err())
+	}
+}
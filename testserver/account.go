@@ -0,0 +1,349 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/libsignal/ecc"
+	"go.mau.fi/libsignal/keys/identity"
+	"go.mau.fi/libsignal/keys/prekey"
+	"go.mau.fi/libsignal/util/optional"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+)
+
+// Account is a simulated WhatsApp account with a primary device (the phone) and any number of companion devices.
+type Account struct {
+	server *Server
+
+	PN       types.JID
+	LID      types.JID
+	PushName string
+
+	// Phone is the simulated primary device of the account.
+	Phone *Device
+
+	devices      map[uint16]*deviceRecord
+	nextDeviceID uint16
+	nextKeyIndex uint32
+}
+
+type deviceRecord struct {
+	account *Account
+	jid     types.JID
+	lid     types.JID
+
+	noiseKey       [32]byte
+	registrationID uint32
+	identityKey    [32]byte
+	signedPreKey   *keys.PreKey
+	preKeys        []*keys.PreKey
+
+	sim     *Device
+	conn    *Conn
+	offline []waBinary.Node
+}
+
+// NewAccount creates a new account with the given phone number and a simulated primary device.
+func (srv *Server) NewAccount(phone string) *Account {
+	acc := &Account{
+		server:       srv,
+		PN:           types.NewJID(phone, types.DefaultUserServer),
+		LID:          types.NewJID(strconv.FormatUint(srv.lidSource.Add(1), 10), types.HiddenUserServer),
+		PushName:     "User " + phone,
+		devices:      make(map[uint16]*deviceRecord),
+		nextDeviceID: 1,
+		nextKeyIndex: 1,
+	}
+	srv.lock.Lock()
+	srv.accounts[acc.PN.User] = acc
+	srv.accountsByLID[acc.LID.User] = acc
+	srv.lock.Unlock()
+	acc.Phone = acc.newSimDevice(0)
+	return acc
+}
+
+// AddDevice adds a new simulated companion device to the account.
+func (acc *Account) AddDevice() *Device {
+	acc.server.lock.Lock()
+	deviceID := acc.nextDeviceID
+	acc.nextDeviceID++
+	acc.server.lock.Unlock()
+	return acc.newSimDevice(deviceID)
+}
+
+// Devices returns the JIDs of all devices (both simulated and real clients) on the account.
+func (acc *Account) Devices() []types.JID {
+	acc.server.lock.Lock()
+	defer acc.server.lock.Unlock()
+	devices := make([]types.JID, 0, len(acc.devices))
+	for _, dev := range acc.deviceList() {
+		devices = append(devices, dev.jid)
+	}
+	return devices
+}
+
+// RemoveDevice logs out a companion device from the account.
+//
+// If the device is connected, it will receive a device_removed stream error.
+func (acc *Account) RemoveDevice(jid types.JID) error {
+	acc.server.lock.Lock()
+	dev, ok := acc.devices[jid.Device]
+	if !ok || jid.Device == 0 {
+		acc.server.lock.Unlock()
+		return fmt.Errorf("%w %s", ErrUnknownDevice, jid)
+	}
+	delete(acc.devices, jid.Device)
+	conn := dev.conn
+	acc.server.lock.Unlock()
+	if conn != nil {
+		conn.sendStreamError("401", "device_removed")
+	}
+	return nil
+}
+
+// Pair scans the given QR code with the account's phone, which links the client that generated the code
+// as a new companion device.
+//
+// The client is expected to reconnect after pairing. This returns after the client has accepted the pairing,
+// use Server.WaitConnected to wait for the new device to log in.
+func (acc *Account) Pair(ctx context.Context, qr string) (types.JID, error) {
+	parts := strings.Split(qr, ",")
+	if len(parts) != 4 {
+		return types.EmptyJID, ErrInvalidQR
+	}
+	noiseKey, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(noiseKey) != 32 {
+		return types.EmptyJID, fmt.Errorf("%w: invalid noise key", ErrInvalidQR)
+	}
+	identityKey, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil || len(identityKey) != 32 {
+		return types.EmptyJID, fmt.Errorf("%w: invalid identity key", ErrInvalidQR)
+	}
+	advSecret, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("%w: invalid adv secret", ErrInvalidQR)
+	}
+	srv := acc.server
+	srv.lock.Lock()
+	conn, ok := srv.pairingRefs[parts[0]]
+	deviceID := acc.nextDeviceID
+	keyIndex := acc.nextKeyIndex
+	if ok {
+		acc.nextDeviceID++
+		acc.nextKeyIndex++
+	}
+	srv.lock.Unlock()
+	if !ok {
+		return types.EmptyJID, ErrUnknownQRRef
+	}
+	reg := conn.ClientPayload.GetDevicePairingData()
+	if conn.NoiseKey != [32]byte(noiseKey) || !slices.Equal(reg.GetEIdent(), identityKey) {
+		return types.EmptyJID, ErrQRKeyMismatch
+	}
+	signedPreKey := &keys.PreKey{
+		KeyPair:   keys.KeyPair{Pub: (*[32]byte)(reg.GetESkeyVal())},
+		KeyID:     uint32(reg.GetESkeyID()[0])<<16 | uint32(reg.GetESkeyID()[1])<<8 | uint32(reg.GetESkeyID()[2]),
+		Signature: (*[64]byte)(reg.GetESkeySig()),
+	}
+	regID := reg.GetERegid()
+	dev := &deviceRecord{
+		account:        acc,
+		jid:            types.JID{User: acc.PN.User, Device: deviceID, Server: types.DefaultUserServer},
+		lid:            types.JID{User: acc.LID.User, Device: deviceID, Server: types.HiddenUserServer},
+		noiseKey:       conn.NoiseKey,
+		registrationID: uint32(regID[0])<<24 | uint32(regID[1])<<16 | uint32(regID[2])<<8 | uint32(regID[3]),
+		identityKey:    [32]byte(identityKey),
+		signedPreKey:   signedPreKey,
+	}
+
+	details, err := proto.Marshal(&waAdv.ADVDeviceIdentity{
+		RawID:     proto.Uint32(uint32(deviceID)),
+		Timestamp: proto.Uint64(uint64(time.Now().Unix())),
+		KeyIndex:  proto.Uint32(keyIndex),
+	})
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("failed to marshal device identity details: %w", err)
+	}
+	accountKey := acc.Phone.Store.IdentityKey
+	accountSignature := ecc.CalculateSignature(
+		ecc.NewDjbECPrivateKey(*accountKey.Priv),
+		slices.Concat(whatsmeow.AdvAccountSignaturePrefix, details, identityKey),
+	)
+	signedIdentity, err := proto.Marshal(&waAdv.ADVSignedDeviceIdentity{
+		Details:             details,
+		AccountSignatureKey: accountKey.Pub[:],
+		AccountSignature:    accountSignature[:],
+	})
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("failed to marshal signed device identity: %w", err)
+	}
+	h := hmac.New(sha256.New, advSecret)
+	h.Write(signedIdentity)
+	container, err := proto.Marshal(&waAdv.ADVSignedDeviceIdentityHMAC{
+		Details: signedIdentity,
+		HMAC:    h.Sum(nil),
+	})
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("failed to marshal device identity container: %w", err)
+	}
+
+	resp, err := conn.SendIQ(ctx, waBinary.Node{
+		Tag: "iq",
+		Attrs: waBinary.Attrs{
+			"type":  "set",
+			"xmlns": "md",
+		},
+		Content: []waBinary.Node{{
+			Tag: "pair-success",
+			Content: []waBinary.Node{
+				{Tag: "device-identity", Content: container},
+				{Tag: "platform", Attrs: waBinary.Attrs{"name": "android"}},
+				{Tag: "device", Attrs: waBinary.Attrs{"jid": dev.jid, "lid": dev.lid}},
+			},
+		}},
+	})
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("failed to send pair-success: %w", err)
+	} else if resp.Attrs["type"] != "result" {
+		return types.EmptyJID, fmt.Errorf("%w: %s", ErrPairingRejected, resp.XMLString())
+	}
+	deviceIdentityNode, ok := resp.GetOptionalChildByTag("pair-device-sign", "device-identity")
+	selfSigned, _ := deviceIdentityNode.Content.([]byte)
+	var selfSignedIdentity waAdv.ADVSignedDeviceIdentity
+	if !ok {
+		return types.EmptyJID, fmt.Errorf("%w: missing device-identity in response", ErrPairingRejected)
+	} else if err = proto.Unmarshal(selfSigned, &selfSignedIdentity); err != nil {
+		return types.EmptyJID, fmt.Errorf("%w: invalid device-identity in response: %w", ErrPairingRejected, err)
+	} else if len(selfSignedIdentity.DeviceSignature) != 64 || !ecc.VerifySignature(
+		ecc.NewDjbECPublicKey(dev.identityKey),
+		slices.Concat(whatsmeow.AdvDeviceSignaturePrefix, details, identityKey, accountKey.Pub[:]),
+		[64]byte(selfSignedIdentity.DeviceSignature),
+	) {
+		return types.EmptyJID, fmt.Errorf("%w: invalid device signature", ErrPairingRejected)
+	}
+
+	srv.lock.Lock()
+	acc.devices[deviceID] = dev
+	for ref, refConn := range srv.pairingRefs {
+		if refConn == conn {
+			delete(srv.pairingRefs, ref)
+		}
+	}
+	srv.lock.Unlock()
+	go conn.sendStreamError("515", "")
+	return dev.jid, nil
+}
+
+func (acc *Account) newSimDevice(deviceID uint16) *Device {
+	srv := acc.server
+	sim := newDevice(srv, acc, deviceID)
+	preKeys, err := sim.Store.PreKeys.GetOrGenPreKeys(context.TODO(), 50)
+	if err != nil {
+		panic(fmt.Errorf("failed to generate prekeys for simulated device: %w", err))
+	}
+	_ = sim.Store.PreKeys.MarkPreKeysAsUploaded(context.TODO(), preKeys[len(preKeys)-1].KeyID)
+	dev := &deviceRecord{
+		account:        acc,
+		jid:            sim.JID,
+		lid:            sim.LID,
+		noiseKey:       *sim.Store.NoiseKey.Pub,
+		registrationID: sim.Store.RegistrationID,
+		identityKey:    *sim.Store.IdentityKey.Pub,
+		signedPreKey:   sim.Store.SignedPreKey,
+		preKeys:        preKeys,
+		sim:            sim,
+	}
+	sim.record = dev
+	srv.lock.Lock()
+	acc.devices[deviceID] = dev
+	srv.lock.Unlock()
+	return sim
+}
+
+// deviceList returns all devices of the account sorted by device ID. The server lock must be held.
+func (acc *Account) deviceList() []*deviceRecord {
+	return slices.SortedFunc(maps.Values(acc.devices), func(a, b *deviceRecord) int {
+		return int(a.jid.Device) - int(b.jid.Device)
+	})
+}
+
+// deliver sends a node to the device, or queues it if the device is offline. The server lock must be held.
+func (dev *deviceRecord) deliver(node waBinary.Node) {
+	if dev.sim != nil {
+		dev.sim.Push(node)
+		return
+	} else if dev.conn != nil {
+		err := dev.conn.SendNode(node)
+		if err == nil {
+			return
+		}
+		dev.conn.log.Debugf("Failed to deliver %s, queueing it: %v", node.Tag, err)
+	}
+	dev.offline = append(dev.offline, node)
+}
+
+// bundle returns a prekey bundle for starting a session with the device.
+// One-time prekeys are consumed in order. The server lock must be held.
+func (dev *deviceRecord) bundle() *prekey.Bundle {
+	preKeyID := optional.NewEmptyUint32()
+	var preKeyPub ecc.ECPublicKeyable
+	if len(dev.preKeys) > 0 {
+		preKey := dev.preKeys[0]
+		dev.preKeys = dev.preKeys[1:]
+		preKeyID = optional.NewOptionalUint32(preKey.KeyID)
+		preKeyPub = ecc.NewDjbECPublicKey(*preKey.Pub)
+	}
+	return prekey.NewBundle(
+		dev.registrationID, uint32(dev.jid.Device), preKeyID, dev.signedPreKey.KeyID,
+		preKeyPub, ecc.NewDjbECPublicKey(*dev.signedPreKey.Pub), *dev.signedPreKey.Signature,
+		identity.NewKey(ecc.NewDjbECPublicKey(dev.identityKey)),
+	)
+}
+
+// getAccount finds an account by phone number or LID. The server lock must be held.
+func (srv *Server) getAccount(jid types.JID) *Account {
+	switch jid.Server {
+	case types.DefaultUserServer:
+		return srv.accounts[jid.User]
+	case types.HiddenUserServer:
+		return srv.accountsByLID[jid.User]
+	default:
+		return nil
+	}
+}
+
+// getDevice finds a device by its phone number or LID JID. The server lock must be held.
+func (srv *Server) getDevice(jid types.JID) *deviceRecord {
+	acc := srv.getAccount(jid)
+	if acc == nil {
+		return nil
+	}
+	return acc.devices[jid.Device]
+}
+
+// Account returns the account with the given phone number or LID.
+func (srv *Server) Account(jid types.JID) *Account {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.getAccount(jid)
+}
@@ -0,0 +1,533 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"go.mau.fi/util/random"
+	"google.golang.org/protobuf/proto"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waWa6"
+	"go.mau.fi/whatsmeow/socket"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// HandshakeTimeout is the maximum time to wait for each handshake message from the client.
+var HandshakeTimeout = 20 * time.Second
+
+// StreamErrorGracePeriod is how long the server waits for the client to disconnect after sending a stream error
+// before closing the connection forcibly.
+var StreamErrorGracePeriod = 1 * time.Second
+
+// Conn is a single websocket connection to the fake server.
+type Conn struct {
+	server *Server
+	ws     *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	log    waLog.Logger
+
+	readKey      cipher.AEAD
+	writeKey     cipher.AEAD
+	readCounter  uint32
+	writeCounter uint32
+	writeLock    sync.Mutex
+	frames       [][]byte
+	headerRead   bool
+
+	// ClientPayload is the payload that the client sent at the end of the noise handshake.
+	ClientPayload *waWa6.ClientPayload
+	// NoiseKey is the client's static noise public key.
+	NoiseKey [32]byte
+
+	// device is set after login and is protected by the server lock.
+	device *deviceRecord
+
+	responseWaiters     map[string]chan<- *waBinary.Node
+	responseWaitersLock sync.Mutex
+}
+
+func newConn(srv *Server, ws *websocket.Conn) *Conn {
+	conn := &Conn{
+		server:          srv,
+		ws:              ws,
+		log:             srv.Log.Sub("Conn"),
+		responseWaiters: make(map[string]chan<- *waBinary.Node),
+	}
+	conn.ctx, conn.cancel = context.WithCancel(srv.ctx)
+	return conn
+}
+
+// JID returns the device JID that the connection is logged in as, or an empty JID if it's not logged in.
+func (conn *Conn) JID() types.JID {
+	conn.server.lock.Lock()
+	defer conn.server.lock.Unlock()
+	if conn.device == nil {
+		return types.EmptyJID
+	}
+	return conn.device.jid
+}
+
+// Close closes the connection without sending any stream end or error nodes.
+func (conn *Conn) Close() {
+	conn.cancel()
+	_ = conn.ws.CloseNow()
+}
+
+func (conn *Conn) sendStreamError(code, conflictType string) {
+	node := waBinary.Node{
+		Tag:   "stream:error",
+		Attrs: waBinary.Attrs{},
+	}
+	if code != "" {
+		node.Attrs["code"] = code
+	}
+	if conflictType != "" {
+		node.Content = []waBinary.Node{{Tag: "conflict", Attrs: waBinary.Attrs{"type": conflictType}}}
+	}
+	err := conn.SendNode(node)
+	if err != nil {
+		conn.log.Debugf("Failed to send stream error: %v", err)
+	}
+	// Give the client a chance to process the error and close the connection by itself
+	select {
+	case <-conn.ctx.Done():
+	case <-time.After(StreamErrorGracePeriod):
+	}
+	conn.Close()
+}
+
+func (conn *Conn) readFrame(ctx context.Context) ([]byte, error) {
+	for len(conn.frames) == 0 {
+		_, data, err := conn.ws.Read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !conn.headerRead {
+			if !bytes.HasPrefix(data, socket.WAConnHeader) {
+				return nil, fmt.Errorf("invalid connection header %X", data[:min(len(data), 4)])
+			}
+			data = data[len(socket.WAConnHeader):]
+			conn.headerRead = true
+		}
+		for len(data) > 0 {
+			if len(data) < socket.FrameLengthSize {
+				return nil, fmt.Errorf("incomplete frame header")
+			}
+			length := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+			data = data[socket.FrameLengthSize:]
+			if len(data) < length {
+				return nil, fmt.Errorf("incomplete frame (expected %d bytes, got %d)", length, len(data))
+			}
+			conn.frames = append(conn.frames, data[:length])
+			data = data[length:]
+		}
+	}
+	frame := conn.frames[0]
+	conn.frames = conn.frames[1:]
+	return frame, nil
+}
+
+func (conn *Conn) writeFrame(ctx context.Context, data []byte) error {
+	if len(data) >= socket.FrameMaxSize {
+		return socket.ErrFrameTooLarge
+	}
+	frame := make([]byte, socket.FrameLengthSize+len(data))
+	frame[0] = byte(len(data) >> 16)
+	frame[1] = byte(len(data) >> 8)
+	frame[2] = byte(len(data))
+	copy(frame[socket.FrameLengthSize:], data)
+	return conn.ws.Write(ctx, websocket.MessageBinary, frame)
+}
+
+func generateIV(count uint32) []byte {
+	iv := make([]byte, 12)
+	binary.BigEndian.PutUint32(iv[8:], count)
+	return iv
+}
+
+// SendNode encrypts and sends the given node to the client.
+func (conn *Conn) SendNode(node waBinary.Node) error {
+	payload, err := waBinary.Marshal(node)
+	if err != nil {
+		return fmt.Errorf("failed to marshal node: %w", err)
+	}
+	conn.writeLock.Lock()
+	defer conn.writeLock.Unlock()
+	if conn.writeKey == nil {
+		return ErrConnClosed
+	}
+	ciphertext := conn.writeKey.Seal(nil, generateIV(conn.writeCounter), payload, nil)
+	conn.writeCounter++
+	conn.log.Debugf("Sending %s", node.XMLString())
+	err = conn.writeFrame(conn.ctx, ciphertext)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConnClosed, err)
+	}
+	return nil
+}
+
+// SendIQ sends the given IQ to the client and waits for the response.
+//
+// If the node doesn't have an ID, one will be generated.
+func (conn *Conn) SendIQ(ctx context.Context, node waBinary.Node) (*waBinary.Node, error) {
+	if node.Attrs == nil {
+		node.Attrs = waBinary.Attrs{}
+	}
+	id, ok := node.Attrs["id"].(string)
+	if !ok {
+		id = conn.server.generateID()
+		node.Attrs["id"] = id
+	}
+	if _, ok = node.Attrs["from"]; !ok {
+		node.Attrs["from"] = types.ServerJID
+	}
+	waiter := make(chan *waBinary.Node, 1)
+	conn.responseWaitersLock.Lock()
+	conn.responseWaiters[id] = waiter
+	conn.responseWaitersLock.Unlock()
+	defer func() {
+		conn.responseWaitersLock.Lock()
+		delete(conn.responseWaiters, id)
+		conn.responseWaitersLock.Unlock()
+	}()
+	err := conn.SendNode(node)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case resp := <-waiter:
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-conn.ctx.Done():
+		return nil, ErrConnClosed
+	}
+}
+
+func (conn *Conn) serve() {
+	defer conn.Close()
+	err := conn.handshake()
+	if err != nil {
+		conn.log.Warnf("Handshake failed: %v", err)
+		return
+	}
+	if reg := conn.ClientPayload.GetDevicePairingData(); reg != nil {
+		err = conn.startPairing()
+	} else {
+		err = conn.login()
+	}
+	if err != nil {
+		conn.log.Warnf("Failed to start session: %v", err)
+		return
+	}
+	for {
+		frame, err := conn.readFrame(conn.ctx)
+		if err != nil {
+			conn.log.Debugf("Read loop exiting: %v", err)
+			return
+		}
+		plaintext, err := conn.readKey.Open(frame[:0], generateIV(conn.readCounter), frame, nil)
+		conn.readCounter++
+		if err != nil {
+			conn.log.Warnf("Failed to decrypt frame: %v", err)
+			return
+		}
+		unpacked, err := waBinary.Unpack(plaintext)
+		if err != nil {
+			conn.log.Warnf("Failed to decompress frame: %v", err)
+			continue
+		}
+		node, err := waBinary.Unmarshal(unpacked)
+		if err != nil {
+			conn.log.Warnf("Failed to decode node: %v", err)
+			continue
+		}
+		conn.log.Debugf("Received %s", node.XMLString())
+		conn.handleNode(node)
+	}
+}
+
+func (conn *Conn) handshake() error {
+	ctx, cancel := context.WithTimeout(conn.ctx, HandshakeTimeout)
+	defer cancel()
+	data, err := conn.readFrame(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read client hello: %w", err)
+	}
+	var hello waWa6.HandshakeMessage
+	err = proto.Unmarshal(data, &hello)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal client hello: %w", err)
+	}
+	clientEphemeral := hello.GetClientHello().GetEphemeral()
+	if len(clientEphemeral) != 32 {
+		return fmt.Errorf("invalid client ephemeral key length %d", len(clientEphemeral))
+	}
+	clientEphemeralArr := *(*[32]byte)(clientEphemeral)
+	conn.server.lock.Lock()
+	staticKey, certChain := conn.server.staticKey, conn.server.certChain
+	conn.server.lock.Unlock()
+
+	nh := socket.NewNoiseHandshake()
+	if hello.GetClientHello().GetStatic() != nil {
+		if ok, err := conn.resumeHandshake(ctx, hello.GetClientHello(), staticKey, certChain); ok || err != nil {
+			return err
+		}
+		conn.log.Debugf("Couldn't decrypt resume handshake, falling back to full handshake")
+		conn.server.handshakeStats.fallback.Add(1)
+		nh.Start(socket.NoiseFallbackPattern, socket.WAConnHeader)
+	} else {
+		conn.server.handshakeStats.full.Add(1)
+		nh.Start(socket.NoiseStartPattern, socket.WAConnHeader)
+	}
+	nh.Authenticate(clientEphemeral)
+	ephemeralKP := keys.NewKeyPair()
+	nh.Authenticate(ephemeralKP.Pub[:])
+	if err = nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, clientEphemeralArr); err != nil {
+		return fmt.Errorf("failed to mix ephemeral keys: %w", err)
+	}
+	encryptedStatic := nh.Encrypt(staticKey.Pub[:])
+	if err = nh.MixSharedSecretIntoKey(*staticKey.Priv, clientEphemeralArr); err != nil {
+		return fmt.Errorf("failed to mix static key: %w", err)
+	}
+	encryptedCert := nh.Encrypt(certChain)
+	data, err = proto.Marshal(&waWa6.HandshakeMessage{
+		ServerHello: &waWa6.HandshakeMessage_ServerHello{
+			Ephemeral: ephemeralKP.Pub[:],
+			Static:    encryptedStatic,
+			Payload:   encryptedCert,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal server hello: %w", err)
+	} else if err = conn.writeFrame(ctx, data); err != nil {
+		return fmt.Errorf("failed to send server hello: %w", err)
+	}
+
+	data, err = conn.readFrame(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read client finish: %w", err)
+	}
+	var finish waWa6.HandshakeMessage
+	err = proto.Unmarshal(data, &finish)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal client finish: %w", err)
+	}
+	clientStatic, err := nh.Decrypt(finish.GetClientFinish().GetStatic())
+	if err != nil {
+		return fmt.Errorf("failed to decrypt client static key: %w", err)
+	} else if len(clientStatic) != 32 {
+		return fmt.Errorf("invalid client static key length %d", len(clientStatic))
+	}
+	conn.NoiseKey = *(*[32]byte)(clientStatic)
+	if err = nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, conn.NoiseKey); err != nil {
+		return fmt.Errorf("failed to mix client static key: %w", err)
+	}
+	payload, err := nh.Decrypt(finish.GetClientFinish().GetPayload())
+	if err != nil {
+		return fmt.Errorf("failed to decrypt client payload: %w", err)
+	}
+	return conn.finishHandshake(nh, payload)
+}
+
+// resumeHandshake handles the first message of a Noise_IK handshake. If the message can't be decrypted
+// with the current static key, it returns false so that the caller can fall back to Noise_XXfallback.
+func (conn *Conn) resumeHandshake(ctx context.Context, hello *waWa6.HandshakeMessage_ClientHello, staticKey *keys.KeyPair, certChain []byte) (bool, error) {
+	clientEphemeralArr := *(*[32]byte)(hello.GetEphemeral())
+	nh := socket.NewNoiseHandshake()
+	nh.Start(socket.NoiseResumePattern, socket.WAConnHeader)
+	nh.Authenticate(staticKey.Pub[:])
+	nh.Authenticate(hello.GetEphemeral())
+	if err := nh.MixSharedSecretIntoKey(*staticKey.Priv, clientEphemeralArr); err != nil {
+		return false, fmt.Errorf("failed to mix ephemeral key: %w", err)
+	}
+	clientStatic, err := nh.Decrypt(hello.GetStatic())
+	if err != nil || len(clientStatic) != 32 {
+		return false, nil
+	}
+	conn.NoiseKey = *(*[32]byte)(clientStatic)
+	if err = nh.MixSharedSecretIntoKey(*staticKey.Priv, conn.NoiseKey); err != nil {
+		return false, fmt.Errorf("failed to mix client static key: %w", err)
+	}
+	payload, err := nh.Decrypt(hello.GetPayload())
+	if err != nil {
+		return false, nil
+	}
+	ephemeralKP := keys.NewKeyPair()
+	nh.Authenticate(ephemeralKP.Pub[:])
+	if err = nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, clientEphemeralArr); err != nil {
+		return false, fmt.Errorf("failed to mix ephemeral keys: %w", err)
+	} else if err = nh.MixSharedSecretIntoKey(*ephemeralKP.Priv, conn.NoiseKey); err != nil {
+		return false, fmt.Errorf("failed to mix ephemeral key with client static key: %w", err)
+	}
+	conn.server.handshakeStats.resumed.Add(1)
+	data, err := proto.Marshal(&waWa6.HandshakeMessage{
+		ServerHello: &waWa6.HandshakeMessage_ServerHello{
+			Ephemeral: ephemeralKP.Pub[:],
+			Payload:   nh.Encrypt(certChain),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal server hello: %w", err)
+	} else if err = conn.writeFrame(ctx, data); err != nil {
+		return false, fmt.Errorf("failed to send server hello: %w", err)
+	}
+	return true, conn.finishHandshake(nh, payload)
+}
+
+func (conn *Conn) finishHandshake(nh *socket.NoiseHandshake, payload []byte) error {
+	conn.ClientPayload = &waWa6.ClientPayload{}
+	err := proto.Unmarshal(payload, conn.ClientPayload)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal client payload: %w", err)
+	}
+	// The keys are from the client's point of view, so they're swapped here.
+	readKey, writeKey, err := nh.FinalKeys()
+	if err != nil {
+		return err
+	}
+	conn.writeLock.Lock()
+	conn.readKey, conn.writeKey = readKey, writeKey
+	conn.writeLock.Unlock()
+	return nil
+}
+
+func (conn *Conn) startPairing() error {
+	refs := make([]waBinary.Node, 6)
+	conn.server.lock.Lock()
+	for i := range refs {
+		ref := "2@" + random.String(32)
+		conn.server.pairingRefs[ref] = conn
+		refs[i] = waBinary.Node{Tag: "ref", Content: []byte(ref)}
+	}
+	conn.server.lock.Unlock()
+	return conn.SendNode(waBinary.Node{
+		Tag: "iq",
+		Attrs: waBinary.Attrs{
+			"from":  types.ServerJID,
+			"type":  "set",
+			"id":    conn.server.generateID(),
+			"xmlns": "md",
+		},
+		Content: []waBinary.Node{{Tag: "pair-device", Content: refs}},
+	})
+}
+
+func (conn *Conn) login() error {
+	user := strconv.FormatUint(conn.ClientPayload.GetUsername(), 10)
+	deviceID := uint16(conn.ClientPayload.GetDevice())
+	srv := conn.server
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	acc, ok := srv.accounts[user]
+	var dev *deviceRecord
+	if ok {
+		dev = acc.devices[deviceID]
+	}
+	if dev == nil || dev.sim != nil || dev.noiseKey != conn.NoiseKey {
+		_ = conn.SendNode(waBinary.Node{
+			Tag:   "failure",
+			Attrs: waBinary.Attrs{"reason": "401", "location": "lla"},
+		})
+		return fmt.Errorf("%w %s.%d", ErrUnknownDevice, user, deviceID)
+	}
+	if oldConn := dev.conn; oldConn != nil {
+		go oldConn.sendStreamError("", "replaced")
+	}
+	conn.device = dev
+	dev.conn = conn
+	err := conn.SendNode(waBinary.Node{
+		Tag: "success",
+		Attrs: waBinary.Attrs{
+			"t":        time.Now().Unix(),
+			"props":    "1",
+			"location": "lla",
+			"lid":      dev.lid,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	offline := dev.offline
+	dev.offline = nil
+	for i, node := range offline {
+		err = conn.SendNode(node)
+		if err != nil {
+			dev.offline = offline[i:]
+			return err
+		}
+	}
+	return conn.SendNode(waBinary.Node{
+		Tag:     "ib",
+		Content: []waBinary.Node{{Tag: "offline", Attrs: waBinary.Attrs{"count": len(offline)}}},
+	})
+}
+
+func (conn *Conn) handleNode(node *waBinary.Node) {
+	switch node.Tag {
+	case "iq":
+		typ, _ := node.Attrs["type"].(string)
+		if typ == "result" || typ == "error" {
+			conn.receiveResponse(node)
+		} else {
+			go conn.handleIQ(node)
+		}
+	case "message":
+		conn.handleMessage(node)
+	case "receipt":
+		conn.handleReceipt(node)
+	case "ack", "presence", "chatstate", "xmlstreamend":
+		// Ignored
+	default:
+		conn.log.Debugf("Ignoring unknown %s node", node.Tag)
+	}
+}
+
+func (conn *Conn) receiveResponse(node *waBinary.Node) {
+	id, _ := node.Attrs["id"].(string)
+	conn.responseWaitersLock.Lock()
+	waiter, ok := conn.responseWaiters[id]
+	delete(conn.responseWaiters, id)
+	conn.responseWaitersLock.Unlock()
+	if ok {
+		waiter <- node
+	}
+}
+
+func (conn *Conn) sendAck(node *waBinary.Node, errorCode int) {
+	attrs := waBinary.Attrs{
+		"class": node.Tag,
+		"id":    node.Attrs["id"],
+		"t":     time.Now().Unix(),
+	}
+	if to, ok := node.Attrs["to"]; ok {
+		attrs["from"] = to
+	}
+	if typ, ok := node.Attrs["type"]; ok && node.Tag != "message" {
+		attrs["type"] = typ
+	}
+	if errorCode != 0 {
+		attrs["error"] = errorCode
+	}
+	err := conn.SendNode(waBinary.Node{Tag: "ack", Attrs: attrs})
+	if err != nil {
+		conn.log.Debugf("Failed to send ack: %v", err)
+	}
+}
@@ -0,0 +1,710 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mau.fi/libsignal/ecc"
+	"go.mau.fi/libsignal/groups"
+	"go.mau.fi/libsignal/keys/identity"
+	"go.mau.fi/libsignal/keys/prekey"
+	"go.mau.fi/libsignal/protocol"
+	"go.mau.fi/libsignal/session"
+	"go.mau.fi/libsignal/util/optional"
+	"go.mau.fi/util/random"
+	"google.golang.org/protobuf/proto"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/memstore"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+var pbSerializer = store.SignalProtobufSerializer
+
+// Device is a simulated device (either a primary phone or a companion) that lives inside the server.
+//
+// Simulated devices encrypt and decrypt messages with the normal Signal protocol, so they can exchange
+// messages with real clients. Incoming messages are automatically acknowledged with delivery receipts,
+// and messages that fail to decrypt are answered with retry receipts.
+type Device struct {
+	Store   *store.Device
+	JID     types.JID
+	LID     types.JID
+	Account *Account
+
+	server *Server
+	record *deviceRecord
+	log    waLog.Logger
+
+	inbox    *queue[waBinary.Node]
+	messages *queue[*Message]
+	receipts *queue[*Receipt]
+
+	sentLock sync.Mutex
+	sent     map[types.MessageID]*sentMessage
+}
+
+// Message is a message received and decrypted by a simulated device.
+type Message struct {
+	Info    types.MessageInfo
+	Message *waE2E.Message
+	Raw     *waBinary.Node
+}
+
+// Receipt is a receipt received by a simulated device.
+type Receipt struct {
+	ID     types.MessageID
+	Chat   types.JID
+	Sender types.JID
+	Type   types.ReceiptType
+	Raw    *waBinary.Node
+}
+
+type sentMessage struct {
+	chat    types.JID
+	message *waE2E.Message
+}
+
+type queue[T any] struct {
+	lock   sync.Mutex
+	items  []T
+	signal chan struct{}
+}
+
+func newQueue[T any]() *queue[T] {
+	return &queue[T]{signal: make(chan struct{}, 1)}
+}
+
+func (q *queue[T]) push(item T) {
+	q.lock.Lock()
+	q.items = append(q.items, item)
+	q.lock.Unlock()
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (q *queue[T]) pop(ctx context.Context) (item T, err error) {
+	for {
+		q.lock.Lock()
+		if len(q.items) > 0 {
+			item = q.items[0]
+			q.items = q.items[1:]
+			if len(q.items) > 0 {
+				select {
+				case q.signal <- struct{}{}:
+				default:
+				}
+			}
+			q.lock.Unlock()
+			return
+		}
+		q.lock.Unlock()
+		select {
+		case <-q.signal:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
+
+func newDevice(srv *Server, acc *Account, deviceID uint16) *Device {
+	jid := types.JID{User: acc.PN.User, Device: deviceID, Server: types.DefaultUserServer}
+	lid := types.JID{User: acc.LID.User, Device: deviceID, Server: types.HiddenUserServer}
+	log := srv.Log.Sub(fmt.Sprintf("Device/%s", jid))
+	container := memstore.New(log)
+	deviceStore := container.NewDevice()
+	deviceStore.ID = &jid
+	deviceStore.LID = lid
+	deviceStore.PushName = acc.PushName
+	_ = container.PutDevice(context.TODO(), deviceStore)
+	dev := &Device{
+		Store:    deviceStore,
+		JID:      jid,
+		LID:      lid,
+		Account:  acc,
+		server:   srv,
+		log:      log,
+		inbox:    newQueue[waBinary.Node](),
+		messages: newQueue[*Message](),
+		receipts: newQueue[*Receipt](),
+		sent:     make(map[types.MessageID]*sentMessage),
+	}
+	go dev.loop()
+	return dev
+}
+
+// WaitMessage waits for the next decrypted message received by the device.
+func (dev *Device) WaitMessage(ctx context.Context) (*Message, error) {
+	return dev.messages.pop(ctx)
+}
+
+// WaitReceipt waits for the next receipt received by the device.
+func (dev *Device) WaitReceipt(ctx context.Context) (*Receipt, error) {
+	return dev.receipts.pop(ctx)
+}
+
+// Push delivers an arbitrary node to this device as if it was sent by the server.
+//
+// The node is encoded and decoded like it would be on a real connection, so attribute types match what
+// clients see (e.g. integers become strings).
+func (dev *Device) Push(node waBinary.Node) {
+	data, err := waBinary.Marshal(node)
+	if err != nil {
+		dev.log.Errorf("Failed to marshal %s node: %v", node.Tag, err)
+		return
+	}
+	unpacked, err := waBinary.Unpack(data)
+	if err != nil {
+		dev.log.Errorf("Failed to unpack %s node: %v", node.Tag, err)
+		return
+	}
+	decoded, err := waBinary.Unmarshal(unpacked)
+	if err != nil {
+		dev.log.Errorf("Failed to unmarshal %s node: %v", node.Tag, err)
+		return
+	}
+	dev.inbox.push(*decoded)
+}
+
+// ResetSession deletes the Signal session with the given device, which makes the next message
+// sent to it a prekey message and messages from it fail to decrypt.
+func (dev *Device) ResetSession(ctx context.Context, peer types.JID) error {
+	return dev.Store.Sessions.DeleteSession(ctx, dev.server.lidFor(peer).SignalAddress().String())
+}
+
+func (dev *Device) loop() {
+	for {
+		node, err := dev.inbox.pop(dev.server.ctx)
+		if err != nil {
+			return
+		}
+		switch node.Tag {
+		case "message":
+			dev.handleMessage(&node)
+		case "receipt":
+			dev.handleReceipt(&node)
+		}
+	}
+}
+
+// lidFor returns the LID of the given device JID.
+func (srv *Server) lidFor(jid types.JID) types.JID {
+	if jid.Server == types.HiddenUserServer {
+		return jid
+	}
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if acc := srv.getAccount(jid); acc != nil {
+		return types.JID{User: acc.LID.User, Device: jid.Device, Server: types.HiddenUserServer}
+	}
+	return jid
+}
+
+func padMessage(plaintext []byte) []byte {
+	pad := random.Bytes(1)
+	pad[0] &= 0xf
+	if pad[0] == 0 {
+		pad[0] = 0xf
+	}
+	return append(plaintext, bytes.Repeat(pad, int(pad[0]))...)
+}
+
+func unpadMessage(plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 || int(plaintext[len(plaintext)-1]) > len(plaintext) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	return plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])], nil
+}
+
+// SendMessage sends a message from this device to the given user or group.
+//
+// Messages to users are also sent to the other devices of this device's account, like real clients do.
+func (dev *Device) SendMessage(ctx context.Context, to types.JID, message *waE2E.Message) (types.MessageID, error) {
+	id := dev.server.generateMessageID()
+	dev.sentLock.Lock()
+	dev.sent[id] = &sentMessage{chat: to, message: message}
+	dev.sentLock.Unlock()
+	node := waBinary.Node{
+		Tag: "message",
+		Attrs: waBinary.Attrs{
+			"id":   id,
+			"to":   to,
+			"type": "text",
+		},
+	}
+	var err error
+	if to.Server == types.GroupServer {
+		node.Content, err = dev.encryptGroup(ctx, to, message)
+	} else {
+		node.Content, err = dev.encryptDM(ctx, to, message)
+	}
+	if err != nil {
+		return "", err
+	}
+	srv := dev.server
+	srv.lock.Lock()
+	errorCode := srv.routeMessage(dev.record, &node)
+	srv.lock.Unlock()
+	if errorCode != 0 {
+		return id, fmt.Errorf("server returned error %d", errorCode)
+	}
+	return id, nil
+}
+
+func (dev *Device) encryptDM(ctx context.Context, to types.JID, message *waE2E.Message) ([]waBinary.Node, error) {
+	plaintext, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	dsmPlaintext, err := proto.Marshal(&waE2E.Message{
+		DeviceSentMessage: &waE2E.DeviceSentMessage{
+			DestinationJID: proto.String(to.String()),
+			Message:        message,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	srv := dev.server
+	srv.lock.Lock()
+	var targets []*deviceRecord
+	if acc := srv.getAccount(to); acc == nil {
+		srv.lock.Unlock()
+		return nil, fmt.Errorf("%w %s", ErrUnknownUser, to)
+	} else if acc != dev.Account {
+		targets = acc.deviceList()
+	}
+	targets = append(targets, dev.Account.deviceList()...)
+	srv.lock.Unlock()
+	participants := make([]waBinary.Node, 0, len(targets))
+	for _, target := range targets {
+		if target == dev.record {
+			continue
+		}
+		pt := plaintext
+		if target.account == dev.Account {
+			pt = dsmPlaintext
+		}
+		enc, err := dev.encryptFor(ctx, target, pt, nil)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, waBinary.Node{
+			Tag:     "to",
+			Attrs:   waBinary.Attrs{"jid": target.jidIn(to.Server)},
+			Content: []waBinary.Node{enc},
+		})
+	}
+	return []waBinary.Node{{Tag: "participants", Content: participants}}, nil
+}
+
+func (dev *Device) encryptGroup(ctx context.Context, to types.JID, message *waE2E.Message) ([]waBinary.Node, error) {
+	srv := dev.server
+	srv.lock.Lock()
+	group, ok := srv.groups[to]
+	var targets []*deviceRecord
+	if ok {
+		for _, acc := range group.participants {
+			targets = append(targets, acc.deviceList()...)
+		}
+	}
+	srv.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownGroup, to)
+	}
+
+	builder := groups.NewGroupSessionBuilder(dev.Store, pbSerializer)
+	senderKeyName := protocol.NewSenderKeyName(to.String(), dev.LID.SignalAddress())
+	skdm, err := builder.Create(ctx, senderKeyName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sender key distribution message: %w", err)
+	}
+	skdmPlaintext, err := proto.Marshal(&waE2E.Message{
+		SenderKeyDistributionMessage: &waE2E.SenderKeyDistributionMessage{
+			GroupID:                             proto.String(to.String()),
+			AxolotlSenderKeyDistributionMessage: skdm.Serialize(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := proto.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := groups.NewGroupCipher(builder, senderKeyName, dev.Store).Encrypt(ctx, padMessage(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt group message: %w", err)
+	}
+
+	participants := make([]waBinary.Node, 0, len(targets))
+	for _, target := range targets {
+		if target == dev.record {
+			continue
+		}
+		enc, err := dev.encryptFor(ctx, target, skdmPlaintext, nil)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, waBinary.Node{
+			Tag:     "to",
+			Attrs:   waBinary.Attrs{"jid": target.lid},
+			Content: []waBinary.Node{enc},
+		})
+	}
+	return []waBinary.Node{
+		{Tag: "participants", Content: participants},
+		{Tag: "enc", Attrs: waBinary.Attrs{"v": "2", "type": "skmsg"}, Content: encrypted.SignedSerialize()},
+	}, nil
+}
+
+// encryptFor encrypts the given plaintext for a single device.
+// If there's no existing session and bundle is nil, a prekey bundle is fetched from the server.
+func (dev *Device) encryptFor(ctx context.Context, target *deviceRecord, plaintext []byte, bundle *prekey.Bundle) (waBinary.Node, error) {
+	addr := target.lid.SignalAddress()
+	builder := session.NewBuilderFromSignal(dev.Store, addr, pbSerializer)
+	if bundle == nil {
+		if exists, err := dev.Store.ContainsSession(ctx, addr); err != nil {
+			return waBinary.Node{}, err
+		} else if !exists {
+			dev.server.lock.Lock()
+			bundle = target.bundle()
+			dev.server.lock.Unlock()
+		}
+	}
+	if bundle != nil {
+		err := builder.ProcessBundle(ctx, bundle)
+		if err != nil {
+			return waBinary.Node{}, fmt.Errorf("failed to process prekey bundle of %s: %w", target.jid, err)
+		}
+	}
+	ciphertext, err := session.NewCipher(builder, addr).Encrypt(ctx, padMessage(plaintext))
+	if err != nil {
+		return waBinary.Node{}, fmt.Errorf("failed to encrypt for %s: %w", target.jid, err)
+	}
+	encType := "msg"
+	if ciphertext.Type() == protocol.PREKEY_TYPE {
+		encType = "pkmsg"
+	}
+	return waBinary.Node{
+		Tag:     "enc",
+		Attrs:   waBinary.Attrs{"v": "2", "type": encType},
+		Content: ciphertext.Serialize(),
+	}, nil
+}
+
+func (dev *Device) handleMessage(node *waBinary.Node) {
+	ctx := context.TODO()
+	ag := node.AttrGetter()
+	info := types.MessageInfo{
+		ID:        ag.String("id"),
+		Timestamp: ag.UnixTime("t"),
+		Type:      ag.OptionalString("type"),
+		PushName:  ag.OptionalString("notify"),
+	}
+	from := ag.JID("from")
+	if from.Server == types.GroupServer {
+		info.Chat = from
+		info.IsGroup = true
+		info.Sender = ag.JID("participant")
+	} else {
+		info.Sender = from
+		info.Chat = ag.OptionalJIDOrEmpty("recipient")
+		if info.Chat.IsEmpty() {
+			info.Chat = from.ToNonAD()
+		}
+	}
+	info.IsFromMe = info.Sender.User == dev.JID.User || info.Sender.User == dev.LID.User
+	if !ag.OK() {
+		dev.log.Warnf("Failed to parse message attributes: %v", ag.Error())
+		return
+	}
+	senderLID := dev.server.lidFor(info.Sender)
+
+	var msg *waE2E.Message
+	for _, child := range node.GetChildren() {
+		if child.Tag != "enc" {
+			continue
+		}
+		ciphertext, _ := child.Content.([]byte)
+		var plaintext []byte
+		var err error
+		switch child.Attrs["type"] {
+		case "pkmsg", "msg":
+			plaintext, err = dev.decryptDM(ctx, senderLID, ciphertext, child.Attrs["type"] == "pkmsg")
+		case "skmsg":
+			plaintext, err = dev.decryptGroup(ctx, info.Chat, senderLID, ciphertext)
+		default:
+			continue
+		}
+		if err == nil {
+			plaintext, err = unpadMessage(plaintext)
+		}
+		if err != nil {
+			dev.log.Warnf("Failed to decrypt %s from %s: %v", info.ID, info.Sender, err)
+			dev.sendRetryReceipt(ctx, node)
+			return
+		}
+		decrypted := &waE2E.Message{}
+		err = proto.Unmarshal(plaintext, decrypted)
+		if err != nil {
+			dev.log.Warnf("Failed to unmarshal %s from %s: %v", info.ID, info.Sender, err)
+			return
+		}
+		if skdm := decrypted.GetSenderKeyDistributionMessage(); skdm != nil {
+			dev.processSKDM(ctx, skdm, senderLID)
+		}
+		if dsm := decrypted.GetDeviceSentMessage(); dsm != nil {
+			info.DeviceSentMeta = &types.DeviceSentMeta{DestinationJID: dsm.GetDestinationJID(), Phash: dsm.GetPhash()}
+			decrypted = dsm.GetMessage()
+			if decrypted == nil {
+				decrypted = &waE2E.Message{}
+			}
+		}
+		if msg == nil || child.Attrs["type"] == "skmsg" {
+			msg = decrypted
+		}
+	}
+	dev.sendReceipt(node, info)
+	if msg != nil && !isOnlySKDM(msg) {
+		dev.messages.push(&Message{Info: info, Message: msg, Raw: node})
+	}
+}
+
+func isOnlySKDM(msg *waE2E.Message) bool {
+	if msg.SenderKeyDistributionMessage == nil {
+		return false
+	}
+	clone := proto.Clone(msg).(*waE2E.Message)
+	clone.SenderKeyDistributionMessage = nil
+	clone.MessageContextInfo = nil
+	return proto.Size(clone) == 0
+}
+
+func (dev *Device) decryptDM(ctx context.Context, sender types.JID, ciphertext []byte, isPreKey bool) ([]byte, error) {
+	addr := sender.SignalAddress()
+	cipher := session.NewCipher(session.NewBuilderFromSignal(dev.Store, addr, pbSerializer), addr)
+	if isPreKey {
+		msg, err := protocol.NewPreKeySignalMessageFromBytes(ciphertext, pbSerializer.PreKeySignalMessage, pbSerializer.SignalMessage)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.DecryptMessage(ctx, msg)
+	}
+	msg, err := protocol.NewSignalMessageFromBytes(ciphertext, pbSerializer.SignalMessage)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Decrypt(ctx, msg)
+}
+
+func (dev *Device) decryptGroup(ctx context.Context, chat, sender types.JID, ciphertext []byte) ([]byte, error) {
+	senderKeyName := protocol.NewSenderKeyName(chat.String(), sender.SignalAddress())
+	builder := groups.NewGroupSessionBuilder(dev.Store, pbSerializer)
+	msg, err := protocol.NewSenderKeyMessageFromBytes(ciphertext, pbSerializer.SenderKeyMessage)
+	if err != nil {
+		return nil, err
+	}
+	return groups.NewGroupCipher(builder, senderKeyName, dev.Store).Decrypt(ctx, msg)
+}
+
+func (dev *Device) processSKDM(ctx context.Context, skdm *waE2E.SenderKeyDistributionMessage, sender types.JID) {
+	senderKeyName := protocol.NewSenderKeyName(skdm.GetGroupID(), sender.SignalAddress())
+	msg, err := protocol.NewSenderKeyDistributionMessageFromBytes(skdm.GetAxolotlSenderKeyDistributionMessage(), pbSerializer.SenderKeyDistributionMessage)
+	if err == nil {
+		err = groups.NewGroupSessionBuilder(dev.Store, pbSerializer).Process(ctx, senderKeyName, msg)
+	}
+	if err != nil {
+		dev.log.Warnf("Failed to process sender key distribution message from %s: %v", sender, err)
+	}
+}
+
+func (dev *Device) sendReceipt(msgNode *waBinary.Node, info types.MessageInfo) {
+	attrs := waBinary.Attrs{
+		"id": info.ID,
+		"to": msgNode.Attrs["from"],
+	}
+	if participant, ok := msgNode.Attrs["participant"]; ok {
+		attrs["participant"] = participant
+	}
+	if recipient, ok := msgNode.Attrs["recipient"]; ok {
+		attrs["recipient"] = recipient
+	}
+	if info.IsFromMe {
+		attrs["type"] = string(types.ReceiptTypeSender)
+	}
+	dev.server.lock.Lock()
+	dev.server.routeReceipt(dev.record, &waBinary.Node{Tag: "receipt", Attrs: attrs})
+	dev.server.lock.Unlock()
+}
+
+func (dev *Device) sendRetryReceipt(ctx context.Context, msgNode *waBinary.Node) {
+	preKey, err := dev.Store.PreKeys.GenOnePreKey(ctx)
+	if err != nil {
+		dev.log.Errorf("Failed to generate prekey for retry receipt: %v", err)
+		return
+	}
+	var regID [4]byte
+	binary.BigEndian.PutUint32(regID[:], dev.Store.RegistrationID)
+	attrs := waBinary.Attrs{
+		"id":   msgNode.Attrs["id"],
+		"to":   msgNode.Attrs["from"],
+		"type": string(types.ReceiptTypeRetry),
+	}
+	if participant, ok := msgNode.Attrs["participant"]; ok {
+		attrs["participant"] = participant
+	}
+	if recipient, ok := msgNode.Attrs["recipient"]; ok {
+		attrs["recipient"] = recipient
+	}
+	node := waBinary.Node{
+		Tag:   "receipt",
+		Attrs: attrs,
+		Content: []waBinary.Node{
+			{Tag: "retry", Attrs: waBinary.Attrs{
+				"count": "1",
+				"id":    msgNode.Attrs["id"],
+				"t":     msgNode.Attrs["t"],
+				"v":     "1",
+			}},
+			{Tag: "registration", Content: regID[:]},
+			{Tag: "keys", Content: []waBinary.Node{
+				{Tag: "type", Content: []byte{ecc.DjbType}},
+				{Tag: "identity", Content: dev.Store.IdentityKey.Pub[:]},
+				preKeyToNode(preKey),
+				preKeyToNode(dev.Store.SignedPreKey),
+			}},
+		},
+	}
+	dev.server.lock.Lock()
+	dev.server.routeReceipt(dev.record, &node)
+	dev.server.lock.Unlock()
+}
+
+func (dev *Device) handleReceipt(node *waBinary.Node) {
+	ag := node.AttrGetter()
+	receipt := &Receipt{
+		ID:   ag.String("id"),
+		Type: types.ReceiptType(ag.OptionalString("type")),
+		Raw:  node,
+	}
+	from := ag.JID("from")
+	if from.Server == types.GroupServer {
+		receipt.Chat = from
+		receipt.Sender = ag.JID("participant")
+	} else {
+		receipt.Chat = from.ToNonAD()
+		receipt.Sender = from
+	}
+	if !ag.OK() {
+		dev.log.Warnf("Failed to parse receipt attributes: %v", ag.Error())
+		return
+	}
+	if receipt.Type == types.ReceiptTypeRetry {
+		err := dev.handleRetryReceipt(context.TODO(), receipt)
+		if err != nil {
+			dev.log.Warnf("Failed to handle retry receipt for %s from %s: %v", receipt.ID, receipt.Sender, err)
+		}
+	}
+	dev.receipts.push(receipt)
+}
+
+func (dev *Device) handleRetryReceipt(ctx context.Context, receipt *Receipt) error {
+	dev.sentLock.Lock()
+	sent, ok := dev.sent[receipt.ID]
+	dev.sentLock.Unlock()
+	if !ok {
+		return ErrNoSentMessage
+	}
+	srv := dev.server
+	srv.lock.Lock()
+	target := srv.getDevice(receipt.Sender)
+	srv.lock.Unlock()
+	if target == nil {
+		return fmt.Errorf("%w %s", ErrUnknownDevice, receipt.Sender)
+	}
+	var bundle *prekey.Bundle
+	if _, hasKeys := receipt.Raw.GetOptionalChildByTag("keys"); hasKeys {
+		var err error
+		bundle, err = nodeToPreKeyBundle(uint32(target.jid.Device), *receipt.Raw)
+		if err != nil {
+			return err
+		}
+	}
+	message := sent.message
+	if target.account == dev.Account {
+		message = &waE2E.Message{DeviceSentMessage: &waE2E.DeviceSentMessage{
+			DestinationJID: proto.String(sent.chat.String()),
+			Message:        message,
+		}}
+	}
+	plaintext, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	enc, err := dev.encryptFor(ctx, target, plaintext, bundle)
+	if err != nil {
+		return err
+	}
+	retryCount, _ := receipt.Raw.GetChildByTag("retry").Attrs["count"].(string)
+	enc.Attrs["count"] = retryCount
+	attrs := waBinary.Attrs{
+		"id":   receipt.ID,
+		"to":   receipt.Raw.Attrs["from"],
+		"type": "text",
+	}
+	if participant, ok := receipt.Raw.Attrs["participant"]; ok {
+		attrs["participant"] = participant
+	}
+	srv.lock.Lock()
+	errorCode := srv.routeMessage(dev.record, &waBinary.Node{Tag: "message", Attrs: attrs, Content: []waBinary.Node{enc}})
+	srv.lock.Unlock()
+	if errorCode != 0 {
+		return fmt.Errorf("server returned error %d", errorCode)
+	}
+	return nil
+}
+
+func nodeToPreKeyBundle(deviceID uint32, node waBinary.Node) (*prekey.Bundle, error) {
+	regID, _ := node.GetChildByTag("registration").Content.([]byte)
+	keysNode := node.GetChildByTag("keys")
+	identityKey, _ := keysNode.GetChildByTag("identity").Content.([]byte)
+	if len(regID) != 4 || len(identityKey) != 32 {
+		return nil, errors.New("invalid registration ID or identity key in prekey bundle")
+	}
+	signedPreKey, err := nodeToPreKey(keysNode.GetChildByTag("skey"))
+	if err != nil {
+		return nil, err
+	}
+	preKeyID := optional.NewEmptyUint32()
+	var preKeyPub ecc.ECPublicKeyable
+	if preKeyNode, ok := keysNode.GetOptionalChildByTag("key"); ok {
+		preKey, err := nodeToPreKey(preKeyNode)
+		if err != nil {
+			return nil, err
+		}
+		preKeyID = optional.NewOptionalUint32(preKey.KeyID)
+		preKeyPub = ecc.NewDjbECPublicKey(*preKey.Pub)
+	}
+	return prekey.NewBundle(
+		binary.BigEndian.Uint32(regID), deviceID, preKeyID, signedPreKey.KeyID,
+		preKeyPub, ecc.NewDjbECPublicKey(*signedPreKey.Pub), *signedPreKey.Signature,
+		identity.NewKey(ecc.NewDjbECPublicKey([32]byte(identityKey))),
+	), nil
+}
@@ -0,0 +1,25 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"errors"
+)
+
+// Errors returned by the fake server.
+var (
+	ErrUnknownUser     = errors.New("unknown user")
+	ErrUnknownDevice   = errors.New("unknown device")
+	ErrUnknownGroup    = errors.New("unknown group")
+	ErrInvalidQR       = errors.New("invalid QR code")
+	ErrUnknownQRRef    = errors.New("QR code ref is not active")
+	ErrQRKeyMismatch   = errors.New("QR code keys don't match the connection")
+	ErrConnClosed      = errors.New("connection closed")
+	ErrPairingRejected = errors.New("client rejected pairing")
+	ErrNoSentMessage   = errors.New("message not found in sent message cache")
+	ErrInvalidPreKey   = errors.New("invalid prekey node")
+)
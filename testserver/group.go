@@ -0,0 +1,138 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Group is a simulated group chat. All groups on the fake server use LID addressing.
+type Group struct {
+	JID     types.JID
+	Name    string
+	Created time.Time
+	Creator *Account
+
+	participants []*Account
+	admins       map[*Account]bool
+}
+
+// CreateGroup creates a new group with the given creator (who will be a super admin) and members.
+//
+// This doesn't notify any devices about the group, it just makes the server aware of it.
+func (srv *Server) CreateGroup(name string, creator *Account, members ...*Account) *Group {
+	group := &Group{
+		JID:          types.NewJID(strconv.FormatUint(srv.idCounter.Add(1)+120363000000000000, 10), types.GroupServer),
+		Name:         name,
+		Created:      time.Now(),
+		Creator:      creator,
+		participants: append([]*Account{creator}, members...),
+		admins:       map[*Account]bool{creator: true},
+	}
+	srv.lock.Lock()
+	srv.groups[group.JID] = group
+	srv.lock.Unlock()
+	return group
+}
+
+// Group returns the group with the given JID.
+func (srv *Server) Group(jid types.JID) *Group {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	return srv.groups[jid]
+}
+
+// SetParticipants replaces the participant list of the group.
+func (srv *Server) SetParticipants(jid types.JID, participants ...*Account) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	group, ok := srv.groups[jid]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownGroup, jid)
+	}
+	group.participants = slices.Clone(participants)
+	return nil
+}
+
+// SetAdmin promotes or demotes a participant of the group.
+func (srv *Server) SetAdmin(jid types.JID, acc *Account, admin bool) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	group, ok := srv.groups[jid]
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownGroup, jid)
+	}
+	group.admins[acc] = admin
+	return nil
+}
+
+func (group *Group) isParticipant(acc *Account) bool {
+	return slices.Contains(group.participants, acc)
+}
+
+func (group *Group) toNode() waBinary.Node {
+	content := make([]waBinary.Node, 0, len(group.participants))
+	for _, acc := range group.participants {
+		attrs := waBinary.Attrs{
+			"jid":          acc.LID,
+			"phone_number": acc.PN,
+		}
+		if acc == group.Creator {
+			attrs["type"] = "superadmin"
+		} else if group.admins[acc] {
+			attrs["type"] = "admin"
+		}
+		content = append(content, waBinary.Node{Tag: "participant", Attrs: attrs})
+	}
+	return waBinary.Node{
+		Tag: "group",
+		Attrs: waBinary.Attrs{
+			"id":              group.JID.User,
+			"subject":         group.Name,
+			"s_t":             group.Created.Unix(),
+			"creation":        group.Created.Unix(),
+			"creator":         group.Creator.LID,
+			"creator_pn":      group.Creator.PN,
+			"size":            len(group.participants),
+			"addressing_mode": string(types.AddressingModeLID),
+		},
+		Content: content,
+	}
+}
+
+func (conn *Conn) handleGroupIQ(iq *waBinary.Node) *waBinary.Node {
+	to, _ := iq.Attrs["to"].(types.JID)
+	if _, ok := iq.GetOptionalChildByTag("participating"); ok && iq.Attrs["type"] == "get" && to == types.GroupServerJID {
+		return conn.handleParticipatingGroupsIQ(iq)
+	}
+	if _, ok := iq.GetOptionalChildByTag("query"); !ok || iq.Attrs["type"] != "get" || to.Server != types.GroupServer {
+		return ErrorIQ(iq, 501, "feature-not-implemented")
+	}
+	group, ok := conn.server.groups[to]
+	if !ok {
+		return ErrorIQ(iq, 404, "item-not-found")
+	} else if !group.isParticipant(conn.device.account) {
+		return ErrorIQ(iq, 403, "forbidden")
+	}
+	return ResultIQ(iq, group.toNode())
+}
+
+func (conn *Conn) handleParticipatingGroupsIQ(iq *waBinary.Node) *waBinary.Node {
+	var groups []waBinary.Node
+	for _, group := range conn.server.groups {
+		if group.isParticipant(conn.device.account) {
+			groups = append(groups, group.toNode())
+		}
+	}
+	return ResultIQ(iq, waBinary.Node{Tag: "groups", Content: groups})
+}
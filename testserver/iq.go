@@ -0,0 +1,254 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"encoding/binary"
+	"slices"
+	"strings"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+)
+
+// IQHandler is a function that handles an IQ sent by a client.
+//
+// The returned node is sent to the client as the response.
+// Handlers registered with Server.HandleIQ can return nil to fall back to the built-in handler.
+type IQHandler func(conn *Conn, iq *waBinary.Node) *waBinary.Node
+
+// ResultIQ creates a successful response to the given IQ.
+func ResultIQ(iq *waBinary.Node, content ...waBinary.Node) *waBinary.Node {
+	attrs := waBinary.Attrs{
+		"id":   iq.Attrs["id"],
+		"type": "result",
+		"from": types.ServerJID,
+	}
+	if to, ok := iq.Attrs["to"]; ok {
+		attrs["from"] = to
+	}
+	resp := &waBinary.Node{Tag: "iq", Attrs: attrs}
+	if len(content) > 0 {
+		resp.Content = content
+	}
+	return resp
+}
+
+// ErrorIQ creates an error response to the given IQ.
+func ErrorIQ(iq *waBinary.Node, code int, text string) *waBinary.Node {
+	resp := ResultIQ(iq, waBinary.Node{
+		Tag:   "error",
+		Attrs: waBinary.Attrs{"code": code, "text": text},
+	})
+	resp.Attrs["type"] = "error"
+	return resp
+}
+
+func (conn *Conn) handleIQ(iq *waBinary.Node) {
+	xmlns, _ := iq.Attrs["xmlns"].(string)
+	conn.server.iqHandlersLock.RLock()
+	handler := conn.server.iqHandlers[xmlns]
+	conn.server.iqHandlersLock.RUnlock()
+	var resp *waBinary.Node
+	if handler != nil {
+		resp = handler(conn, iq)
+	}
+	if resp == nil {
+		resp = conn.handleBuiltinIQ(xmlns, iq)
+	}
+	err := conn.SendNode(*resp)
+	if err != nil {
+		conn.log.Debugf("Failed to send IQ response: %v", err)
+	}
+}
+
+func (conn *Conn) handleBuiltinIQ(xmlns string, iq *waBinary.Node) *waBinary.Node {
+	srv := conn.server
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if conn.device == nil {
+		return ErrorIQ(iq, 401, "not-authorized")
+	}
+	switch xmlns {
+	case "w:p", "passive", "urn:xmpp:ping":
+		return ResultIQ(iq)
+	case "encrypt":
+		return conn.handleEncryptIQ(iq)
+	case "usync":
+		return srv.handleUsyncIQ(iq)
+	case "w:g2":
+		return conn.handleGroupIQ(iq)
+	case "w:m":
+		return srv.handleMediaConnIQ(iq)
+	default:
+		return ErrorIQ(iq, 501, "feature-not-implemented")
+	}
+}
+
+func (conn *Conn) handleEncryptIQ(iq *waBinary.Node) *waBinary.Node {
+	dev := conn.device
+	typ, _ := iq.Attrs["type"].(string)
+	if count, ok := iq.GetOptionalChildByTag("count"); ok && typ == "get" {
+		count.Attrs = waBinary.Attrs{"value": len(dev.preKeys)}
+		return ResultIQ(iq, count)
+	} else if keyReq, ok := iq.GetOptionalChildByTag("key"); ok && typ == "get" {
+		users := make([]waBinary.Node, 0, len(keyReq.GetChildren()))
+		for _, user := range keyReq.GetChildren() {
+			jid, _ := user.Attrs["jid"].(types.JID)
+			users = append(users, conn.server.preKeyBundleNode(jid))
+		}
+		return ResultIQ(iq, waBinary.Node{Tag: "list", Content: users})
+	} else if list, ok := iq.GetOptionalChildByTag("list"); ok && typ == "set" {
+		regID, _ := iq.GetChildByTag("registration").Content.([]byte)
+		identity, _ := iq.GetChildByTag("identity").Content.([]byte)
+		skey, err := nodeToPreKey(iq.GetChildByTag("skey"))
+		if len(regID) != 4 || len(identity) != 32 || err != nil {
+			return ErrorIQ(iq, 400, "bad-request")
+		}
+		dev.registrationID = binary.BigEndian.Uint32(regID)
+		dev.identityKey = [32]byte(identity)
+		dev.signedPreKey = skey
+		for _, keyNode := range list.GetChildren() {
+			key, err := nodeToPreKey(keyNode)
+			if err != nil {
+				return ErrorIQ(iq, 400, "bad-request")
+			}
+			dev.preKeys = append(dev.preKeys, key)
+		}
+		return ResultIQ(iq)
+	} else if rotate, ok := iq.GetOptionalChildByTag("rotate"); ok && typ == "set" {
+		skey, err := nodeToPreKey(rotate.GetChildByTag("skey"))
+		if err != nil {
+			return ErrorIQ(iq, 400, "bad-request")
+		}
+		dev.signedPreKey = skey
+		return ResultIQ(iq)
+	}
+	return ErrorIQ(iq, 501, "feature-not-implemented")
+}
+
+func (srv *Server) preKeyBundleNode(jid types.JID) waBinary.Node {
+	dev := srv.getDevice(jid)
+	if dev == nil {
+		return waBinary.Node{
+			Tag:     "user",
+			Attrs:   waBinary.Attrs{"jid": jid},
+			Content: []waBinary.Node{{Tag: "error", Attrs: waBinary.Attrs{"code": 404, "text": "item-not-found"}}},
+		}
+	}
+	var regID [4]byte
+	binary.BigEndian.PutUint32(regID[:], dev.registrationID)
+	content := []waBinary.Node{
+		{Tag: "registration", Content: regID[:]},
+		{Tag: "type", Content: []byte{5}},
+		// Copy the key, as the node is encoded after the server lock is released and the device may upload new keys
+		{Tag: "identity", Content: slices.Clone(dev.identityKey[:])},
+	}
+	if len(dev.preKeys) > 0 {
+		content = append(content, preKeyToNode(dev.preKeys[0]))
+		dev.preKeys = dev.preKeys[1:]
+	}
+	content = append(content, preKeyToNode(dev.signedPreKey))
+	return waBinary.Node{Tag: "user", Attrs: waBinary.Attrs{"jid": jid}, Content: content}
+}
+
+func (srv *Server) handleUsyncIQ(iq *waBinary.Node) *waBinary.Node {
+	usync := iq.GetChildByTag("usync")
+	queryNode := usync.GetChildByTag("query")
+	listNode := usync.GetChildByTag("list")
+	query := queryNode.GetChildren()
+	users := listNode.GetChildren()
+	respUsers := make([]waBinary.Node, 0, len(users))
+	for _, user := range users {
+		jid, ok := user.Attrs["jid"].(types.JID)
+		if !ok {
+			phone, _ := user.GetChildByTag("contact").Content.(string)
+			jid = types.NewJID(strings.TrimPrefix(phone, "+"), types.DefaultUserServer)
+		}
+		acc := srv.getAccount(jid)
+		content := make([]waBinary.Node, 0, len(query))
+		for _, q := range query {
+			switch q.Tag {
+			case "devices":
+				if acc == nil {
+					continue
+				}
+				devices := acc.deviceList()
+				deviceNodes := make([]waBinary.Node, len(devices))
+				for i, dev := range devices {
+					deviceNodes[i] = waBinary.Node{Tag: "device", Attrs: waBinary.Attrs{"id": int(dev.jid.Device)}}
+				}
+				content = append(content, waBinary.Node{
+					Tag:     "devices",
+					Content: []waBinary.Node{{Tag: "device-list", Content: deviceNodes}},
+				})
+			case "lid":
+				if acc != nil && jid.Server == types.DefaultUserServer {
+					content = append(content, waBinary.Node{Tag: "lid", Attrs: waBinary.Attrs{"val": acc.LID}})
+				}
+			case "contact":
+				contactType := "out"
+				if acc != nil {
+					contactType = "in"
+				}
+				content = append(content, waBinary.Node{Tag: "contact", Attrs: waBinary.Attrs{"type": contactType}})
+			default:
+				content = append(content, waBinary.Node{Tag: q.Tag})
+			}
+		}
+		respUsers = append(respUsers, waBinary.Node{Tag: "user", Attrs: waBinary.Attrs{"jid": jid}, Content: content})
+	}
+	return ResultIQ(iq, waBinary.Node{
+		Tag:   "usync",
+		Attrs: usync.Attrs,
+		Content: []waBinary.Node{{
+			Tag:     "list",
+			Content: respUsers,
+		}},
+	})
+}
+
+func preKeyToNode(key *keys.PreKey) waBinary.Node {
+	var keyID [4]byte
+	binary.BigEndian.PutUint32(keyID[:], key.KeyID)
+	node := waBinary.Node{
+		Tag: "key",
+		Content: []waBinary.Node{
+			{Tag: "id", Content: keyID[1:]},
+			{Tag: "value", Content: key.Pub[:]},
+		},
+	}
+	if key.Signature != nil {
+		node.Tag = "skey"
+		node.Content = append(node.GetChildren(), waBinary.Node{
+			Tag:     "signature",
+			Content: key.Signature[:],
+		})
+	}
+	return node
+}
+
+func nodeToPreKey(node waBinary.Node) (*keys.PreKey, error) {
+	idBytes, _ := node.GetChildByTag("id").Content.([]byte)
+	pub, _ := node.GetChildByTag("value").Content.([]byte)
+	if len(idBytes) != 3 || len(pub) != 32 {
+		return nil, ErrInvalidPreKey
+	}
+	key := &keys.PreKey{
+		KeyPair: keys.KeyPair{Pub: (*[32]byte)(pub)},
+		KeyID:   binary.BigEndian.Uint32(append([]byte{0}, idBytes...)),
+	}
+	if node.Tag == "skey" {
+		sig, _ := node.GetChildByTag("signature").Content.([]byte)
+		if len(sig) != 64 {
+			return nil, ErrInvalidPreKey
+		}
+		key.Signature = (*[64]byte)(sig)
+	}
+	return key, nil
+}
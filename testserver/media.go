@@ -0,0 +1,313 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/util/random"
+	"google.golang.org/protobuf/proto"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waMmsRetry"
+	"go.mau.fi/whatsmeow/util/gcmutil"
+	"go.mau.fi/whatsmeow/util/hkdfutil"
+)
+
+// The media hosts are fake subdomains of example.com, because the certificate of httptest.Server is valid for them.
+// The HTTP client given to whatsmeow clients routes all of them to the same local server.
+var defaultMediaHosts = []string{"mmg.example.com", "mmg-fallback.example.com"}
+
+type mediaServer struct {
+	httpServer *httptest.Server
+	httpClient *http.Client
+	auth       string
+
+	lock            sync.Mutex
+	hosts           []string
+	downHosts       map[string]bool
+	uploads         map[string]*mediaUpload
+	files           map[string][]byte
+	bytesReceived   int64
+	interruptUpload int64
+	downloads       map[string]int
+}
+
+type mediaUpload struct {
+	data       []byte
+	directPath string
+}
+
+func newMediaServer() *mediaServer {
+	ms := &mediaServer{
+		auth:      base64.RawURLEncoding.EncodeToString(random.Bytes(32)),
+		hosts:     defaultMediaHosts,
+		downHosts: make(map[string]bool),
+		uploads:   make(map[string]*mediaUpload),
+		files:     make(map[string][]byte),
+		downloads: make(map[string]int),
+	}
+	ms.httpServer = httptest.NewTLSServer(http.HandlerFunc(ms.serveHTTP))
+	certPool := x509.NewCertPool()
+	certPool.AddCert(ms.httpServer.Certificate())
+	transport := ms.httpServer.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.RootCAs = certPool
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, ms.httpServer.Listener.Addr().String())
+	}
+	ms.httpClient = &http.Client{Transport: transport}
+	return ms
+}
+
+// MediaHosts returns the hostnames that are returned to clients in media_conn queries.
+func (srv *Server) MediaHosts() []string {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	return srv.media.hosts
+}
+
+// SetMediaHostDown makes the given media host respond to all requests with HTTP 503.
+func (srv *Server) SetMediaHostDown(host string, down bool) {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	srv.media.downHosts[host] = down
+}
+
+// InterruptNextUpload makes the media server drop the connection of the next upload request
+// after receiving the given number of bytes. The received bytes are kept for resuming.
+func (srv *Server) InterruptNextUpload(afterBytes int64) {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	srv.media.interruptUpload = afterBytes
+}
+
+// MediaBytesReceived returns the total number of bytes received in media upload requests.
+func (srv *Server) MediaBytesReceived() int64 {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	return srv.media.bytesReceived
+}
+
+// MediaDownloadRequests returns the number of download requests that the given media host has received.
+func (srv *Server) MediaDownloadRequests(host string) int {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	return srv.media.downloads[host]
+}
+
+// GetMedia returns the (encrypted) data of an uploaded file.
+func (srv *Server) GetMedia(directPath string) ([]byte, bool) {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	data, ok := srv.media.files[mediaFileKey(directPath)]
+	return data, ok
+}
+
+// PutMedia stores the given data on the media server as if it was uploaded and returns the direct path.
+func (srv *Server) PutMedia(data []byte) string {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	return srv.media.putFile(data)
+}
+
+// DeleteMedia removes a file from the media server, as if it had expired.
+func (srv *Server) DeleteMedia(directPath string) {
+	srv.media.lock.Lock()
+	defer srv.media.lock.Unlock()
+	delete(srv.media.files, mediaFileKey(directPath))
+}
+
+// RespondMediaRetry responds to a media retry receipt (a receipt with type server-error) as if the phone
+// re-uploaded the media to the given direct path. If the direct path is empty, the response will say
+// that the media is no longer available on the phone.
+func (dev *Device) RespondMediaRetry(receipt *Receipt, mediaKey []byte, directPath string) error {
+	rmr, ok := receipt.Raw.GetOptionalChildByTag("rmr")
+	if !ok {
+		return fmt.Errorf("receipt %s doesn't have a <rmr> element", receipt.ID)
+	}
+	var content waBinary.Node
+	if directPath == "" {
+		content = waBinary.Node{Tag: "error", Attrs: waBinary.Attrs{"code": 2}}
+	} else {
+		plaintext, err := proto.Marshal(&waMmsRetry.MediaRetryNotification{
+			StanzaID:   proto.String(receipt.ID),
+			DirectPath: proto.String(directPath),
+			Result:     waMmsRetry.MediaRetryNotification_SUCCESS.Enum(),
+		})
+		if err != nil {
+			return err
+		}
+		iv := random.Bytes(12)
+		retryKey := hkdfutil.SHA256(mediaKey, nil, []byte("WhatsApp Media Retry Notification"), 32)
+		ciphertext, err := gcmutil.Encrypt(retryKey, iv, plaintext, []byte(receipt.ID))
+		if err != nil {
+			return err
+		}
+		content = waBinary.Node{Tag: "encrypt", Content: []waBinary.Node{
+			{Tag: "enc_p", Content: ciphertext},
+			{Tag: "enc_iv", Content: iv},
+		}}
+	}
+	return dev.server.Push(receipt.Sender, waBinary.Node{
+		Tag: "notification",
+		Attrs: waBinary.Attrs{
+			"id":   receipt.ID,
+			"type": "mediaretry",
+			"from": dev.JID.ToNonAD(),
+			"t":    time.Now().Unix(),
+		},
+		Content: []waBinary.Node{{Tag: "rmr", Attrs: rmr.Attrs}, content},
+	})
+}
+
+func mediaFileKey(directPath string) string {
+	key, _, _ := strings.Cut(directPath, "?")
+	return key
+}
+
+func (ms *mediaServer) putFile(data []byte) string {
+	directPath := fmt.Sprintf("/v/t62.7118-24/%s.enc?ccb=11-4", strings.ToLower(random.String(16)))
+	ms.files[mediaFileKey(directPath)] = data
+	return directPath
+}
+
+func (srv *Server) handleMediaConnIQ(iq *waBinary.Node) *waBinary.Node {
+	srv.media.lock.Lock()
+	hosts := make([]waBinary.Node, len(srv.media.hosts))
+	for i, host := range srv.media.hosts {
+		hosts[i] = waBinary.Node{Tag: "host", Attrs: waBinary.Attrs{"hostname": host}}
+	}
+	srv.media.lock.Unlock()
+	return ResultIQ(iq, waBinary.Node{
+		Tag: "media_conn",
+		Attrs: waBinary.Attrs{
+			"auth":        srv.media.auth,
+			"ttl":         300,
+			"auth_ttl":    21600,
+			"max_buckets": 12,
+		},
+		Content: hosts,
+	})
+}
+
+func (ms *mediaServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	ms.lock.Lock()
+	down := ms.downHosts[host]
+	ms.lock.Unlock()
+	if down {
+		http.Error(w, "host is down", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		ms.serveUpload(w, r, host)
+	case http.MethodGet, http.MethodHead:
+		ms.serveDownload(w, r, host)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func writeMediaJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (ms *mediaServer) serveUpload(w http.ResponseWriter, r *http.Request, host string) {
+	query := r.URL.Query()
+	if query.Get("auth") != ms.auth {
+		http.Error(w, "invalid auth", http.StatusUnauthorized)
+		return
+	}
+	token := path.Base(r.URL.Path)
+	ms.lock.Lock()
+	upload, ok := ms.uploads[r.URL.Path]
+	if !ok {
+		upload = &mediaUpload{}
+		ms.uploads[r.URL.Path] = upload
+	}
+	if query.Get("resume") == "1" {
+		var resp map[string]any
+		if upload.directPath != "" {
+			resp = map[string]any{"resume": "complete", "url": "https://" + host + upload.directPath, "direct_path": upload.directPath}
+		} else {
+			resp = map[string]any{"resume": len(upload.data)}
+		}
+		ms.lock.Unlock()
+		writeMediaJSON(w, resp)
+		return
+	}
+	offset, _ := strconv.Atoi(query.Get("file_offset"))
+	if offset > len(upload.data) {
+		ms.lock.Unlock()
+		http.Error(w, "file offset is past the end of received data", http.StatusBadRequest)
+		return
+	}
+	upload.data = upload.data[:offset]
+	upload.directPath = ""
+	interruptAfter := ms.interruptUpload
+	ms.interruptUpload = 0
+	ms.lock.Unlock()
+
+	var body io.Reader = r.Body
+	if interruptAfter > 0 {
+		body = io.LimitReader(r.Body, interruptAfter)
+	}
+	data, err := io.ReadAll(body)
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	ms.bytesReceived += int64(len(data))
+	upload.data = append(upload.data, data...)
+	if interruptAfter > 0 {
+		panic(http.ErrAbortHandler)
+	} else if err != nil {
+		return
+	}
+	hash := sha256.Sum256(upload.data)
+	if base64.URLEncoding.EncodeToString(hash[:]) != token {
+		upload.data = nil
+		http.Error(w, "hash mismatch", http.StatusBadRequest)
+		return
+	}
+	upload.directPath = ms.putFile(bytes.Clone(upload.data))
+	writeMediaJSON(w, map[string]any{
+		"url":         "https://" + host + upload.directPath,
+		"direct_path": upload.directPath,
+	})
+}
+
+func (ms *mediaServer) serveDownload(w http.ResponseWriter, r *http.Request, host string) {
+	ms.lock.Lock()
+	ms.downloads[host]++
+	data, ok := ms.files[r.URL.Path]
+	ms.lock.Unlock()
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package testserver
+
+import (
+	"maps"
+	"time"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// jidIn returns the JID of the device on the given server, i.e. either the phone number or LID JID.
+func (dev *deviceRecord) jidIn(server string) types.JID {
+	if server == types.HiddenUserServer {
+		return dev.lid
+	}
+	return dev.jid
+}
+
+func (conn *Conn) handleMessage(node *waBinary.Node) {
+	srv := conn.server
+	srv.lock.Lock()
+	errorCode := 0
+	if conn.device == nil {
+		errorCode = 401
+	} else {
+		errorCode = srv.routeMessage(conn.device, node)
+	}
+	srv.lock.Unlock()
+	conn.sendAck(node, errorCode)
+}
+
+func (conn *Conn) handleReceipt(node *waBinary.Node) {
+	srv := conn.server
+	srv.lock.Lock()
+	if conn.device != nil {
+		srv.routeReceipt(conn.device, node)
+	}
+	srv.lock.Unlock()
+	conn.sendAck(node, 0)
+}
+
+// routeMessage fans out a message stanza sent by a device to the recipient devices.
+// It returns a non-zero error code if the message couldn't be routed. The server lock must be held.
+func (srv *Server) routeMessage(sender *deviceRecord, node *waBinary.Node) int {
+	ag := node.AttrGetter()
+	to := ag.JID("to")
+	id := ag.String("id")
+	if !ag.OK() {
+		return 400
+	}
+	baseAttrs := waBinary.Attrs{
+		"id":     id,
+		"t":      time.Now().Unix(),
+		"notify": sender.account.PushName,
+	}
+	for _, key := range []string{"type", "edit", "category", "recipient"} {
+		if val, ok := node.Attrs[key]; ok {
+			baseAttrs[key] = val
+		}
+	}
+	deviceIdentity, hasDeviceIdentity := node.GetOptionalChildByTag("device-identity")
+	directEnc, hasDirectEnc := node.GetOptionalChildByTag("enc")
+	pairwise := make(map[*deviceRecord]waBinary.Node)
+	participants := node.GetChildByTag("participants")
+	for _, child := range participants.GetChildren() {
+		jid, _ := child.Attrs["jid"].(types.JID)
+		if target := srv.getDevice(jid); child.Tag == "to" && target != nil {
+			pairwise[target] = child.GetChildByTag("enc")
+		}
+	}
+	deliver := func(target *deviceRecord, attrs waBinary.Attrs, encs ...waBinary.Node) {
+		content := encs
+		for _, enc := range encs {
+			if enc.Attrs["type"] == "pkmsg" && hasDeviceIdentity {
+				content = append(content, deviceIdentity)
+				break
+			}
+		}
+		target.deliver(waBinary.Node{Tag: "message", Attrs: attrs, Content: content})
+	}
+
+	if to.Server == types.GroupServer {
+		group, ok := srv.groups[to]
+		if !ok {
+			return 404
+		} else if !group.isParticipant(sender.account) {
+			return 403
+		}
+		baseAttrs["from"] = to
+		baseAttrs["participant"] = sender.lid
+		baseAttrs["participant_pn"] = sender.jid
+		baseAttrs["addressing_mode"] = string(types.AddressingModeLID)
+		if participant, ok := node.Attrs["participant"].(types.JID); ok && hasDirectEnc {
+			if target := srv.getDevice(participant); target != nil {
+				deliver(target, baseAttrs, directEnc)
+			}
+			return 0
+		}
+		for _, acc := range group.participants {
+			for _, target := range acc.deviceList() {
+				if target == sender {
+					continue
+				}
+				var encs []waBinary.Node
+				if enc, ok := pairwise[target]; ok {
+					encs = append(encs, enc)
+				}
+				if hasDirectEnc {
+					encs = append(encs, directEnc)
+				}
+				if len(encs) > 0 {
+					deliver(target, maps.Clone(baseAttrs), encs...)
+				}
+			}
+		}
+		return 0
+	}
+
+	if hasDirectEnc {
+		target := srv.getDevice(to)
+		if target == nil {
+			return 404
+		}
+		pairwise = map[*deviceRecord]waBinary.Node{target: directEnc}
+	} else if srv.getAccount(to) == nil {
+		return 404
+	}
+	for target, enc := range pairwise {
+		attrs := maps.Clone(baseAttrs)
+		attrs["from"] = sender.jidIn(to.Server)
+		if target.account == sender.account {
+			if _, ok := attrs["recipient"]; !ok && attrs["category"] != "peer" {
+				attrs["recipient"] = to.ToNonAD()
+			}
+		} else if to.Server == types.HiddenUserServer {
+			attrs["sender_pn"] = sender.account.PN
+		} else {
+			attrs["sender_lid"] = sender.account.LID
+		}
+		deliver(target, attrs, enc)
+	}
+	return 0
+}
+
+// routeReceipt delivers a receipt sent by a device to all devices of the user who sent the original message,
+// except for retry receipts, which are only delivered to the specific device.
+// The server lock must be held.
+func (srv *Server) routeReceipt(sender *deviceRecord, node *waBinary.Node) {
+	ag := node.AttrGetter()
+	to := ag.JID("to")
+	participant := ag.OptionalJIDOrEmpty("participant")
+	if !ag.OK() {
+		return
+	}
+	attrs := waBinary.Attrs{
+		"id": node.Attrs["id"],
+		"t":  time.Now().Unix(),
+	}
+	if typ, ok := node.Attrs["type"]; ok {
+		attrs["type"] = typ
+	}
+	target := to
+	if to.Server == types.GroupServer {
+		attrs["from"] = to
+		attrs["participant"] = sender.jidIn(participant.Server)
+		target = participant
+	} else {
+		attrs["from"] = sender.jidIn(to.Server)
+		if recipient, ok := node.Attrs["recipient"]; ok {
+			attrs["recipient"] = recipient
+		}
+	}
+	if attrs["type"] == "retry" {
+		// Retries are only relevant to the device that sent the message
+		if dev := srv.getDevice(target); dev != nil {
+			dev.deliver(waBinary.Node{Tag: "receipt", Attrs: attrs, Content: node.Content})
+		}
+		return
+	}
+	acc := srv.getAccount(target)
+	if acc == nil {
+		return
+	}
+	for _, dev := range acc.deviceList() {
+		if dev != sender {
+			dev.deliver(waBinary.Node{Tag: "receipt", Attrs: maps.Clone(attrs), Content: node.Content})
+		}
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package testserver implements an in-process fake WhatsApp server for end-to-end testing.
+//
+// The server speaks the real noise handshake and binary XML protocol over a local websocket,
+// so a normal whatsmeow.Client can connect to it, pair with a simulated phone, and exchange
+// Signal-encrypted messages with simulated devices. IQs can be scripted with HandleIQ and
+// arbitrary nodes (e.g. notifications) can be pushed to devices with Push.
+//
+//	srv, _ := testserver.New(nil)
+//	defer srv.Close()
+//	alice := srv.NewAccount("1234")
+//	cli := srv.NewClient(nil)
+//	qrChan, _ := cli.GetQRChannel(ctx)
+//	_ = cli.Connect()
+//	_, _ = alice.Pair(ctx, (<-qrChan).Code)
+package testserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coder/websocket"
+	"go.mau.fi/libsignal/ecc"
+	"go.mau.fi/util/random"
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waCert"
+	"go.mau.fi/whatsmeow/store/memstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// Server is an in-process fake WhatsApp server.
+type Server struct {
+	Log waLog.Logger
+
+	listener   net.Listener
+	httpServer *http.Server
+	ctx        context.Context
+	cancel     context.CancelFunc
+
+	certRoot  *keys.KeyPair
+	staticKey *keys.KeyPair
+	certChain []byte
+
+	lock          sync.Mutex
+	accounts      map[string]*Account
+	accountsByLID map[string]*Account
+	groups        map[types.JID]*Group
+	pairingRefs   map[string]*Conn
+	conns         map[*Conn]struct{}
+
+	iqHandlers     map[string]IQHandler
+	iqHandlersLock sync.RWMutex
+
+	media *mediaServer
+
+	idCounter atomic.Uint64
+	lidSource atomic.Uint64
+
+	handshakeStats struct {
+		full, resumed, fallback atomic.Int64
+	}
+}
+
+// HandshakeStats contains the number of each type of noise handshake the server has handled.
+type HandshakeStats struct {
+	// Full is the number of normal Noise_XX handshakes.
+	Full int64
+	// Resumed is the number of accepted Noise_IK handshakes.
+	Resumed int64
+	// Fallback is the number of Noise_IK handshakes that fell back to Noise_XXfallback.
+	Fallback int64
+}
+
+// HandshakeStats returns the number of handshakes of each type the server has handled.
+func (srv *Server) HandshakeStats() HandshakeStats {
+	return HandshakeStats{
+		Full:     srv.handshakeStats.full.Load(),
+		Resumed:  srv.handshakeStats.resumed.Load(),
+		Fallback: srv.handshakeStats.fallback.Load(),
+	}
+}
+
+// RotateStaticKey generates a new static noise key for the server,
+// which makes clients that cached the old key fall back to a full handshake.
+func (srv *Server) RotateStaticKey() error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	oldKey := srv.staticKey
+	srv.staticKey = keys.NewKeyPair()
+	chain, err := srv.makeCertChain()
+	if err != nil {
+		srv.staticKey = oldKey
+		return err
+	}
+	srv.certChain = chain
+	return nil
+}
+
+// New starts a new fake server listening on a random local port.
+//
+// The logger can be nil, it will default to a no-op logger.
+func New(log waLog.Logger) (*Server, error) {
+	if log == nil {
+		log = waLog.Noop
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	srv := &Server{
+		Log:      log,
+		listener: listener,
+
+		certRoot:  keys.NewKeyPair(),
+		staticKey: keys.NewKeyPair(),
+
+		accounts:      make(map[string]*Account),
+		accountsByLID: make(map[string]*Account),
+		groups:        make(map[types.JID]*Group),
+		pairingRefs:   make(map[string]*Conn),
+		conns:         make(map[*Conn]struct{}),
+		iqHandlers:    make(map[string]IQHandler),
+		media:         newMediaServer(),
+	}
+	srv.lidSource.Store(100000000000000)
+	srv.certChain, err = srv.makeCertChain()
+	if err != nil {
+		_ = listener.Close()
+		srv.media.httpServer.Close()
+		return nil, err
+	}
+	srv.ctx, srv.cancel = context.WithCancel(context.Background())
+	srv.httpServer = &http.Server{Handler: http.HandlerFunc(srv.serveWebsocket)}
+	go func() {
+		err := srv.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			srv.Log.Errorf("HTTP server stopped: %v", err)
+		}
+	}()
+	return srv, nil
+}
+
+// Close disconnects all clients and stops the server.
+func (srv *Server) Close() error {
+	srv.cancel()
+	srv.lock.Lock()
+	conns := make([]*Conn, 0, len(srv.conns))
+	for conn := range srv.conns {
+		conns = append(conns, conn)
+	}
+	srv.lock.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	srv.media.httpServer.Close()
+	return srv.httpServer.Close()
+}
+
+// Addr returns the local address that the server is listening on.
+func (srv *Server) Addr() string {
+	return srv.listener.Addr().String()
+}
+
+// CertRootKey returns the public key that the server's noise certificate chain is signed with.
+//
+// Clients must have this set as whatsmeow.Client.CertRootKey to accept the server's handshake.
+func (srv *Server) CertRootKey() *[32]byte {
+	key := *srv.certRoot.Pub
+	return &key
+}
+
+// URL returns the websocket URL of this server.
+func (srv *Server) URL() string {
+	return "ws://" + srv.Addr() + "/ws/chat"
+}
+
+// Configure points the given client (including media uploads and downloads) at this server.
+func (srv *Server) Configure(cli *whatsmeow.Client) {
+	cli.CertRootKey = srv.CertRootKey()
+	cli.WebsocketConfig = &whatsmeow.WebsocketConfig{URL: srv.URL()}
+	cli.SetMediaHTTPClient(srv.media.httpClient)
+}
+
+// NewClient creates a new client with an in-memory device store and configures it to connect to this server.
+func (srv *Server) NewClient(log waLog.Logger) *whatsmeow.Client {
+	cli := whatsmeow.NewClient(memstore.New(log).NewDevice(), log)
+	srv.Configure(cli)
+	return cli
+}
+
+func (srv *Server) makeCertChain() ([]byte, error) {
+	intermediate := keys.NewKeyPair()
+	now := time.Now()
+	intermediateDetails, err := proto.Marshal(&waCert.CertChain_NoiseCertificate_Details{
+		Serial:       proto.Uint32(1),
+		IssuerSerial: proto.Uint32(whatsmeow.WACertIssuerSerial),
+		Key:          intermediate.Pub[:],
+		NotBefore:    proto.Uint64(uint64(now.Add(-24 * time.Hour).Unix())),
+		NotAfter:     proto.Uint64(uint64(now.Add(365 * 24 * time.Hour).Unix())),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal intermediate cert details: %w", err)
+	}
+	leafDetails, err := proto.Marshal(&waCert.CertChain_NoiseCertificate_Details{
+		Serial:       proto.Uint32(2),
+		IssuerSerial: proto.Uint32(1),
+		Key:          srv.staticKey.Pub[:],
+		NotBefore:    proto.Uint64(uint64(now.Add(-24 * time.Hour).Unix())),
+		NotAfter:     proto.Uint64(uint64(now.Add(30 * 24 * time.Hour).Unix())),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal leaf cert details: %w", err)
+	}
+	intermediateSig := ecc.CalculateSignature(ecc.NewDjbECPrivateKey(*srv.certRoot.Priv), intermediateDetails)
+	leafSig := ecc.CalculateSignature(ecc.NewDjbECPrivateKey(*intermediate.Priv), leafDetails)
+	chain, err := proto.Marshal(&waCert.CertChain{
+		Leaf: &waCert.CertChain_NoiseCertificate{
+			Details:   leafDetails,
+			Signature: leafSig[:],
+		},
+		Intermediate: &waCert.CertChain_NoiseCertificate{
+			Details:   intermediateDetails,
+			Signature: intermediateSig[:],
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cert chain: %w", err)
+	}
+	return chain, nil
+}
+
+func (srv *Server) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		srv.Log.Warnf("Failed to accept websocket: %v", err)
+		return
+	}
+	ws.SetReadLimit(1 << 24)
+	conn := newConn(srv, ws)
+	srv.lock.Lock()
+	srv.conns[conn] = struct{}{}
+	srv.lock.Unlock()
+	conn.serve()
+	srv.lock.Lock()
+	delete(srv.conns, conn)
+	for ref, refConn := range srv.pairingRefs {
+		if refConn == conn {
+			delete(srv.pairingRefs, ref)
+		}
+	}
+	if conn.device != nil && conn.device.conn == conn {
+		conn.device.conn = nil
+	}
+	srv.lock.Unlock()
+}
+
+func (srv *Server) generateID() string {
+	return "srv-" + strconv.FormatUint(srv.idCounter.Add(1), 10)
+}
+
+func (srv *Server) generateMessageID() types.MessageID {
+	return "3EB0" + fmt.Sprintf("%X", random.Bytes(9))
+}
+
+// HandleIQ registers a handler for IQs with the given namespace.
+//
+// Custom handlers take priority over the built-in ones. If the handler returns nil,
+// the IQ is passed on to the built-in handler. Passing a nil handler removes the custom handler.
+func (srv *Server) HandleIQ(xmlns string, handler IQHandler) {
+	srv.iqHandlersLock.Lock()
+	if handler == nil {
+		delete(srv.iqHandlers, xmlns)
+	} else {
+		srv.iqHandlers[xmlns] = handler
+	}
+	srv.iqHandlersLock.Unlock()
+}
+
+// Push sends the given node to a device.
+//
+// If the JID doesn't have a device part, the node is sent to all devices of the user
+// (use Device.Push to target only the primary device).
+// Nodes sent to disconnected devices are queued and delivered as offline events when they reconnect.
+func (srv *Server) Push(jid types.JID, node waBinary.Node) error {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	var targets []*deviceRecord
+	if jid.Device == 0 {
+		acc := srv.getAccount(jid)
+		if acc == nil {
+			return fmt.Errorf("%w %s", ErrUnknownUser, jid)
+		}
+		targets = acc.deviceList()
+	} else if dev := srv.getDevice(jid); dev != nil {
+		targets = []*deviceRecord{dev}
+	} else {
+		return fmt.Errorf("%w %s", ErrUnknownDevice, jid)
+	}
+	for _, dev := range targets {
+		dev.deliver(node)
+	}
+	return nil
+}
+
+// Disconnect abruptly closes the connection of the given device without sending any stream error.
+func (srv *Server) Disconnect(jid types.JID) {
+	srv.lock.Lock()
+	dev := srv.getDevice(jid)
+	var conn *Conn
+	if dev != nil {
+		conn = dev.conn
+	}
+	srv.lock.Unlock()
+	if conn != nil {
+		conn.Close()
+	}
+}
+
+// SendStreamError sends a stream error with the given code to the device and closes the connection.
+func (srv *Server) SendStreamError(jid types.JID, code string) {
+	srv.lock.Lock()
+	dev := srv.getDevice(jid)
+	var conn *Conn
+	if dev != nil {
+		conn = dev.conn
+	}
+	srv.lock.Unlock()
+	if conn != nil {
+		conn.sendStreamError(code, "")
+	}
+}
+
+// WaitConnected waits until the given device has an active logged-in connection to the server.
+func (srv *Server) WaitConnected(ctx context.Context, jid types.JID) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		srv.lock.Lock()
+		dev := srv.getDevice(jid)
+		connected := dev != nil && dev.conn != nil
+		srv.lock.Unlock()
+		if connected {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
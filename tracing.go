@@ -0,0 +1,87 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TraceAttribute is a key-value pair attached to a tracing span.
+type TraceAttribute struct {
+	Key   string
+	Value any
+}
+
+// Tracer creates tracing spans for the work done by the client. It can be set in Client.Tracer
+// to connect the client to a tracing system like OpenTelemetry.
+//
+// Spans are created for each phase of SendMessage (whatsmeow.send.*) and for receiving messages
+// (whatsmeow.decryptMessages, whatsmeow.decrypt, whatsmeow.bufferedDecrypt and whatsmeow.dispatchEvent).
+// The parent span is read from the context, so spans of SendMessage are children of whatever span
+// is in the context passed to SendMessage.
+type Tracer interface {
+	// Start starts a new span as a child of the span in ctx (if any) and returns a context containing the new span.
+	Start(ctx context.Context, name string, attrs ...TraceAttribute) (context.Context, Span)
+}
+
+// Span is a single traced operation created by a Tracer.
+type Span interface {
+	// SetAttributes adds attributes to the span.
+	SetAttributes(attrs ...TraceAttribute)
+	// End ends the span. If err is non-nil, the span should be marked as failed.
+	End(err error)
+}
+
+// NoopTracer is an implementation of Tracer that doesn't record anything. It's the default value of Client.Tracer.
+type NoopTracer struct{}
+
+var _ Tracer = NoopTracer{}
+
+func (NoopTracer) Start(ctx context.Context, _ string, _ ...TraceAttribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...TraceAttribute) {}
+func (noopSpan) End(error)                       {}
+
+var errEventHandlerFailed = errors.New("event handler failed")
+
+// getTracer returns Client.Tracer, or NoopTracer if it's nil.
+func (cli *Client) getTracer() Tracer {
+	if cli.Tracer == nil {
+		return NoopTracer{}
+	}
+	return cli.Tracer
+}
+
+// startSendPhase starts a span for a phase of sending a message. The returned function ends the span
+// and stores the duration of the phase in the given MessageDebugTimings field.
+func (cli *Client) startSendPhase(ctx context.Context, name string, timing *time.Duration) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := cli.getTracer().Start(ctx, "whatsmeow.send."+name)
+	return ctx, func(err error) {
+		*timing = time.Since(start)
+		span.End(err)
+	}
+}
+
+// dispatchEventCtx is dispatchEvent with a tracing span that's a child of the span in the given context.
+func (cli *Client) dispatchEventCtx(ctx context.Context, evt any) (handlerFailed bool) {
+	_, span := cli.getTracer().Start(ctx, "whatsmeow.dispatchEvent", TraceAttribute{Key: "event_type", Value: fmt.Sprintf("%T", evt)})
+	handlerFailed = cli.dispatchEvent(evt)
+	if handlerFailed {
+		span.End(errEventHandlerFailed)
+	} else {
+		span.End(nil)
+	}
+	return
+}
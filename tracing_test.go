@@ -0,0 +1,83 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+type recordingTracer struct {
+	lock  sync.Mutex
+	spans []string
+}
+
+type recordingSpan struct{}
+
+type parentSpanKey struct{}
+
+func (rt *recordingTracer) Start(ctx context.Context, name string, _ ...whatsmeow.TraceAttribute) (context.Context, whatsmeow.Span) {
+	if parent, ok := ctx.Value(parentSpanKey{}).(string); ok {
+		name = parent + " > " + name
+	}
+	rt.lock.Lock()
+	rt.spans = append(rt.spans, name)
+	rt.lock.Unlock()
+	return context.WithValue(ctx, parentSpanKey{}, name), recordingSpan{}
+}
+
+func (recordingSpan) SetAttributes(...whatsmeow.TraceAttribute) {}
+func (recordingSpan) End(error)                                 {}
+
+func TestTracing(t *testing.T) {
+	ctx, _, alice, bob, cli := newPairedTestClients(t)
+	tracer := &recordingTracer{}
+	cli.Tracer = tracer
+
+	received := make(chan struct{}, 1)
+	cli.AddEventHandler(func(evt any) {
+		if _, ok := evt.(*events.Message); ok {
+			received <- struct{}{}
+		}
+	})
+	_, err := cli.SendMessage(context.WithValue(ctx, parentSpanKey{}, "caller"), bob.PN, &waE2E.Message{Conversation: proto.String("hello bob")})
+	if err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	_, err = bob.Phone.SendMessage(ctx, alice.PN, &waE2E.Message{Conversation: proto.String("hello alice")})
+	if err != nil {
+		t.Fatalf("Failed to send message from simulated device: %v", err)
+	}
+	select {
+	case <-received:
+	case <-ctx.Done():
+		t.Fatalf("Client didn't receive message: %v", ctx.Err())
+	}
+
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	for _, expected := range []string{
+		"caller > whatsmeow.SendMessage > whatsmeow.send.get_devices",
+		"caller > whatsmeow.SendMessage > whatsmeow.send.peer_encrypt",
+		"caller > whatsmeow.SendMessage > whatsmeow.send.send",
+		"caller > whatsmeow.SendMessage > whatsmeow.send.resp",
+		"whatsmeow.decryptMessages > whatsmeow.decrypt",
+		"whatsmeow.decryptMessages > whatsmeow.dispatchEvent",
+	} {
+		if !slices.Contains(tracer.spans, expected) {
+			t.Errorf("Span %q not found in %v", expected, tracer.spans)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package types
+
+// ConnectionState is the state of the client's connection to WhatsApp.
+type ConnectionState string
+
+const (
+	// ConnectionStateDisconnected means the client isn't connected and isn't trying to reconnect.
+	ConnectionStateDisconnected ConnectionState = "disconnected"
+	// ConnectionStateConnecting means the websocket is being opened.
+	ConnectionStateConnecting ConnectionState = "connecting"
+	// ConnectionStateHandshaking means the websocket is open and the noise handshake is in progress.
+	ConnectionStateHandshaking ConnectionState = "handshaking"
+	// ConnectionStateAuthenticating means the handshake is done and the client is waiting for the server
+	// to accept the login (or for the QR code to be scanned if the client isn't paired yet).
+	ConnectionStateAuthenticating ConnectionState = "authenticating"
+	// ConnectionStateSyncingOffline means the client is logged in and receiving messages that arrived while it was offline.
+	ConnectionStateSyncingOffline ConnectionState = "syncing_offline"
+	// ConnectionStateOnline means the client is logged in and has received all offline messages.
+	ConnectionStateOnline ConnectionState = "online"
+	// ConnectionStateBackingOff means the client was disconnected and is waiting before reconnecting automatically.
+	ConnectionStateBackingOff ConnectionState = "backing_off"
+	// ConnectionStateLoggedOut means the device was logged out and must be paired again.
+	ConnectionStateLoggedOut ConnectionState = "logged_out"
+)
@@ -241,6 +241,63 @@ type StreamError struct {
 // Disconnected is emitted when the websocket is closed by the server.
 type Disconnected struct{}
 
+// SessionLeaseLost is emitted when the client fails to renew its session lease (see whatsmeow.SessionLeaseConfig),
+// usually because another process took over the device after the lease expired. The client is disconnected
+// before this event is emitted and won't reconnect automatically.
+type SessionLeaseLost struct {
+	Error error
+}
+
+// OutboxState is the delivery state of a message queued with Client.EnqueueMessage.
+type OutboxState string
+
+const (
+	OutboxStateQueued   OutboxState = "queued"
+	OutboxStateSending  OutboxState = "sending"
+	OutboxStateRetrying OutboxState = "retrying"
+	OutboxStateSent     OutboxState = "sent"
+	OutboxStateFailed   OutboxState = "failed"
+)
+
+// OutboxStatus is emitted when the delivery state of a message in the outbox changes (see Client.EnqueueMessage).
+type OutboxStatus struct {
+	Chat  types.JID
+	ID    types.MessageID
+	State OutboxState
+	// Attempt is the number of send attempts made so far, including the current one.
+	Attempt int
+	// Error is the error from the last attempt if State is retrying or failed.
+	Error error
+	// Timestamp is the server timestamp of the message if State is sent.
+	Timestamp time.Time
+}
+
+// ConnectionStateChange is emitted whenever the state of the connection changes (see Client.ConnectionState).
+//
+// Events are emitted in order, but asynchronously, so the state may have already changed again
+// by the time the event is handled.
+type ConnectionStateChange struct {
+	Previous types.ConnectionState
+	State    types.ConnectionState
+	// Reason is a human-readable description of what caused the transition.
+	Reason string
+}
+
+// ReconnectScheduled is emitted when the client has scheduled an automatic reconnection attempt.
+type ReconnectScheduled struct {
+	// Attempt is the number of the scheduled attempt, starting from 1.
+	Attempt int
+	// Delay is how long the client will wait before the attempt.
+	Delay time.Duration
+	// Cause is the reason for reconnecting (see the whatsmeow.ReconnectCause constants).
+	Cause string
+	// LastError is the error from the previous failed attempt, if any.
+	LastError error
+	// CircuitOpen is true if the delay was extended because the circuit breaker tripped
+	// after repeated connect failures or stream errors.
+	CircuitOpen bool
+}
+
 // HistorySync is emitted when the phone has sent a blob of historical messages.
 type HistorySync struct {
 	Data *waHistorySync.HistorySync
@@ -495,6 +552,23 @@ type GroupInfo struct {
 	UnknownChanges []*waBinary.Node
 }
 
+// GroupMembershipDiff is emitted when the participants of a group don't match the stored snapshot
+// (see whatsmeow.Client.EnableGroupSnapshots), usually because notifications were missed while offline.
+//
+// Participant changes received as GroupInfo events are applied to the snapshot, so they won't be emitted again here.
+type GroupMembershipDiff struct {
+	JID types.JID
+	// Old is the stored snapshot of the group. Fields other than participants may be outdated.
+	Old *types.GroupInfo
+	// New is the current info of the group, or nil if the user is no longer a participant.
+	New *types.GroupInfo
+
+	Join    []types.JID // Users who joined or were added while the snapshot was outdated
+	Leave   []types.JID // Users who left or were removed while the snapshot was outdated
+	Promote []types.JID // Users who were promoted to admins
+	Demote  []types.JID // Users who were demoted to normal users
+}
+
 // Picture is emitted when a user's profile picture or group's photo is changed.
 //
 // You can use Client.GetProfilePictureInfo to get the actual image URL after this event.
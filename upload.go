@@ -13,13 +13,19 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"time"
 
 	"go.mau.fi/util/random"
+	"go.mau.fi/util/retryafter"
 
 	"go.mau.fi/whatsmeow/socket"
 	"go.mau.fi/whatsmeow/util/cbcutil"
@@ -38,6 +44,17 @@ type UploadResponse struct {
 	FileLength    uint64 `json:"-"`
 }
 
+// UploadRequestExtra contains optional parameters for Client.Upload and Client.UploadReader.
+type UploadRequestExtra struct {
+	// Resumable enables the media server's resume protocol. If the connection is interrupted,
+	// the server is asked how much of the file it already received, and the upload continues from there
+	// instead of starting over. Hosts are only switched if the upload keeps failing on the same host.
+	Resumable bool
+	// Progress is called whenever more data is sent to the server. The sent count includes data
+	// that was uploaded before resuming, and it may go backwards if the upload has to be restarted on another host.
+	Progress func(sent, total int64)
+}
+
 // Upload uploads the given attachment to WhatsApp servers.
 //
 // You should copy the fields in the response to the corresponding fields in a protobuf message.
@@ -65,12 +82,24 @@ type UploadResponse struct {
 //	// handle error again
 //
 // The same applies to the other message types like DocumentMessage, just replace the struct type and Message field name.
-func (cli *Client) Upload(ctx context.Context, plaintext []byte, appInfo MediaType) (resp UploadResponse, err error) {
+//
+// The upload is retried on the other media hosts if one of them fails. Additional options like
+// progress reporting and resumable uploads can be enabled with UploadRequestExtra.
+//
+// If Client.MediaCache is set and the same file has been uploaded with the same media type before,
+// the previous upload is returned instead of uploading again, as long as the media server still has the file.
+func (cli *Client) Upload(ctx context.Context, plaintext []byte, appInfo MediaType, extra ...UploadRequestExtra) (resp UploadResponse, err error) {
+	if len(extra) > 1 {
+		err = errors.New("only one extra parameter may be provided to Upload")
+		return
+	}
 	resp.FileLength = uint64(len(plaintext))
-	resp.MediaKey = random.Bytes(32)
-
 	plaintextSHA256 := sha256.Sum256(plaintext)
 	resp.FileSHA256 = plaintextSHA256[:]
+	if cached := cli.getCachedUpload(ctx, resp.FileSHA256, resp.FileLength, appInfo); cached != nil {
+		return *cached, nil
+	}
+	resp.MediaKey = random.Bytes(32)
 
 	iv, cipherKey, macKey, _ := getMediaKeys(resp.MediaKey, appInfo)
 
@@ -89,10 +118,21 @@ func (cli *Client) Upload(ctx context.Context, plaintext []byte, appInfo MediaTy
 	dataHash := sha256.Sum256(dataToUpload)
 	resp.FileEncSHA256 = dataHash[:]
 
-	err = cli.rawUpload(ctx, bytes.NewReader(dataToUpload), uint64(len(dataToUpload)), resp.FileEncSHA256, appInfo, false, &resp)
+	err = cli.rawUploadWithExtra(ctx, bytes.NewReader(dataToUpload), uint64(len(dataToUpload)), resp.FileEncSHA256, appInfo, false, &resp, getUploadRequestExtra(extra))
+	if err == nil {
+		cli.putCachedUpload(ctx, appInfo, &resp)
+		cli.putCachedMedia(ctx, resp.FileSHA256, resp.FileEncSHA256, plaintext)
+	}
 	return
 }
 
+func getUploadRequestExtra(extra []UploadRequestExtra) UploadRequestExtra {
+	if len(extra) == 0 {
+		return UploadRequestExtra{}
+	}
+	return extra[0]
+}
+
 // UploadReader uploads the given attachment to WhatsApp servers.
 //
 // This is otherwise identical to [Upload], but it reads the plaintext from an [io.Reader] instead of a byte slice.
@@ -100,7 +140,14 @@ func (cli *Client) Upload(ctx context.Context, plaintext []byte, appInfo MediaTy
 // and deleted after the upload.
 //
 // To use only one file, pass the same file as both plaintext and tempFile. This will cause the file to be overwritten with encrypted data.
-func (cli *Client) UploadReader(ctx context.Context, plaintext io.Reader, tempFile io.ReadWriteSeeker, appInfo MediaType) (resp UploadResponse, err error) {
+//
+// If Client.MediaCache is set, previous uploads are reused like in [Upload], but unlike [Upload], the plaintext
+// is not stored in the cache for later downloads, as it's never fully held in memory.
+func (cli *Client) UploadReader(ctx context.Context, plaintext io.Reader, tempFile io.ReadWriteSeeker, appInfo MediaType, extra ...UploadRequestExtra) (resp UploadResponse, err error) {
+	if len(extra) > 1 {
+		err = errors.New("only one extra parameter may be provided to UploadReader")
+		return
+	}
 	resp.MediaKey = random.Bytes(32)
 	iv, cipherKey, macKey, _ := getMediaKeys(resp.MediaKey, appInfo)
 	if tempFile == nil {
@@ -121,12 +168,19 @@ func (cli *Client) UploadReader(ctx context.Context, plaintext io.Reader, tempFi
 		err = fmt.Errorf("failed to encrypt file: %w", err)
 		return
 	}
+	// The plaintext hash is only known after reading the whole file, but the encrypted data can still be discarded
+	if cached := cli.getCachedUpload(ctx, resp.FileSHA256, resp.FileLength, appInfo); cached != nil {
+		return *cached, nil
+	}
 	_, err = tempFile.Seek(0, io.SeekStart)
 	if err != nil {
 		err = fmt.Errorf("failed to seek to start of temporary file: %w", err)
 		return
 	}
-	err = cli.rawUpload(ctx, tempFile, uploadSize, resp.FileEncSHA256, appInfo, false, &resp)
+	err = cli.rawUploadWithExtra(ctx, tempFile, uploadSize, resp.FileEncSHA256, appInfo, false, &resp, getUploadRequestExtra(extra))
+	if err == nil {
+		cli.putCachedUpload(ctx, appInfo, &resp)
+	}
 	return
 }
 
@@ -189,9 +243,24 @@ func (cli *Client) UploadNewsletterReader(ctx context.Context, data io.ReadSeeke
 }
 
 func (cli *Client) rawUpload(ctx context.Context, dataToUpload io.Reader, uploadSize uint64, fileHash []byte, appInfo MediaType, newsletter bool, resp *UploadResponse) error {
+	return cli.rawUploadWithExtra(ctx, dataToUpload, uploadSize, fileHash, appInfo, newsletter, resp, UploadRequestExtra{})
+}
+
+func (cli *Client) rawUploadWithExtra(
+	ctx context.Context,
+	dataToUpload io.Reader,
+	uploadSize uint64,
+	fileHash []byte,
+	appInfo MediaType,
+	newsletter bool,
+	resp *UploadResponse,
+	extra UploadRequestExtra,
+) error {
 	mediaConn, err := cli.refreshMediaConn(ctx, false)
 	if err != nil {
 		return fmt.Errorf("failed to refresh media connections: %w", err)
+	} else if len(mediaConn.Hosts) == 0 {
+		return fmt.Errorf("no media hosts available")
 	}
 
 	token := base64.URLEncoding.EncodeToString(fileHash)
@@ -212,27 +281,159 @@ func (cli *Client) rawUpload(ctx context.Context, dataToUpload io.Reader, upload
 		mmsType = fmt.Sprintf("newsletter-%s", mmsType)
 		uploadPrefix = "newsletter"
 	}
-	var host string
+	hosts := slices.Clone(mediaConn.Hosts)
 	// Hacky hack to prefer last option (rupload.facebook.com) for messenger uploads.
 	// For some reason, the primary host doesn't work, even though it has the <upload/> tag.
 	if cli.MessengerConfig != nil {
-		host = mediaConn.Hosts[len(mediaConn.Hosts)-1].Hostname
-	} else {
-		host = mediaConn.Hosts[0].Hostname
+		slices.Reverse(hosts)
+	}
+	seeker, canSeek := dataToUpload.(io.ReadSeeker)
+	if !canSeek {
+		// Retrying requires rewinding the data, so only try one host if the data can't be seeked
+		hosts = hosts[:1]
+		extra.Resumable = false
+	}
+	for i, host := range hosts {
+		uploadURL := url.URL{
+			Scheme:   "https",
+			Host:     host.Hostname,
+			Path:     fmt.Sprintf("/%s/%s/%s", uploadPrefix, mmsType, token),
+			RawQuery: q.Encode(),
+		}
+		if extra.Resumable {
+			err = cli.resumableUpload(ctx, uploadURL, seeker, int64(uploadSize), extra.Progress, resp)
+		} else {
+			if i > 0 {
+				_, err = seeker.Seek(0, io.SeekStart)
+				if err != nil {
+					err = fmt.Errorf("failed to seek to start of data: %w", err)
+					break
+				}
+			}
+			err = cli.doUploadRequest(ctx, uploadURL, dataToUpload, 0, int64(uploadSize), extra.Progress, resp)
+		}
+		if err == nil || !shouldRetryMediaUpload(err) {
+			break
+		} else if i < len(hosts)-1 {
+			cli.Log.Warnf("Failed to upload media to %s: %v, trying with next host...", host.Hostname, err)
+		}
+	}
+	cli.getMetrics().MediaTransfer(MediaTransferUpload, int64(uploadSize), err)
+	return err
+}
+
+const maxResumableUploadAttempts = 5
+
+// resumableUpload uploads the given data to one host, resuming from the offset reported by the server
+// if the connection is interrupted.
+func (cli *Client) resumableUpload(ctx context.Context, uploadURL url.URL, data io.ReadSeeker, uploadSize int64, progress func(sent, total int64), resp *UploadResponse) (err error) {
+	for attempt := 0; attempt < maxResumableUploadAttempts; attempt++ {
+		if attempt > 0 {
+			retryDuration := time.Duration(attempt-1) * time.Second
+			cli.Log.Warnf("Failed to upload media to %s: %v, resuming in %s...", uploadURL.Host, err, retryDuration)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryDuration):
+			}
+		}
+		var offset int64
+		var complete bool
+		offset, complete, err = cli.checkUploadResume(ctx, uploadURL, resp)
+		if err == nil && complete {
+			if progress != nil {
+				progress(uploadSize, uploadSize)
+			}
+			return nil
+		} else if err == nil && (offset < 0 || offset > uploadSize) {
+			err = fmt.Errorf("server returned invalid resume offset %d", offset)
+			offset = 0
+		}
+		if err != nil {
+			if !shouldRetryMediaUpload(err) {
+				return err
+			}
+			continue
+		} else if offset > 0 {
+			cli.Log.Debugf("Resuming upload to %s from byte %d/%d", uploadURL.Host, offset, uploadSize)
+		}
+		_, err = data.Seek(offset, io.SeekStart)
+		if err != nil {
+			return fmt.Errorf("failed to seek to resume offset: %w", err)
+		}
+		err = cli.doUploadRequest(ctx, uploadURL, io.LimitReader(data, uploadSize-offset), offset, uploadSize, progress, resp)
+		if err == nil || !shouldRetryMediaUpload(err) {
+			return err
+		}
+	}
+	return err
+}
+
+type uploadResumeResponse struct {
+	UploadResponse
+	Resume any `json:"resume"`
+}
+
+// checkUploadResume asks the media server how much of the file it already has.
+func (cli *Client) checkUploadResume(ctx context.Context, uploadURL url.URL, resp *UploadResponse) (offset int64, complete bool, err error) {
+	q := uploadURL.Query()
+	q.Set("resume", "1")
+	uploadURL.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL.String(), nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to prepare request: %w", err)
+	}
+	req.Header.Set("Origin", socket.Origin)
+	req.Header.Set("Referer", socket.Origin+"/")
+	httpResp, err := cli.mediaHTTP.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to check upload resume state: %w", err)
 	}
-	uploadURL := url.URL{
-		Scheme:   "https",
-		Host:     host,
-		Path:     fmt.Sprintf("/%s/%s/%s", uploadPrefix, mmsType, token),
-		RawQuery: q.Encode(),
+	defer httpResp.Body.Close()
+	var resumeResp uploadResumeResponse
+	if httpResp.StatusCode != http.StatusOK {
+		return 0, false, UploadHTTPError{Response: httpResp}
+	} else if err = json.NewDecoder(httpResp.Body).Decode(&resumeResp); err != nil {
+		return 0, false, fmt.Errorf("failed to parse upload resume response: %w", err)
 	}
+	switch resume := resumeResp.Resume.(type) {
+	case string:
+		if resume == "complete" {
+			resp.URL = resumeResp.URL
+			resp.DirectPath = resumeResp.DirectPath
+			resp.Handle = resumeResp.Handle
+			resp.ObjectID = resumeResp.ObjectID
+			return 0, true, nil
+		}
+		offset, err = strconv.ParseInt(resume, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("unexpected upload resume state %q", resume)
+		}
+		return offset, false, nil
+	case float64:
+		return int64(resume), false, nil
+	case nil:
+		return 0, false, nil
+	default:
+		return 0, false, fmt.Errorf("unexpected upload resume state %v", resume)
+	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL.String(), dataToUpload)
+func (cli *Client) doUploadRequest(ctx context.Context, uploadURL url.URL, body io.Reader, offset, uploadSize int64, progress func(sent, total int64), resp *UploadResponse) error {
+	if offset > 0 {
+		q := uploadURL.Query()
+		q.Set("file_offset", strconv.FormatInt(offset, 10))
+		uploadURL.RawQuery = q.Encode()
+	}
+	if progress != nil {
+		body = &uploadProgressReader{Reader: body, sent: offset, total: uploadSize, progress: progress}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL.String(), body)
 	if err != nil {
 		return fmt.Errorf("failed to prepare request: %w", err)
 	}
 
-	req.ContentLength = int64(uploadSize)
+	req.ContentLength = uploadSize - offset
 	req.Header.Set("Origin", socket.Origin)
 	req.Header.Set("Referer", socket.Origin+"/")
 
@@ -240,7 +441,7 @@ func (cli *Client) rawUpload(ctx context.Context, dataToUpload io.Reader, upload
 	if err != nil {
 		err = fmt.Errorf("failed to execute request: %w", err)
 	} else if httpResp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("upload failed with status code %d", httpResp.StatusCode)
+		err = UploadHTTPError{Response: httpResp}
 	} else if err = json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
 		err = fmt.Errorf("failed to parse upload response: %w", err)
 	}
@@ -249,3 +450,30 @@ func (cli *Client) rawUpload(ctx context.Context, dataToUpload io.Reader, upload
 	}
 	return err
 }
+
+func shouldRetryMediaUpload(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	var httpErr UploadHTTPError
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &httpErr) && retryafter.Should(httpErr.StatusCode, true))
+}
+
+type uploadProgressReader struct {
+	io.Reader
+	sent     int64
+	total    int64
+	progress func(sent, total int64)
+}
+
+func (upr *uploadProgressReader) Read(p []byte) (n int, err error) {
+	n, err = upr.Reader.Read(p)
+	if n > 0 {
+		upr.sent += int64(n)
+		upr.progress(upr.sent, upr.total)
+	}
+	return
+}
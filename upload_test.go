@@ -0,0 +1,58 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func TestResumableUpload(t *testing.T) {
+	ctx, srv, _, _, cli := newPairedTestClients(t)
+
+	data := bytes.Repeat([]byte("meow"), 1<<18)
+	var lastSent, total int64
+	srv.InterruptNextUpload(300_000)
+	resp, err := cli.Upload(ctx, data, whatsmeow.MediaDocument, whatsmeow.UploadRequestExtra{
+		Resumable: true,
+		Progress: func(sent, size int64) {
+			lastSent, total = sent, size
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	uploaded, ok := srv.GetMedia(resp.DirectPath)
+	if !ok || int64(len(uploaded)) != total {
+		t.Fatalf("Server didn't receive the whole file (%d/%d bytes)", len(uploaded), total)
+	} else if received := srv.MediaBytesReceived(); received != total {
+		t.Errorf("Upload wasn't resumed: server received %d bytes for a %d byte file", received, total)
+	} else if lastSent != total {
+		t.Errorf("Progress callback reported %d/%d bytes at the end", lastSent, total)
+	}
+
+	hosts := srv.MediaHosts()
+	srv.SetMediaHostDown(hosts[0], true)
+	resp, err = cli.Upload(ctx, []byte("hello"), whatsmeow.MediaDocument)
+	if err != nil {
+		t.Fatalf("Failed to upload with primary host down: %v", err)
+	} else if _, ok = srv.GetMedia(resp.DirectPath); !ok || !strings.Contains(resp.URL, hosts[1]) {
+		t.Errorf("Upload didn't fail over to %s (got URL %s)", hosts[1], resp.URL)
+	}
+
+	canceledCtx, cancelUpload := context.WithCancel(ctx)
+	cancelUpload()
+	_, err = cli.Upload(canceledCtx, data, whatsmeow.MediaDocument, whatsmeow.UploadRequestExtra{Resumable: true})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected canceled upload to fail with context.Canceled, got %v", err)
+	}
+}
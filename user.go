@@ -449,12 +449,17 @@ func (cli *Client) GetUserDevices(ctx context.Context, jids []types.JID) ([]type
 	if cli == nil {
 		return nil, ErrClientIsNil
 	}
+	var toPersist []*store.CachedDevices
 	cli.userDevicesCacheLock.Lock()
-	defer cli.userDevicesCacheLock.Unlock()
+	defer func() {
+		cli.userDevicesCacheLock.Unlock()
+		cli.persistCachedDevices(ctx, toPersist)
+	}()
 
+	cachedDevices := cli.getManyCachedDevices(ctx, jids)
 	var devices, jidsToSync, fbJIDsToSync []types.JID
 	for _, jid := range jids {
-		cached, ok := cli.userDevicesCache[jid]
+		cached, ok := cachedDevices[jid]
 		if ok && len(cached.devices) > 0 {
 			devices = append(devices, cached.devices...)
 		} else if jid.Server == types.MessengerServer {
@@ -480,13 +485,16 @@ func (cli *Client) GetUserDevices(ctx context.Context, jids []types.JID) ([]type
 				continue
 			}
 			userDevices := parseDeviceList(jid, user.GetChildByTag("devices"))
-			cli.userDevicesCache[jid] = deviceCache{devices: userDevices, dhash: participantListHashV2(userDevices)}
+			if cached := cli.cacheDevices(jid, deviceCache{devices: userDevices, dhash: participantListHashV2(userDevices)}); cached != nil {
+				toPersist = append(toPersist, cached)
+			}
 			devices = append(devices, userDevices...)
 		}
 	}
 
 	if len(fbJIDsToSync) > 0 {
-		userDevices, err := cli.getFBIDDevices(ctx, fbJIDsToSync)
+		userDevices, fbToPersist, err := cli.getFBIDDevices(ctx, fbJIDsToSync)
+		toPersist = append(toPersist, fbToPersist...)
 		if err != nil {
 			return nil, err
 		}
@@ -809,12 +817,15 @@ func (cli *Client) getFBIDDevicesInternal(ctx context.Context, jids []types.JID)
 	}
 }
 
-func (cli *Client) getFBIDDevices(ctx context.Context, jids []types.JID) ([]types.JID, error) {
+// getFBIDDevices fetches the device lists of the given Messenger users and saves them in the in-memory cache.
+// The returned cache entries should be persisted with persistCachedDevices after releasing userDevicesCacheLock.
+func (cli *Client) getFBIDDevices(ctx context.Context, jids []types.JID) ([]types.JID, []*store.CachedDevices, error) {
 	var devices []types.JID
+	var toPersist []*store.CachedDevices
 	for chunk := range slices.Chunk(jids, 15) {
 		list, err := cli.getFBIDDevicesInternal(ctx, chunk)
 		if err != nil {
-			return nil, err
+			return nil, toPersist, err
 		}
 		for _, user := range list.GetChildren() {
 			jid, jidOK := user.Attrs["jid"].(types.JID)
@@ -822,11 +833,13 @@ func (cli *Client) getFBIDDevices(ctx context.Context, jids []types.JID) ([]type
 				continue
 			}
 			userDevices := parseFBDeviceList(jid, user.GetChildByTag("devices"))
-			cli.userDevicesCache[jid] = userDevices
+			if cached := cli.cacheDevices(jid, userDevices); cached != nil {
+				toPersist = append(toPersist, cached)
+			}
 			devices = append(devices, userDevices.devices...)
 		}
 	}
-	return devices, nil
+	return devices, toPersist, nil
 }
 
 type UsyncQueryExtras struct {
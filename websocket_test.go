@@ -0,0 +1,77 @@
+// Copyright (c) 2025 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+)
+
+func TestWebsocketConfig(t *testing.T) {
+	var lock sync.Mutex
+	var requests []*http.Request
+	var newConns int
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, r)
+		lock.Unlock()
+		http.Error(w, "no websockets here", http.StatusForbidden)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			lock.Lock()
+			newConns++
+			lock.Unlock()
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	certPool := x509.NewCertPool()
+	certPool.AddCert(srv.Certificate())
+
+	cli := whatsmeow.NewClient(store.NoopDevice, nil)
+	cli.WebsocketConfig = &whatsmeow.WebsocketConfig{
+		URL:       "wss://" + strings.TrimPrefix(srv.URL, "https://") + "/ws/chat",
+		Origin:    "https://example.com",
+		Header:    http.Header{"X-Test": {"meow"}},
+		TLSConfig: &tls.Config{RootCAs: certPool},
+	}
+	for range 2 {
+		err := cli.Connect()
+		if err == nil {
+			t.Fatal("Connect succeeded without a websocket server")
+		} else if strings.Contains(err.Error(), "certificate") {
+			t.Fatalf("TLS config wasn't applied: %v", err)
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 websocket handshake requests, got %d", len(requests))
+	}
+	for _, req := range requests {
+		if req.URL.Path != "/ws/chat" {
+			t.Errorf("Unexpected path %s", req.URL.Path)
+		} else if origin := req.Header.Get("Origin"); origin != "https://example.com" {
+			t.Errorf("Expected custom origin, got %q", origin)
+		} else if req.Header.Get("X-Test") != "meow" {
+			t.Error("Custom header wasn't sent")
+		}
+	}
+	if newConns != 1 {
+		t.Errorf("Expected reconnect to reuse pooled connection, got %d connections", newConns)
+	}
+}